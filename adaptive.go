@@ -0,0 +1,87 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// GridRecommendation is the result of RecommendGrid.
+type GridRecommendation struct {
+	// GridSize is the smallest 2^n+1 grid that reproduces the terrain
+	// within the requested error when interpolated bilinearly.
+	GridSize int
+	// MaxError is the error budget left for meshing at GridSize.
+	MaxError float64
+	// Roughness is the standard deviation of the terrain heights.
+	Roughness float64
+}
+
+// RecommendGrid analyses a terrain tile and picks the coarsest grid that
+// still represents it within maxError, so that flat tiles are meshed on a
+// small grid while rugged ones keep their full resolution. Use Decimate to
+// reduce the terrain to the recommended size; meshing the result at the
+// recommended MaxError keeps the total error within maxError.
+func RecommendGrid(terrain []float64, gridSize int, maxError float64) (GridRecommendation, error) {
+	if !validGridSize(gridSize) || len(terrain) != gridSize*gridSize {
+		return GridRecommendation{}, errors.New("Expected terrain data of length gridSize*gridSize with gridSize 2^n+1")
+	}
+	sum, sum2 := 0.0, 0.0
+	for _, h := range terrain {
+		sum += h
+		sum2 += h * h
+	}
+	n := float64(len(terrain))
+	rec := GridRecommendation{
+		GridSize:  gridSize,
+		MaxError:  maxError,
+		Roughness: math.Sqrt(math.Max(sum2/n-(sum/n)*(sum/n), 0)),
+	}
+	for step := 2; step < gridSize; step *= 2 {
+		e := decimationError(terrain, gridSize, step)
+		if e > maxError {
+			break
+		}
+		rec.GridSize = (gridSize-1)/step + 1
+		rec.MaxError = maxError - e
+	}
+	return rec, nil
+}
+
+// decimationError is the largest difference between the terrain and its
+// bilinear interpolation from every step-th sample.
+func decimationError(terrain []float64, gridSize, step int) float64 {
+	max := 0.0
+	for y := 0; y < gridSize; y++ {
+		y0 := y / step * step
+		y1 := minInt(y0+step, gridSize-1)
+		fy := float64(y-y0) / float64(step)
+		for x := 0; x < gridSize; x++ {
+			x0 := x / step * step
+			x1 := minInt(x0+step, gridSize-1)
+			fx := float64(x-x0) / float64(step)
+			top := terrain[y0*gridSize+x0]*(1-fx) + terrain[y0*gridSize+x1]*fx
+			bottom := terrain[y1*gridSize+x0]*(1-fx) + terrain[y1*gridSize+x1]*fx
+			max = math.Max(max, math.Abs(top*(1-fy)+bottom*fy-terrain[y*gridSize+x]))
+		}
+	}
+	return max
+}
+
+// Decimate keeps every sample of a terrain grid that falls on the smaller
+// 2^n+1 grid newGridSize, covering the same extent.
+func Decimate(terrain []float64, gridSize, newGridSize int) ([]float64, error) {
+	if len(terrain) != gridSize*gridSize {
+		return nil, errors.New("Expected terrain data of length gridSize*gridSize")
+	}
+	if !validGridSize(gridSize) || !validGridSize(newGridSize) || newGridSize > gridSize {
+		return nil, errors.New("Expected grid sizes 2^n+1 with newGridSize <= gridSize")
+	}
+	step := (gridSize - 1) / (newGridSize - 1)
+	out := make([]float64, newGridSize*newGridSize)
+	for y := 0; y < newGridSize; y++ {
+		for x := 0; x < newGridSize; x++ {
+			out[y*newGridSize+x] = terrain[y*step*gridSize+x*step]
+		}
+	}
+	return out, nil
+}
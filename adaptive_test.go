@@ -0,0 +1,39 @@
+package martini
+
+import "testing"
+
+func TestRecommendGrid(t *testing.T) {
+	flat := make([]float64, 257*257)
+	rec, err := RecommendGrid(flat, 257, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.GridSize != 2 || rec.MaxError != 1 || rec.Roughness != 0 {
+		t.Errorf("flat tile: got %+v", rec)
+	}
+
+	plane := testTerrain(257, func(x, y int) float64 { return float64(x) - 2*float64(y) })
+	if rec, _ := RecommendGrid(plane, 257, 0); rec.GridSize != 2 {
+		t.Errorf("plane: got grid size %d, want 2", rec.GridSize)
+	}
+
+	rugged := testTerrain(257, hills)
+	rec, _ = RecommendGrid(rugged, 257, 20)
+	if rec.GridSize <= 2 || rec.GridSize >= 257 || rec.MaxError < 0 || rec.MaxError > 20 {
+		t.Fatalf("rugged tile: got %+v", rec)
+	}
+	if step := 256 / (rec.GridSize - 1); decimationError(rugged, 257, step) > 20 || decimationError(rugged, 257, 2*step) <= 20 {
+		t.Errorf("grid size %d is not the coarsest within the error", rec.GridSize)
+	}
+
+	small, err := Decimate(rugged, 257, rec.GridSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small[len(small)-1] != rugged[len(rugged)-1] {
+		t.Error("expected decimation to keep the corners")
+	}
+	if _, err := Decimate(rugged, 257, 100); err == nil {
+		t.Error("expected error for an invalid grid size")
+	}
+}
@@ -0,0 +1,147 @@
+package martini
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errBusy = errors.New("Server busy, retry later")
+
+// admit waits for one of the MaxConcurrent mesh generation slots, at most
+// QueueTimeout, and returns the function releasing it.
+func (s *Server) admit(ctx context.Context) (func(), error) {
+	if s.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	s.slotsOnce.Do(func() { s.slots = make(chan struct{}, s.MaxConcurrent) })
+	release := func() { <-s.slots }
+	select {
+	case s.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if s.QueueTimeout <= 0 {
+		return nil, errBusy
+	}
+	timer := time.NewTimer(s.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, errBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// clientKey identifies the client of a request for rate limiting: its
+// X-API-Key header when that is one of APIKeys, or its IP address. Unknown
+// keys are ignored so that clients cannot pick fresh buckets at will.
+func (s *Server) clientKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" && s.APIKeys[key] {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimit charges n requests to the client of r. When the client is over
+// its RateLimit it answers 429 with a Retry-After header and returns false.
+func (s *Server) rateLimit(w http.ResponseWriter, r *http.Request, n int) bool {
+	if s.RateLimit <= 0 {
+		return true
+	}
+	ok, wait := s.limiter.allow(s.clientKey(r), float64(n), s.RateLimit, s.rateBurst(), time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+// rateBurst returns RateBurst or its default.
+func (s *Server) rateBurst() int {
+	if s.RateBurst > 0 {
+		return s.RateBurst
+	}
+	return maxInt(int(math.Ceil(s.RateLimit)), s.MaxBatchTiles)
+}
+
+// maxBuckets bounds the clients a rateLimiter remembers.
+const maxBuckets = 10000
+
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per client, least recently used first
+// in lru. Buckets that have refilled behave as new ones and are dropped,
+// as is the least recently used one beyond maxBuckets.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     list.List
+}
+
+// allow takes n tokens from the bucket of key, refilled at rate per second
+// up to burst, or reports how long until enough tokens are available.
+// Requests costing more than burst are always refused.
+func (l *rateLimiter) allow(key string, n, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	capacity := math.Max(float64(burst), 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*list.Element)
+	}
+	l.expire(rate, capacity, now)
+	e, ok := l.buckets[key]
+	if ok {
+		l.lru.MoveToBack(e)
+	} else {
+		if l.lru.Len() >= maxBuckets {
+			l.remove(l.lru.Front())
+		}
+		e = l.lru.PushBack(&tokenBucket{key: key, tokens: capacity, last: now})
+		l.buckets[key] = e
+	}
+	b := e.Value.(*tokenBucket)
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= n {
+		b.tokens -= n
+		return true, 0
+	}
+	wait := time.Duration((math.Min(n, capacity) - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// expire drops the least recently used buckets that have refilled by now.
+// A bucket is full at the latest capacity/rate after its last use, so the
+// scan stops at the first one used more recently than that.
+func (l *rateLimiter) expire(rate, capacity float64, now time.Time) {
+	ttl := time.Duration(capacity / rate * float64(time.Second))
+	for e := l.lru.Front(); e != nil; e = l.lru.Front() {
+		if now.Sub(e.Value.(*tokenBucket).last) < ttl {
+			return
+		}
+		l.remove(e)
+	}
+}
+
+func (l *rateLimiter) remove(e *list.Element) {
+	l.lru.Remove(e)
+	delete(l.buckets, e.Value.(*tokenBucket).key)
+}
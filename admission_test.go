@@ -0,0 +1,133 @@
+package martini
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var l rateLimiter
+	now := time.Unix(0, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", 1, 2, 3, now); !ok {
+			t.Fatalf("request %d refused within burst", i)
+		}
+	}
+	ok, wait := l.allow("a", 1, 2, 3, now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("got %v, %v; want refusal for 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("b", 1, 2, 3, now); !ok {
+		t.Fatal("other client refused")
+	}
+	if ok, _ := l.allow("a", 1, 2, 3, now.Add(wait)); !ok {
+		t.Fatal("refused after waiting")
+	}
+	if ok, _ := l.allow("c", 4, 2, 3, now); ok {
+		t.Fatal("allowed more than the burst")
+	}
+
+	// Refilled buckets expire, and the least recently used go first.
+	l.allow("d", 1, 2, 3, now.Add(10*time.Second))
+	if len(l.buckets) != 1 || l.lru.Len() != 1 {
+		t.Fatalf("expected only the new bucket, got %d", len(l.buckets))
+	}
+	for i := 0; i <= maxBuckets; i++ {
+		l.allow(strconv.Itoa(i), 1, 2, 3, now.Add(10*time.Second))
+	}
+	if _, ok := l.buckets["d"]; ok || len(l.buckets) != maxBuckets {
+		t.Fatalf("expected %d buckets without d, got %d", maxBuckets, len(l.buckets))
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	s.RateLimit, s.RateBurst = 1, 2
+	s.APIKeys = map[string]bool{"a": true, "b": true, "c": true}
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/1/0/0.json", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		if rec := get("a"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+	rec := get("a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("got status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := get("b"); rec.Code != http.StatusOK {
+		t.Fatalf("other key: status %d", rec.Code)
+	}
+	if rec := get(""); rec.Code != http.StatusOK {
+		t.Fatalf("no key: status %d", rec.Code)
+	}
+	// Unknown keys share the bucket of the IP address.
+	if rec := get("x"); rec.Code != http.StatusOK {
+		t.Fatalf("unknown key: status %d", rec.Code)
+	}
+	if rec := get("y"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second unknown key: status %d", rec.Code)
+	}
+
+	// Without a burst, a full batch fits.
+	s.RateBurst, s.MaxBatchTiles = 0, 4
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(`{"bbox": [-10, -10, 10, 10], "zoom": 1}`))
+	req.Header.Set("X-API-Key", "c")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("batch with the default burst: status %d", rec.Code)
+	}
+	if rec := get("c"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("after the batch: status %d", rec.Code)
+	}
+}
+
+func TestServerAdmission(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	source := TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		if id.Z == 1 {
+			entered <- struct{}{}
+			<-unblock
+		}
+		return testSource().Terrain(ctx, id, gridSize)
+	})
+	s, err := NewServer(source, 17, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxConcurrent, s.QueueTimeout = 1, 10*time.Millisecond
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	done := make(chan int)
+	go func() { done <- get("/1/0/0.json").Code }()
+	<-entered
+	rec := get("/2/0/0.json")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("got status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(unblock)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("blocked request: status %d", code)
+	}
+	if rec := get("/2/0/0.json"); rec.Code != http.StatusOK {
+		t.Fatalf("after release: status %d", rec.Code)
+	}
+}
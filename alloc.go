@@ -0,0 +1,55 @@
+package martini
+
+import "errors"
+
+// Allocator supplies the backing arrays for the large internal buffers:
+// Coords and Indices of a Martini and Errors of every Tile created from it.
+// Implementations may hand out arena, mmap or shared memory; returned
+// slices must have at least the requested length and need not be zeroed.
+type Allocator interface {
+	Uint16s(n int) []uint16
+	Float64s(n int) []float64
+}
+
+type heapAllocator struct{}
+
+func (heapAllocator) Uint16s(n int) []uint16 {
+	return make([]uint16, n)
+}
+
+func (heapAllocator) Float64s(n int) []float64 {
+	return make([]float64, n)
+}
+
+var errShortBuffer = errors.New("Allocator returned a short buffer")
+
+func (m *Martini) allocator() Allocator {
+	if m.alloc == nil {
+		return heapAllocator{}
+	}
+	return m.alloc
+}
+
+func allocUint16s(a Allocator, n int) ([]uint16, error) {
+	buf := a.Uint16s(n)
+	if len(buf) < n {
+		return nil, errShortBuffer
+	}
+	buf = buf[:n]
+	for i := range buf {
+		buf[i] = 0
+	}
+	return buf, nil
+}
+
+func allocFloat64s(a Allocator, n int) ([]float64, error) {
+	buf := a.Float64s(n)
+	if len(buf) < n {
+		return nil, errShortBuffer
+	}
+	buf = buf[:n]
+	for i := range buf {
+		buf[i] = 0
+	}
+	return buf, nil
+}
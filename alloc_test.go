@@ -0,0 +1,59 @@
+package martini
+
+import "testing"
+
+type arena struct {
+	u16 []uint16
+	f64 []float64
+}
+
+func (a *arena) Uint16s(n int) []uint16 {
+	buf := a.u16[:n]
+	a.u16 = a.u16[n:]
+	return buf
+}
+
+func (a *arena) Float64s(n int) []float64 {
+	buf := a.f64[:n]
+	a.f64 = a.f64[n:]
+	return buf
+}
+
+type shortAllocator struct{ heapAllocator }
+
+func (shortAllocator) Float64s(n int) []float64 {
+	return make([]float64, n-1)
+}
+
+func TestAllocator(t *testing.T) {
+	a := &arena{u16: make([]uint16, 17*17+2*16*16*2*4), f64: make([]float64, 17*17)}
+	for i := range a.f64 {
+		a.f64[i] = 1e9
+	}
+	backing := a.f64
+
+	m, err := NewMartiniWithOptions(17, &MartiniOptions{Allocator: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, err := m.CreateTile(testTerrain(17, hills))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &tile.Errors[0] != &backing[0] {
+		t.Error("expected tile errors to use the arena")
+	}
+
+	ref, _ := NewMartini(17)
+	refTile, _ := ref.CreateTile(testTerrain(17, hills))
+	for i := range refTile.Errors {
+		if refTile.Errors[i] != tile.Errors[i] {
+			t.Fatalf("errors differ at %d", i)
+		}
+	}
+
+	short, _ := NewMartiniWithOptions(17, &MartiniOptions{Allocator: shortAllocator{}})
+	if _, err := short.CreateTile(testTerrain(17, hills)); err == nil {
+		t.Error("expected error for short buffer")
+	}
+}
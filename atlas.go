@@ -0,0 +1,110 @@
+package martini
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// TexturedTile is a placed tile mesh with an image draped over its extent,
+// corner to corner as Tile.Orthophoto is, row 0 at the smallest grid y.
+type TexturedTile struct {
+	PlacedMesh
+	Image image.Image
+}
+
+// TextureAtlas is a set of images packed into one.
+type TextureAtlas struct {
+	Image *image.RGBA
+	// Rects holds where each image was placed, in input order.
+	Rects []image.Rectangle
+}
+
+// PackAtlas packs images into rows of an atlas about as wide as it is
+// tall. Each image is surrounded by padding pixels repeating its edges, so
+// that filtering near a border does not bleed in its neighbours.
+func PackAtlas(images []image.Image, padding int) *TextureAtlas {
+	padding = maxInt(padding, 0)
+	order := make([]int, len(images))
+	area, width := 0, 0
+	for i, img := range images {
+		order[i] = i
+		b := img.Bounds()
+		area += (b.Dx() + 2*padding) * (b.Dy() + 2*padding)
+		width = maxInt(width, b.Dx()+2*padding)
+	}
+	width = maxInt(width, int(math.Ceil(math.Sqrt(float64(area)))))
+	sort.SliceStable(order, func(i, j int) bool {
+		return images[order[i]].Bounds().Dy() > images[order[j]].Bounds().Dy()
+	})
+
+	atlas := &TextureAtlas{Rects: make([]image.Rectangle, len(images))}
+	x, y, shelf := 0, 0, 0
+	for _, i := range order {
+		b := images[i].Bounds()
+		w, h := b.Dx()+2*padding, b.Dy()+2*padding
+		if x+w > width {
+			x, y, shelf = 0, y+shelf, 0
+		}
+		atlas.Rects[i] = image.Rect(x+padding, y+padding, x+padding+b.Dx(), y+padding+b.Dy())
+		x += w
+		shelf = maxInt(shelf, h)
+	}
+	atlas.Image = image.NewRGBA(image.Rect(0, 0, width, y+shelf))
+	for i, img := range images {
+		b, r := img.Bounds(), atlas.Rects[i]
+		if b.Empty() {
+			continue
+		}
+		for py := -padding; py < b.Dy()+padding; py++ {
+			sy := b.Min.Y + clampInt(py, 0, b.Dy()-1)
+			for px := -padding; px < b.Dx()+padding; px++ {
+				sx := b.Min.X + clampInt(px, 0, b.Dx()-1)
+				atlas.Image.Set(r.Min.X+px, r.Min.Y+py, img.At(sx, sy))
+			}
+		}
+	}
+	return atlas
+}
+
+// MergeTextured concatenates the placed tiles into one mesh and packs their
+// images into an atlas. It returns u, v texture coordinates into the atlas
+// per vertex of the mesh, with v growing downwards as in glTF; pass them to
+// the exporters with SceneTile.UVs. Unlike MergeMeshes, vertices on shared
+// edges are not welded, since each tile samples its own image.
+func MergeTextured(tiles []TexturedTile, padding int) (*Mesh, []float64, *TextureAtlas) {
+	images := make([]image.Image, len(tiles))
+	for i, t := range tiles {
+		images[i] = t.Image
+	}
+	atlas := PackAtlas(images, padding)
+	size := atlas.Image.Bounds().Size()
+
+	merged := &Mesh{}
+	var uvs []float64
+	for i, t := range tiles {
+		m := t.Mesh
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for k := 0; k < m.NumVertices(); k++ {
+			x, y, _ := m.Vertex(k)
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+		spanX, spanY := math.Max(maxX-minX, 1e-300), math.Max(maxY-minY, 1e-300)
+		r := atlas.Rects[i]
+
+		base := uint32(merged.NumVertices())
+		for k := 0; k < m.NumVertices(); k++ {
+			x, y, z := m.Vertex(k)
+			merged.Vertices = append(merged.Vertices, x+t.OffsetX, y+t.OffsetY, z+t.OffsetZ)
+			// The extent's corners are the centres of the corner pixels.
+			px := float64(r.Min.X) + 0.5 + (x-minX)/spanX*float64(maxInt(r.Dx()-1, 0))
+			py := float64(r.Min.Y) + 0.5 + (y-minY)/spanY*float64(maxInt(r.Dy()-1, 0))
+			uvs = append(uvs, px/float64(size.X), py/float64(size.Y))
+		}
+		for _, v := range m.Triangles {
+			merged.Triangles = append(merged.Triangles, base+v)
+		}
+	}
+	return merged, uvs, atlas
+}
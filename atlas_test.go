@@ -0,0 +1,55 @@
+package martini
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestMergeTextured(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	fill := func(w, h int, c color.RGBA) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+		return img
+	}
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	tiles := []TexturedTile{
+		{PlacedMesh{Mesh: mesh}, fill(8, 8, blue)},
+		{PlacedMesh{Mesh: mesh, OffsetX: 16}, fill(32, 32, red)},
+	}
+
+	merged, uvs, atlas := MergeTextured(tiles, 2)
+	if merged.NumVertices() != 2*mesh.NumVertices() || len(uvs) != 2*merged.NumVertices() {
+		t.Fatalf("got %d vertices and %d texture coordinates", merged.NumVertices(), len(uvs))
+	}
+	if r := atlas.Rects[0]; r.Dx() != 8 || r.Overlaps(atlas.Rects[1]) {
+		t.Fatalf("bad placement %v", atlas.Rects)
+	}
+	size := atlas.Image.Bounds().Size()
+	for i := 0; i < merged.NumVertices(); i++ {
+		want := blue
+		if i >= mesh.NumVertices() {
+			want = red
+		}
+		px, py := int(uvs[2*i]*float64(size.X)), int(uvs[2*i+1]*float64(size.Y))
+		if got := atlas.Image.RGBAAt(px, py); got != want {
+			t.Fatalf("vertex %d samples %v at (%d, %d), want %v", i, got, px, py, want)
+		}
+	}
+	// Padding repeats the edges.
+	r := atlas.Rects[1]
+	if got := atlas.Image.RGBAAt(r.Min.X-2, r.Max.Y+1); got != red {
+		t.Errorf("padding is %v", got)
+	}
+
+	var s Scene
+	s.Tiles = []SceneTile{{PlacedMesh: PlacedMesh{Mesh: merged}, UVs: uvs}}
+	s.Materials = []SceneMaterial{{Name: "atlas", Texture: "atlas.png"}}
+	if got := s.sceneUVs()[0]; &got[0] != &uvs[0] {
+		t.Error("scene does not use the atlas coordinates")
+	}
+}
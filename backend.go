@@ -0,0 +1,71 @@
+package martini
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ErrorBackend computes error pyramids somewhere other than the calling
+// goroutine. The only backend so far is ParallelBackend, which uses every
+// core; no GPU backend exists yet. The interface leaves room for one, set
+// in MartiniOptions.Backend like ParallelBackend, but it would need cgo
+// and a vendor SDK. Offloading only pays off for large grids, 4097 and up,
+// so a backend is free to decline smaller ones.
+type ErrorBackend interface {
+	// ComputeErrors fills errs with the error pyramid of the gridSize by
+	// gridSize terrain, exactly as Update does on the CPU. It returns
+	// false without touching errs when it cannot handle the grid, for
+	// example when no device is available, and Update falls back to the
+	// CPU.
+	ComputeErrors(terrain, errs []float64, gridSize int) bool
+}
+
+// updateOnBackend runs Update on the Martini's backend, if it has one and
+// accepts the tile. Tiles with per-sample bias, thresholds or a seed from
+// Split always use the CPU, since the backend interface does not carry
+// them.
+func (t *Tile) updateOnBackend() bool {
+	b := t.Martini.backend
+	if b == nil || t.bias != nil || t.thresholds != nil || t.seed != nil {
+		return false
+	}
+	return b.ComputeErrors(t.Terrain, t.Errors, t.Martini.GridSize)
+}
+
+// ParallelBackend is an ErrorBackend that shares every level of the error
+// pyramid out across goroutines, in bands of rows. The result is identical
+// to Update's.
+type ParallelBackend struct {
+	// Workers is the number of goroutines; zero means GOMAXPROCS.
+	Workers int
+	// MinGridSize is the smallest grid handled; smaller grids are left to
+	// Update. Zero means 4097.
+	MinGridSize int
+}
+
+func (b *ParallelBackend) ComputeErrors(terrain, errs []float64, gridSize int) bool {
+	minSize, workers := b.MinGridSize, b.Workers
+	if minSize == 0 {
+		minSize = 4097
+	}
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if gridSize < minSize || workers < 2 {
+		return false
+	}
+	t := &Tile{Terrain: terrain, Errors: errs, Martini: &Martini{GridSize: gridSize}}
+	band := (gridSize + workers - 1) / workers
+	t.sweep(func(pass func(y0, y1 int)) {
+		var wg sync.WaitGroup
+		for y0 := 0; y0 < gridSize; y0 += band {
+			wg.Add(1)
+			go func(y0 int) {
+				defer wg.Done()
+				pass(y0, minInt(y0+band, gridSize))
+			}(y0)
+		}
+		wg.Wait()
+	})
+	return true
+}
@@ -0,0 +1,80 @@
+package martini
+
+import "testing"
+
+// cpuBackend stands in for an external backend, computing the pyramid with a
+// plain Martini when the grid is at least minSize.
+type cpuBackend struct {
+	minSize int
+	calls   int
+}
+
+func (b *cpuBackend) ComputeErrors(terrain, errs []float64, gridSize int) bool {
+	b.calls++
+	if gridSize < b.minSize {
+		return false
+	}
+	m, _ := NewMartini(gridSize)
+	tile, _ := m.CreateTile(terrain)
+	copy(errs, tile.Errors)
+	return true
+}
+
+func TestErrorBackend(t *testing.T) {
+	terrain := testTerrain(65, hills)
+	ref, _ := NewMartini(65)
+	want, _ := ref.CreateTile(terrain)
+
+	for _, minSize := range []int{0, 4097} {
+		b := &cpuBackend{minSize: minSize}
+		m, _ := NewMartiniWithOptions(65, &MartiniOptions{Backend: b})
+		tile, err := m.CreateTile(terrain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.calls != 1 {
+			t.Errorf("min size %d: backend called %d times", minSize, b.calls)
+		}
+		for i := range want.Errors {
+			if tile.Errors[i] != want.Errors[i] {
+				t.Fatalf("min size %d: errors differ at %d", minSize, i)
+			}
+		}
+	}
+
+	b := &cpuBackend{}
+	m, _ := NewMartiniWithOptions(65, &MartiniOptions{Backend: b})
+	if _, err := m.CreateTileWithOptions(terrain, &TileOptions{Curvature: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if b.calls != 0 {
+		t.Error("expected biased tiles to stay on the CPU")
+	}
+}
+
+func TestParallelBackend(t *testing.T) {
+	terrain := testTerrain(257, hills)
+	ref, _ := NewMartini(257)
+	want, _ := ref.CreateTile(terrain)
+
+	// Bands that split the coarse levels unevenly.
+	for _, workers := range []int{2, 3, 7, 64} {
+		m, _ := NewMartiniWithOptions(257, &MartiniOptions{Backend: &ParallelBackend{Workers: workers, MinGridSize: 257}})
+		tile, err := m.CreateTile(terrain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want.Errors {
+			if tile.Errors[i] != want.Errors[i] {
+				t.Fatalf("%d workers: errors differ at %d", workers, i)
+			}
+		}
+	}
+
+	if (&ParallelBackend{Workers: 4}).ComputeErrors(terrain, make([]float64, len(terrain)), 257) {
+		t.Error("expected grids below 4097 to be declined by default")
+	}
+	if (&ParallelBackend{Workers: 1, MinGridSize: 3}).ComputeErrors(terrain, make([]float64, len(terrain)), 257) {
+		t.Error("expected a single worker to be declined")
+	}
+}
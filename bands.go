@@ -0,0 +1,60 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// BandTile meshes several aligned elevation bands, such as a surface and
+// the bedrock beneath it, with one triangulation refined wherever any band
+// needs it.
+type BandTile struct {
+	Bands   [][]float64
+	Martini *Martini
+	// Errors is the per-sample maximum of the bands' error pyramids.
+	Errors []float64
+}
+
+func NewBandTile(bands [][]float64, martini *Martini) (*BandTile, error) {
+	if len(bands) == 0 {
+		return nil, errors.New("Expected at least one band")
+	}
+	errs := make([]float64, martini.GridSize*martini.GridSize)
+	for _, band := range bands {
+		tile, err := NewTile(band, martini)
+		if err != nil {
+			return nil, err
+		}
+		for i, e := range tile.Errors {
+			errs[i] = math.Max(errs[i], e)
+		}
+	}
+	return &BandTile{Bands: bands, Martini: martini, Errors: errs}, nil
+}
+
+func (m *Martini) CreateBandTile(bands [][]float64) (*BandTile, error) {
+	return NewBandTile(bands, m)
+}
+
+// ToMeshes extracts one mesh per band. All meshes share the same vertex
+// positions in x and y and the same triangles; only heights differ.
+func (t *BandTile) ToMeshes(maxError float64) []*Mesh {
+	shared := &Tile{Terrain: t.Bands[0], Martini: t.Martini, Errors: t.Errors}
+	base := shared.ToMesh(maxError)
+	size := t.Martini.GridSize
+
+	meshes := make([]*Mesh, len(t.Bands))
+	meshes[0] = base
+	for b := 1; b < len(t.Bands); b++ {
+		mesh := &Mesh{
+			Vertices:  append([]float64(nil), base.Vertices...),
+			Triangles: append([]uint32(nil), base.Triangles...),
+		}
+		for i := 0; i < len(mesh.Vertices); i += 3 {
+			x, y := int(mesh.Vertices[i]), int(mesh.Vertices[i+1])
+			mesh.Vertices[i+2] = t.Bands[b][y*size+x]
+		}
+		meshes[b] = mesh
+	}
+	return meshes
+}
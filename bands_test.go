@@ -0,0 +1,51 @@
+package martini
+
+import "testing"
+
+func TestBandTile(t *testing.T) {
+	// The surface is rough in the west, the bedrock in the east.
+	surface := testTerrain(33, func(x, y int) float64 {
+		if x < 16 {
+			return hills(x, y)
+		}
+		return 0
+	})
+	bedrock := testTerrain(33, func(x, y int) float64 {
+		if x > 16 {
+			return hills(x, y) - 500
+		}
+		return -500
+	})
+	m, _ := NewMartini(33)
+	tile, err := m.CreateBandTile([][]float64{surface, bedrock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meshes := tile.ToMeshes(1)
+	if len(meshes) != 2 {
+		t.Fatalf("expected 2 meshes, got %d", len(meshes))
+	}
+	a, b := meshes[0], meshes[1]
+	if a.NumVertices() != b.NumVertices() || a.NumTriangles() != b.NumTriangles() {
+		t.Fatal("expected shared triangulation")
+	}
+	for i := 0; i < a.NumVertices(); i++ {
+		ax, ay, az := a.Vertex(i)
+		bx, by, bz := b.Vertex(i)
+		if ax != bx || ay != by {
+			t.Fatalf("vertex %d differs in xy", i)
+		}
+		if az != surface[int(ay)*33+int(ax)] || bz != bedrock[int(by)*33+int(bx)] {
+			t.Fatalf("vertex %d has wrong height", i)
+		}
+	}
+
+	single, _ := m.CreateTile(surface)
+	if a.NumTriangles() <= single.ToMesh(1).NumTriangles() {
+		t.Error("expected the union to refine beyond the surface alone")
+	}
+
+	if _, err := m.CreateBandTile(nil); err == nil {
+		t.Error("expected error for no bands")
+	}
+}
@@ -0,0 +1,199 @@
+package martini
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures ProcessTiles.
+type BatchOptions struct {
+	GridSize int
+	MaxError float64
+	// Mesher, if set, meshes every tile instead of a per-worker Martini. It
+	// must be safe for concurrent use.
+	Mesher Mesher
+	// Workers is the number of tiles processed concurrently. Zero uses
+	// GOMAXPROCS.
+	Workers int
+	// TileOptions preprocesses the terrain of every tile.
+	TileOptions *TileOptions
+	// MeshOptions is applied to every extracted mesh.
+	MeshOptions *MeshOptions
+	// Cache, if set, is consulted before generating a tile and filled with
+	// the generated meshes.
+	Cache *MeshCache
+	// DiskCache, if set, stores meshes keyed by the terrain content so that
+	// unchanged tiles are not meshed again.
+	DiskCache *DiskCache
+	// Metrics, if set, records generated tiles and failures.
+	Metrics *Metrics
+	// Logger, if set, receives tile failures and tiles slower than
+	// SlowTile.
+	Logger   Logger
+	SlowTile time.Duration
+	// Stats computes the ElevationStats of every tile's prepared terrain
+	// into Result.Stats.
+	Stats bool
+}
+
+// Result is the outcome of processing one tile.
+type Result struct {
+	ID   TileID
+	Mesh *Mesh
+	// Stats is set when BatchOptions.Stats asks for it, unless the mesh
+	// came from Cache without loading the terrain.
+	Stats *ElevationStats
+	Err   error
+}
+
+// ProcessTiles loads, meshes and emits every tile on the returned channel,
+// which is closed once all tiles are done or ctx is cancelled. Results arrive
+// in completion order. At most Workers tiles are held in memory besides the
+// ones waiting to be received, so slow consumers throttle the pipeline.
+func ProcessTiles(ctx context.Context, source TerrainSource, tiles []TileID, opts BatchOptions) <-chan Result {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	out := make(chan Result)
+	ids := make(chan TileID)
+
+	go func() {
+		defer close(ids)
+		for _, id := range tiles {
+			select {
+			case ids <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			var martini *Martini
+			for id := range ids {
+				if ctx.Err() != nil {
+					return
+				}
+				r := Result{ID: id}
+				if martini == nil && opts.Mesher == nil {
+					// Extraction writes to Indices, so each worker needs its own
+					// hierarchy.
+					martini, r.Err = NewMartini(opts.GridSize)
+				}
+				if r.Err == nil {
+					r.Mesh, r.Stats, r.Err = processTile(ctx, source, martini, id, opts)
+				}
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// cacheKey identifies the mesh of tile id generated with opts.
+func (opts *BatchOptions) cacheKey(id TileID) CacheKey {
+	return CacheKey{
+		ID:       id,
+		GridSize: opts.GridSize,
+		MaxError: opts.MaxError,
+		Options:  OptionsKey(opts.Mesher, opts.TileOptions, opts.MeshOptions),
+	}
+}
+
+func processTile(ctx context.Context, source TerrainSource, martini *Martini, id TileID, opts BatchOptions) (*Mesh, *ElevationStats, error) {
+	mesh, stats, err := generateTile(ctx, source, martini, id, opts)
+	if err != nil {
+		if opts.Metrics != nil {
+			opts.Metrics.observeError()
+		}
+		loggerOrNop(opts.Logger).Error("tile failed", "tile", id.String(), "err", err)
+	}
+	return mesh, stats, err
+}
+
+func generateTile(ctx context.Context, source TerrainSource, martini *Martini, id TileID, opts BatchOptions) (*Mesh, *ElevationStats, error) {
+	start := time.Now()
+	key := opts.cacheKey(id)
+	if opts.Cache != nil {
+		if mesh, ok := opts.Cache.Get(key); ok {
+			return mesh, nil, nil
+		}
+	}
+	terrain, err := source.Terrain(ctx, id, opts.GridSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	terrain, _, err = prepareTerrain(terrain, opts.GridSize, opts.TileOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stats *ElevationStats
+	if opts.Stats && len(terrain) == opts.GridSize*opts.GridSize {
+		s := TerrainStats(terrain, opts.GridSize)
+		stats = &s
+	}
+
+	var contentKey string
+	if opts.DiskCache != nil {
+		contentKey = diskCacheKey(terrain, opts.GridSize, opts.MaxError, opts.Mesher, opts.MeshOptions, opts.TileOptions)
+		mesh, ok, err := opts.DiskCache.Get(contentKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			if opts.Cache != nil {
+				opts.Cache.Add(key, mesh)
+			}
+			return mesh, stats, nil
+		}
+	}
+
+	var mesh *Mesh
+	if opts.Mesher != nil {
+		mesh, err = opts.Mesher.Mesh(terrain, opts.GridSize, opts.GridSize, opts.MaxError)
+	} else if flatFastPath(terrain, opts.MaxError, opts.TileOptions) {
+		mesh = FlatTileMesh(terrain, opts.GridSize)
+	} else {
+		var tile *Tile
+		tile, err = martini.createPreparedTile(terrain, opts.TileOptions)
+		if tile != nil {
+			mesh = tile.ToMesh(opts.MaxError)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	mesh.applyOptions(opts.GridSize, opts.MeshOptions)
+	elapsed := time.Since(start)
+	if opts.Metrics != nil {
+		opts.Metrics.observeTile(elapsed, mesh)
+	}
+	if opts.SlowTile > 0 && elapsed > opts.SlowTile {
+		loggerOrNop(opts.Logger).Warn("slow tile", "tile", id.String(), "duration", elapsed, "triangles", mesh.NumTriangles())
+	}
+	if opts.DiskCache != nil {
+		if err := opts.DiskCache.Put(contentKey, mesh); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.Cache != nil {
+		opts.Cache.Add(key, mesh)
+	}
+	return mesh, stats, nil
+}
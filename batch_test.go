@@ -0,0 +1,81 @@
+package martini
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// testRoot holds the directories of testDir, removed after the tests.
+var testRoot string
+
+func TestMain(m *testing.M) {
+	var err error
+	if testRoot, err = ioutil.TempDir("", "martini-test"); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(testRoot)
+	os.Exit(code)
+}
+
+// testDir returns a new empty directory for t.
+func testDir(t *testing.T) string {
+	dir, err := ioutil.TempDir(testRoot, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func testSource() TerrainSource {
+	return TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		if id.Z > 3 {
+			return nil, errors.New("no data")
+		}
+		return testTerrain(gridSize, func(x, y int) float64 {
+			return hills(x+id.X*(gridSize-1), y+id.Y*(gridSize-1))
+		}), nil
+	})
+}
+
+func TestProcessTiles(t *testing.T) {
+	tiles := []TileID{{2, 0, 0}, {2, 1, 0}, {2, 0, 1}, {2, 1, 1}, {4, 0, 0}}
+	results := ProcessTiles(context.Background(), testSource(), tiles, BatchOptions{GridSize: 17, MaxError: 5, Workers: 3})
+
+	seen := make(map[TileID]bool)
+	for r := range results {
+		seen[r.ID] = true
+		if r.ID.Z == 4 {
+			if r.Err == nil {
+				t.Error("expected source error to be reported")
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("%v: %v", r.ID, r.Err)
+		} else if r.Mesh.NumTriangles() == 0 {
+			t.Errorf("%v: empty mesh", r.ID)
+		}
+	}
+	if len(seen) != len(tiles) {
+		t.Errorf("expected %d results, got %d", len(tiles), len(seen))
+	}
+}
+
+func TestProcessTilesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tiles := make([]TileID, 100)
+	results := ProcessTiles(ctx, testSource(), tiles, BatchOptions{GridSize: 17, Workers: 2})
+	<-results
+	cancel()
+	n := 0
+	for range results {
+		n++
+	}
+	if n > 4 {
+		t.Errorf("expected processing to stop after cancel, got %d more results", n)
+	}
+}
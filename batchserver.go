@@ -0,0 +1,194 @@
+package martini
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchRequest is the JSON body of a POST /batch request: either a list of
+// "z/x/y" tiles or a bounding box and zoom level, or both, and the
+// extension of the format to encode them in, json when empty.
+type BatchRequest struct {
+	Tiles  []string    `json:"tiles,omitempty"`
+	BBox   *[4]float64 `json:"bbox,omitempty"`
+	Zoom   int         `json:"zoom,omitempty"`
+	Format string      `json:"format,omitempty"`
+}
+
+// batchSize counts the tiles of a request from the tile ranges of its
+// bbox, so that oversized requests are refused before anything is listed.
+func (s *Server) batchSize(req *BatchRequest, scheme *TilingScheme) (int, error) {
+	n := int64(len(req.Tiles))
+	if req.BBox != nil {
+		if req.Zoom < 0 || req.Zoom > 30 {
+			return 0, fmt.Errorf("Invalid zoom %d", req.Zoom)
+		}
+		n += scheme.countTilesInBounds(req.Zoom, req.bounds())
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("Expected tiles or a bbox")
+	}
+	if n > int64(s.MaxBatchTiles) {
+		return 0, fmt.Errorf("Expected at most %d tiles, got %d", s.MaxBatchTiles, n)
+	}
+	return int(n), nil
+}
+
+// batchTiles resolves the tiles of a request checked by batchSize.
+func (s *Server) batchTiles(req *BatchRequest, scheme *TilingScheme) ([]TileID, error) {
+	var ids []TileID
+	for _, t := range req.Tiles {
+		id, _, ok := parseTilePath(t+".x", scheme)
+		if !ok {
+			return nil, fmt.Errorf("Invalid tile %q", t)
+		}
+		ids = append(ids, id)
+	}
+	if req.BBox != nil {
+		ids = append(ids, scheme.TilesInBounds(req.Zoom, req.bounds())...)
+	}
+	return ids, nil
+}
+
+func (req *BatchRequest) bounds() Bounds {
+	b := req.BBox
+	return Bounds{b[0], b[1], b[2], b[3]}
+}
+
+// serveBatch meshes the tiles of a BatchRequest and streams them as a tar
+// archive of z/x/y.ext entries, or as multipart/mixed when the client
+// accepts it. Tiles that fail are z/x/y.error entries holding the message,
+// since the status has been sent by then.
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var req BatchRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid batch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	scheme := s.Scheme
+	if scheme == nil {
+		scheme = SchemeXYZ
+	}
+	n, err := s.batchSize(&req, scheme)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.rateLimit(w, r, n) {
+		return
+	}
+	ids, err := s.batchTiles(&req, scheme)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ext := req.Format
+	if ext == "" {
+		ext = FormatJSON.Extension
+	}
+	format, ok := s.Formats[ext]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown format %q", ext), http.StatusBadRequest)
+		return
+	}
+
+	var write func(name, contentType string, data []byte) error
+	var finish func() error
+	if strings.Contains(r.Header.Get("Accept"), "multipart/mixed") {
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		write = func(name, contentType string, data []byte) error {
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Type", contentType)
+			h.Set("Content-Location", "/"+name)
+			part, err := mw.CreatePart(h)
+			if err == nil {
+				_, err = part.Write(data)
+			}
+			return err
+		}
+		finish = mw.Close
+	} else {
+		tw := tar.NewWriter(w)
+		w.Header().Set("Content-Type", "application/x-tar")
+		now := time.Now()
+		write = func(name, contentType string, data []byte) error {
+			hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := tw.Write(data)
+			return err
+		}
+		finish = tw.Close
+	}
+
+	type result struct {
+		id   TileID
+		data []byte
+		err  error
+	}
+	results := make(chan result)
+	jobs := make(chan TileID)
+	var wg sync.WaitGroup
+	for i := 0; i < minInt(4, len(ids)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				res := result{id: id}
+				mesh, maxError, err := s.mesh(r, id)
+				if err == nil {
+					var buf bytes.Buffer
+					err = s.encode(&buf, format, id, mesh, maxError)
+					res.data = buf.Bytes()
+				}
+				res.err = err
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, id := range ids {
+			select {
+			case jobs <- id:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var werr error
+	for res := range results {
+		if werr != nil {
+			continue // drain the workers
+		}
+		if res.err != nil {
+			werr = write(res.id.String()+".error", "text/plain; charset=utf-8", []byte(s.errorText(r, res.err)))
+		} else {
+			werr = write(res.id.String()+"."+ext, format.ContentType, res.data)
+		}
+	}
+	if werr == nil {
+		finish()
+	}
+}
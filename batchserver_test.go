@@ -0,0 +1,83 @@
+package martini
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestServerBatch(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxBatchTiles = 8
+	post := func(body, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"tiles": ["0/0/0", "5/0/0"], "bbox": [-10, -10, 10, 10], "zoom": 1}`, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-tar" {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	var names []string
+	tr := tar.NewReader(rec.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		if strings.HasSuffix(hdr.Name, ".json") && !strings.HasPrefix(string(data), "{") {
+			t.Errorf("%s: unexpected content %q", hdr.Name, data)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	want := []string{"0/0/0.json", "1/0/0.json", "1/0/1.json", "1/1/0.json", "1/1/1.json", "5/0/0.error"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("got entries %v, want %v", names, want)
+	}
+
+	rec = post(`{"tiles": ["1/1/0"]}`, "multipart/mixed")
+	_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := multipart.NewReader(rec.Body, params["boundary"]).NextPart()
+	if err != nil || part.Header.Get("Content-Location") != "/1/1/0.json" {
+		t.Errorf("unexpected part %v %v", part, err)
+	}
+
+	for body, code := range map[string]int{
+		`{"bbox": [-180, -85, 180, 85], "zoom": 3}`: http.StatusBadRequest,
+		// Counted, not listed: a 2^60 tile list would exhaust memory.
+		`{"bbox": [-180, -85, 180, 85], "zoom": 30}`: http.StatusBadRequest,
+		`{"tiles": ["1/2/0"]}`:                       http.StatusBadRequest,
+		`{"tiles": ["0/0/0"], "format": "glb"}`:      http.StatusBadRequest,
+		`{}`:                                         http.StatusBadRequest,
+		`not json`:                                   http.StatusBadRequest,
+	} {
+		if rec := post(body, ""); rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", body, code, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/batch", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
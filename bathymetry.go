@@ -0,0 +1,52 @@
+package martini
+
+// bathymetryTile returns a view of t with maxError for samples at or above
+// seaLevel and seaMaxError below it.
+func (t *Tile) bathymetryTile(maxError, seaMaxError, seaLevel float64) *Tile {
+	thresholds := make([]float64, len(t.Terrain))
+	for i, h := range t.Terrain {
+		if h < seaLevel {
+			thresholds[i] = seaMaxError
+		} else {
+			thresholds[i] = maxError
+		}
+	}
+	return t.thresholdTile(thresholds)
+}
+
+// thresholdTile returns a view of t whose errors are normalized by a
+// per-sample threshold, so that extracting it at 1 applies each threshold to
+// its own region. The normalized errors are propagated up the hierarchy like
+// plain ones, which keeps the mesh free of cracks where thresholds change.
+func (t *Tile) thresholdTile(thresholds []float64) *Tile {
+	return t.view(thresholds, t.bias)
+}
+
+// view returns a tile sharing the terrain, attributes and orthophoto of t
+// whose error pyramid is computed with other thresholds and bias.
+func (t *Tile) view(thresholds, bias []float64) *Tile {
+	w := &Tile{
+		Terrain:    t.Terrain,
+		Martini:    t.Martini,
+		Attributes: t.Attributes,
+		Orthophoto: t.Orthophoto,
+		Errors:     make([]float64, len(t.Terrain)),
+		thresholds: thresholds,
+		bias:       bias,
+	}
+	w.Update()
+	return w
+}
+
+// GetMeshBathymetry is GetMesh with a separate seaMaxError for samples
+// below seaLevel, so seafloor can be simplified more aggressively than the
+// land in the same tile. A triangle is split when any sample it covers
+// exceeds its own threshold.
+func (t *Tile) GetMeshBathymetry(maxError, seaMaxError, seaLevel float64) ([]uint16, []uint16) {
+	return t.bathymetryTile(maxError, seaMaxError, seaLevel).GetMesh(1)
+}
+
+// ToMeshBathymetry is ToMesh with a separate seaMaxError below seaLevel.
+func (t *Tile) ToMeshBathymetry(maxError, seaMaxError, seaLevel float64) *Mesh {
+	return t.bathymetryTile(maxError, seaMaxError, seaLevel).ToMesh(1)
+}
@@ -0,0 +1,38 @@
+package martini
+
+import "testing"
+
+func TestMeshBathymetry(t *testing.T) {
+	// West half is seafloor, east half land, both equally rough.
+	terrain := testTerrain(65, func(x, y int) float64 {
+		h := hills(x, y) / 4
+		if x < 32 {
+			return h - 200
+		}
+		return h + 200
+	})
+	m, _ := NewMartini(65)
+	tile, _ := m.CreateTile(terrain)
+
+	same := tile.ToMeshBathymetry(1, 1, 0)
+	if ref := tile.ToMesh(1); same.NumTriangles() != ref.NumTriangles() {
+		t.Errorf("expected equal thresholds to match ToMesh: %d vs %d", same.NumTriangles(), ref.NumTriangles())
+	}
+
+	mesh := tile.ToMeshBathymetry(1, 20, 0)
+	var sea, land int
+	for i := 0; i < mesh.NumVertices(); i++ {
+		if x, _, _ := mesh.Vertex(i); x < 31 {
+			sea++
+		} else if x > 33 {
+			land++
+		}
+	}
+	if sea*2 >= land {
+		t.Errorf("expected sparser seafloor: %d sea vertices, %d land", sea, land)
+	}
+
+	if v, tris := tile.GetMeshBathymetry(0, 1e9, -1e9); len(tris)/3 != 2*64*64 || len(v) != 2*65*65 {
+		t.Errorf("expected a full mesh at zero error, got %d triangles", len(tris)/3)
+	}
+}
@@ -0,0 +1,51 @@
+package martini
+
+// BoundaryLoops returns the boundary curves of m as loops of vertex
+// indices, the first vertex not repeated. Loops run along the triangle
+// winding, so the mesh lies on the same side of all of them: an outer
+// boundary turns the same way as the triangles and a hole the other way.
+func (m *Mesh) BoundaryLoops() [][]uint32 {
+	n := len(m.Triangles) / 3 * 3
+	edge := func(h int) (uint32, uint32) {
+		return m.Triangles[h], m.Triangles[NextHalfEdge(h)]
+	}
+	edges := make(map[[2]uint32]bool, n)
+	for h := 0; h < n; h++ {
+		a, b := edge(h)
+		edges[[2]uint32{a, b}] = true
+	}
+	// Boundary half-edges have no twin. Vertices touched by several loops
+	// have several leaving them.
+	var boundary []int
+	leaving := make(map[uint32][]int)
+	for h := 0; h < n; h++ {
+		if a, b := edge(h); a != b && !edges[[2]uint32{b, a}] {
+			boundary = append(boundary, h)
+			leaving[a] = append(leaving[a], h)
+		}
+	}
+
+	used := make(map[int]bool, len(boundary))
+	var loops [][]uint32
+	for _, h := range boundary {
+		if used[h] {
+			continue
+		}
+		var loop []uint32
+		for h >= 0 {
+			used[h] = true
+			a, b := edge(h)
+			loop = append(loop, a)
+			next := -1
+			for _, c := range leaving[b] {
+				if !used[c] {
+					next = c
+					break
+				}
+			}
+			h = next
+		}
+		loops = append(loops, loop)
+	}
+	return loops
+}
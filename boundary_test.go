@@ -0,0 +1,68 @@
+package martini
+
+import "testing"
+
+func TestBoundaryLoops(t *testing.T) {
+	m, _ := NewMartini(5)
+	tile, _ := m.CreateTile(make([]float64, 25))
+	mesh := tile.ToMesh(-1)
+
+	loops := mesh.BoundaryLoops()
+	if len(loops) != 1 || len(loops[0]) != 16 {
+		t.Fatalf("got loops %v, want one of 16 vertices", loops)
+	}
+	area := func(loop []uint32) float64 {
+		a := 0.0
+		for i, v := range loop {
+			x0, y0, _ := mesh.Vertex(int(v))
+			x1, y1, _ := mesh.Vertex(int(loop[(i+1)%len(loop)]))
+			a += x0*y1 - x1*y0
+		}
+		return a / 2
+	}
+	ccw := mesh.isCCW(2)
+	if outer := area(loops[0]); outer != 16 && outer != -16 || (outer > 0) != ccw {
+		t.Errorf("outer loop area %v, triangles counter-clockwise %v", outer, ccw)
+	}
+	for i, v := range loops[0] {
+		x0, y0, _ := mesh.Vertex(int(v))
+		x1, y1, _ := mesh.Vertex(int(loops[0][(i+1)%16]))
+		if d := (x1-x0)*(x1-x0) + (y1-y0)*(y1-y0); d != 1 {
+			t.Fatalf("loop steps from (%v, %v) to (%v, %v)", x0, y0, x1, y1)
+		}
+	}
+
+	// Cut a hole around the centre.
+	var kept []uint32
+	for i := 0; i < len(mesh.Triangles); i += 3 {
+		centre := false
+		for _, v := range mesh.Triangles[i : i+3] {
+			if x, y, _ := mesh.Vertex(int(v)); x == 2 && y == 2 {
+				centre = true
+			}
+		}
+		if !centre {
+			kept = append(kept, mesh.Triangles[i:i+3]...)
+		}
+	}
+	mesh.Triangles = kept
+	loops = mesh.BoundaryLoops()
+	if len(loops) != 2 {
+		t.Fatalf("got %d loops, want 2", len(loops))
+	}
+	for _, loop := range loops {
+		a := area(loop)
+		switch len(loop) {
+		case 16:
+			if (a > 0) != ccw {
+				t.Error("outer loop turns against the triangles")
+			}
+		case 8:
+			if a != 4 && a != -4 || (a > 0) == ccw {
+				t.Errorf("hole of area %v turns with the triangles", a)
+			}
+		default:
+			t.Errorf("unexpected loop of %d vertices", len(loop))
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package martini
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// CacheKey identifies a generated tile mesh.
+type CacheKey struct {
+	ID       TileID
+	GridSize int
+	MaxError float64
+	// Options identifies the Mesher, TileOptions and MeshOptions the mesh
+	// was generated with, as returned by OptionsKey.
+	Options string
+}
+
+// OptionsKey summarizes everything besides the tile, grid size and
+// maxError that changes a generated mesh. It is empty for the defaults.
+// Meshers are told apart by type and, for pointers, by identity.
+func OptionsKey(mesher Mesher, tileOpts *TileOptions, meshOpts *MeshOptions) string {
+	desc := mesherKey(mesher)
+	if tileOpts != nil && *tileOpts != (TileOptions{}) {
+		noData := "none"
+		if tileOpts.NoData != nil {
+			noData = fmt.Sprint(*tileOpts.NoData)
+		}
+		desc += fmt.Sprintf("tile %d %s %v %v %v;", tileOpts.Fill, noData, tileOpts.Smoothing, tileOpts.Curvature, tileOpts.Float32)
+	}
+	if meshOpts != nil && *meshOpts != (MeshOptions{}) {
+		desc += fmt.Sprintf("mesh %+v;", *meshOpts)
+	}
+	if desc == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(desc))
+	return hex.EncodeToString(sum[:16])
+}
+
+// mesherKey describes a Mesher by type and, for pointers, by identity.
+func mesherKey(mesher Mesher) string {
+	if mesher == nil {
+		return ""
+	}
+	v := reflect.ValueOf(mesher)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice:
+		return fmt.Sprintf("mesher %T %x;", mesher, v.Pointer())
+	default:
+		return fmt.Sprintf("mesher %T %+v;", mesher, mesher)
+	}
+}
+
+// CacheStats reports the activity of a MeshCache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+}
+
+type cacheEntry struct {
+	key  CacheKey
+	mesh *Mesh
+	size int64
+}
+
+// MeshCache is a concurrency-safe least-recently-used cache of meshes,
+// bounded by the approximate memory held by the cached meshes. Cached meshes
+// are shared and must not be modified.
+type MeshCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ll       *list.List
+	items    map[CacheKey]*list.Element
+	stats    CacheStats
+}
+
+func NewMeshCache(maxBytes int64) *MeshCache {
+	return &MeshCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[CacheKey]*list.Element),
+	}
+}
+
+// SizeBytes approximates the memory held by the mesh buffers.
+func (m *Mesh) SizeBytes() int64 {
+	return int64(8*len(m.Vertices) + 4*len(m.Triangles))
+}
+
+func (c *MeshCache) Get(key CacheKey) (*Mesh, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		c.stats.Hits++
+		return el.Value.(*cacheEntry).mesh, true
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// Add stores a mesh, evicting the least recently used entries to stay within
+// the size bound. Meshes larger than the bound are not cached.
+func (c *MeshCache) Add(key CacheKey, mesh *Mesh) {
+	size := mesh.SizeBytes()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	if size > c.maxBytes {
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, mesh: mesh, size: size})
+	c.stats.Bytes += size
+	for c.stats.Bytes > c.maxBytes {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *MeshCache) Remove(key CacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *MeshCache) removeElement(el *list.Element) {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	c.stats.Bytes -= e.size
+}
+
+func (c *MeshCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.ll.Len()
+	return s
+}
@@ -0,0 +1,86 @@
+package martini
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMeshCache(t *testing.T) {
+	mesh := &Mesh{Vertices: make([]float64, 3), Triangles: make([]uint32, 3)}
+	size := mesh.SizeBytes()
+	c := NewMeshCache(2 * size)
+
+	k1 := CacheKey{ID: TileID{1, 0, 0}, GridSize: 17, MaxError: 1}
+	k2 := CacheKey{ID: TileID{1, 1, 0}, GridSize: 17, MaxError: 1}
+	k3 := CacheKey{ID: TileID{1, 0, 0}, GridSize: 17, MaxError: 2}
+
+	if _, ok := c.Get(k1); ok {
+		t.Error("unexpected hit on empty cache")
+	}
+	c.Add(k1, mesh)
+	c.Add(k2, mesh)
+	if _, ok := c.Get(k1); !ok {
+		t.Error("expected hit")
+	}
+	c.Add(k3, mesh)
+	if _, ok := c.Get(k2); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, ok := c.Get(k1); !ok {
+		t.Error("expected recently used entry to survive")
+	}
+
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 2 || s.Evictions != 1 || s.Entries != 2 || s.Bytes != 2*size {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestProcessTilesCache(t *testing.T) {
+	c := NewMeshCache(1 << 20)
+	tiles := []TileID{{1, 0, 0}, {1, 1, 0}}
+	opts := BatchOptions{GridSize: 17, MaxError: 5, Workers: 1, Cache: c}
+	for r := range ProcessTiles(context.Background(), testSource(), tiles, opts) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+	for r := range ProcessTiles(context.Background(), testSource(), tiles, opts) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestCacheKeyOptions(t *testing.T) {
+	cache := NewMeshCache(1 << 20)
+	id := TileID{2, 1, 1}
+	mesh := func(opts BatchOptions) *Mesh {
+		opts.GridSize, opts.MaxError, opts.Cache = 17, 5, cache
+		r := <-ProcessTiles(context.Background(), testSource(), []TileID{id}, opts)
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		return r.Mesh
+	}
+	plain := mesh(BatchOptions{})
+	variants := []BatchOptions{
+		{TileOptions: &TileOptions{Curvature: 10}},
+		{MeshOptions: &MeshOptions{Axes: AxesYUp}},
+		{Mesher: DelatinMesher{}},
+	}
+	for i, opts := range variants {
+		if got := mesh(opts); got == plain {
+			t.Errorf("variant %d was served the cached default mesh", i)
+		}
+	}
+	if got := mesh(BatchOptions{}); got != plain {
+		t.Error("expected the default mesh from the cache")
+	}
+	if OptionsKey(nil, &TileOptions{}, &MeshOptions{}) != "" {
+		t.Error("expected an empty key for default options")
+	}
+}
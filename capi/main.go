@@ -0,0 +1,223 @@
+//go:build cgo
+// +build cgo
+
+// Command capi builds the C ABI of the package:
+//
+//	go build -buildmode=c-shared -o libmartini.so ./capi
+//
+// which also writes libmartini.h. Objects are referred to by integer
+// handles; zero means failure, and martini_last_error copies its message
+// into a caller buffer. That message is a single value shared by all
+// threads, so a failure on one thread replaces the message of another.
+// Mesh buffers are owned by the mesh handle and stay valid until
+// martini_mesh_free or martini_destroy. Calls on a martini handle are
+// serialized by its lock.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+
+	martini "github.com/flywave/go-martini"
+)
+
+// Element types of the buffers shared with C.
+type (
+	cChar   = C.char
+	cDouble = C.double
+	cInt64  = C.int64_t
+	cUint32 = C.uint32_t
+)
+
+// doubles and uint32s view n elements of C memory as a slice; unlike a
+// cast to a fixed-size array pointer, this also works on 32-bit targets.
+func doubles(p *cDouble, n int) []float64 {
+	var s []float64
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+	h.Data, h.Len, h.Cap = uintptr(unsafe.Pointer(p)), n, n
+	return s
+}
+
+func uint32s(p *cUint32, n int) []uint32 {
+	var s []uint32
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+	h.Data, h.Len, h.Cap = uintptr(unsafe.Pointer(p)), n, n
+	return s
+}
+
+func chars(p *cChar, n int) []byte {
+	var s []byte
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+	h.Data, h.Len, h.Cap = uintptr(unsafe.Pointer(p)), n, n
+	return s
+}
+
+type cMartini struct {
+	mu sync.Mutex
+	m  *martini.Martini
+}
+
+type cMesh struct {
+	numVertices, numTriangles int
+	vertices                  *C.double
+	triangles                 *C.uint32_t
+}
+
+var (
+	mu      sync.Mutex
+	next    int64
+	objects = map[int64]interface{}{}
+	lastErr string
+)
+
+func put(v interface{}) C.int64_t {
+	mu.Lock()
+	defer mu.Unlock()
+	next++
+	objects[next] = v
+	return C.int64_t(next)
+}
+
+func get(h C.int64_t) interface{} {
+	mu.Lock()
+	defer mu.Unlock()
+	return objects[int64(h)]
+}
+
+// remove drops a handle and frees the C memory it owns.
+func remove(h C.int64_t) {
+	mu.Lock()
+	v := objects[int64(h)]
+	delete(objects, int64(h))
+	mu.Unlock()
+	if m, ok := v.(*cMesh); ok {
+		C.free(unsafe.Pointer(m.vertices))
+		C.free(unsafe.Pointer(m.triangles))
+	}
+}
+
+func fail(msg string) C.int64_t {
+	mu.Lock()
+	defer mu.Unlock()
+	lastErr = msg
+	return 0
+}
+
+// martini_last_error copies the message of the last failed call, on any
+// thread, into buf, truncated and NUL-terminated to fit size bytes, and
+// returns the length of the whole message, or 0 if no call has failed.
+//
+//export martini_last_error
+func martini_last_error(buf *C.char, size C.int64_t) C.int64_t {
+	mu.Lock()
+	msg := lastErr
+	mu.Unlock()
+	if buf != nil && size > 0 {
+		out := chars(buf, int(size))
+		out[copy(out[:len(out)-1], msg)] = 0
+	}
+	return C.int64_t(len(msg))
+}
+
+// martini_create precomputes the hierarchy for a grid size of 2^n+1.
+//
+//export martini_create
+func martini_create(gridSize C.int32_t) C.int64_t {
+	m, err := martini.NewMartini(int(gridSize))
+	if err != nil {
+		return fail(err.Error())
+	}
+	return put(&cMartini{m: m})
+}
+
+// martini_destroy releases a martini or mesh handle.
+//
+//export martini_destroy
+func martini_destroy(h C.int64_t) {
+	remove(h)
+}
+
+// martini_mesh meshes gridSize*gridSize heights at maxError.
+//
+//export martini_mesh
+func martini_mesh(h C.int64_t, terrain *C.double, n C.int64_t, maxError C.double) C.int64_t {
+	cm, ok := get(h).(*cMartini)
+	if !ok {
+		return fail("invalid martini handle")
+	}
+	if terrain == nil || n < 0 {
+		return fail("invalid terrain")
+	}
+	data := make([]float64, int(n))
+	copy(data, doubles(terrain, int(n)))
+
+	cm.mu.Lock()
+	tile, err := cm.m.CreateTile(data)
+	var mesh *martini.Mesh
+	if err == nil {
+		mesh = tile.ToMesh(float64(maxError))
+	}
+	cm.mu.Unlock()
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	out := &cMesh{numVertices: mesh.NumVertices(), numTriangles: mesh.NumTriangles()}
+	out.vertices = (*C.double)(C.malloc(C.size_t(8 * (len(mesh.Vertices) + 1))))
+	out.triangles = (*C.uint32_t)(C.malloc(C.size_t(4 * (len(mesh.Triangles) + 1))))
+	copy(doubles(out.vertices, len(mesh.Vertices)), mesh.Vertices)
+	copy(uint32s(out.triangles, len(mesh.Triangles)), mesh.Triangles)
+	return put(out)
+}
+
+//export martini_mesh_num_vertices
+func martini_mesh_num_vertices(h C.int64_t) C.int64_t {
+	if m, ok := get(h).(*cMesh); ok {
+		return C.int64_t(m.numVertices)
+	}
+	return 0
+}
+
+//export martini_mesh_num_triangles
+func martini_mesh_num_triangles(h C.int64_t) C.int64_t {
+	if m, ok := get(h).(*cMesh); ok {
+		return C.int64_t(m.numTriangles)
+	}
+	return 0
+}
+
+// martini_mesh_vertices returns x, y, z triples in grid units.
+//
+//export martini_mesh_vertices
+func martini_mesh_vertices(h C.int64_t) *C.double {
+	if m, ok := get(h).(*cMesh); ok {
+		return m.vertices
+	}
+	return nil
+}
+
+// martini_mesh_triangles returns three vertex indices per triangle.
+//
+//export martini_mesh_triangles
+func martini_mesh_triangles(h C.int64_t) *C.uint32_t {
+	if m, ok := get(h).(*cMesh); ok {
+		return m.triangles
+	}
+	return nil
+}
+
+//export martini_mesh_free
+func martini_mesh_free(h C.int64_t) {
+	if _, ok := get(h).(*cMesh); ok {
+		remove(h)
+	}
+}
+
+func main() {}
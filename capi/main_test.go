@@ -0,0 +1,87 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func lastError() string {
+	n := martini_last_error(nil, 0)
+	buf := make([]byte, n+1)
+	martini_last_error((*cChar)(unsafe.Pointer(&buf[0])), cInt64(len(buf)))
+	return string(buf[:n])
+}
+
+func TestCAPIMesh(t *testing.T) {
+	const size = 17
+	h := martini_create(size)
+	if h == 0 {
+		t.Fatal(lastError())
+	}
+	defer martini_destroy(h)
+
+	terrain := make([]float64, size*size)
+	for i := range terrain {
+		x, y := i%size, i/size
+		terrain[i] = float64((x - 8) * (y - 8))
+	}
+	mh := martini_mesh(h, (*cDouble)(unsafe.Pointer(&terrain[0])), cInt64(len(terrain)), 0)
+	if mh == 0 {
+		t.Fatal(lastError())
+	}
+	nv, nt := int(martini_mesh_num_vertices(mh)), int(martini_mesh_num_triangles(mh))
+	if nv == 0 || nt == 0 {
+		t.Fatalf("Expected a mesh, got %d vertices and %d triangles", nv, nt)
+	}
+	vertices := doubles(martini_mesh_vertices(mh), 3*nv)
+	for i := 0; i < nv; i++ {
+		x, y, z := vertices[3*i], vertices[3*i+1], vertices[3*i+2]
+		if want := terrain[int(y)*size+int(x)]; z != want {
+			t.Fatalf("Vertex %d at (%v, %v): expected height %v, got %v", i, x, y, want, z)
+		}
+	}
+	for i, v := range uint32s(martini_mesh_triangles(mh), 3*nt) {
+		if int(v) >= nv {
+			t.Fatalf("Index %d out of range: %d", i, v)
+		}
+	}
+
+	// Destroying a mesh handle releases it like martini_mesh_free.
+	martini_destroy(mh)
+	if martini_mesh_vertices(mh) != nil || martini_mesh_num_vertices(mh) != 0 {
+		t.Error("Expected destroyed mesh handle to be invalid")
+	}
+	martini_mesh_free(mh)
+}
+
+func TestCAPIErrors(t *testing.T) {
+	if h := martini_create(16); h != 0 {
+		martini_destroy(h)
+		t.Fatal("Expected invalid grid size to fail")
+	}
+	msg := lastError()
+	if msg == "" {
+		t.Fatal("Expected an error message")
+	}
+
+	// A short buffer gets a truncated, NUL-terminated prefix.
+	buf := []byte("xxxxx")
+	if n := martini_last_error((*cChar)(unsafe.Pointer(&buf[0])), 4); int(n) != len(msg) {
+		t.Errorf("Expected length %d, got %d", len(msg), n)
+	}
+	if string(buf) != msg[:3]+"\x00x" {
+		t.Errorf("Unexpected truncation %q of %q", buf, msg)
+	}
+
+	var terrain [4]float64
+	if martini_mesh(12345, (*cDouble)(unsafe.Pointer(&terrain[0])), 4, 0) != 0 {
+		t.Fatal("Expected invalid handle to fail")
+	}
+	if msg := lastError(); !strings.Contains(msg, "handle") {
+		t.Errorf("Unexpected error %q", msg)
+	}
+}
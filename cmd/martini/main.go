@@ -0,0 +1,295 @@
+// Command martini generates and serves RTIN terrain meshes.
+//
+// Usage:
+//
+//	martini serve [flags]
+//	martini warm -zooms 0-12 [-bbox w,s,e,n] [flags]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	martini "github.com/flywave/go-martini"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: martini <command> [flags]\n\ncommands:\n  serve    serve tile meshes over HTTP\n  warm     mesh the tiles of a region ahead of serving them\n")
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("martini: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "serve":
+		serve(os.Args[2:])
+	case "warm":
+		warm(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func parseEncoding(s string) martini.Encoding {
+	enc, err := martini.ParseEncoding(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return enc
+}
+
+// serverFlags are the flags shared by serve and warm, which must agree for
+// warmed tiles to be found.
+type serverFlags struct {
+	url, encoding, config, cacheDir, preset string
+	gridSize                                int
+	maxError                                float64
+}
+
+func (f *serverFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.url, "url", "", "elevation tile URL template with {z}, {x} and {y}")
+	fs.StringVar(&f.encoding, "encoding", "terrain-rgb", "elevation encoding: terrain-rgb or terrarium")
+	fs.IntVar(&f.gridSize, "grid", 257, "grid size, 2^n+1")
+	fs.Float64Var(&f.maxError, "max-error", 1, "maximum error in terrain units")
+	fs.StringVar(&f.preset, "preset", "", "choose maxError per zoom for web, print or simulation instead of -max-error")
+	fs.StringVar(&f.config, "config", "", "JSON server config overriding -max-error, -preset, -url and -cache-mb, reloaded by serve on SIGHUP")
+	fs.StringVar(&f.cacheDir, "cache-dir", "", "directory of meshes kept across restarts, filled by warm")
+}
+
+// newServer builds the Server described by the flags, with an in-memory
+// cache of cacheBytes unless the config file sets one.
+func (f *serverFlags) newServer(cacheBytes int64) *martini.Server {
+	if f.url == "" {
+		log.Fatal("-url is required")
+	}
+	source := martini.NewHTTPSource(f.url, parseEncoding(f.encoding))
+	s, err := martini.NewServer(source, f.gridSize, f.maxError)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cacheBytes > 0 {
+		s.Cache = martini.NewMeshCache(cacheBytes)
+	}
+	if f.cacheDir != "" {
+		if s.DiskCache, err = martini.NewDiskCache(f.cacheDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if f.config != "" {
+		if err := s.ReloadFile(f.config); err != nil {
+			log.Fatal(err)
+		}
+	} else if f.preset != "" {
+		cfg := martini.ServerConfig{MaxError: f.maxError, Preset: f.preset, CacheBytes: &cacheBytes}
+		if err := s.Reload(cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return s
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var sf serverFlags
+	sf.register(fs)
+	addr := fs.String("addr", ":8080", "listen address")
+	cacheMB := fs.Int("cache-mb", 256, "in-memory mesh cache size in MiB, 0 to disable")
+	preview := fs.Bool("preview", false, "serve a web viewer at / with a live maxError slider")
+	adminToken := fs.String("admin-token", "", "enable /admin/config with this bearer token")
+	cacheControl := fs.String("cache-control", "", "Cache-Control header for tiles, e.g. \"public, max-age=86400\"")
+	maxConcurrent := fs.Int("max-concurrent", 0, "meshes generated at once, 0 for no limit")
+	queueTimeout := fs.Duration("queue-timeout", 5*time.Second, "how long requests wait for -max-concurrent before 503")
+	rateLimit := fs.Float64("rate-limit", 0, "tiles per second allowed per API key or IP address, 0 for no limit")
+	rateBurst := fs.Int("rate-burst", 0, "tiles a client may request at once above -rate-limit, 0 for a second's worth and at least a full batch")
+	apiKeys := fs.String("api-keys", "", "comma-separated X-API-Key values rate limited on their own rather than by IP address")
+	quantize := fs.Bool("quantize", false, "serve glb tiles with 16-bit positions under KHR_mesh_quantization")
+	colorRamp := fs.String("color-ramp", "", "color glb, ply and obj vertices by height: hypsometric (meters) or relief")
+	fs.Parse(args)
+
+	s := sf.newServer(int64(*cacheMB) << 20)
+	for _, f := range []*martini.Format{martini.FormatGLB, martini.FormatPLY, martini.FormatOBJ} {
+		s.Formats[f.Extension] = f
+	}
+	if *quantize {
+		s.Formats[martini.FormatGLBQuantized.Extension] = martini.FormatGLBQuantized
+	}
+	if *colorRamp != "" {
+		ramps := map[string]*martini.ColorRamp{"hypsometric": martini.HypsometricRamp, "relief": martini.ReliefRamp}
+		ramp, ok := ramps[*colorRamp]
+		if !ok {
+			log.Fatalf("serve: unknown color ramp %q", *colorRamp)
+		}
+		for _, ext := range []string{"glb", "ply", "obj"} {
+			s.Formats[ext] = s.Formats[ext].WithColorRamp(ramp, martini.AxesZUp)
+		}
+	}
+	s.CacheControl = *cacheControl
+	s.AdminToken = *adminToken
+	s.MaxConcurrent, s.QueueTimeout = *maxConcurrent, *queueTimeout
+	s.RateLimit, s.RateBurst = *rateLimit, *rateBurst
+	if *apiKeys != "" {
+		s.APIKeys = make(map[string]bool)
+		for _, key := range strings.Split(*apiKeys, ",") {
+			s.APIKeys[strings.TrimSpace(key)] = true
+		}
+	}
+	if *preview {
+		s.Preview = true
+		s.Formats[martini.FormatPNG.Extension] = martini.FormatPNG
+		s.Formats[martini.FormatSVG.Extension] = martini.FormatSVG
+		log.Printf("preview at http://localhost%s/", *addr)
+	}
+	if sf.config != "" {
+		// The config was applied by newServer; reapply it on SIGHUP.
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := s.ReloadFile(sf.config); err != nil {
+					log.Printf("reload: %v", err)
+					continue
+				}
+				log.Printf("reloaded %s", sf.config)
+			}
+		}()
+	}
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
+
+func warm(args []string) {
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	var sf serverFlags
+	sf.register(fs)
+	bbox := fs.String("bbox", "", "region to warm as west,south,east,north in degrees, the world when empty")
+	zooms := fs.String("zooms", "", "zoom levels to warm, e.g. 10 or 0-12")
+	workers := fs.Int("workers", 0, "tiles meshed concurrently, 0 for one per CPU")
+	server := fs.String("server", "", "base URL of a running server whose memory cache to warm by requesting the tiles, instead of -cache-dir")
+	fs.Parse(args)
+
+	b := martini.WorldBounds
+	if *bbox != "" {
+		var err error
+		if b, err = parseBBox(*bbox); err != nil {
+			log.Fatal(err)
+		}
+	}
+	minZoom, maxZoom, err := parseZooms(*zooms)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tiles []martini.TileID
+	for z := minZoom; z <= maxZoom; z++ {
+		tiles = append(tiles, martini.TilesInBounds(z, b)...)
+	}
+
+	var results <-chan martini.Result
+	switch {
+	case *server != "":
+		results = fetchTiles(strings.TrimSuffix(*server, "/"), tiles, *workers)
+	case sf.cacheDir != "":
+		s := sf.newServer(0)
+		results = s.Warm(context.Background(), tiles, *workers)
+	default:
+		log.Fatal("warm: -cache-dir or -server is required")
+	}
+	failed := 0
+	for r := range results {
+		if r.Err != nil {
+			failed++
+			log.Printf("%v: %v", r.ID, r.Err)
+		}
+	}
+	log.Printf("warmed %d tiles, %d failed", len(tiles)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// fetchTiles requests every tile from a running server, so that it meshes
+// and caches them.
+func fetchTiles(base string, tiles []martini.TileID, workers int) <-chan martini.Result {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	ids := make(chan martini.TileID)
+	out := make(chan martini.Result)
+	go func() {
+		defer close(ids)
+		for _, id := range tiles {
+			ids <- id
+		}
+	}()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				r := martini.Result{ID: id}
+				resp, err := http.Get(base + "/" + id.String() + ".json")
+				if err == nil {
+					io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						err = fmt.Errorf("%s", resp.Status)
+					}
+				}
+				r.Err = err
+				out <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// parseBBox parses "west,south,east,north".
+func parseBBox(s string) (martini.Bounds, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return martini.Bounds{}, fmt.Errorf("Invalid bbox %q, expected west,south,east,north", s)
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return martini.Bounds{}, fmt.Errorf("Invalid bbox %q: %v", s, err)
+		}
+		v[i] = f
+	}
+	return martini.Bounds{West: v[0], South: v[1], East: v[2], North: v[3]}, nil
+}
+
+// parseZooms parses a zoom level or an inclusive range "min-max".
+func parseZooms(s string) (int, int, error) {
+	lo, hi := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	min, err1 := strconv.Atoi(lo)
+	max, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil || min < 0 || max < min || max > 30 {
+		return 0, 0, fmt.Errorf("Invalid zooms %q, expected e.g. 10 or 0-12", s)
+	}
+	return min, max, nil
+}
@@ -0,0 +1,62 @@
+package martini
+
+// Coarsen relaxes a mesh extracted from t to newMaxError. Vertices that
+// survive keep their index when it is still in range; the slots of removed
+// vertices are filled by survivors from the end of the buffer, so a GPU
+// vertex buffer can be patched and truncated instead of replaced. remap
+// gives the new index of every vertex of m, or -1 if it was removed. The
+// result equals t.ToMesh(newMaxError) up to vertex and triangle order. m
+// must be in grid units, as returned by ToMesh, and is not modified.
+func (m *Mesh) Coarsen(t *Tile, newMaxError float64) (mesh *Mesh, remap []int, err error) {
+	old, err := t.gridVertices(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	max := t.Martini.GridSize - 1
+	b := &meshBuilder{t: t, index: make(map[int]uint32)}
+	b.split(0, 0, max, max, max, 0, newMaxError)
+	b.split(max, max, 0, 0, 0, max, newMaxError)
+
+	n := len(b.vertices) / 2
+	slot := make([]int, n)
+	taken := make([]bool, n)
+	for i := range slot {
+		slot[i] = -1
+		k := int(b.vertices[2*i+1])*(max+1) + int(b.vertices[2*i])
+		if j, ok := old.index[k]; ok && int(j) < n {
+			slot[i] = int(j)
+			taken[j] = true
+		}
+	}
+	free := 0
+	for i := range slot {
+		if slot[i] >= 0 {
+			continue
+		}
+		for taken[free] {
+			free++
+		}
+		slot[i] = free
+		taken[free] = true
+	}
+
+	vertices := make([]uint16, 2*n)
+	for i, s := range slot {
+		vertices[2*s], vertices[2*s+1] = b.vertices[2*i], b.vertices[2*i+1]
+	}
+	for i, v := range b.triangles {
+		b.triangles[i] = uint32(slot[v])
+	}
+
+	remap = make([]int, m.NumVertices())
+	for i := range remap {
+		remap[i] = -1
+	}
+	for i, s := range slot {
+		k := int(b.vertices[2*i+1])*(max+1) + int(b.vertices[2*i])
+		if j, ok := old.index[k]; ok {
+			remap[j] = s
+		}
+	}
+	return t.liftMesh(vertices, b.triangles), remap, nil
+}
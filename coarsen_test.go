@@ -0,0 +1,47 @@
+package martini
+
+import "testing"
+
+func TestCoarsen(t *testing.T) {
+	martini, _ := NewMartini(65)
+	tile, _ := martini.CreateTile(testTerrain(65, hills))
+
+	fine := tile.ToMesh(5)
+	coarse, remap, err := fine.Coarsen(tile, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := CompareMeshes(coarse, tile.ToMesh(50)); !c.Equal() {
+		t.Errorf("coarsened mesh differs from direct extraction: %+v", c)
+	}
+	if len(remap) != fine.NumVertices() {
+		t.Fatalf("remap has %d entries, want %d", len(remap), fine.NumVertices())
+	}
+	kept := 0
+	for i, j := range remap {
+		if j < 0 {
+			continue
+		}
+		kept++
+		if i < coarse.NumVertices() && j != i {
+			t.Errorf("vertex %d moved to %d although its slot is still in range", i, j)
+		}
+		fx, fy, fz := fine.Vertex(i)
+		cx, cy, cz := coarse.Vertex(j)
+		if fx != cx || fy != cy || fz != cz {
+			t.Errorf("remap %d -> %d points at a different vertex", i, j)
+		}
+	}
+	if kept != coarse.NumVertices() {
+		t.Errorf("remap keeps %d vertices, mesh has %d", kept, coarse.NumVertices())
+	}
+
+	// Coarsening and refining back restores the original mesh.
+	back, err := coarse.Refine(tile, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := CompareMeshes(back, fine); !c.Equal() {
+		t.Errorf("round trip differs: %+v", c)
+	}
+}
@@ -0,0 +1,682 @@
+package martini
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TIFF tags used by the COG reader.
+const (
+	tagNewSubfileType  = 254
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagSamplesPerPixel = 277
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagGDALNoData      = 42113
+)
+
+type cogLevel struct {
+	width, height       int
+	tileWidth           int
+	tileHeight          int
+	bitsPerSample       int
+	sampleFormat        int
+	samplesPerPixel     int
+	compression         int
+	predictor           int
+	tileOffsets, counts []uint64
+}
+
+// COG reads elevation from a tiled, single-band GeoTIFF such as a Cloud
+// Optimized GeoTIFF. Only the internal tiles covering a requested window are
+// read, which makes it practical over HTTP range requests.
+type COG struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+	big   bool
+	// levels[0] is the full resolution image followed by its overviews.
+	levels []*cogLevel
+
+	// OriginX, OriginY are the model coordinates of the top-left corner and
+	// PixelSizeX, PixelSizeY the full resolution pixel size.
+	OriginX, OriginY       float64
+	PixelSizeX, PixelSizeY float64
+	// NoData is the GDAL nodata value, NaN when absent.
+	NoData float64
+}
+
+// OpenCOG parses the TIFF header and all image file directories.
+func OpenCOG(r io.ReaderAt) (*COG, error) {
+	meta := &blockReaderAt{r: r, blockSize: 16 << 10, blocks: make(map[int64][]byte)}
+	c := &COG{r: r, NoData: math.NaN()}
+
+	var hdr [16]byte
+	if _, err := meta.ReadAt(hdr[:8], 0); err != nil {
+		return nil, err
+	}
+	switch string(hdr[:2]) {
+	case "II":
+		c.order = binary.LittleEndian
+	case "MM":
+		c.order = binary.BigEndian
+	default:
+		return nil, errors.New("Expected TIFF byte order mark")
+	}
+	var next uint64
+	switch c.order.Uint16(hdr[2:]) {
+	case 42:
+		next = uint64(c.order.Uint32(hdr[4:]))
+	case 43:
+		c.big = true
+		if _, err := meta.ReadAt(hdr[:16], 0); err != nil {
+			return nil, err
+		}
+		next = c.order.Uint64(hdr[8:])
+	default:
+		return nil, errors.New("Expected TIFF or BigTIFF version")
+	}
+
+	for next != 0 && len(c.levels) < 64 {
+		tags, n, err := c.readIFD(meta, next)
+		if err != nil {
+			return nil, err
+		}
+		next = n
+		if first(tags[tagNewSubfileType])&4 != 0 {
+			continue // transparency mask
+		}
+		level, err := newCOGLevel(tags)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.levels) == 0 {
+			if s := tags[tagModelPixelScale]; len(s) >= 2 {
+				c.PixelSizeX, c.PixelSizeY = s[0], s[1]
+			}
+			if tp := tags[tagModelTiepoint]; len(tp) >= 6 {
+				c.OriginX = tp[3] - tp[0]*c.PixelSizeX
+				c.OriginY = tp[4] + tp[1]*c.PixelSizeY
+			}
+			if nd, ok := tags[tagGDALNoData]; ok && len(nd) == 1 {
+				c.NoData = nd[0]
+			}
+		}
+		c.levels = append(c.levels, level)
+	}
+	if len(c.levels) == 0 {
+		return nil, errors.New("Expected at least one image in TIFF")
+	}
+	sort.SliceStable(c.levels, func(i, j int) bool { return c.levels[i].width > c.levels[j].width })
+	return c, nil
+}
+
+func first(v []float64) int {
+	if len(v) == 0 {
+		return 0
+	}
+	return int(v[0])
+}
+
+func newCOGLevel(tags map[int][]float64) (*cogLevel, error) {
+	l := &cogLevel{
+		width:           first(tags[tagImageWidth]),
+		height:          first(tags[tagImageLength]),
+		tileWidth:       first(tags[tagTileWidth]),
+		tileHeight:      first(tags[tagTileLength]),
+		bitsPerSample:   first(tags[tagBitsPerSample]),
+		sampleFormat:    first(tags[tagSampleFormat]),
+		samplesPerPixel: first(tags[tagSamplesPerPixel]),
+		compression:     first(tags[tagCompression]),
+		predictor:       first(tags[tagPredictor]),
+	}
+	if l.sampleFormat == 0 {
+		l.sampleFormat = 1
+	}
+	if l.samplesPerPixel == 0 {
+		l.samplesPerPixel = 1
+	}
+	if l.compression == 0 {
+		l.compression = 1
+	}
+	if l.tileWidth == 0 || l.tileHeight == 0 {
+		return nil, errors.New("Expected a tiled TIFF")
+	}
+	if l.samplesPerPixel != 1 {
+		return nil, errors.New("Expected a single band TIFF")
+	}
+	switch l.compression {
+	case 1, 8, 32946:
+	default:
+		return nil, fmt.Errorf("Unsupported TIFF compression %d", l.compression)
+	}
+	for _, v := range tags[tagTileOffsets] {
+		l.tileOffsets = append(l.tileOffsets, uint64(v))
+	}
+	for _, v := range tags[tagTileByteCounts] {
+		l.counts = append(l.counts, uint64(v))
+	}
+	across := (l.width + l.tileWidth - 1) / l.tileWidth
+	down := (l.height + l.tileHeight - 1) / l.tileHeight
+	if len(l.tileOffsets) < across*down || len(l.counts) < across*down {
+		return nil, errors.New("Expected tile offsets for every tile")
+	}
+	return l, nil
+}
+
+// readIFD returns the numeric tags of the directory at off and the offset of
+// the next directory. ASCII tags are parsed as a single number when possible.
+func (c *COG) readIFD(r io.ReaderAt, off uint64) (map[int][]float64, uint64, error) {
+	countSize, entrySize, offSize := 2, 12, 4
+	if c.big {
+		countSize, entrySize, offSize = 8, 20, 8
+	}
+	buf := make([]byte, countSize)
+	if _, err := r.ReadAt(buf, int64(off)); err != nil {
+		return nil, 0, err
+	}
+	var n uint64
+	if c.big {
+		n = c.order.Uint64(buf)
+	} else {
+		n = uint64(c.order.Uint16(buf))
+	}
+	if n > 4096 {
+		return nil, 0, errors.New("Too many TIFF directory entries")
+	}
+	buf = make([]byte, int(n)*entrySize+offSize)
+	if _, err := r.ReadAt(buf, int64(off)+int64(countSize)); err != nil {
+		return nil, 0, err
+	}
+
+	tags := make(map[int][]float64)
+	for i := 0; i < int(n); i++ {
+		e := buf[i*entrySize : (i+1)*entrySize]
+		tag := int(c.order.Uint16(e))
+		typ := int(c.order.Uint16(e[2:]))
+		var count uint64
+		var inline []byte
+		if c.big {
+			count = c.order.Uint64(e[4:])
+			inline = e[12:20]
+		} else {
+			count = uint64(c.order.Uint32(e[4:]))
+			inline = e[8:12]
+		}
+		size := tiffTypeSize(typ)
+		if size == 0 || count > 1<<26 {
+			continue
+		}
+		data := inline[:0]
+		if total := count * uint64(size); total <= uint64(len(inline)) {
+			data = inline[:total]
+		} else {
+			var valueOff uint64
+			if c.big {
+				valueOff = c.order.Uint64(inline)
+			} else {
+				valueOff = uint64(c.order.Uint32(inline))
+			}
+			data = make([]byte, total)
+			if _, err := r.ReadAt(data, int64(valueOff)); err != nil {
+				return nil, 0, err
+			}
+		}
+		tags[tag] = c.decodeValues(typ, data)
+	}
+
+	tail := buf[int(n)*entrySize:]
+	var next uint64
+	if c.big {
+		next = c.order.Uint64(tail)
+	} else {
+		next = uint64(c.order.Uint32(tail))
+	}
+	return tags, next, nil
+}
+
+func tiffTypeSize(typ int) int {
+	switch typ {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 11:
+		return 4
+	case 5, 10, 12, 16, 17, 18:
+		return 8
+	}
+	return 0
+}
+
+func (c *COG) decodeValues(typ int, data []byte) []float64 {
+	if typ == 2 {
+		s := strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return []float64{v}
+		}
+		return nil
+	}
+	size := tiffTypeSize(typ)
+	out := make([]float64, 0, len(data)/size)
+	for i := 0; i+size <= len(data); i += size {
+		d := data[i:]
+		var v float64
+		switch typ {
+		case 1, 7:
+			v = float64(d[0])
+		case 6:
+			v = float64(int8(d[0]))
+		case 3:
+			v = float64(c.order.Uint16(d))
+		case 8:
+			v = float64(int16(c.order.Uint16(d)))
+		case 4:
+			v = float64(c.order.Uint32(d))
+		case 9:
+			v = float64(int32(c.order.Uint32(d)))
+		case 11:
+			v = float64(math.Float32frombits(c.order.Uint32(d)))
+		case 12:
+			v = math.Float64frombits(c.order.Uint64(d))
+		case 16, 18:
+			v = float64(c.order.Uint64(d))
+		case 17:
+			v = float64(int64(c.order.Uint64(d)))
+		case 5:
+			v = float64(c.order.Uint32(d)) / float64(c.order.Uint32(d[4:]))
+		case 10:
+			v = float64(int32(c.order.Uint32(d))) / float64(int32(c.order.Uint32(d[4:])))
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// Levels reports the number of resolution levels, the full image included.
+func (c *COG) Levels() int {
+	return len(c.levels)
+}
+
+// Size returns the pixel dimensions of a level.
+func (c *COG) Size(level int) (int, int) {
+	l := c.levels[level]
+	return l.width, l.height
+}
+
+// ReadWindow returns the w*h samples of level starting at pixel (x, y).
+// Pixels outside the image are NaN.
+func (c *COG) ReadWindow(level, x, y, w, h int) ([]float64, error) {
+	l := c.levels[level]
+	out := make([]float64, w*h)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	across := (l.width + l.tileWidth - 1) / l.tileWidth
+	tx0, ty0 := floorDiv(x, l.tileWidth), floorDiv(y, l.tileHeight)
+	tx1, ty1 := floorDiv(x+w-1, l.tileWidth), floorDiv(y+h-1, l.tileHeight)
+	for ty := ty0; ty <= ty1; ty++ {
+		for tx := tx0; tx <= tx1; tx++ {
+			if tx < 0 || ty < 0 || tx >= across || ty*l.tileHeight >= l.height {
+				continue
+			}
+			samples, err := c.readTile(l, ty*across+tx)
+			if err != nil {
+				return nil, err
+			}
+			for py := 0; py < l.tileHeight; py++ {
+				gy := ty*l.tileHeight + py
+				if gy < y || gy >= y+h || gy >= l.height {
+					continue
+				}
+				for px := 0; px < l.tileWidth; px++ {
+					gx := tx*l.tileWidth + px
+					if gx < x || gx >= x+w || gx >= l.width {
+						continue
+					}
+					out[(gy-y)*w+gx-x] = samples[py*l.tileWidth+px]
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+func (c *COG) readTile(l *cogLevel, index int) ([]float64, error) {
+	n := l.tileWidth * l.tileHeight
+	if l.counts[index] == 0 {
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = c.NoData
+		}
+		return out, nil
+	}
+	raw := make([]byte, l.counts[index])
+	if _, err := c.r.ReadAt(raw, int64(l.tileOffsets[index])); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if l.compression != 1 {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = ioutil.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	bps := l.bitsPerSample / 8
+	if bps == 0 || len(raw) < n*bps {
+		return nil, errors.New("Short TIFF tile")
+	}
+
+	switch l.predictor {
+	case 2:
+		for row := 0; row < l.tileHeight; row++ {
+			line := raw[row*l.tileWidth*bps : (row+1)*l.tileWidth*bps]
+			for i := 1; i < l.tileWidth; i++ {
+				switch bps {
+				case 1:
+					line[i] += line[i-1]
+				case 2:
+					c.order.PutUint16(line[2*i:], c.order.Uint16(line[2*i:])+c.order.Uint16(line[2*i-2:]))
+				case 4:
+					c.order.PutUint32(line[4*i:], c.order.Uint32(line[4*i:])+c.order.Uint32(line[4*i-4:]))
+				}
+			}
+		}
+	case 3:
+		// Floating point predictor: bytes are differenced and then stored
+		// most significant byte plane first.
+		rowBytes := l.tileWidth * bps
+		tmp := make([]byte, rowBytes)
+		for row := 0; row < l.tileHeight; row++ {
+			line := raw[row*rowBytes : (row+1)*rowBytes]
+			for i := 1; i < rowBytes; i++ {
+				line[i] += line[i-1]
+			}
+			copy(tmp, line)
+			for i := 0; i < l.tileWidth; i++ {
+				for b := 0; b < bps; b++ {
+					// Planes are big-endian; write in file byte order.
+					v := tmp[b*l.tileWidth+i]
+					if c.order == binary.LittleEndian {
+						line[i*bps+bps-1-b] = v
+					} else {
+						line[i*bps+b] = v
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]float64, n)
+	for i := range out {
+		d := raw[i*bps:]
+		switch {
+		case l.sampleFormat == 3 && bps == 4:
+			out[i] = float64(math.Float32frombits(c.order.Uint32(d)))
+		case l.sampleFormat == 3 && bps == 8:
+			out[i] = math.Float64frombits(c.order.Uint64(d))
+		case l.sampleFormat == 2 && bps == 1:
+			out[i] = float64(int8(d[0]))
+		case l.sampleFormat == 2 && bps == 2:
+			out[i] = float64(int16(c.order.Uint16(d)))
+		case l.sampleFormat == 2 && bps == 4:
+			out[i] = float64(int32(c.order.Uint32(d)))
+		case bps == 1:
+			out[i] = float64(d[0])
+		case bps == 2:
+			out[i] = float64(c.order.Uint16(d))
+		case bps == 4:
+			out[i] = float64(c.order.Uint32(d))
+		default:
+			return nil, fmt.Errorf("Unsupported TIFF sample format %d/%d", l.sampleFormat, l.bitsPerSample)
+		}
+	}
+	return out, nil
+}
+
+// Projection is the coordinate system of a georeferenced raster.
+type Projection int
+
+const (
+	// ProjectionWebMercator is EPSG:3857 in meters.
+	ProjectionWebMercator Projection = iota
+	// ProjectionGeographic is EPSG:4326 in degrees.
+	ProjectionGeographic
+)
+
+const webMercatorHalf = math.Pi * 6378137
+
+// COGSource is a TerrainSource sampling XYZ web mercator tiles from a COG.
+// For every tile it picks the coarsest overview that still has at least the
+// tile's resolution and reads only the internal tiles covering it. NoData
+// samples are left out of the interpolation; tile samples with no data
+// around them are NaN.
+type COGSource struct {
+	COG        *COG
+	Projection Projection
+}
+
+func (s *COGSource) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	c := s.COG
+	px, py, need := tileSamplePoints(id, gridSize, s.Projection)
+	fullW, _ := c.Size(0)
+	level := 0
+	for l := 1; l < c.Levels(); l++ {
+		w, _ := c.Size(l)
+		if c.PixelSizeX*float64(fullW)/float64(w) > need {
+			break
+		}
+		level = l
+	}
+	w, h := c.Size(level)
+	sx := c.PixelSizeX * float64(fullW) / float64(w)
+	sy := c.PixelSizeY * float64(fullW) / float64(w)
+
+	// Pixel-centre coordinates in the chosen level.
+	minPX, minPY := math.Inf(1), math.Inf(1)
+	maxPX, maxPY := math.Inf(-1), math.Inf(-1)
+	for k := range px {
+		px[k] = (px[k]-c.OriginX)/sx - 0.5
+		py[k] = (c.OriginY-py[k])/sy - 0.5
+		minPX, maxPX = math.Min(minPX, px[k]), math.Max(maxPX, px[k])
+		minPY, maxPY = math.Min(minPY, py[k]), math.Max(maxPY, py[k])
+	}
+	x0 := int(math.Max(math.Floor(minPX), 0))
+	y0 := int(math.Max(math.Floor(minPY), 0))
+	x1 := int(math.Min(math.Floor(maxPX)+1, float64(w-1)))
+	y1 := int(math.Min(math.Floor(maxPY)+1, float64(h-1)))
+	if x1 < x0 || y1 < y0 {
+		return nil, fmt.Errorf("tile %v: outside of the COG extent", id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ww, wh := x1-x0+1, y1-y0+1
+	window, err := c.ReadWindow(level, x0, y0, ww, wh)
+	if err != nil {
+		return nil, err
+	}
+	if !math.IsNaN(c.NoData) {
+		for i, v := range window {
+			if v == c.NoData {
+				window[i] = math.NaN()
+			}
+		}
+	}
+
+	terrain := make([]float64, gridSize*gridSize)
+	for k := range terrain {
+		terrain[k] = bilinear(window, ww, wh, px[k]-float64(x0), py[k]-float64(y0))
+	}
+	return terrain, nil
+}
+
+// bilinear samples a w*h grid at (x, y), clamping to the grid edges. NaN
+// samples are void: the weights of the others are renormalised, and the
+// result is NaN only when every sample with weight is void.
+func bilinear(grid []float64, w, h int, x, y float64) float64 {
+	x = math.Max(0, math.Min(x, float64(w-1)))
+	y = math.Max(0, math.Min(y, float64(h-1)))
+	ix, iy := int(x), int(y)
+	if ix >= w-1 {
+		ix = w - 2
+	}
+	if iy >= h-1 {
+		iy = h - 2
+	}
+	if ix < 0 {
+		ix = 0
+	}
+	if iy < 0 {
+		iy = 0
+	}
+	fx, fy := x-float64(ix), y-float64(iy)
+	at := func(i, j int) float64 {
+		if i >= w {
+			i = w - 1
+		}
+		if j >= h {
+			j = h - 1
+		}
+		return grid[j*w+i]
+	}
+	a, b, c, d := at(ix, iy), at(ix+1, iy), at(ix, iy+1), at(ix+1, iy+1)
+	if !math.IsNaN(a + b + c + d) {
+		top := a*(1-fx) + b*fx
+		bottom := c*(1-fx) + d*fx
+		return top*(1-fy) + bottom*fy
+	}
+	sum, weight := 0.0, 0.0
+	for k, v := range [4]float64{a, b, c, d} {
+		wx, wy := 1-fx, 1-fy
+		if k&1 == 1 {
+			wx = fx
+		}
+		if k&2 == 2 {
+			wy = fy
+		}
+		if !math.IsNaN(v) && wx*wy > 0 {
+			sum += v * wx * wy
+			weight += wx * wy
+		}
+	}
+	if weight == 0 {
+		return math.NaN()
+	}
+	return sum / weight
+}
+
+// blockReaderAt caches fixed-size blocks of an underlying reader. It is
+// used for the many small reads of TIFF directories.
+type blockReaderAt struct {
+	r         io.ReaderAt
+	blockSize int64
+	mu        sync.Mutex
+	blocks    map[int64][]byte
+}
+
+func (b *blockReaderAt) block(i int64) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if data, ok := b.blocks[i]; ok {
+		return data, nil
+	}
+	data := make([]byte, b.blockSize)
+	n, err := b.r.ReadAt(data, i*b.blockSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+	b.blocks[i] = data
+	return data, nil
+}
+
+func (b *blockReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		data, err := b.block(pos / b.blockSize)
+		if err != nil {
+			return n, err
+		}
+		start := pos % b.blockSize
+		if start >= int64(len(data)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[start:])
+	}
+	return n, nil
+}
+
+// HTTPRangeReader reads a remote file with HTTP range requests.
+type HTTPRangeReader struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *HTTPRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, h.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server ignored the range; skip to the offset.
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, off); err != nil {
+			return 0, io.EOF
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		return 0, fmt.Errorf("range request %s: %s", h.URL, resp.Status)
+	}
+	n, err := io.ReadFull(body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,245 @@
+package martini
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+type tiffEntry struct {
+	tag, typ uint16
+	values   []uint32
+	raw      []byte
+}
+
+// buildTestCOG writes a little-endian tiled float32 TIFF with one image per
+// entry of levels; level i has size/2^i pixels.
+func buildTestCOG(size, tileSize int, f func(x, y float64) float64, levels int) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.Write([]byte{'I', 'I', 42, 0, 0, 0, 0, 0})
+
+	var ifds [][]tiffEntry
+	for lvl := 0; lvl < levels; lvl++ {
+		w := size >> uint(lvl)
+		scale := float64(int(1) << uint(lvl))
+		across := (w + tileSize - 1) / tileSize
+		var offsets, counts []uint32
+		for ty := 0; ty < across; ty++ {
+			for tx := 0; tx < across; tx++ {
+				var tile bytes.Buffer
+				for py := 0; py < tileSize; py++ {
+					for px := 0; px < tileSize; px++ {
+						// Value at the pixel centre in level 0 pixel coordinates.
+						x := (float64(tx*tileSize+px)+0.5)*scale - 0.5
+						y := (float64(ty*tileSize+py)+0.5)*scale - 0.5
+						binary.Write(&tile, le, float32(f(x, y)))
+					}
+				}
+				data := tile.Bytes()
+				if lvl == 0 {
+					var z bytes.Buffer
+					zw := zlib.NewWriter(&z)
+					zw.Write(data)
+					zw.Close()
+					data = z.Bytes()
+				}
+				offsets = append(offsets, uint32(buf.Len()))
+				counts = append(counts, uint32(len(data)))
+				buf.Write(data)
+			}
+		}
+		compression := uint32(1)
+		if lvl == 0 {
+			compression = 8
+		}
+		entries := []tiffEntry{
+			{tag: tagImageWidth, typ: 4, values: []uint32{uint32(w)}},
+			{tag: tagImageLength, typ: 4, values: []uint32{uint32(w)}},
+			{tag: tagBitsPerSample, typ: 3, values: []uint32{32}},
+			{tag: tagCompression, typ: 3, values: []uint32{compression}},
+			{tag: tagSamplesPerPixel, typ: 3, values: []uint32{1}},
+			{tag: tagTileWidth, typ: 3, values: []uint32{uint32(tileSize)}},
+			{tag: tagTileLength, typ: 3, values: []uint32{uint32(tileSize)}},
+			{tag: tagTileOffsets, typ: 4, values: offsets},
+			{tag: tagTileByteCounts, typ: 4, values: counts},
+			{tag: tagSampleFormat, typ: 3, values: []uint32{3}},
+		}
+		if lvl > 0 {
+			entries = append(entries, tiffEntry{tag: tagNewSubfileType, typ: 4, values: []uint32{1}})
+		} else {
+			px := 2 * webMercatorHalf / float64(size)
+			var scaleRaw, tieRaw bytes.Buffer
+			binary.Write(&scaleRaw, le, []float64{px, px, 0})
+			binary.Write(&tieRaw, le, []float64{0, 0, 0, -webMercatorHalf, webMercatorHalf, 0})
+			entries = append(entries,
+				tiffEntry{tag: tagModelPixelScale, typ: 12, raw: scaleRaw.Bytes()},
+				tiffEntry{tag: tagModelTiepoint, typ: 12, raw: tieRaw.Bytes()},
+				tiffEntry{tag: tagGDALNoData, typ: 2, raw: []byte("-9999\x00")},
+			)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+		ifds = append(ifds, entries)
+	}
+
+	var ifdOffsets []int
+	for _, entries := range ifds {
+		// Out-of-line values first, then the directory itself.
+		valueOffsets := make([]uint32, len(entries))
+		for k, e := range entries {
+			raw := e.raw
+			if raw == nil {
+				var b bytes.Buffer
+				for _, v := range e.values {
+					if e.typ == 3 {
+						binary.Write(&b, le, uint16(v))
+					} else {
+						binary.Write(&b, le, v)
+					}
+				}
+				raw = b.Bytes()
+				entries[k].raw = raw
+			}
+			if len(raw) > 4 {
+				valueOffsets[k] = uint32(buf.Len())
+				buf.Write(raw)
+			}
+		}
+		if buf.Len()%2 == 1 {
+			buf.WriteByte(0)
+		}
+		ifdOffsets = append(ifdOffsets, buf.Len())
+		binary.Write(&buf, le, uint16(len(entries)))
+		for k, e := range entries {
+			binary.Write(&buf, le, e.tag)
+			binary.Write(&buf, le, e.typ)
+			binary.Write(&buf, le, uint32(len(e.raw)/tiffTypeSize(int(e.typ))))
+			if len(e.raw) > 4 {
+				binary.Write(&buf, le, valueOffsets[k])
+			} else {
+				var inline [4]byte
+				copy(inline[:], e.raw)
+				buf.Write(inline[:])
+			}
+		}
+		binary.Write(&buf, le, uint32(0)) // patched below
+	}
+	out := buf.Bytes()
+	le.PutUint32(out[4:], uint32(ifdOffsets[0]))
+	for i := 0; i+1 < len(ifdOffsets); i++ {
+		end := ifdOffsets[i] + 2 + 12*len(ifds[i])
+		le.PutUint32(out[end:], uint32(ifdOffsets[i+1]))
+	}
+	return out
+}
+
+func linear(x, y float64) float64 {
+	return x + 2*y
+}
+
+func TestCOGReadWindow(t *testing.T) {
+	c, err := OpenCOG(bytes.NewReader(buildTestCOG(64, 16, linear, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Levels() != 2 {
+		t.Fatalf("expected 2 levels, got %d", c.Levels())
+	}
+	if c.NoData != -9999 {
+		t.Errorf("unexpected nodata %v", c.NoData)
+	}
+	if c.OriginX != -webMercatorHalf || c.OriginY != webMercatorHalf {
+		t.Errorf("unexpected origin %v %v", c.OriginX, c.OriginY)
+	}
+
+	window, err := c.ReadWindow(0, 10, 12, 20, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window[0] != linear(10, 12) || window[3*20+15] != linear(25, 15) {
+		t.Errorf("unexpected window values %v %v", window[0], window[3*20+15])
+	}
+
+	window, _ = c.ReadWindow(0, 60, 60, 8, 8)
+	if !math.IsNaN(window[7*8+7]) || window[0] != linear(60, 60) {
+		t.Error("expected NaN outside the image")
+	}
+}
+
+func TestCOGSource(t *testing.T) {
+	data := buildTestCOG(64, 16, linear, 2)
+	var ranges int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges++
+		}
+		http.ServeContent(w, r, "dem.tif", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	c, err := OpenCOG(&HTTPRangeReader{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &COGSource{COG: c}
+	terrain, err := source.Terrain(context.Background(), TileID{1, 1, 0}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranges == 0 {
+		t.Error("expected range requests")
+	}
+
+	// Tile 1/1/0 covers level 0 pixels 32..64 in x and 0..32 in y.
+	for j := 1; j < 16; j++ {
+		for i := 1; i < 16; i++ {
+			want := linear(32+float64(i)*2-0.5, float64(j)*2-0.5)
+			if got := terrain[j*17+i]; math.Abs(got-want) > 1e-6 {
+				t.Fatalf("grid %d,%d: got %v want %v", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestCOGSourceNoData(t *testing.T) {
+	void := func(x, y float64) float64 {
+		if x < 40 {
+			return -9999
+		}
+		return linear(x, y)
+	}
+	c, err := OpenCOG(bytes.NewReader(buildTestCOG(64, 16, void, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	terrain, err := (&COGSource{COG: c}).Terrain(context.Background(), TileID{1, 1, 0}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tile 1/1/0 starts at level 0 pixel 32; samples left of pixel 39
+	// have only NoData around them, the others blend valid pixels only.
+	for j := 1; j < 16; j++ {
+		for i := 1; i < 16; i++ {
+			x, got := 32+float64(i)*2-0.5, terrain[j*17+i]
+			switch {
+			case x < 39:
+				if !math.IsNaN(got) {
+					t.Fatalf("grid %d,%d: got %v, want NaN", i, j, got)
+				}
+			case x > 40:
+				if want := linear(x, float64(j)*2-0.5); math.Abs(got-want) > 1e-6 {
+					t.Fatalf("grid %d,%d: got %v want %v", i, j, got, want)
+				}
+			case math.IsNaN(got) || got < 0:
+				t.Fatalf("grid %d,%d: got %v, blended with NoData", i, j, got)
+			}
+		}
+	}
+}
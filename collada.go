@@ -0,0 +1,87 @@
+package martini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// EncodeDAE writes m as a COLLADA 1.4.1 document with a single geometry
+// instanced in the scene, Z up and one unit per meter. Colors become a
+// per-vertex COLOR input.
+func EncodeDAE(w io.Writer, m *Mesh) error {
+	bw := bufio.NewWriter(w)
+	nv := m.NumVertices()
+	colors := len(m.Colors) == 3*nv && nv > 0
+	floats := func(id string, values []float64, stride int, params string) {
+		fmt.Fprintf(bw, "        <source id=\"%s\">\n          <float_array id=\"%s-array\" count=\"%d\">", id, id, len(values))
+		for i, v := range values {
+			if i > 0 {
+				bw.WriteByte(' ')
+			}
+			bw.WriteString(strconv.FormatFloat(v, 'g', -1, 32))
+		}
+		fmt.Fprintf(bw, "</float_array>\n          <technique_common>\n            <accessor source=\"#%s-array\" count=\"%d\" stride=\"%d\">\n", id, len(values)/stride, stride)
+		for _, p := range params {
+			fmt.Fprintf(bw, "              <param name=\"%c\" type=\"float\"/>\n", p)
+		}
+		fmt.Fprintf(bw, "            </accessor>\n          </technique_common>\n        </source>\n")
+	}
+
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="utf-8"?>
+<COLLADA xmlns="http://www.collada.org/2005/11/COLLADASchema" version="1.4.1">
+  <asset>
+    <contributor><authoring_tool>go-martini</authoring_tool></contributor>
+    <unit name="meter" meter="1"/>
+    <up_axis>Z_UP</up_axis>
+  </asset>
+  <library_geometries>
+    <geometry id="terrain" name="terrain">
+      <mesh>
+`)
+	floats("terrain-positions", m.Vertices, 3, "XYZ")
+	if colors {
+		values := make([]float64, len(m.Colors))
+		for i, c := range m.Colors {
+			values[i] = float64(c) / 255
+		}
+		floats("terrain-colors", values, 3, "RGB")
+	}
+	fmt.Fprintf(bw, "        <vertices id=\"terrain-vertices\">\n          <input semantic=\"POSITION\" source=\"#terrain-positions\"/>\n")
+	if colors {
+		fmt.Fprintf(bw, "          <input semantic=\"COLOR\" source=\"#terrain-colors\"/>\n")
+	}
+	fmt.Fprintf(bw, "        </vertices>\n        <triangles count=\"%d\">\n          <input semantic=\"VERTEX\" source=\"#terrain-vertices\" offset=\"0\"/>\n          <p>", m.NumTriangles())
+	for i, v := range m.Triangles {
+		if i > 0 {
+			bw.WriteByte(' ')
+		}
+		bw.WriteString(strconv.FormatUint(uint64(v), 10))
+	}
+	fmt.Fprintf(bw, `</p>
+        </triangles>
+      </mesh>
+    </geometry>
+  </library_geometries>
+  <library_visual_scenes>
+    <visual_scene id="scene">
+      <node id="terrain-node" name="terrain">
+        <instance_geometry url="#terrain"/>
+      </node>
+    </visual_scene>
+  </library_visual_scenes>
+  <scene>
+    <instance_visual_scene url="#scene"/>
+  </scene>
+</COLLADA>
+`)
+	return bw.Flush()
+}
+
+var FormatDAE = &Format{
+	Name:        "dae",
+	Extension:   "dae",
+	ContentType: "model/vnd.collada+xml",
+	Encode:      EncodeDAE,
+}
@@ -0,0 +1,48 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDAE(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	mesh.Colors = make([]uint8, 3*mesh.NumVertices())
+
+	var buf bytes.Buffer
+	if err := EncodeDAE(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		UpAxis  string `xml:"asset>up_axis"`
+		Sources []struct {
+			ID     string `xml:"id,attr"`
+			Floats string `xml:"float_array"`
+		} `xml:"library_geometries>geometry>mesh>source"`
+		Triangles struct {
+			Count int    `xml:"count,attr"`
+			P     string `xml:"p"`
+		} `xml:"library_geometries>geometry>mesh>triangles"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.UpAxis != "Z_UP" || len(doc.Sources) != 2 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	positions := strings.Fields(doc.Sources[0].Floats)
+	if len(positions) != len(mesh.Vertices) {
+		t.Fatalf("got %d position values, want %d", len(positions), len(mesh.Vertices))
+	}
+	if z, _ := strconv.ParseFloat(positions[2], 32); float32(z) != float32(mesh.Vertices[2]) {
+		t.Errorf("first height %v, want %v", z, mesh.Vertices[2])
+	}
+	if doc.Triangles.Count != mesh.NumTriangles() || len(strings.Fields(doc.Triangles.P)) != len(mesh.Triangles) {
+		t.Errorf("bad triangles: count %d", doc.Triangles.Count)
+	}
+}
@@ -0,0 +1,142 @@
+package martini
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// sampleColor bilinearly samples img at grid position (x, y), mapping the
+// grid's corners onto the image's corner pixels.
+func sampleColor(img image.Image, gridSize int, x, y float64) (uint8, uint8, uint8) {
+	rect := img.Bounds()
+	scale := float64(gridSize - 1)
+	px := x / scale * float64(rect.Dx()-1)
+	py := y / scale * float64(rect.Dy()-1)
+	ix := clampInt(int(math.Floor(px)), 0, rect.Dx()-1)
+	iy := clampInt(int(math.Floor(py)), 0, rect.Dy()-1)
+	ix1 := clampInt(ix+1, 0, rect.Dx()-1)
+	iy1 := clampInt(iy+1, 0, rect.Dy()-1)
+	fx, fy := px-float64(ix), py-float64(iy)
+
+	var out [3]float64
+	for _, s := range []struct {
+		x, y int
+		w    float64
+	}{
+		{ix, iy, (1 - fx) * (1 - fy)},
+		{ix1, iy, fx * (1 - fy)},
+		{ix, iy1, (1 - fx) * fy},
+		{ix1, iy1, fx * fy},
+	} {
+		r, g, b, _ := img.At(rect.Min.X+s.x, rect.Min.Y+s.y).RGBA()
+		out[0] += s.w * float64(r>>8)
+		out[1] += s.w * float64(g>>8)
+		out[2] += s.w * float64(b>>8)
+	}
+	return uint8(math.Round(out[0])), uint8(math.Round(out[1])), uint8(math.Round(out[2]))
+}
+
+// ColorStop is the color of a ColorRamp at a height.
+type ColorStop struct {
+	Height float64
+	Color  color.RGBA
+}
+
+// ColorRamp colors vertices by height, interpolating between stops sorted
+// by height and clamping beyond the first and last.
+type ColorRamp struct {
+	Stops []ColorStop
+	// Relative makes stop heights fractions of the height range of each
+	// mesh, 0 at its lowest vertex and 1 at its highest.
+	Relative bool
+}
+
+// HypsometricRamp tints heights in meters from deep water to snow.
+var HypsometricRamp = &ColorRamp{Stops: []ColorStop{
+	{-1000, color.RGBA{8, 48, 107, 255}},
+	{0, color.RGBA{107, 174, 214, 255}},
+	{1, color.RGBA{56, 128, 54, 255}},
+	{500, color.RGBA{170, 190, 90, 255}},
+	{1500, color.RGBA{150, 110, 60, 255}},
+	{3000, color.RGBA{140, 140, 140, 255}},
+	{5000, color.RGBA{255, 255, 255, 255}},
+}}
+
+// ReliefRamp spreads green to white over the height range of each mesh,
+// for terrain in unknown units.
+var ReliefRamp = &ColorRamp{Relative: true, Stops: []ColorStop{
+	{0, color.RGBA{56, 128, 54, 255}},
+	{0.4, color.RGBA{170, 190, 90, 255}},
+	{0.75, color.RGBA{150, 110, 60, 255}},
+	{1, color.RGBA{255, 255, 255, 255}},
+}}
+
+// At returns the color at height h, in the units of the stops.
+func (r *ColorRamp) At(h float64) color.RGBA {
+	stops := r.Stops
+	if len(stops) == 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	i := 0
+	for i < len(stops) && stops[i].Height < h {
+		i++
+	}
+	if i == 0 {
+		return stops[0].Color
+	}
+	if i == len(stops) {
+		return stops[i-1].Color
+	}
+	a, b := stops[i-1], stops[i]
+	f := (h - a.Height) / (b.Height - a.Height)
+	mix := func(p, q uint8) uint8 {
+		return uint8(math.Round(float64(p) + f*(float64(q)-float64(p))))
+	}
+	return color.RGBA{mix(a.Color.R, b.Color.R), mix(a.Color.G, b.Color.G), mix(a.Color.B, b.Color.B), mix(a.Color.A, b.Color.A)}
+}
+
+// ColorByHeight replaces the colors of m with r applied to the height of
+// every vertex, read from the up axis of axes.
+func (m *Mesh) ColorByHeight(r *ColorRamp, axes Axes) {
+	up := axes.up()
+	min, max := math.Inf(1), math.Inf(-1)
+	for i := up; i < len(m.Vertices); i += 3 {
+		min, max = math.Min(min, m.Vertices[i]), math.Max(max, m.Vertices[i])
+	}
+	colors := make([]uint8, 3*m.NumVertices())
+	for i := 0; i < m.NumVertices(); i++ {
+		h := m.Vertices[3*i+up]
+		if r.Relative {
+			h = 0
+			if max > min {
+				h = (m.Vertices[3*i+up] - min) / (max - min)
+			}
+		}
+		c := r.At(h)
+		colors[3*i], colors[3*i+1], colors[3*i+2] = c.R, c.G, c.B
+	}
+	m.Colors = colors
+}
+
+// WithColorRamp returns a Format that encodes like f a copy of each mesh
+// colored by ColorByHeight, for formats storing vertex colors such as GLB
+// and PLY.
+func (f *Format) WithColorRamp(r *ColorRamp, axes Axes) *Format {
+	colored := func(m *Mesh) *Mesh {
+		c := *m
+		c.ColorByHeight(r, axes)
+		return &c
+	}
+	out := *f
+	out.Encode = func(w io.Writer, m *Mesh) error {
+		return f.Encode(w, colored(m))
+	}
+	if f.EncodeMeta != nil {
+		out.EncodeMeta = func(w io.Writer, m *Mesh, meta *TileMeta) error {
+			return f.EncodeMeta(w, colored(m), meta)
+		}
+	}
+	return &out
+}
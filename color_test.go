@@ -0,0 +1,92 @@
+package martini
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestOrthophotoColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 5, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			img.Set(x, y, color.RGBA{uint8(50 * x), uint8(50 * y), 7, 255})
+		}
+	}
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	tile.Orthophoto = img
+	mesh := tile.ToMesh(5)
+	if len(mesh.Colors) != 3*mesh.NumVertices() {
+		t.Fatalf("expected a color per vertex, got %d values", len(mesh.Colors))
+	}
+	for i := 0; i < mesh.NumVertices(); i++ {
+		x, y, _ := mesh.Vertex(i)
+		want := []uint8{uint8(x / 16 * 200), uint8(y / 16 * 200), 7}
+		got := mesh.Colors[3*i : 3*i+3]
+		for c := range want {
+			if d := int(got[c]) - int(want[c]); d < -1 || d > 1 {
+				t.Fatalf("vertex (%v, %v): got %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestColorRamp(t *testing.T) {
+	r := &ColorRamp{Stops: []ColorStop{
+		{0, color.RGBA{0, 0, 0, 255}},
+		{100, color.RGBA{200, 100, 0, 255}},
+	}}
+	for _, c := range []struct {
+		h    float64
+		want color.RGBA
+	}{
+		{-5, color.RGBA{0, 0, 0, 255}},
+		{50, color.RGBA{100, 50, 0, 255}},
+		{100, color.RGBA{200, 100, 0, 255}},
+		{1e6, color.RGBA{200, 100, 0, 255}},
+	} {
+		if got := r.At(c.h); got != c.want {
+			t.Errorf("At(%v) = %v, want %v", c.h, got, c.want)
+		}
+	}
+
+	mesh := &Mesh{Vertices: []float64{0, 0, 10, 1, 0, 20, 0, 1, 30}, Triangles: []uint32{0, 1, 2}}
+	mesh.ColorByHeight(&ColorRamp{Relative: true, Stops: []ColorStop{
+		{0, color.RGBA{0, 0, 0, 255}},
+		{1, color.RGBA{200, 100, 0, 255}},
+	}}, AxesZUp)
+	if !bytes.Equal(mesh.Colors, []uint8{0, 0, 0, 100, 50, 0, 200, 100, 0}) {
+		t.Errorf("relative colors %v", mesh.Colors)
+	}
+
+	mesh.Colors = nil
+	var buf bytes.Buffer
+	if err := FormatPLY.WithColorRamp(HypsometricRamp, AxesZUp).Encode(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("property uchar red\n")) || mesh.Colors != nil {
+		t.Error("expected colors in the output only")
+	}
+}
+
+func TestViewTilesKeepVertexData(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	tile.AddAttribute("class", testTerrain(17, func(x, y int) float64 { return float64(x + y) }))
+	tile.Orthophoto = image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	pinned, _ := tile.ToMeshPinned(5, [][2]int{{3, 3}})
+	for name, mesh := range map[string]*Mesh{
+		"bathymetry": tile.ToMeshBathymetry(5, 20, 0),
+		"rings":      tile.ToMeshRings(8, 8, []LODRing{{Radius: 4, MaxError: 1}}, 20),
+		"edges":      tile.ToMeshEdges(5, map[Edge]float64{EdgeNorth: 1}),
+		"pinned":     pinned,
+	} {
+		n := mesh.NumVertices()
+		if len(mesh.Attributes) != 1 || len(mesh.Attributes[0].Values) != n || len(mesh.Colors) != 3*n {
+			t.Errorf("%s: %d attributes and %d color values for %d vertices", name, len(mesh.Attributes), len(mesh.Colors), n)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package martini
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// Compression selects how stored tiles are pre-compressed. Servers must
+// then send the matching Content-Encoding, as Cesium terrain servers do for
+// quantized-mesh tiles. Only gzip is offered, as the standard library has
+// no Brotli encoder.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+)
+
+// ContentEncoding returns the HTTP Content-Encoding of c, empty for
+// CompressionNone.
+func (c Compression) ContentEncoding() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	}
+	return ""
+}
+
+// CompressTile compresses encoded tile data.
+func CompressTile(data []byte, c Compression) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	}
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressTile undoes CompressTile. Data without the gzip magic is
+// returned unchanged.
+func DecompressTile(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(zr)
+}
@@ -0,0 +1,42 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressTile(t *testing.T) {
+	data := bytes.Repeat([]byte("martini"), 100)
+	z, err := CompressTile(data, CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) >= len(data) {
+		t.Errorf("expected compression, got %d bytes", len(z))
+	}
+	got, err := DecompressTile(z)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("round trip failed: %v", err)
+	}
+	if got, _ := DecompressTile(data); !bytes.Equal(got, data) {
+		t.Error("expected plain data to pass through")
+	}
+
+	sink := &DirSink{Dir: testDir(t), Extension: "terrain", Compression: CompressionGzip}
+	if err := sink.WriteTile(context.Background(), TileID{1, 0, 1}, data); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := ioutil.ReadFile(filepath.Join(sink.Dir, "1", "0", "1.terrain"))
+	if got, _ := DecompressTile(stored); !bytes.Equal(stored, z) || !bytes.Equal(got, data) {
+		t.Error("expected the stored tile to be gzipped")
+	}
+	var meta map[string]string
+	raw, _ := ioutil.ReadFile(filepath.Join(sink.Dir, "metadata.json"))
+	if err := json.Unmarshal(raw, &meta); err != nil || meta["contentEncoding"] != "gzip" {
+		t.Errorf("unexpected metadata %s", raw)
+	}
+}
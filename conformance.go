@@ -0,0 +1,106 @@
+package martini
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ReferenceMesh is the output of getMesh of the JavaScript martini stored
+// as JSON, the form of the conformance fixtures in tests/: vertices as x, y
+// pairs and triangles as three vertex indices each.
+type ReferenceMesh struct {
+	GridSize  int      `json:"gridSize"`
+	MaxError  float64  `json:"maxError"`
+	Vertices  []uint16 `json:"vertices"`
+	Triangles []uint32 `json:"triangles"`
+}
+
+func ReadReferenceMesh(r io.Reader) (*ReferenceMesh, error) {
+	var m ReferenceMesh
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Mesh converts the reference to a Mesh with zero heights.
+func (r *ReferenceMesh) Mesh() *Mesh {
+	mesh := &Mesh{
+		Vertices:  make([]float64, len(r.Vertices)/2*3),
+		Triangles: append([]uint32(nil), r.Triangles...),
+	}
+	for i := 0; i < len(r.Vertices)/2; i++ {
+		mesh.Vertices[3*i] = float64(r.Vertices[2*i])
+		mesh.Vertices[3*i+1] = float64(r.Vertices[2*i+1])
+	}
+	return mesh
+}
+
+// Comparison counts the differences between two meshes found by
+// CompareMeshes.
+type Comparison struct {
+	MissingVertices, ExtraVertices   int
+	MissingTriangles, ExtraTriangles int
+}
+
+func (c Comparison) Equal() bool {
+	return c == Comparison{}
+}
+
+type xy struct{ x, y float64 }
+
+// CompareMeshes compares got against want as sets of vertex positions in x
+// and y and of triangles with the same winding, ignoring heights, vertex
+// order and which vertex each triangle starts at. Meshes that compare equal
+// describe the same triangulation however they are numbered.
+func CompareMeshes(got, want *Mesh) Comparison {
+	var c Comparison
+	c.ExtraVertices, c.MissingVertices = diffCounts(vertexSet(got), vertexSet(want))
+	c.ExtraTriangles, c.MissingTriangles = diffCounts(triangleSet(got), triangleSet(want))
+	return c
+}
+
+func vertexSet(m *Mesh) map[interface{}]int {
+	set := make(map[interface{}]int)
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, _ := m.Vertex(i)
+		set[xy{x, y}]++
+	}
+	return set
+}
+
+func triangleSet(m *Mesh) map[interface{}]int {
+	set := make(map[interface{}]int)
+	for t := 0; t+2 < len(m.Triangles); t += 3 {
+		var p [3]xy
+		for k := range p {
+			x, y, _ := m.Vertex(int(m.Triangles[t+k]))
+			p[k] = xy{x, y}
+		}
+		// Rotate the smallest vertex first, keeping the winding.
+		first := 0
+		for k := 1; k < 3; k++ {
+			if p[k].x < p[first].x || p[k].x == p[first].x && p[k].y < p[first].y {
+				first = k
+			}
+		}
+		set[[3]xy{p[first], p[(first+1)%3], p[(first+2)%3]}]++
+	}
+	return set
+}
+
+// diffCounts returns how many elements a has beyond b and b beyond a.
+func diffCounts(a, b map[interface{}]int) (int, int) {
+	var extra, missing int
+	for k, n := range a {
+		if d := n - b[k]; d > 0 {
+			extra += d
+		}
+	}
+	for k, n := range b {
+		if d := n - a[k]; d > 0 {
+			missing += d
+		}
+	}
+	return extra, missing
+}
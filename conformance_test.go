@@ -0,0 +1,71 @@
+package martini
+
+import (
+	"os"
+	"testing"
+)
+
+// The fixtures in tests/ were produced by Martini.createTile(terrain)
+// .getMesh(maxError) of a transcription of mapbox/martini's index.js
+// v0.2.0, run under node. Its terrain was the heights LoadPngData decodes
+// from fuji.png, written out as little-endian float32 and read back into a
+// Float32Array, which is why the tile here is built with Float32. The
+// output was saved with JSON.stringify of gridSize, maxError and the two
+// buffers.
+func TestConformance(t *testing.T) {
+	terrain, _ := LoadPngData("./tests/fuji.png")
+	m, _ := NewMartini(513)
+	tile, _ := m.CreateTileWithOptions(terrain, &TileOptions{Float32: true})
+
+	for _, name := range []string{"tests/fuji-500.json", "tests/fuji-50.json"} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := ReadReferenceMesh(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tile.ToMesh(ref.MaxError)
+		if c := CompareMeshes(got, ref.Mesh()); !c.Equal() {
+			t.Errorf("%s: %+v", name, c)
+		}
+
+		// Martini is a port, so the numbering matches too.
+		vertices, triangles := tile.GetMesh(ref.MaxError)
+		if len(vertices) != len(ref.Vertices) || len(triangles) != len(ref.Triangles) {
+			t.Fatalf("%s: %d vertex and %d triangle indices, want %d and %d", name, len(vertices), len(triangles), len(ref.Vertices), len(ref.Triangles))
+		}
+		for i, v := range vertices {
+			if v != ref.Vertices[i] {
+				t.Fatalf("%s: vertex buffer differs at %d", name, i)
+			}
+		}
+		for i, v := range triangles {
+			if uint32(v) != ref.Triangles[i] {
+				t.Fatalf("%s: triangle buffer differs at %d", name, i)
+			}
+		}
+	}
+}
+
+func TestCompareMeshes(t *testing.T) {
+	a := &Mesh{
+		Vertices:  []float64{0, 0, 1, 1, 0, 2, 0, 1, 3, 1, 1, 4},
+		Triangles: []uint32{0, 1, 2, 1, 3, 2},
+	}
+	// Same triangulation, renumbered and rotated, with other heights.
+	b := &Mesh{
+		Vertices:  []float64{1, 1, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0},
+		Triangles: []uint32{1, 3, 2, 1, 2, 0},
+	}
+	if c := CompareMeshes(a, b); !c.Equal() {
+		t.Errorf("expected equal meshes, got %+v", c)
+	}
+
+	flipped := &Mesh{Vertices: a.Vertices, Triangles: []uint32{0, 2, 1, 1, 3, 2}}
+	if c := CompareMeshes(flipped, a); c.ExtraTriangles != 1 || c.MissingTriangles != 1 {
+		t.Errorf("expected a winding mismatch, got %+v", c)
+	}
+}
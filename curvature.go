@@ -0,0 +1,27 @@
+package martini
+
+import "math"
+
+// curvatureBias returns weight times the largest absolute second
+// difference at every sample, halved so that it is in the same units as
+// the error of a leaf triangle. Samples on the border use the directions
+// that fit inside the grid.
+func curvatureBias(terrain []float64, gridSize int, weight float64) []float64 {
+	bias := make([]float64, len(terrain))
+	dirs := [4][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}}
+	for y := 0; y < gridSize; y++ {
+		for x := 0; x < gridSize; x++ {
+			h := terrain[y*gridSize+x]
+			var k float64
+			for _, d := range dirs {
+				x0, y0, x1, y1 := x-d[0], y-d[1], x+d[0], y+d[1]
+				if x0 < 0 || y0 < 0 || y1 < 0 || x1 >= gridSize || y0 >= gridSize || y1 >= gridSize {
+					continue
+				}
+				k = math.Max(k, math.Abs(terrain[y0*gridSize+x0]+terrain[y1*gridSize+x1]-2*h)/2)
+			}
+			bias[y*gridSize+x] = weight * k
+		}
+	}
+	return bias
+}
@@ -0,0 +1,52 @@
+package martini
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCurvature(t *testing.T) {
+	// A gentle plane with a low sharp ridge along x = 20.
+	terrain := testTerrain(33, func(x, y int) float64 {
+		h := float64(x+y) * 2
+		if x == 20 {
+			h += 0.5
+		}
+		return h
+	})
+	m, _ := NewMartini(33)
+
+	plain, _ := m.CreateTileWithOptions(terrain, nil)
+	if plain.ToMesh(1).NumTriangles() != 2 {
+		t.Fatalf("expected the ridge to vanish without curvature, got %d triangles", plain.ToMesh(1).NumTriangles())
+	}
+
+	tile, err := m.CreateTileWithOptions(terrain, &TileOptions{Curvature: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh := tile.ToMesh(1)
+	onRidge := 0
+	for i := 0; i < mesh.NumVertices(); i++ {
+		if x, _, _ := mesh.Vertex(i); x == 20 {
+			onRidge++
+		}
+	}
+	if onRidge < 33 {
+		t.Errorf("expected every ridge sample to be kept, got %d", onRidge)
+	}
+
+	flat := testTerrain(33, func(x, y int) float64 { return float64(x) })
+	if tile, _ := m.CreateTileWithOptions(flat, &TileOptions{Curvature: 10}); tile.ToMesh(0).NumTriangles() != 2 {
+		t.Error("expected a plane to stay two triangles")
+	}
+}
+
+func TestBatchCurvature(t *testing.T) {
+	id := TileID{1, 0, 0}
+	plain, _, _ := generateTile(context.Background(), testSource(), mustMartini(t, 17), id, BatchOptions{GridSize: 17, MaxError: 5})
+	curved, _, _ := generateTile(context.Background(), testSource(), mustMartini(t, 17), id, BatchOptions{GridSize: 17, MaxError: 5, TileOptions: &TileOptions{Curvature: 100}})
+	if curved.NumTriangles() <= plain.NumTriangles() {
+		t.Errorf("expected curvature to refine batch tiles: %d vs %d", curved.NumTriangles(), plain.NumTriangles())
+	}
+}
@@ -0,0 +1,423 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// Delatin triangulates a height grid of any size by greedy insertion: it
+// starts from two triangles and repeatedly inserts the sample with the
+// largest error, keeping the mesh Delaunay. It typically needs noticeably
+// fewer triangles than RTIN for the same error, at a higher cost.
+//
+// This is a port of https://github.com/mapbox/delatin.
+type Delatin struct {
+	Data          []float64
+	Width, Height int
+	// Coords holds x, y pairs per vertex and Triangles three vertex indices
+	// per triangle.
+	Coords    []int
+	Triangles []int
+
+	halfedges    []int
+	candidates   []int
+	queueIndices []int
+	queue        []int
+	errors       []float64
+	rms          []float64
+	pending      []int
+	rmsSum       float64
+}
+
+func NewDelatin(data []float64, width, height int) (*Delatin, error) {
+	if width < 2 || height < 2 || len(data) != width*height {
+		return nil, errors.New("Expected width*height samples of at least 2x2")
+	}
+	d := &Delatin{Data: data, Width: width, Height: height}
+	x1, y1 := width-1, height-1
+	p0 := d.addPoint(0, 0)
+	p1 := d.addPoint(x1, 0)
+	p2 := d.addPoint(0, y1)
+	p3 := d.addPoint(x1, y1)
+	t0 := d.addTriangle(p3, p0, p2, -1, -1, -1, -1)
+	d.addTriangle(p0, p3, p1, t0, -1, -1, -1)
+	d.flush()
+	return d, nil
+}
+
+// Run refines the mesh until its maximum error is at most maxError, or
+// until there is no point left to insert.
+func (d *Delatin) Run(maxError float64) error {
+	if !(maxError >= 0) {
+		return errors.New("Expected a non-negative maxError")
+	}
+	for len(d.queue) > 0 && d.MaxError() > maxError {
+		d.Refine()
+	}
+	return nil
+}
+
+// Refine inserts a single point. It does nothing once there is no point
+// left to insert.
+func (d *Delatin) Refine() {
+	if len(d.queue) == 0 {
+		return
+	}
+	d.step()
+	d.flush()
+}
+
+// MaxError returns the maximum error of the current mesh.
+func (d *Delatin) MaxError() float64 {
+	if len(d.errors) == 0 {
+		return 0
+	}
+	return d.errors[0]
+}
+
+// RMSD returns the root-mean-square deviation of the current mesh.
+func (d *Delatin) RMSD() float64 {
+	if d.rmsSum <= 0 {
+		return 0
+	}
+	return math.Sqrt(d.rmsSum / float64(d.Width*d.Height))
+}
+
+func (d *Delatin) HeightAt(x, y int) float64 {
+	return d.Data[d.Width*y+x]
+}
+
+// ToMesh returns the current triangulation lifted to the sample heights.
+func (d *Delatin) ToMesh() *Mesh {
+	mesh := &Mesh{
+		Vertices:  make([]float64, len(d.Coords)/2*3),
+		Triangles: make([]uint32, len(d.Triangles)),
+	}
+	for i := 0; i < len(d.Coords)/2; i++ {
+		x, y := d.Coords[2*i], d.Coords[2*i+1]
+		mesh.Vertices[3*i] = float64(x)
+		mesh.Vertices[3*i+1] = float64(y)
+		mesh.Vertices[3*i+2] = d.HeightAt(x, y)
+	}
+	for i, v := range d.Triangles {
+		mesh.Triangles[i] = uint32(v)
+	}
+	return mesh
+}
+
+// flush rasterizes and queues the triangles added or updated by step.
+func (d *Delatin) flush() {
+	for _, t := range d.pending {
+		a := 2 * d.Triangles[3*t]
+		b := 2 * d.Triangles[3*t+1]
+		c := 2 * d.Triangles[3*t+2]
+		d.findCandidate(d.Coords[a], d.Coords[a+1], d.Coords[b], d.Coords[b+1], d.Coords[c], d.Coords[c+1], t)
+	}
+	d.pending = d.pending[:0]
+}
+
+// findCandidate rasterizes a triangle, finds its largest error and queues it.
+func (d *Delatin) findCandidate(p0x, p0y, p1x, p1y, p2x, p2y, t int) {
+	minX := minInt(p0x, minInt(p1x, p2x))
+	minY := minInt(p0y, minInt(p1y, p2y))
+	maxX := maxInt(p0x, maxInt(p1x, p2x))
+	maxY := maxInt(p0y, maxInt(p1y, p2y))
+
+	// Forward differencing of the edge functions.
+	w00 := orient2(p1x, p1y, p2x, p2y, minX, minY)
+	w01 := orient2(p2x, p2y, p0x, p0y, minX, minY)
+	w02 := orient2(p0x, p0y, p1x, p1y, minX, minY)
+	a01, b01 := p1y-p0y, p0x-p1x
+	a12, b12 := p2y-p1y, p1x-p2x
+	a20, b20 := p0y-p2y, p2x-p0x
+
+	// Heights premultiplied by the inverse of the doubled area.
+	a := float64(orient2(p0x, p0y, p1x, p1y, p2x, p2y))
+	z0 := d.HeightAt(p0x, p0y) / a
+	z1 := d.HeightAt(p1x, p1y) / a
+	z2 := d.HeightAt(p2x, p2y) / a
+
+	var maxError, rms float64
+	mx, my := 0, 0
+	for y := minY; y <= maxY; y++ {
+		dx := 0
+		if w00 < 0 && a12 != 0 {
+			dx = maxInt(dx, int(math.Floor(float64(-w00)/float64(a12))))
+		}
+		if w01 < 0 && a20 != 0 {
+			dx = maxInt(dx, int(math.Floor(float64(-w01)/float64(a20))))
+		}
+		if w02 < 0 && a01 != 0 {
+			dx = maxInt(dx, int(math.Floor(float64(-w02)/float64(a01))))
+		}
+		w0 := w00 + a12*dx
+		w1 := w01 + a20*dx
+		w2 := w02 + a01*dx
+
+		wasInside := false
+		for x := minX + dx; x <= maxX; x++ {
+			if w0 >= 0 && w1 >= 0 && w2 >= 0 {
+				wasInside = true
+				z := z0*float64(w0) + z1*float64(w1) + z2*float64(w2)
+				dz := math.Abs(z - d.HeightAt(x, y))
+				rms += dz * dz
+				if dz > maxError {
+					maxError, mx, my = dz, x, y
+				}
+			} else if wasInside {
+				break
+			}
+			w0 += a12
+			w1 += a20
+			w2 += a01
+		}
+		w00 += b12
+		w01 += b20
+		w02 += b01
+	}
+
+	if mx == p0x && my == p0y || mx == p1x && my == p1y || mx == p2x && my == p2y {
+		maxError = 0
+	}
+
+	d.candidates[2*t] = mx
+	d.candidates[2*t+1] = my
+	d.rms[t] = rms
+	d.queuePush(t, maxError, rms)
+}
+
+// step splits the triangle with the largest error at its candidate point.
+func (d *Delatin) step() {
+	t := d.queuePop()
+	e0, e1, e2 := 3*t, 3*t+1, 3*t+2
+	p0, p1, p2 := d.Triangles[e0], d.Triangles[e1], d.Triangles[e2]
+
+	ax, ay := d.Coords[2*p0], d.Coords[2*p0+1]
+	bx, by := d.Coords[2*p1], d.Coords[2*p1+1]
+	cx, cy := d.Coords[2*p2], d.Coords[2*p2+1]
+	px, py := d.candidates[2*t], d.candidates[2*t+1]
+
+	pn := d.addPoint(px, py)
+
+	switch {
+	case orient2(ax, ay, bx, by, px, py) == 0:
+		d.handleCollinear(pn, e0)
+	case orient2(bx, by, cx, cy, px, py) == 0:
+		d.handleCollinear(pn, e1)
+	case orient2(cx, cy, ax, ay, px, py) == 0:
+		d.handleCollinear(pn, e2)
+	default:
+		h0, h1, h2 := d.halfedges[e0], d.halfedges[e1], d.halfedges[e2]
+		t0 := d.addTriangle(p0, p1, pn, h0, -1, -1, e0)
+		t1 := d.addTriangle(p1, p2, pn, h1, -1, t0+1, -1)
+		t2 := d.addTriangle(p2, p0, pn, h2, t0+2, t1+1, -1)
+		d.legalize(t0)
+		d.legalize(t1)
+		d.legalize(t2)
+	}
+}
+
+func (d *Delatin) addPoint(x, y int) int {
+	i := len(d.Coords) / 2
+	d.Coords = append(d.Coords, x, y)
+	return i
+}
+
+// addTriangle adds a triangle, or replaces the one starting at halfedge e
+// when e is not negative, and returns its first halfedge.
+func (d *Delatin) addTriangle(a, b, c, ab, bc, ca, e int) int {
+	if e < 0 {
+		e = len(d.Triangles)
+		d.Triangles = append(d.Triangles, 0, 0, 0)
+		d.halfedges = append(d.halfedges, 0, 0, 0)
+		d.candidates = append(d.candidates, 0, 0)
+		d.queueIndices = append(d.queueIndices, 0)
+		d.rms = append(d.rms, 0)
+	}
+	t := e / 3
+
+	d.Triangles[e], d.Triangles[e+1], d.Triangles[e+2] = a, b, c
+	d.halfedges[e], d.halfedges[e+1], d.halfedges[e+2] = ab, bc, ca
+	if ab >= 0 {
+		d.halfedges[ab] = e
+	}
+	if bc >= 0 {
+		d.halfedges[bc] = e + 1
+	}
+	if ca >= 0 {
+		d.halfedges[ca] = e + 2
+	}
+
+	d.candidates[2*t], d.candidates[2*t+1] = 0, 0
+	d.queueIndices[t] = -1
+	d.rms[t] = 0
+	d.pending = append(d.pending, t)
+	return e
+}
+
+// legalize flips the edge at halfedge a, and recursively the edges it
+// exposes, while the pair of triangles sharing it is not Delaunay.
+func (d *Delatin) legalize(a int) {
+	b := d.halfedges[a]
+	if b < 0 {
+		return
+	}
+	a0, b0 := a-a%3, b-b%3
+	al, ar := a0+(a+1)%3, a0+(a+2)%3
+	bl, br := b0+(b+2)%3, b0+(b+1)%3
+	p0, pr, pl, p1 := d.Triangles[ar], d.Triangles[a], d.Triangles[al], d.Triangles[bl]
+	c := d.Coords
+	if !inCircle(c[2*p0], c[2*p0+1], c[2*pr], c[2*pr+1], c[2*pl], c[2*pl+1], c[2*p1], c[2*p1+1]) {
+		return
+	}
+
+	hal, har := d.halfedges[al], d.halfedges[ar]
+	hbl, hbr := d.halfedges[bl], d.halfedges[br]
+	d.queueRemove(a0 / 3)
+	d.queueRemove(b0 / 3)
+
+	t0 := d.addTriangle(p0, p1, pl, -1, hbl, hal, a0)
+	t1 := d.addTriangle(p1, p0, pr, t0, har, hbr, b0)
+	d.legalize(t0 + 1)
+	d.legalize(t1 + 2)
+}
+
+// handleCollinear splits the triangles on both sides of halfedge a at a new
+// vertex pn lying on it.
+func (d *Delatin) handleCollinear(pn, a int) {
+	a0 := a - a%3
+	al, ar := a0+(a+1)%3, a0+(a+2)%3
+	p0, pr, pl := d.Triangles[ar], d.Triangles[a], d.Triangles[al]
+	hal, har := d.halfedges[al], d.halfedges[ar]
+
+	b := d.halfedges[a]
+	if b < 0 {
+		t0 := d.addTriangle(pn, p0, pr, -1, har, -1, a0)
+		t1 := d.addTriangle(p0, pn, pl, t0, -1, hal, -1)
+		d.legalize(t0 + 1)
+		d.legalize(t1 + 2)
+		return
+	}
+
+	b0 := b - b%3
+	bl, br := b0+(b+2)%3, b0+(b+1)%3
+	p1 := d.Triangles[bl]
+	hbl, hbr := d.halfedges[bl], d.halfedges[br]
+	d.queueRemove(b0 / 3)
+
+	t0 := d.addTriangle(p0, pr, pn, har, -1, -1, a0)
+	t1 := d.addTriangle(pr, p1, pn, hbr, -1, t0+1, b0)
+	t2 := d.addTriangle(p1, pl, pn, hbl, -1, t1+1, -1)
+	t3 := d.addTriangle(pl, p0, pn, hal, t0+2, t2+1, -1)
+	d.legalize(t0)
+	d.legalize(t1)
+	d.legalize(t2)
+	d.legalize(t3)
+}
+
+// The queue is a binary max-heap of triangles keyed by error.
+
+func (d *Delatin) queuePush(t int, err, rms float64) {
+	i := len(d.queue)
+	d.queueIndices[t] = i
+	d.queue = append(d.queue, t)
+	d.errors = append(d.errors, err)
+	d.rmsSum += rms
+	d.queueUp(i)
+}
+
+func (d *Delatin) queuePop() int {
+	n := len(d.queue) - 1
+	d.queueSwap(0, n)
+	d.queueDown(0, n)
+	return d.queuePopBack()
+}
+
+func (d *Delatin) queuePopBack() int {
+	n := len(d.queue) - 1
+	t := d.queue[n]
+	d.queue = d.queue[:n]
+	d.errors = d.errors[:n]
+	d.rmsSum -= d.rms[t]
+	d.queueIndices[t] = -1
+	return t
+}
+
+func (d *Delatin) queueRemove(t int) {
+	i := d.queueIndices[t]
+	if i < 0 {
+		for k, p := range d.pending {
+			if p == t {
+				last := len(d.pending) - 1
+				d.pending[k] = d.pending[last]
+				d.pending = d.pending[:last]
+				return
+			}
+		}
+		panic("martini: broken delatin triangulation")
+	}
+	n := len(d.queue) - 1
+	if n != i {
+		d.queueSwap(i, n)
+		if !d.queueDown(i, n) {
+			d.queueUp(i)
+		}
+	}
+	d.queuePopBack()
+}
+
+func (d *Delatin) queueLess(i, j int) bool {
+	return d.errors[i] > d.errors[j]
+}
+
+func (d *Delatin) queueSwap(i, j int) {
+	pi, pj := d.queue[i], d.queue[j]
+	d.queue[i], d.queue[j] = pj, pi
+	d.queueIndices[pi], d.queueIndices[pj] = j, i
+	d.errors[i], d.errors[j] = d.errors[j], d.errors[i]
+}
+
+func (d *Delatin) queueUp(j int) {
+	for j > 0 {
+		i := (j - 1) / 2
+		if !d.queueLess(j, i) {
+			break
+		}
+		d.queueSwap(i, j)
+		j = i
+	}
+}
+
+func (d *Delatin) queueDown(i0, n int) bool {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n {
+			break
+		}
+		j := j1
+		if j2 := j1 + 1; j2 < n && d.queueLess(j2, j1) {
+			j = j2
+		}
+		if !d.queueLess(j, i) {
+			break
+		}
+		d.queueSwap(i, j)
+		i = j
+	}
+	return i > i0
+}
+
+func orient2(ax, ay, bx, by, cx, cy int) int {
+	return (bx-cx)*(ay-cy) - (by-cy)*(ax-cx)
+}
+
+func inCircle(ax, ay, bx, by, cx, cy, px, py int) bool {
+	dx, dy := float64(ax-px), float64(ay-py)
+	ex, ey := float64(bx-px), float64(by-py)
+	fx, fy := float64(cx-px), float64(cy-py)
+	ap := dx*dx + dy*dy
+	bp := ex*ex + ey*ey
+	cp := fx*fx + fy*fy
+	return dx*(ey*cp-bp*fy)-dy*(ex*cp-bp*fx)+ap*(ex*fy-ey*fx) < 0
+}
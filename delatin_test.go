@@ -0,0 +1,77 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDelatin(t *testing.T) {
+	terrain, _ := LoadPngData("./tests/fuji.png")
+	size := 513
+	d, err := NewDelatin(terrain, size, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Run(10); err != nil {
+		t.Fatal(err)
+	}
+	if d.MaxError() > 10 {
+		t.Errorf("expected max error at most 10, got %v", d.MaxError())
+	}
+
+	mesh := d.ToMesh()
+	if r := Validate(mesh); len(r.DegenerateTriangles) > 0 || len(r.NonManifoldEdges) > 0 || len(r.OutOfRangeTriangles) > 0 {
+		t.Fatalf("invalid mesh: %+v", r)
+	}
+	for _, e := range triangleErrors(mesh, terrain, size) {
+		if e > 10+1e-6 {
+			t.Fatalf("triangle error %v above 10", e)
+		}
+	}
+
+	m, _ := NewMartini(size)
+	tile, _ := m.CreateTile(terrain)
+	if rtin := tile.ToMesh(10).NumTriangles(); mesh.NumTriangles() >= rtin {
+		t.Errorf("expected fewer triangles than RTIN: %d vs %d", mesh.NumTriangles(), rtin)
+	}
+}
+
+func TestDelatinRectangular(t *testing.T) {
+	terrain := make([]float64, 40*23)
+	for i := range terrain {
+		terrain[i] = hills(i%40, i/40)
+	}
+	d, err := NewDelatin(terrain, 40, 23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	if mesh := d.ToMesh(); mesh.NumVertices() != 40*23 {
+		t.Errorf("expected every sample as a vertex, got %d", mesh.NumVertices())
+	}
+	if d.MaxError() != 0 || d.RMSD() != 0 {
+		t.Errorf("expected an exact mesh, got max %v rmsd %v", d.MaxError(), d.RMSD())
+	}
+
+	if _, err := NewDelatin(terrain, 41, 23); err == nil {
+		t.Error("expected size mismatch error")
+	}
+}
+
+func TestDelatinRunInvalid(t *testing.T) {
+	d, _ := NewDelatin(make([]float64, 9*9), 9, 9)
+	for _, e := range []float64{-1, math.NaN()} {
+		if err := d.Run(e); err == nil {
+			t.Errorf("expected error for maxError %v", e)
+		}
+	}
+	// A flat grid leaves nothing to refine; Refine must not loop or panic.
+	for i := 0; i < 10; i++ {
+		d.Refine()
+	}
+	if err := d.Run(0); err != nil || d.MaxError() != 0 {
+		t.Errorf("unexpected result %v, max error %v", err, d.MaxError())
+	}
+}
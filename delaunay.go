@@ -0,0 +1,91 @@
+package martini
+
+import "math"
+
+// DelaunayFlip returns a copy of a mesh in its grid frame in which interior
+// edges have been flipped towards the Delaunay condition, giving fatter
+// triangles for physics and FEM consumers. A flip is only made if both new
+// triangles stay within maxError of the terrain the mesh was extracted
+// from, so the error bound of the input still holds.
+func DelaunayFlip(m *Mesh, terrain []float64, gridSize int, maxError float64) *Mesh {
+	out := &Mesh{
+		Vertices:   m.Vertices,
+		Triangles:  append([]uint32(nil), m.Triangles...),
+		Attributes: m.Attributes,
+		Colors:     m.Colors,
+	}
+	tris := out.Triangles
+	point := func(v uint32) [3]float64 {
+		x, y, z := out.Vertex(int(v))
+		return [3]float64{x, y, z}
+	}
+	withinError := func(a, b, c uint32) bool {
+		ok := true
+		triangleSamples([3][3]float64{point(a), point(b), point(c)}, gridSize, func(i int, z float64) {
+			if math.Abs(terrain[i]-z) > maxError {
+				ok = false
+			}
+		})
+		return ok
+	}
+
+	type edge struct{ a, b uint32 }
+	for pass := 0; pass < 100; pass++ {
+		// Map each directed edge to the triangle and corner it starts at.
+		edges := make(map[edge]int, len(tris))
+		for i := range tris {
+			edges[edge{tris[i], tris[i-i%3+(i+1)%3]}] = i
+		}
+		touched := make([]bool, len(tris)/3)
+		flips := 0
+		for i := range tris {
+			t := i / 3
+			a, b, c := tris[i], tris[t*3+(i+1)%3], tris[t*3+(i+2)%3]
+			j, ok := edges[edge{b, a}]
+			if !ok || touched[t] || touched[j/3] {
+				continue
+			}
+			u := j / 3
+			d := tris[u*3+(j+2)%3]
+			pa, pb, pc, pd := point(a), point(b), point(c), point(d)
+			sign := orient3(pa, pb, pc)
+			if sign == 0 || !inCircumcircle(pa, pb, pc, pd, sign) {
+				continue
+			}
+			// The quad is convex iff both new triangles keep the winding.
+			if orient3(pc, pa, pd)*sign <= 0 || orient3(pd, pb, pc)*sign <= 0 {
+				continue
+			}
+			if !withinError(c, a, d) || !withinError(d, b, c) {
+				continue
+			}
+			tris[t*3], tris[t*3+1], tris[t*3+2] = c, a, d
+			tris[u*3], tris[u*3+1], tris[u*3+2] = d, b, c
+			touched[t], touched[u] = true, true
+			flips++
+		}
+		if flips == 0 {
+			break
+		}
+	}
+	return out
+}
+
+func orient3(a, b, c [3]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// inCircumcircle reports whether p lies strictly inside the circumcircle
+// of the triangle abc whose orientation has the given sign.
+func inCircumcircle(a, b, c, p [3]float64, sign float64) bool {
+	dx, dy := a[0]-p[0], a[1]-p[1]
+	ex, ey := b[0]-p[0], b[1]-p[1]
+	fx, fy := c[0]-p[0], c[1]-p[1]
+	det := (dx*dx+dy*dy)*(ex*fy-ey*fx) -
+		(ex*ex+ey*ey)*(dx*fy-dy*fx) +
+		(fx*fx+fy*fy)*(dx*ey-dy*ex)
+	if sign < 0 {
+		det = -det
+	}
+	return det > 1e-9
+}
@@ -0,0 +1,66 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDelaunayFlip(t *testing.T) {
+	terrain := testTerrain(65, hills)
+	m, _ := NewMartini(65)
+	tile, _ := m.CreateTile(terrain)
+	mesh := tile.ToMesh(20)
+	flipped := DelaunayFlip(mesh, terrain, 65, 20)
+
+	if flipped.NumTriangles() != mesh.NumTriangles() {
+		t.Fatal("expected the same number of triangles")
+	}
+	if r := Validate(flipped); !r.Valid() {
+		t.Fatalf("invalid mesh: %+v", r)
+	}
+	// RTIN bounds the error at hypotenuse midpoints only, so the input may
+	// already exceed maxError somewhere; flips must not make it worse.
+	bound := 20.0
+	for _, e := range triangleErrors(mesh, terrain, 65) {
+		bound = math.Max(bound, e)
+	}
+	for _, e := range triangleErrors(flipped, terrain, 65) {
+		if e > bound+1e-9 {
+			t.Fatalf("flip broke the error bound: %v > %v", e, bound)
+		}
+	}
+	if minAngle(flipped) < minAngle(mesh) {
+		t.Errorf("expected the smallest angle not to shrink: %v < %v", minAngle(flipped), minAngle(mesh))
+	}
+
+	// A thin quad with a long diagonal is flipped to the short one.
+	quad := &Mesh{
+		Vertices:  []float64{0, 1, 0, 4, 0, 0, 8, 1, 0, 4, 2, 0},
+		Triangles: []uint32{0, 1, 2, 0, 2, 3},
+	}
+	flat := make([]float64, 9*9)
+	out := DelaunayFlip(quad, flat, 9, 0)
+	if out.Triangles[0] == 0 && out.Triangles[1] == 1 && out.Triangles[2] == 2 {
+		t.Errorf("expected the long diagonal to be flipped, got %v", out.Triangles)
+	}
+	if quad.Triangles[0] != 0 || quad.Triangles[2] != 2 {
+		t.Error("expected the input to be left untouched")
+	}
+}
+
+func minAngle(m *Mesh) float64 {
+	min := 4.0
+	for i := 0; i < len(m.Triangles); i += 3 {
+		for k := 0; k < 3; k++ {
+			ax, ay, _ := m.Vertex(int(m.Triangles[i+k]))
+			bx, by, _ := m.Vertex(int(m.Triangles[i+(k+1)%3]))
+			cx, cy, _ := m.Vertex(int(m.Triangles[i+(k+2)%3]))
+			ux, uy, vx, vy := bx-ax, by-ay, cx-ax, cy-ay
+			cos := (ux*vx + uy*vy) / (math.Hypot(ux, uy) * math.Hypot(vx, vy))
+			if a := math.Acos(cos); a < min {
+				min = a
+			}
+		}
+	}
+	return min
+}
@@ -0,0 +1,68 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// ChangeStats summarizes the difference between two terrain epochs.
+// Volumes are in grid cells times terrain units.
+type ChangeStats struct {
+	// Changed is the number of samples whose height moved by more than
+	// the threshold given to Diff.
+	Changed int
+	// Fraction is Changed over the number of samples.
+	Fraction float64
+	// MaxRise and MaxDrop are the largest increase and decrease, both
+	// non-negative.
+	MaxRise, MaxDrop float64
+	// Fill and Cut are the volumes gained and lost over the changed
+	// samples.
+	Fill, Cut float64
+	// Bounds is the grid rectangle MinX, MinY, MaxX, MaxY enclosing the
+	// changed samples, all -1 when nothing changed.
+	Bounds [4]int
+}
+
+// Diff meshes the difference surface after - before of two terrains on
+// m's grid, such as LiDAR surveys before and after construction, at
+// maxError. Vertex heights are the height change. Samples that moved by
+// more than threshold are counted as changed in the returned statistics.
+func (m *Martini) Diff(before, after []float64, maxError, threshold float64) (*Mesh, ChangeStats, error) {
+	size := m.GridSize
+	stats := ChangeStats{Bounds: [4]int{-1, -1, -1, -1}}
+	if len(before) != size*size || len(after) != size*size {
+		return nil, stats, errors.New("Expected both terrains of length gridSize*gridSize")
+	}
+	diff := make([]float64, len(before))
+	for i := range diff {
+		d := after[i] - before[i]
+		diff[i] = d
+		if math.Abs(d) <= threshold {
+			continue
+		}
+		stats.Changed++
+		if d > 0 {
+			stats.Fill += d
+			stats.MaxRise = math.Max(stats.MaxRise, d)
+		} else {
+			stats.Cut -= d
+			stats.MaxDrop = math.Max(stats.MaxDrop, -d)
+		}
+		x, y := i%size, i/size
+		if stats.Bounds[0] < 0 {
+			stats.Bounds = [4]int{x, y, x, y}
+		}
+		stats.Bounds[0] = minInt(stats.Bounds[0], x)
+		stats.Bounds[1] = minInt(stats.Bounds[1], y)
+		stats.Bounds[2] = maxInt(stats.Bounds[2], x)
+		stats.Bounds[3] = maxInt(stats.Bounds[3], y)
+	}
+	stats.Fraction = float64(stats.Changed) / float64(len(diff))
+
+	tile, err := m.CreateTile(diff)
+	if err != nil {
+		return nil, stats, err
+	}
+	return tile.ToMesh(maxError), stats, nil
+}
@@ -0,0 +1,41 @@
+package martini
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	m, _ := NewMartini(33)
+	before := testTerrain(33, hills)
+	after := testTerrain(33, func(x, y int) float64 {
+		h := hills(x, y)
+		switch {
+		case x >= 4 && x < 8 && y >= 10 && y < 12:
+			h += 5 // a new building
+		case x >= 20 && x < 22 && y >= 20 && y < 23:
+			h -= 3 // an excavation
+		}
+		return h
+	})
+
+	mesh, stats, err := m.Diff(before, after, 0.5, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Changed != 14 || stats.Fill != 40 || stats.Cut != 18 || stats.MaxRise != 5 || stats.MaxDrop != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.Bounds != [4]int{4, 10, 21, 22} {
+		t.Errorf("bounds = %v", stats.Bounds)
+	}
+	for i := 0; i < mesh.NumVertices(); i++ {
+		if x, y, z := mesh.Vertex(i); z != after[int(y)*33+int(x)]-before[int(y)*33+int(x)] {
+			t.Fatalf("vertex %d height %v is not the difference", i, z)
+		}
+	}
+	if tile, _ := m.CreateTile(before); mesh.NumTriangles() >= tile.ToMesh(0.5).NumTriangles() {
+		t.Errorf("expected the difference surface to be simpler than the terrain")
+	}
+
+	if _, _, err := m.Diff(before, after[:10], 1, 0); err == nil {
+		t.Error("expected error for mismatched terrains")
+	}
+}
@@ -0,0 +1,110 @@
+package martini
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// ContentKey hashes everything that determines a generated mesh: the terrain
+// samples, the grid size, maxError and the extraction options.
+func ContentKey(terrain []float64, gridSize int, maxError float64, opts *MeshOptions) string {
+	return diskCacheKey(terrain, gridSize, maxError, nil, opts, nil)
+}
+
+// diskCacheKey is ContentKey including the Mesher, like OptionsKey, and the
+// TileOptions that change the mesh without changing the prepared terrain.
+func diskCacheKey(terrain []float64, gridSize int, maxError float64, mesher Mesher, opts *MeshOptions, tileOpts *TileOptions) string {
+	h := sha256.New()
+	var buf [8]byte
+	put := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	h.Write([]byte("martini-mesh-v2"))
+	put(uint64(gridSize))
+	put(math.Float64bits(maxError))
+	if opts != nil {
+		flip := uint64(0)
+		if opts.FlipY {
+			flip = 1
+		}
+		put(uint64(opts.Winding))
+		put(uint64(opts.Axes))
+		put(flip)
+	}
+	if mesher != nil {
+		h.Write([]byte(mesherKey(mesher)))
+	}
+	if tileOpts != nil && tileOpts.Curvature > 0 {
+		h.Write([]byte("curvature"))
+		put(math.Float64bits(tileOpts.Curvature))
+	}
+	if tileOpts != nil && tileOpts.Float32 {
+		h.Write([]byte("float32"))
+	}
+	for _, v := range terrain {
+		put(math.Float64bits(v))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DiskCache persists meshes in a directory, keyed by ContentKey. Entries are
+// spread over 256 subdirectories and written atomically, so several
+// processes may share one cache directory.
+type DiskCache struct {
+	Dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".mesh")
+}
+
+// Get returns the cached mesh for key. A missing entry is not an error.
+func (c *DiskCache) Get(key string) (*Mesh, bool, error) {
+	f, err := os.Open(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	mesh, err := ReadMesh(f)
+	if err != nil {
+		return nil, false, err
+	}
+	return mesh, true, nil
+}
+
+func (c *DiskCache) Put(key string, mesh *Mesh) error {
+	p := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(mesh.Marshal()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
@@ -0,0 +1,53 @@
+package martini
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContentKey(t *testing.T) {
+	terrain := testTerrain(17, hills)
+	k := ContentKey(terrain, 17, 5, nil)
+	if k != ContentKey(terrain, 17, 5, nil) {
+		t.Error("expected stable key")
+	}
+	if k == ContentKey(terrain, 17, 6, nil) {
+		t.Error("expected maxError to change the key")
+	}
+	if k == ContentKey(terrain, 17, 5, &MeshOptions{FlipY: true}) {
+		t.Error("expected options to change the key")
+	}
+	changed := append([]float64(nil), terrain...)
+	changed[100]++
+	if k == ContentKey(changed, 17, 5, nil) {
+		t.Error("expected terrain to change the key")
+	}
+	if k == diskCacheKey(terrain, 17, 5, DelatinMesher{}, nil, nil) {
+		t.Error("expected the mesher to change the key")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := NewDiskCache(testDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	key := ContentKey(tile.Terrain, 17, 5, nil)
+
+	if _, ok, err := c.Get(key); ok || err != nil {
+		t.Fatalf("expected miss, got ok=%v err=%v", ok, err)
+	}
+	if err := c.Put(key, mesh); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := c.Get(key)
+	if !ok || err != nil {
+		t.Fatalf("expected hit, got ok=%v err=%v", ok, err)
+	}
+	if !reflect.DeepEqual(got, mesh) {
+		t.Error("cached mesh differs")
+	}
+}
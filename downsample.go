@@ -0,0 +1,68 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// Aggregation combines the samples covered by one coarser sample.
+type Aggregation int
+
+const (
+	AggregateMean Aggregation = iota
+	AggregateMax
+	AggregateMin
+	// AggregateExtreme keeps whichever of the minimum and maximum lies
+	// further from the mean, preserving both summits and valley floors.
+	AggregateExtreme
+)
+
+func (a Aggregation) combine(values []float64) float64 {
+	min, max, sum := math.Inf(1), math.Inf(-1), 0.0
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	switch a {
+	case AggregateMax:
+		return max
+	case AggregateMin:
+		return min
+	case AggregateExtreme:
+		if max-mean >= mean-min {
+			return max
+		}
+		return min
+	}
+	return mean
+}
+
+// Downsample reduces a terrain grid to a smaller 2^n+1 grid. Every output
+// sample aggregates the input samples of its footprint, the square of
+// side (gridSize-1)/(newGridSize-1) centred on it.
+func Downsample(terrain []float64, gridSize, newGridSize int, agg Aggregation) ([]float64, error) {
+	if len(terrain) != gridSize*gridSize {
+		return nil, errors.New("Expected terrain data of length gridSize*gridSize")
+	}
+	if !validGridSize(gridSize) || !validGridSize(newGridSize) || newGridSize > gridSize {
+		return nil, errors.New("Expected grid sizes 2^n+1 with newGridSize <= gridSize")
+	}
+	f := (gridSize - 1) / (newGridSize - 1)
+	half := f / 2
+	out := make([]float64, newGridSize*newGridSize)
+	values := make([]float64, 0, (f+1)*(f+1))
+	for j := 0; j < newGridSize; j++ {
+		for i := 0; i < newGridSize; i++ {
+			values = values[:0]
+			for y := maxInt(j*f-half, 0); y <= minInt(j*f+half, gridSize-1); y++ {
+				for x := maxInt(i*f-half, 0); x <= minInt(i*f+half, gridSize-1); x++ {
+					values = append(values, terrain[y*gridSize+x])
+				}
+			}
+			out[j*newGridSize+i] = agg.combine(values)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package martini
+
+import "testing"
+
+func TestDownsample(t *testing.T) {
+	terrain := make([]float64, 17*17)
+	terrain[5*17+7] = 1000 // summit
+	terrain[12*17+3] = -500
+
+	for agg, want := range map[Aggregation][2]float64{
+		AggregateMax:     {1000, 0},
+		AggregateMin:     {0, -500},
+		AggregateExtreme: {1000, -500},
+	} {
+		out, err := Downsample(terrain, 17, 5, agg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Footprints are 5x5 around (8,4) and (4,12).
+		if out[1*5+2] != want[0] || out[3*5+1] != want[1] {
+			t.Errorf("aggregation %d: got %v, %v want %v", agg, out[1*5+2], out[3*5+1], want)
+		}
+	}
+
+	out, _ := Downsample(terrain, 17, 5, AggregateMean)
+	if out[1*5+2] != 40 {
+		t.Errorf("expected mean 40, got %v", out[1*5+2])
+	}
+
+	same, _ := Downsample(terrain, 17, 17, AggregateMax)
+	for i := range same {
+		if same[i] != terrain[i] {
+			t.Fatal("expected identity for equal grid sizes")
+		}
+	}
+	if _, err := Downsample(terrain, 17, 33, AggregateMax); err == nil {
+		t.Error("expected error when upsampling")
+	}
+}
@@ -0,0 +1,49 @@
+package martini
+
+import "math"
+
+// edgeTile returns a view of t where samples on a tile edge listed in
+// edges use its maxError, the stricter one at corners, and all others use
+// maxError.
+func (t *Tile) edgeTile(maxError float64, edges map[Edge]float64) *Tile {
+	size := t.Martini.GridSize
+	max := size - 1
+	thresholds := make([]float64, len(t.Terrain))
+	for i := range thresholds {
+		thresholds[i] = maxError
+	}
+	for e, v := range edges {
+		for k := 0; k < size; k++ {
+			var i int
+			switch e {
+			case EdgeNorth:
+				i = k
+			case EdgeSouth:
+				i = max*size + k
+			case EdgeWest:
+				i = k * size
+			case EdgeEast:
+				i = k*size + max
+			}
+			if k == 0 || k == max {
+				thresholds[i] = math.Min(thresholds[i], v)
+			} else {
+				thresholds[i] = v
+			}
+		}
+	}
+	return t.thresholdTile(thresholds)
+}
+
+// GetMeshEdges is GetMesh with a separate maxError for the samples on the
+// given tile edges. Setting a neighbour's stricter maxError on the shared
+// edge brings the boundary vertices of both tiles into near agreement, which
+// shrinks cracks without stitching.
+func (t *Tile) GetMeshEdges(maxError float64, edges map[Edge]float64) ([]uint16, []uint16) {
+	return t.edgeTile(maxError, edges).GetMesh(1)
+}
+
+// ToMeshEdges is ToMesh with per-edge maxError overrides; see GetMeshEdges.
+func (t *Tile) ToMeshEdges(maxError float64, edges map[Edge]float64) *Mesh {
+	return t.edgeTile(maxError, edges).ToMesh(1)
+}
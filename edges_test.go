@@ -0,0 +1,47 @@
+package martini
+
+import "testing"
+
+// edgeMismatch counts vertices on edge e of a that b lacks on the opposite
+// edge, and vice versa.
+func edgeMismatch(a, b *Mesh, e Edge, gridSize int) int {
+	size := float64(gridSize - 1)
+	ts := map[float64]int{}
+	for _, v := range collectEdge(a, e, size) {
+		ts[v.t]++
+	}
+	for _, v := range collectEdge(b, e.Opposite(), size) {
+		ts[v.t]--
+	}
+	n := 0
+	for _, c := range ts {
+		if c != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMeshEdges(t *testing.T) {
+	m, _ := NewMartini(65)
+	west, _ := m.CreateTile(testTerrain(65, hills))
+	east, _ := m.CreateTile(testTerrain(65, func(x, y int) float64 { return hills(x+64, y) }))
+	neighbour := east.ToMesh(1)
+
+	plain := edgeMismatch(west.ToMesh(20), neighbour, EdgeEast, 65)
+	strict := edgeMismatch(west.ToMeshEdges(20, map[Edge]float64{EdgeEast: 1}), neighbour, EdgeEast, 65)
+	if strict >= plain {
+		t.Errorf("expected fewer mismatched boundary vertices: %d with override, %d without", strict, plain)
+	}
+
+	mesh := west.ToMeshEdges(20, map[Edge]float64{EdgeEast: 1, EdgeNorth: 0})
+	if n := tJunctions(mesh); n != 0 {
+		t.Errorf("%d T-junctions", n)
+	}
+	if got := len(collectEdge(mesh, EdgeNorth, 64)); got != 65 {
+		t.Errorf("expected every north edge sample at zero error, got %d", got)
+	}
+	if got, ref := west.ToMeshEdges(7, nil), west.ToMesh(7); !CompareMeshes(got, ref).Equal() {
+		t.Error("expected no overrides to match ToMesh")
+	}
+}
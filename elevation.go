@@ -0,0 +1,198 @@
+package martini
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Encoding identifies how elevation is packed into RGB pixels.
+type Encoding int
+
+const (
+	// EncodingTerrainRGB is Mapbox Terrain-RGB:
+	// height = (R*256*256 + G*256 + B)/10 - 10000.
+	EncodingTerrainRGB Encoding = iota
+	// EncodingTerrarium is Mapzen Terrarium:
+	// height = R*256 + G + B/256 - 32768.
+	EncodingTerrarium
+)
+
+// RGB returns the channel layout of a predefined encoding.
+func (e Encoding) RGB() *RGBEncoding {
+	if e == EncodingTerrarium {
+		return &RGBEncoding{Channels: []int{0, 1, 2}, BitDepth: 8, Scale: 1.0 / 256, Offset: -32768}
+	}
+	return &RGBEncoding{Channels: []int{0, 1, 2}, BitDepth: 8, Scale: 0.1, Offset: -10000}
+}
+
+// RGBEncoding describes an elevation packed into image channels as an
+// unsigned integer: height = value*Scale + Offset. Channels lists the
+// channels (0 red, 1 green, 2 blue, 3 alpha) from most to least
+// significant, each holding BitDepth bits (8 or 16).
+type RGBEncoding struct {
+	Channels []int
+	BitDepth int
+	Scale    float64
+	Offset   float64
+}
+
+func (e *RGBEncoding) validate() error {
+	if len(e.Channels) == 0 || (e.BitDepth != 8 && e.BitDepth != 16) || len(e.Channels)*e.BitDepth > 64 || e.Scale == 0 {
+		return errors.New("Expected 1 to 4 channels of 8 or 16 bits and a non-zero scale")
+	}
+	for _, c := range e.Channels {
+		if c < 0 || c > 3 {
+			return errors.New("Expected channel indices between 0 and 3")
+		}
+	}
+	return nil
+}
+
+// pixel returns the non-premultiplied 16 bit channels of a pixel.
+func pixel(img image.Image, x, y int) [4]uint32 {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		p := img.Pix[img.PixOffset(x, y):]
+		return [4]uint32{uint32(p[0]) * 0x101, uint32(p[1]) * 0x101, uint32(p[2]) * 0x101, uint32(p[3]) * 0x101}
+	case *image.NRGBA64:
+		p := img.Pix[img.PixOffset(x, y):]
+		var c [4]uint32
+		for i := range c {
+			c[i] = uint32(p[2*i])<<8 | uint32(p[2*i+1])
+		}
+		return c
+	}
+	c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+	return [4]uint32{uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)}
+}
+
+func (e *RGBEncoding) decode(c [4]uint32) float64 {
+	var v uint64
+	for _, ch := range e.Channels {
+		s := c[ch]
+		if e.BitDepth == 8 {
+			s >>= 8
+		}
+		v = v<<uint(e.BitDepth) | uint64(s)
+	}
+	return float64(v)*e.Scale + e.Offset
+}
+
+// encode packs a height into the nearest representable value, clamping to
+// the encoding's range. NaN is encoded as zero.
+func (e *RGBEncoding) encode(h float64) [4]uint32 {
+	if math.IsNaN(h) {
+		h = 0
+	}
+	bits := uint(len(e.Channels) * e.BitDepth)
+	max := math.Ldexp(1, int(bits)) - 1
+	n := math.Max(0, math.Min(math.Round((h-e.Offset)/e.Scale), max))
+	v := uint64(n)
+	if n >= max {
+		v = uint64(1)<<bits - 1
+	}
+
+	c := [4]uint32{3: 0xffff}
+	mask := uint64(1)<<uint(e.BitDepth) - 1
+	for i := len(e.Channels) - 1; i >= 0; i-- {
+		s := uint32(v & mask)
+		if e.BitDepth == 8 {
+			s *= 0x101
+		}
+		c[e.Channels[i]] = s
+		v >>= uint(e.BitDepth)
+	}
+	return c
+}
+
+// Decode unpacks an elevation image into a terrain grid like
+// DecodeElevation.
+func (e *RGBEncoding) Decode(img image.Image, gridSize int) ([]float64, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	rect := img.Bounds()
+	if rect.Dx() != rect.Dy() || (rect.Dx() != gridSize && rect.Dx() != gridSize-1) {
+		return nil, errors.New("Expected elevation image of gridSize or gridSize-1 pixels")
+	}
+	terrain := make([]float64, gridSize*gridSize)
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			terrain[y*gridSize+x] = e.decode(pixel(img, rect.Min.X+x, rect.Min.Y+y))
+		}
+	}
+	if rect.Dx() == gridSize-1 {
+		backfill(terrain, gridSize)
+	}
+	return terrain, nil
+}
+
+// Encode packs a terrain grid into a gridSize pixel square image: an
+// *image.NRGBA for 8 bit channels and an *image.NRGBA64 for 16 bit ones.
+// Channels not used by the encoding are zero, except alpha which is opaque.
+func (e *RGBEncoding) Encode(terrain []float64, gridSize int) (draw.Image, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	if len(terrain) != gridSize*gridSize {
+		return nil, errors.New("Expected terrain data of length gridSize*gridSize")
+	}
+	rect := image.Rect(0, 0, gridSize, gridSize)
+	if e.BitDepth == 16 {
+		img := image.NewNRGBA64(rect)
+		for i, h := range terrain {
+			c := e.encode(h)
+			img.SetNRGBA64(i%gridSize, i/gridSize, color.NRGBA64{uint16(c[0]), uint16(c[1]), uint16(c[2]), uint16(c[3])})
+		}
+		return img, nil
+	}
+	img := image.NewNRGBA(rect)
+	for i, h := range terrain {
+		c := e.encode(h)
+		img.SetNRGBA(i%gridSize, i/gridSize, color.NRGBA{uint8(c[0] >> 8), uint8(c[1] >> 8), uint8(c[2] >> 8), uint8(c[3] >> 8)})
+	}
+	return img, nil
+}
+
+// EncodeElevation packs a terrain grid into a gridSize pixel square image,
+// the inverse of DecodeElevation. Take the SubImage of the top left
+// gridSize-1 pixels for the usual power-of-two tile sizes.
+func EncodeElevation(terrain []float64, gridSize int, enc Encoding) (*image.NRGBA, error) {
+	img, err := enc.RGB().Encode(terrain, gridSize)
+	if err != nil {
+		return nil, err
+	}
+	return img.(*image.NRGBA), nil
+}
+
+// WriteElevationPNG encodes terrain with EncodeElevation and writes the
+// top left gridSize-1 pixels as a PNG tile.
+func WriteElevationPNG(w io.Writer, terrain []float64, gridSize int, enc Encoding) error {
+	img, err := EncodeElevation(terrain, gridSize, enc)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img.SubImage(image.Rect(0, 0, gridSize-1, gridSize-1)))
+}
+
+// DecodeElevation unpacks an elevation image into a terrain grid. The image
+// must be either gridSize or gridSize-1 pixels square; in the latter case the
+// last row and column are filled by repeating their neighbours.
+func DecodeElevation(img image.Image, enc Encoding, gridSize int) ([]float64, error) {
+	return enc.RGB().Decode(img, gridSize)
+}
+
+// backfill copies the second-to-last row and column into the last ones.
+func backfill(terrain []float64, gridSize int) {
+	for x := 0; x < gridSize-1; x++ {
+		terrain[gridSize*(gridSize-1)+x] = terrain[gridSize*(gridSize-2)+x]
+	}
+	for y := 0; y < gridSize; y++ {
+		terrain[gridSize*y+gridSize-1] = terrain[gridSize*y+gridSize-2]
+	}
+}
@@ -0,0 +1,106 @@
+package martini
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestDecodeElevation(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(0, 0, color.NRGBA{1, 134, 160, 255})
+	img.Set(3, 3, color.NRGBA{128, 0, 128, 255})
+
+	terrain, err := DecodeElevation(img, EncodingTerrainRGB, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terrain[0] != 0 {
+		t.Errorf("expected sea level, got %v", terrain[0])
+	}
+	if terrain[4*5+4] != terrain[3*5+3] || terrain[4*5] != terrain[3*5] {
+		t.Error("expected last row and column to be backfilled")
+	}
+
+	terrain, _ = DecodeElevation(img, EncodingTerrarium, 5)
+	if terrain[3*5+3] != 0.5 {
+		t.Errorf("expected 0.5, got %v", terrain[3*5+3])
+	}
+
+	if _, err := DecodeElevation(img, EncodingTerrainRGB, 17); err == nil {
+		t.Error("expected size mismatch error")
+	}
+}
+
+func TestEncodeElevation(t *testing.T) {
+	terrain := testTerrain(9, func(x, y int) float64 { return hills(x, y) * 10 })
+	for _, enc := range []Encoding{EncodingTerrainRGB, EncodingTerrarium} {
+		img, err := EncodeElevation(terrain, 9, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, _ := DecodeElevation(img, enc, 9)
+		for i, h := range terrain {
+			if math.Abs(decoded[i]-h) > 0.05 {
+				t.Fatalf("encoding %d: sample %d round-tripped %v to %v", enc, i, h, decoded[i])
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteElevationPNG(&buf, terrain, 9, EncodingTerrarium); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, _ := DecodeElevation(img, EncodingTerrarium, 9)
+	if math.Abs(decoded[3*9+5]-terrain[3*9+5]) > 0.01 {
+		t.Errorf("PNG round trip: got %v, want %v", decoded[3*9+5], terrain[3*9+5])
+	}
+
+	if c := EncodingTerrainRGB.RGB().encode(-20000); c[0]|c[1]|c[2] != 0 {
+		t.Error("expected heights below the range to clamp to zero")
+	}
+}
+
+func TestRGBEncoding(t *testing.T) {
+	// Two 16 bit channels, blue then alpha, in millimetres below 1000 m.
+	enc := &RGBEncoding{Channels: []int{2, 3}, BitDepth: 16, Scale: 0.001, Offset: -1000}
+	terrain := testTerrain(9, func(x, y int) float64 { return hills(x, y) * 10 })
+	img, err := enc.Encode(terrain, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*image.NRGBA64); !ok {
+		t.Fatalf("expected a 16 bit image, got %T", img)
+	}
+	decoded, err := enc.Decode(img, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, h := range terrain {
+		if math.Abs(decoded[i]-h) > 0.001 {
+			t.Fatalf("sample %d round-tripped %v to %v", i, h, decoded[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	read, _ := png.Decode(&buf)
+	decoded, _ = enc.Decode(read, 9)
+	if math.Abs(decoded[40]-terrain[40]) > 0.001 {
+		t.Errorf("PNG round trip: got %v, want %v", decoded[40], terrain[40])
+	}
+
+	if _, err := (&RGBEncoding{Channels: []int{4}, BitDepth: 8, Scale: 1}).Decode(img, 9); err == nil {
+		t.Error("expected invalid channel error")
+	}
+	if _, err := (&RGBEncoding{Channels: []int{0}, BitDepth: 12, Scale: 1}).Decode(img, 9); err == nil {
+		t.Error("expected invalid bit depth error")
+	}
+}
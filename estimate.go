@@ -0,0 +1,42 @@
+package martini
+
+import "sort"
+
+// EstimateTriangles returns the number of triangles GetMesh would emit for
+// maxError. It runs only the subdivision test, allocating nothing and
+// leaving the Martini's scratch indices alone, so it may run concurrently
+// with other tiles of the same Martini. Meshes have about half as many
+// vertices as triangles.
+func (t *Tile) EstimateTriangles(maxError float64) int {
+	max := t.Martini.GridSize - 1
+	return t.countTriangles(0, 0, max, max, max, 0, maxError) +
+		t.countTriangles(max, max, 0, 0, 0, max, maxError)
+}
+
+func (t *Tile) countTriangles(ax, ay, bx, by, cx, cy int, maxError float64) int {
+	size := t.Martini.GridSize
+	mx := (ax + bx) >> 1
+	my := (ay + by) >> 1
+	if abs(ax-cx)+abs(ay-cy) > 1 && t.Errors[my*size+mx] > maxError {
+		return t.countTriangles(cx, cy, ax, ay, mx, my, maxError) +
+			t.countTriangles(bx, by, cx, cy, mx, my, maxError)
+	}
+	return 1
+}
+
+// MaxErrorForTriangles returns the smallest maxError whose mesh has at most
+// budget triangles. The count only changes at the values of Errors, so it
+// bisects those.
+func (t *Tile) MaxErrorForTriangles(budget int) float64 {
+	errs := append([]float64(nil), t.Errors...)
+	sort.Float64s(errs)
+	// Find the first error value that, used as maxError, fits the budget.
+	i := sort.Search(len(errs), func(i int) bool {
+		return t.EstimateTriangles(errs[i]) <= budget
+	})
+	if i == len(errs) {
+		// A tile never has fewer than two triangles.
+		i--
+	}
+	return errs[i]
+}
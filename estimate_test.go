@@ -0,0 +1,24 @@
+package martini
+
+import "testing"
+
+func TestEstimateTriangles(t *testing.T) {
+	m, _ := NewMartini(65)
+	tile, _ := m.CreateTile(testTerrain(65, hills))
+	for _, maxError := range []float64{0, 0.5, 2, 10, 1e9} {
+		_, triangles := tile.GetMesh(maxError)
+		if got := tile.EstimateTriangles(maxError); got != len(triangles)/3 {
+			t.Errorf("maxError %v: estimated %d triangles, got %d", maxError, got, len(triangles)/3)
+		}
+	}
+
+	for _, budget := range []int{2, 100, 1000} {
+		e := tile.MaxErrorForTriangles(budget)
+		if n := tile.EstimateTriangles(e); n > budget {
+			t.Errorf("budget %d: maxError %v gives %d triangles", budget, e, n)
+		}
+		if e > 0 && tile.EstimateTriangles(e*0.999) <= budget {
+			t.Errorf("budget %d: maxError %v is not the smallest", budget, e)
+		}
+	}
+}
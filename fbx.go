@@ -0,0 +1,118 @@
+package martini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// fbxAxes gives the FBX GlobalSettings axis system of a mesh laid out with
+// Axes: up, front and coordinate axis with their signs.
+func fbxAxes(a Axes) [6]int {
+	switch a {
+	case AxesYUp:
+		return [6]int{1, 1, 2, 1, 0, 1}
+	case AxesYUpLeftHanded:
+		return [6]int{1, 1, 2, -1, 0, 1}
+	}
+	return [6]int{2, 1, 1, -1, 0, 1}
+}
+
+// EncodeFBX writes m, in the Z-up layout of ToMesh, as an ASCII FBX 7.4
+// file with vertex normals and UVs; see EncodeFBXAxes.
+func EncodeFBX(w io.Writer, m *Mesh) error {
+	return EncodeFBXAxes(w, m, AxesZUp)
+}
+
+// EncodeFBXAxes writes m as an ASCII FBX 7.4 file, which the FBX SDK and
+// the DCC tools and game engines built on it import. The file declares the
+// axis system m was laid out in with MeshOptions.Axes and units of meters,
+// so importers such as Unity convert it to their own conventions. UVs span
+// the mesh extent as in EncodeUSDA.
+func EncodeFBXAxes(w io.Writer, m *Mesh, axes Axes) error {
+	bw := bufio.NewWriter(w)
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	array := func(indent, name string, n int, item func(i int) string) {
+		fmt.Fprintf(bw, "%s%s: *%d {\n%s\ta: ", indent, name, n, indent)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(item(i))
+		}
+		fmt.Fprintf(bw, "\n%s}\n", indent)
+	}
+	up := axes.up()
+	// u and v run along the two horizontal axes, v northwards, against
+	// grid y.
+	h0, h1 := 0, 1
+	if up == 1 {
+		h1 = 2
+	}
+	min0, min1, max0, max1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := 0; i < m.NumVertices(); i++ {
+		min0, max0 = math.Min(min0, m.Vertices[3*i+h0]), math.Max(max0, m.Vertices[3*i+h0])
+		min1, max1 = math.Min(min1, m.Vertices[3*i+h1]), math.Max(max1, m.Vertices[3*i+h1])
+	}
+	span0, span1 := math.Max(max0-min0, 1e-300), math.Max(max1-min1, 1e-300)
+	uv := func(i int) (float64, float64) {
+		u := (m.Vertices[3*i+h0] - min0) / span0
+		v := (m.Vertices[3*i+h1] - min1) / span1
+		if axes == AxesZUp || axes == AxesYUpLeftHanded {
+			v = 1 - v
+		}
+		return u, v
+	}
+	normals := vertexNormals(m, up)
+	ax := fbxAxes(axes)
+
+	fmt.Fprintf(bw, "; FBX 7.4.0 project file\n; generated by go-martini\n\n")
+	fmt.Fprintf(bw, "FBXHeaderExtension:  {\n\tFBXHeaderVersion: 1003\n\tFBXVersion: 7400\n\tCreator: \"go-martini\"\n}\n")
+	fmt.Fprintf(bw, "GlobalSettings:  {\n\tVersion: 1000\n\tProperties70:  {\n")
+	for i, name := range []string{"UpAxis", "UpAxisSign", "FrontAxis", "FrontAxisSign", "CoordAxis", "CoordAxisSign"} {
+		fmt.Fprintf(bw, "\t\tP: \"%s\", \"int\", \"Integer\", \"\",%d\n", name, ax[i])
+	}
+	fmt.Fprintf(bw, "\t\tP: \"OriginalUpAxis\", \"int\", \"Integer\", \"\",%d\n\t\tP: \"OriginalUpAxisSign\", \"int\", \"Integer\", \"\",1\n", ax[0])
+	// FBX units are centimeters.
+	fmt.Fprintf(bw, "\t\tP: \"UnitScaleFactor\", \"double\", \"Number\", \"\",100\n\t\tP: \"OriginalUnitScaleFactor\", \"double\", \"Number\", \"\",100\n\t}\n}\n")
+	fmt.Fprintf(bw, "Definitions:  {\n\tVersion: 100\n\tCount: 3\n\tObjectType: \"GlobalSettings\" {\n\t\tCount: 1\n\t}\n\tObjectType: \"Geometry\" {\n\t\tCount: 1\n\t}\n\tObjectType: \"Model\" {\n\t\tCount: 1\n\t}\n}\n")
+
+	fmt.Fprintf(bw, "Objects:  {\n\tGeometry: 1000, \"Geometry::Terrain\", \"Mesh\" {\n")
+	array("\t\t", "Vertices", len(m.Vertices), func(i int) string { return f(m.Vertices[i]) })
+	array("\t\t", "PolygonVertexIndex", len(m.Triangles), func(i int) string {
+		v := int64(m.Triangles[i])
+		if i%3 == 2 {
+			// The last index of a polygon is stored as its complement.
+			v = ^v
+		}
+		return strconv.FormatInt(v, 10)
+	})
+	fmt.Fprintf(bw, "\t\tGeometryVersion: 124\n")
+	fmt.Fprintf(bw, "\t\tLayerElementNormal: 0 {\n\t\t\tVersion: 101\n\t\t\tName: \"\"\n\t\t\tMappingInformationType: \"ByVertice\"\n\t\t\tReferenceInformationType: \"Direct\"\n")
+	array("\t\t\t", "Normals", 3*len(normals), func(i int) string { return f(normals[i/3][i%3]) })
+	fmt.Fprintf(bw, "\t\t}\n\t\tLayerElementUV: 0 {\n\t\t\tVersion: 101\n\t\t\tName: \"UVMap\"\n\t\t\tMappingInformationType: \"ByVertice\"\n\t\t\tReferenceInformationType: \"Direct\"\n")
+	array("\t\t\t", "UV", 2*m.NumVertices(), func(i int) string {
+		u, v := uv(i / 2)
+		if i%2 == 0 {
+			return f(u)
+		}
+		return f(v)
+	})
+	fmt.Fprintf(bw, "\t\t}\n\t\tLayer: 0 {\n\t\t\tVersion: 100\n")
+	fmt.Fprintf(bw, "\t\t\tLayerElement:  {\n\t\t\t\tType: \"LayerElementNormal\"\n\t\t\t\tTypedIndex: 0\n\t\t\t}\n")
+	fmt.Fprintf(bw, "\t\t\tLayerElement:  {\n\t\t\t\tType: \"LayerElementUV\"\n\t\t\t\tTypedIndex: 0\n\t\t\t}\n\t\t}\n\t}\n")
+	fmt.Fprintf(bw, "\tModel: 2000, \"Model::Terrain\", \"Mesh\" {\n\t\tVersion: 232\n\t\tShading: T\n\t\tCulling: \"CullingOff\"\n\t}\n}\n")
+	fmt.Fprintf(bw, "Connections:  {\n\tC: \"OO\",1000,2000\n\tC: \"OO\",2000,0\n}\n")
+	return bw.Flush()
+}
+
+var FormatFBX = &Format{
+	Name:        "fbx",
+	Extension:   "fbx",
+	ContentType: "application/octet-stream",
+	Encode:      EncodeFBX,
+}
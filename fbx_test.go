@@ -0,0 +1,43 @@
+package martini
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeFBX(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+
+	for _, axes := range []Axes{AxesZUp, AxesYUp, AxesYUpLeftHanded} {
+		mesh := tile.ToMeshWithOptions(5, &MeshOptions{Axes: axes})
+		var buf bytes.Buffer
+		if err := EncodeFBXAxes(&buf, mesh, axes); err != nil {
+			t.Fatal(err)
+		}
+		fbx := buf.String()
+		if !strings.HasPrefix(fbx, "; FBX 7.4.0 project file") || strings.Count(fbx, "{") != strings.Count(fbx, "}") {
+			t.Fatalf("malformed FBX:\n%s", fbx)
+		}
+		upAxis := map[Axes]string{AxesZUp: `"UpAxis", "int", "Integer", "",2`, AxesYUp: `"UpAxis", "int", "Integer", "",1`, AxesYUpLeftHanded: `"UpAxis", "int", "Integer", "",1`}[axes]
+		for _, want := range []string{upAxis, `"UnitScaleFactor", "double", "Number", "",100`, "Vertices: *" + strconv.Itoa(len(mesh.Vertices)), "Normals: *" + strconv.Itoa(len(mesh.Vertices)), "UV: *" + strconv.Itoa(2*mesh.NumVertices()), `C: "OO",2000,0`} {
+			if !strings.Contains(fbx, want) {
+				t.Errorf("axes %d: missing %q", axes, want)
+			}
+		}
+
+		start := strings.Index(fbx, "PolygonVertexIndex")
+		line := strings.SplitN(fbx[start:], "\n", 3)[1]
+		indices := strings.Split(strings.TrimPrefix(strings.TrimSpace(line), "a: "), ",")
+		if len(indices) != len(mesh.Triangles) {
+			t.Fatalf("got %d indices, want %d", len(indices), len(mesh.Triangles))
+		}
+		for i, s := range indices {
+			if neg := strings.HasPrefix(s, "-"); neg != (i%3 == 2) {
+				t.Fatalf("index %d = %s: polygon ends must be negative", i, s)
+			}
+		}
+	}
+}
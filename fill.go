@@ -0,0 +1,161 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// FillMethod selects how FillHoles interpolates void samples.
+type FillMethod int
+
+const (
+	FillNone FillMethod = iota
+	// FillIDW weights the nearest valid sample in each of the eight grid
+	// directions by inverse squared distance.
+	FillIDW
+	// FillLaplacian solves for the smoothest surface matching the hole
+	// boundary, starting from the IDW estimate.
+	FillLaplacian
+)
+
+// FillReport summarizes a FillHoles run.
+type FillReport struct {
+	Voids  int
+	Filled int
+}
+
+var fillDirections = [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// FillHoles replaces void samples in place. NaN is always void; noData, when
+// not nil, marks additional voids. Voids with no valid sample along any of
+// the eight directions take the value of the nearest valid sample.
+func FillHoles(terrain []float64, gridSize int, noData *float64, method FillMethod) (FillReport, error) {
+	var report FillReport
+	if len(terrain) != gridSize*gridSize {
+		return report, errors.New("Expected terrain data of length gridSize*gridSize")
+	}
+	void := make([]bool, len(terrain))
+	var voids []int
+	for i, v := range terrain {
+		if math.IsNaN(v) || (noData != nil && v == *noData) {
+			void[i] = true
+			voids = append(voids, i)
+		}
+	}
+	report.Voids = len(voids)
+	if report.Voids == 0 || method == FillNone {
+		return report, nil
+	}
+	if report.Voids == len(terrain) {
+		return report, errors.New("Terrain has no valid samples")
+	}
+
+	// Inverse distance weighting along eight rays.
+	filled := make([]float64, len(voids))
+	unreachable := false
+	for k, i := range voids {
+		x, y := i%gridSize, i/gridSize
+		var sum, total float64
+		for _, d := range fillDirections {
+			for step := 1; ; step++ {
+				nx, ny := x+d[0]*step, y+d[1]*step
+				if nx < 0 || ny < 0 || nx >= gridSize || ny >= gridSize {
+					break
+				}
+				if j := ny*gridSize + nx; !void[j] {
+					dist2 := float64(step*step) * float64(d[0]*d[0]+d[1]*d[1])
+					sum += terrain[j] / dist2
+					total += 1 / dist2
+					break
+				}
+			}
+		}
+		if total > 0 {
+			filled[k] = sum / total
+		} else {
+			filled[k] = math.NaN()
+			unreachable = true
+		}
+	}
+	for k, i := range voids {
+		terrain[i] = filled[k]
+	}
+	if unreachable {
+		fillNearest(terrain, gridSize, void)
+	}
+
+	if method == FillLaplacian {
+		relax(terrain, gridSize, voids)
+	}
+	for _, i := range voids {
+		if !math.IsNaN(terrain[i]) && !math.IsInf(terrain[i], 0) {
+			report.Filled++
+		}
+	}
+	return report, nil
+}
+
+// fillNearest sets the void samples that are still NaN to the value of the
+// nearest valid sample, found by a breadth-first search from all of them.
+func fillNearest(terrain []float64, gridSize int, void []bool) {
+	source := make([]int32, len(terrain))
+	queue := make([]int, 0, len(terrain))
+	for i := range terrain {
+		source[i] = -1
+		if !void[i] {
+			source[i] = int32(i)
+			queue = append(queue, i)
+		}
+	}
+	for head := 0; head < len(queue); head++ {
+		i := queue[head]
+		x, y := i%gridSize, i/gridSize
+		for _, d := range fillDirections {
+			nx, ny := x+d[0], y+d[1]
+			if nx < 0 || ny < 0 || nx >= gridSize || ny >= gridSize {
+				continue
+			}
+			if j := ny*gridSize + nx; source[j] < 0 {
+				source[j] = source[i]
+				queue = append(queue, j)
+			}
+		}
+	}
+	for i, v := range terrain {
+		if void[i] && math.IsNaN(v) {
+			terrain[i] = terrain[source[i]]
+		}
+	}
+}
+
+// relax runs Gauss-Seidel iterations of the Laplace equation over the void
+// samples, keeping the valid ones fixed. NaN neighbours are left out of the
+// average.
+func relax(terrain []float64, gridSize int, voids []int) {
+	at := func(x, y int) float64 {
+		return terrain[clampInt(y, 0, gridSize-1)*gridSize+clampInt(x, 0, gridSize-1)]
+	}
+	for iter := 0; iter < 10000; iter++ {
+		change := 0.0
+		for _, i := range voids {
+			x, y := i%gridSize, i/gridSize
+			var sum float64
+			n := 0
+			for _, v := range [4]float64{at(x-1, y), at(x+1, y), at(x, y-1), at(x, y+1)} {
+				if !math.IsNaN(v) {
+					sum += v
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			v := sum / float64(n)
+			change = math.Max(change, math.Abs(v-terrain[i]))
+			terrain[i] = v
+		}
+		if change < 1e-6 {
+			return
+		}
+	}
+}
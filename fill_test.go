@@ -0,0 +1,93 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFillHoles(t *testing.T) {
+	plane := func(x, y int) float64 { return 2*float64(x) + float64(y) }
+	noData := -9999.0
+	for _, method := range []FillMethod{FillIDW, FillLaplacian} {
+		terrain := testTerrain(17, plane)
+		for y := 5; y < 9; y++ {
+			for x := 6; x < 10; x++ {
+				terrain[y*17+x] = noData
+			}
+		}
+		terrain[3*17+12] = math.NaN()
+
+		report, err := FillHoles(terrain, 17, &noData, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Voids != 17 || report.Filled != 17 {
+			t.Errorf("method %d: unexpected report %+v", method, report)
+		}
+		tolerance := 2.0
+		if method == FillLaplacian {
+			// Planes are harmonic, so relaxation recovers them.
+			tolerance = 1e-3
+		}
+		for y := 0; y < 17; y++ {
+			for x := 0; x < 17; x++ {
+				if d := math.Abs(terrain[y*17+x] - plane(x, y)); d > tolerance {
+					t.Fatalf("method %d: sample %d,%d off by %v", method, x, y, d)
+				}
+			}
+		}
+	}
+
+	// A single valid sample leaves voids that no ray reaches.
+	for _, method := range []FillMethod{FillIDW, FillLaplacian} {
+		sparse := make([]float64, 25)
+		for i := range sparse {
+			sparse[i] = math.NaN()
+		}
+		sparse[7] = 3
+		report, err := FillHoles(sparse, 5, nil, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report != (FillReport{Voids: 24, Filled: 24}) {
+			t.Errorf("method %d: unexpected report %+v", method, report)
+		}
+		for i, v := range sparse {
+			if v != 3 {
+				t.Fatalf("method %d: sample %d = %v, want 3", method, i, v)
+			}
+		}
+	}
+
+	empty := make([]float64, 9)
+	for i := range empty {
+		empty[i] = math.NaN()
+	}
+	if _, err := FillHoles(empty, 3, nil, FillIDW); err == nil {
+		t.Error("expected error without valid samples")
+	}
+}
+
+func TestTileOptionsFill(t *testing.T) {
+	terrain := testTerrain(17, hills)
+	terrain[40] = math.NaN()
+	m, _ := NewMartini(17)
+	tile, err := m.CreateTileWithOptions(terrain, &TileOptions{Fill: FillIDW})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsNaN(tile.Terrain[40]) || !math.IsNaN(terrain[40]) {
+		t.Error("expected a filled copy of the terrain")
+	}
+	if tile.FillReport != (FillReport{Voids: 1, Filled: 1}) {
+		t.Errorf("unexpected fill report %+v", tile.FillReport)
+	}
+
+	void := make([]float64, 17*17)
+	for i := range void {
+		void[i] = math.NaN()
+	}
+	if _, err := m.CreateTileWithOptions(void, &TileOptions{Fill: FillIDW}); err == nil {
+		t.Error("expected error for terrain without valid samples")
+	}
+}
@@ -0,0 +1,51 @@
+package martini
+
+import "math"
+
+// IsFlat reports whether the heights of a terrain grid vary by at most
+// tolerance. Martini never splits such a tile at maxError >= tolerance:
+// every hypotenuse midpoint lies within the range of its endpoints.
+func IsFlat(terrain []float64, tolerance float64) bool {
+	return heightRange(terrain) <= tolerance
+}
+
+func heightRange(terrain []float64) float64 {
+	if len(terrain) == 0 {
+		return 0
+	}
+	min, max := terrain[0], terrain[0]
+	for _, h := range terrain {
+		min = math.Min(min, h)
+		max = math.Max(max, h)
+	}
+	return max - min
+}
+
+// FlatTileMesh returns the two-triangle mesh that ToMesh produces for a flat
+// tile, without building the error pyramid. Vertices and triangles are in
+// the same order as ToMesh's.
+func FlatTileMesh(terrain []float64, gridSize int) *Mesh {
+	max := gridSize - 1
+	corner := func(x, y int) []float64 {
+		return []float64{float64(x), float64(y), terrain[y*gridSize+x]}
+	}
+	var vertices []float64
+	for _, c := range [][2]int{{0, 0}, {max, max}, {max, 0}, {0, max}} {
+		vertices = append(vertices, corner(c[0], c[1])...)
+	}
+	return &Mesh{Vertices: vertices, Triangles: []uint32{0, 1, 2, 1, 0, 3}}
+}
+
+// flatFastPath reports whether a batch tile can skip the error pyramid.
+// The curvature bias adds error even to flat tiles, and Float32 rounds the
+// errors before they are compared with maxError.
+func flatFastPath(terrain []float64, maxError float64, opts *TileOptions) bool {
+	if opts != nil && opts.Curvature > 0 {
+		return false
+	}
+	r := heightRange(terrain)
+	if opts != nil && opts.Float32 {
+		r = float64(float32(r))
+	}
+	return r <= maxError
+}
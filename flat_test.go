@@ -0,0 +1,36 @@
+package martini
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFlatTileMesh(t *testing.T) {
+	m, _ := NewMartini(33)
+	for _, terrain := range [][]float64{
+		testTerrain(33, func(x, y int) float64 { return 42 }),
+		testTerrain(33, func(x, y int) float64 { return 10 + float64((x*7+y*3)%5)/10 }),
+	} {
+		if !IsFlat(terrain, 0.5) {
+			t.Fatal("expected terrain to be flat")
+		}
+		tile, _ := m.CreateTile(terrain)
+		got, want := FlatTileMesh(terrain, 33), tile.ToMesh(0.5)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FlatTileMesh = %v %v, ToMesh = %v %v", got.Vertices, got.Triangles, want.Vertices, want.Triangles)
+		}
+	}
+	if IsFlat(testTerrain(33, hills), 10) {
+		t.Error("expected hills not to be flat")
+	}
+
+	ocean := TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		return make([]float64, gridSize*gridSize), nil
+	})
+	for r := range ProcessTiles(context.Background(), ocean, []TileID{{0, 0, 0}}, BatchOptions{GridSize: 65, MaxError: 1}) {
+		if r.Err != nil || r.Mesh.NumTriangles() != 2 {
+			t.Errorf("expected a two-triangle ocean tile, got %v %v", r.Mesh, r.Err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package martini
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const flatIdentifier = "MRTF"
+
+// MarshalFlatBuffer encodes the mesh as the martini.Mesh FlatBuffers table
+// of martini.fbs, with float32 vertices and uint32 indices laid out ready
+// for GPU upload. Attributes are not included.
+func (m *Mesh) MarshalFlatBuffer() []byte {
+	nv, nt := m.NumVertices(), m.NumTriangles()
+	colors := len(m.Colors) == 3*nv && nv > 0
+	size := 36 + 4 + 12*nv + 4 + 12*nt
+	if colors {
+		size += 4 + (3*nv+3)/4*4
+	}
+	b := make([]byte, size)
+	le := binary.LittleEndian
+
+	// Root offset, identifier, then the vtable of three fields.
+	le.PutUint32(b[0:], 20)
+	copy(b[4:], flatIdentifier)
+	le.PutUint16(b[8:], 10)
+	le.PutUint16(b[10:], 16)
+	le.PutUint16(b[12:], 4)
+	le.PutUint16(b[14:], 8)
+	if colors {
+		le.PutUint16(b[16:], 12)
+	}
+	// The table at 20 points back to its vtable and forward to the vectors.
+	le.PutUint32(b[20:], 12)
+
+	p := 36
+	le.PutUint32(b[24:], uint32(p-24))
+	le.PutUint32(b[p:], uint32(3*nv))
+	p += 4
+	for _, v := range m.Vertices[:3*nv] {
+		le.PutUint32(b[p:], math.Float32bits(float32(v)))
+		p += 4
+	}
+	le.PutUint32(b[28:], uint32(p-28))
+	le.PutUint32(b[p:], uint32(3*nt))
+	p += 4
+	for _, v := range m.Triangles[:3*nt] {
+		le.PutUint32(b[p:], v)
+		p += 4
+	}
+	if colors {
+		le.PutUint32(b[32:], uint32(p-32))
+		le.PutUint32(b[p:], uint32(len(m.Colors)))
+		copy(b[p+4:], m.Colors)
+	}
+	return b
+}
+
+// FlatMesh reads a martini.Mesh FlatBuffer in place. Its accessors return
+// slices of the underlying buffer without copying.
+type FlatMesh struct {
+	buf   []byte
+	table int
+}
+
+var errBadFlatBuffer = errors.New("Malformed mesh FlatBuffer")
+
+// ReadFlatMesh checks the layout of a martini.Mesh FlatBuffer.
+func ReadFlatMesh(buf []byte) (*FlatMesh, error) {
+	if len(buf) < 8 || string(buf[4:8]) != flatIdentifier {
+		return nil, errors.New("Expected mesh FlatBuffer identifier")
+	}
+	le := binary.LittleEndian
+	table := int(le.Uint32(buf))
+	if table+4 > len(buf) {
+		return nil, errBadFlatBuffer
+	}
+	vtable := table - int(int32(le.Uint32(buf[table:])))
+	if vtable < 0 || vtable+4 > len(buf) || vtable+int(le.Uint16(buf[vtable:])) > len(buf) {
+		return nil, errBadFlatBuffer
+	}
+	f := &FlatMesh{buf: buf, table: table}
+	for field, elem := range []int{4, 4, 1} {
+		if _, ok := f.vector(field, elem); !ok {
+			return nil, errBadFlatBuffer
+		}
+	}
+	return f, nil
+}
+
+// vector returns the bytes of a vector field with elements of elem bytes,
+// or nil if the field is absent.
+func (f *FlatMesh) vector(field, elem int) ([]byte, bool) {
+	le := binary.LittleEndian
+	vtable := f.table - int(int32(le.Uint32(f.buf[f.table:])))
+	slot := 4 + 2*field
+	if slot+2 > int(le.Uint16(f.buf[vtable:])) {
+		return nil, true
+	}
+	off := int(le.Uint16(f.buf[vtable+slot:]))
+	if off == 0 {
+		return nil, true
+	}
+	at := f.table + off
+	if at+4 > len(f.buf) {
+		return nil, false
+	}
+	vec := at + int(le.Uint32(f.buf[at:]))
+	if vec+4 > len(f.buf) {
+		return nil, false
+	}
+	n := int(le.Uint32(f.buf[vec:]))
+	if n < 0 || vec+4+n*elem > len(f.buf) {
+		return nil, false
+	}
+	return f.buf[vec+4 : vec+4+n*elem], true
+}
+
+// VertexData returns the little-endian float32 x, y, z triples.
+func (f *FlatMesh) VertexData() []byte {
+	b, _ := f.vector(0, 4)
+	return b
+}
+
+// IndexData returns the little-endian uint32 triangle indices.
+func (f *FlatMesh) IndexData() []byte {
+	b, _ := f.vector(1, 4)
+	return b
+}
+
+// Colors returns the r, g, b triples, or nil.
+func (f *FlatMesh) Colors() []byte {
+	b, _ := f.vector(2, 1)
+	return b
+}
+
+func (f *FlatMesh) NumVertices() int {
+	return len(f.VertexData()) / 12
+}
+
+func (f *FlatMesh) NumTriangles() int {
+	return len(f.IndexData()) / 12
+}
+
+// Mesh decodes a copy of the mesh.
+func (f *FlatMesh) Mesh() *Mesh {
+	vd, id := f.VertexData(), f.IndexData()
+	m := &Mesh{
+		Vertices:  make([]float64, len(vd)/4),
+		Triangles: make([]uint32, len(id)/4),
+	}
+	for i := range m.Vertices {
+		m.Vertices[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(vd[4*i:])))
+	}
+	for i := range m.Triangles {
+		m.Triangles[i] = binary.LittleEndian.Uint32(id[4*i:])
+	}
+	if c := f.Colors(); c != nil {
+		m.Colors = append([]uint8(nil), c...)
+	}
+	return m
+}
+
+// FormatFlatBuffers serves meshes as martini.Mesh FlatBuffers.
+var FormatFlatBuffers = &Format{
+	Name:        "flatbuffers",
+	Extension:   "fb",
+	ContentType: "application/octet-stream",
+	Encode: func(w io.Writer, m *Mesh) error {
+		_, err := w.Write(m.MarshalFlatBuffer())
+		return err
+	},
+}
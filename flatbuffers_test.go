@@ -0,0 +1,46 @@
+package martini
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestFlatBuffer(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, func(x, y int) float64 { return float64(x*y) / 4 }))
+	mesh := tile.ToMesh(1)
+
+	buf := mesh.MarshalFlatBuffer()
+	f, err := ReadFlatMesh(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.NumVertices() != mesh.NumVertices() || f.NumTriangles() != mesh.NumTriangles() || f.Colors() != nil {
+		t.Fatal("unexpected counts")
+	}
+	// Heights are exact in float32 here, so the copy matches exactly.
+	if got := f.Mesh(); !reflect.DeepEqual(got, mesh) {
+		t.Fatal("round trip changed the mesh")
+	}
+	if &f.IndexData()[0] != &buf[len(buf)-len(f.IndexData())] {
+		t.Error("expected index data to alias the buffer")
+	}
+	if binary.LittleEndian.Uint32(f.IndexData()[4:]) != mesh.Triangles[1] {
+		t.Error("unexpected index layout")
+	}
+
+	mesh.Colors = make([]uint8, 3*mesh.NumVertices())
+	mesh.Colors[5] = 9
+	f, err = ReadFlatMesh(mesh.MarshalFlatBuffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.Colors(); len(c) != len(mesh.Colors) || c[5] != 9 {
+		t.Error("expected colors")
+	}
+
+	if _, err := ReadFlatMesh(buf[:len(buf)-4]); err == nil {
+		t.Error("expected error for truncated buffer")
+	}
+}
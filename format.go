@@ -0,0 +1,33 @@
+package martini
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Format describes a mesh encoding used when writing or serving tiles.
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	Encode      func(w io.Writer, m *Mesh) error
+	// EncodeMeta, if set, is Encode with the tile's metadata embedded.
+	EncodeMeta func(w io.Writer, m *Mesh, meta *TileMeta) error
+}
+
+// encode uses EncodeMeta when the format supports it and meta is known.
+func (f *Format) encode(w io.Writer, m *Mesh, meta *TileMeta) error {
+	if f.EncodeMeta != nil && meta != nil {
+		return f.EncodeMeta(w, m, meta)
+	}
+	return f.Encode(w, m)
+}
+
+var FormatJSON = &Format{
+	Name:        "json",
+	Extension:   "json",
+	ContentType: "application/json",
+	Encode: func(w io.Writer, m *Mesh) error {
+		return json.NewEncoder(w).Encode(m)
+	},
+}
@@ -0,0 +1,73 @@
+package martini
+
+import "math"
+
+// GeoTransform georeferences a north-up raster: the model coordinates of
+// its top-left corner and the size of one pixel.
+type GeoTransform struct {
+	OriginX, OriginY       float64
+	PixelSizeX, PixelSizeY float64
+}
+
+// tileSamplePoints returns the model coordinates of the gridSize*gridSize
+// points of an XYZ web mercator tile in the given projection, and the model
+// distance between neighbouring points.
+func tileSamplePoints(id TileID, gridSize int, proj Projection) ([]float64, []float64, float64) {
+	n := float64(int(1) << uint(id.Z))
+	tileMeters := 2 * webMercatorHalf / n
+	step := tileMeters / float64(gridSize-1)
+	minX := -webMercatorHalf + float64(id.X)*tileMeters
+	maxY := webMercatorHalf - float64(id.Y)*tileMeters
+
+	px := make([]float64, gridSize*gridSize)
+	py := make([]float64, gridSize*gridSize)
+	for j := 0; j < gridSize; j++ {
+		for i := 0; i < gridSize; i++ {
+			mx := minX + float64(i)*step
+			my := maxY - float64(j)*step
+			if proj == ProjectionGeographic {
+				mx, my = mercatorToLngLat(mx, my)
+			}
+			px[j*gridSize+i] = mx
+			py[j*gridSize+i] = my
+		}
+	}
+
+	need := math.Abs(px[1] - px[0])
+	if proj == ProjectionGeographic {
+		need = math.Min(need, math.Abs(py[0]-py[gridSize]))
+	}
+	return px, py, need
+}
+
+func mercatorToLngLat(x, y float64) (float64, float64) {
+	return x / webMercatorHalf * 180, (2*math.Atan(math.Exp(y/webMercatorHalf*math.Pi)) - math.Pi/2) * 180 / math.Pi
+}
+
+func lngLatToMercator(lng, lat float64) (float64, float64) {
+	r := lat * math.Pi / 180
+	return lng / 180 * webMercatorHalf, math.Log(math.Tan(math.Pi/4+r/2)) / math.Pi * webMercatorHalf
+}
+
+// toLngLat converts model coordinates of a projection to degrees.
+func (p Projection) toLngLat(x, y float64) (float64, float64) {
+	if p == ProjectionGeographic {
+		return x, y
+	}
+	return mercatorToLngLat(x, y)
+}
+
+// Bounds returns the longitude/latitude extent of an XYZ web mercator tile.
+func (id TileID) Bounds() Bounds {
+	west, north := id.gridToLngLat(0, 0, 2)
+	east, south := id.gridToLngLat(1, 1, 2)
+	return Bounds{West: west, South: south, East: east, North: north}
+}
+
+// gridToLngLat converts grid coordinates of an XYZ web mercator tile, with
+// y growing southwards, to longitude/latitude.
+func (id TileID) gridToLngLat(x, y float64, gridSize int) (float64, float64) {
+	tileMeters := 2 * webMercatorHalf / float64(int(1)<<uint(id.Z))
+	step := tileMeters / float64(gridSize-1)
+	return mercatorToLngLat(-webMercatorHalf+float64(id.X)*tileMeters+x*step, webMercatorHalf-float64(id.Y)*tileMeters-y*step)
+}
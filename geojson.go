@@ -0,0 +1,58 @@
+package martini
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+)
+
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string         `json:"type"`
+		Coordinates [][][3]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		Min  float64 `json:"min"`
+		Max  float64 `json:"max"`
+		Mean float64 `json:"mean"`
+	} `json:"properties"`
+}
+
+// EncodeGeoJSON writes the triangles of a tile mesh, in the grid frame
+// ToMesh produces, as a GeoJSON FeatureCollection of polygons placed on the
+// XYZ web mercator tile id. Coordinates carry the height as a third value
+// and every feature has min, max and mean height properties.
+func EncodeGeoJSON(w io.Writer, m *Mesh, id TileID, gridSize int) error {
+	features := make([]geoJSONFeature, m.NumTriangles())
+	for t := range features {
+		f := &features[t]
+		f.Type = "Feature"
+		f.Geometry.Type = "Polygon"
+		ring := make([][3]float64, 4)
+		f.Properties.Min, f.Properties.Max = math.Inf(1), math.Inf(-1)
+		for k := 0; k < 3; k++ {
+			x, y, z := m.Vertex(int(m.Triangles[3*t+k]))
+			lng, lat := id.gridToLngLat(x, y, gridSize)
+			ring[k] = [3]float64{lng, lat, z}
+			f.Properties.Min = math.Min(f.Properties.Min, z)
+			f.Properties.Max = math.Max(f.Properties.Max, z)
+			f.Properties.Mean += z / 3
+		}
+		// Grid y points south, so reverse to keep exterior rings
+		// counter-clockwise as RFC 7946 recommends.
+		if orient(ring[0], ring[1], ring[2]) < 0 {
+			ring[1], ring[2] = ring[2], ring[1]
+		}
+		ring[3] = ring[0]
+		f.Geometry.Coordinates = [][][3]float64{ring}
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{"FeatureCollection", features})
+}
+
+func orient(a, b, c [3]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
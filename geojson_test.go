@@ -0,0 +1,52 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestEncodeGeoJSON(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(10)
+	id := TileID{Z: 2, X: 1, Y: 1}
+
+	var buf bytes.Buffer
+	if err := EncodeGeoJSON(&buf, mesh, id, 17); err != nil {
+		t.Fatal(err)
+	}
+	var fc struct {
+		Type     string
+		Features []geoJSONFeature
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != mesh.NumTriangles() {
+		t.Fatalf("expected %d features, got %d", mesh.NumTriangles(), len(fc.Features))
+	}
+
+	b := id.Bounds()
+	if b.West != -90 || b.East != 0 || math.Abs(b.North-66.51326044311186) > 1e-9 || b.South != 0 {
+		t.Errorf("unexpected tile bounds %+v", b)
+	}
+	for _, f := range fc.Features {
+		ring := f.Geometry.Coordinates[0]
+		if len(ring) != 4 || ring[0] != ring[3] {
+			t.Fatal("expected closed triangle rings")
+		}
+		if orient(ring[0], ring[1], ring[2]) <= 0 {
+			t.Fatal("expected counter-clockwise rings")
+		}
+		for _, p := range ring {
+			if p[0] < b.West || p[0] > b.East || p[1] < b.South-1e-9 || p[1] > b.North+1e-9 {
+				t.Fatalf("point %v outside tile", p)
+			}
+			if p[2] < f.Properties.Min || p[2] > f.Properties.Max {
+				t.Fatal("height outside min/max")
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestEncodeGLB(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	tile.AddAttribute("class", testTerrain(17, func(x, y int) float64 { return float64(x % 3) }))
+	mesh := tile.ToMesh(5)
+
+	var buf bytes.Buffer
+	if err := EncodeGLB(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != "glTF" || int(binary.LittleEndian.Uint32(data[8:])) != len(data) {
+		t.Fatal("bad GLB header")
+	}
+	jsonLen := binary.LittleEndian.Uint32(data[12:])
+	var doc gltfDocument
+	if err := json.Unmarshal(data[20:20+jsonLen], &doc); err != nil {
+		t.Fatal(err)
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	pos := doc.Accessors[prim.Attributes["POSITION"]]
+	if pos.Count != mesh.NumVertices() || len(pos.Min) != 3 {
+		t.Errorf("bad position accessor %+v", pos)
+	}
+	if _, ok := prim.Attributes["_CLASS"]; !ok {
+		t.Error("expected custom attribute")
+	}
+	if doc.Accessors[prim.Indices].Count != len(mesh.Triangles) {
+		t.Error("bad index count")
+	}
+	binLen := binary.LittleEndian.Uint32(data[20+jsonLen:])
+	if int(binLen) != doc.Buffers[0].ByteLength {
+		t.Errorf("BIN chunk length %d, buffer %d", binLen, doc.Buffers[0].ByteLength)
+	}
+}
+
+func TestEncodeGLBQuantized(t *testing.T) {
+	m, _ := NewMartini(65)
+	tile, _ := m.CreateTile(testTerrain(65, hills))
+	mesh := tile.ToMesh(1)
+
+	var buf bytes.Buffer
+	if err := EncodeGLBQuantized(&buf, mesh, &TileMeta{Zoom: 3}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	jsonLen := binary.LittleEndian.Uint32(data[12:])
+	var doc gltfDocument
+	var nodes struct {
+		Nodes []struct {
+			Translation, Scale []float64
+			Extras             struct {
+				Zoom         int
+				Quantization Quantization
+			}
+		}
+	}
+	if err := json.Unmarshal(data[20:20+jsonLen], &doc); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(data[20:20+jsonLen], &nodes)
+	if len(doc.ExtensionsRequired) != 1 || doc.ExtensionsRequired[0] != "KHR_mesh_quantization" {
+		t.Errorf("extensionsRequired %v", doc.ExtensionsRequired)
+	}
+	pos := doc.Accessors[doc.Meshes[0].Primitives[0].Attributes["POSITION"]]
+	if pos.ComponentType != gltfUnsignedShort || doc.BufferViews[pos.BufferView].ByteStride != 8 {
+		t.Errorf("bad position accessor %+v", pos)
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	uv := doc.Accessors[prim.Attributes["TEXCOORD_0"]]
+	if uv.ComponentType != gltfUnsignedShort || !uv.Normalized || uv.Type != "VEC2" || uv.Count != mesh.NumVertices() {
+		t.Errorf("bad texture coordinate accessor %+v", uv)
+	}
+	if doc.Accessors[prim.Indices].ComponentType != gltfUnsignedShort {
+		t.Error("expected 16-bit indices")
+	}
+	var full bytes.Buffer
+	EncodeGLBMeta(&full, mesh, &TileMeta{Zoom: 3})
+	if buf.Len() > full.Len()*3/4 {
+		t.Errorf("quantized tile is %d bytes, unquantized %d", buf.Len(), full.Len())
+	}
+	node := nodes.Nodes[0]
+	q := node.Extras.Quantization
+	if node.Extras.Zoom != 3 || q.Bits != 16 || node.Scale[2] != q.Scale[2] || node.Translation[2] != q.Offset[2] {
+		t.Fatalf("bad node %+v", node)
+	}
+	maxHeight := math.Inf(-1)
+	for i := 2; i < len(mesh.Vertices); i += 3 {
+		maxHeight = math.Max(maxHeight, mesh.Vertices[i])
+	}
+	if got := q.Offset[2] + pos.Max[2]*q.Scale[2]; math.Abs(got-maxHeight) > q.Scale[2] {
+		t.Errorf("dequantized max height %v, want %v", got, maxHeight)
+	}
+}
@@ -0,0 +1,121 @@
+package martini
+
+import "errors"
+
+// HalfEdges is the connectivity of a triangle mesh. Half-edge h runs from
+// vertex Triangles[h] of the mesh to the next corner of its triangle, so
+// half-edges 3t, 3t+1 and 3t+2 belong to triangle t.
+type HalfEdges struct {
+	// Opposite holds the twin of every half-edge, running the other way
+	// in the adjacent triangle, or -1 on the boundary.
+	Opposite []int
+	// VertexEdge holds a half-edge leaving every vertex, -1 for vertices
+	// no triangle uses. For boundary vertices it is the boundary half-edge.
+	VertexEdge []int
+
+	triangles []uint32
+}
+
+// NextHalfEdge returns the half-edge following h in its triangle.
+func NextHalfEdge(h int) int {
+	if h%3 == 2 {
+		return h - 2
+	}
+	return h + 1
+}
+
+// PrevHalfEdge returns the half-edge preceding h in its triangle.
+func PrevHalfEdge(h int) int {
+	if h%3 == 0 {
+		return h + 2
+	}
+	return h - 1
+}
+
+// NewHalfEdges builds the connectivity of m, which must not change while
+// it is used. Edges shared by more than two triangles, or by two triangles
+// of opposite winding, are an error.
+func NewHalfEdges(m *Mesh) (*HalfEdges, error) {
+	n := len(m.Triangles) / 3 * 3
+	he := &HalfEdges{
+		Opposite:   make([]int, n),
+		VertexEdge: make([]int, m.NumVertices()),
+		triangles:  m.Triangles[:n],
+	}
+	for i := range he.VertexEdge {
+		he.VertexEdge[i] = -1
+	}
+	edges := make(map[[2]uint32]int, n)
+	for h := 0; h < n; h++ {
+		a, b := he.Origin(h), he.Dest(h)
+		if int(a) >= len(he.VertexEdge) || int(b) >= len(he.VertexEdge) {
+			return nil, errors.New("Expected triangle indices within the vertices")
+		}
+		if _, ok := edges[[2]uint32{a, b}]; ok {
+			return nil, errors.New("Expected a manifold mesh with consistent winding")
+		}
+		edges[[2]uint32{a, b}] = h
+		he.Opposite[h] = -1
+	}
+	for h := 0; h < n; h++ {
+		if o, ok := edges[[2]uint32{he.Dest(h), he.Origin(h)}]; ok {
+			he.Opposite[h] = o
+		}
+		v := he.Origin(h)
+		if he.VertexEdge[v] < 0 || he.Opposite[h] < 0 {
+			he.VertexEdge[v] = h
+		}
+	}
+	return he, nil
+}
+
+// Origin returns the vertex half-edge h leaves.
+func (he *HalfEdges) Origin(h int) uint32 {
+	return he.triangles[h]
+}
+
+// Dest returns the vertex half-edge h reaches.
+func (he *HalfEdges) Dest(h int) uint32 {
+	return he.triangles[NextHalfEdge(h)]
+}
+
+// Adjacent returns the triangles across the three edges of triangle t, -1
+// where the edge is on the boundary.
+func (he *HalfEdges) Adjacent(t int) [3]int {
+	var adj [3]int
+	for k := range adj {
+		adj[k] = -1
+		if o := he.Opposite[3*t+k]; o >= 0 {
+			adj[k] = o / 3
+		}
+	}
+	return adj
+}
+
+// VertexNeighbors returns the vertices sharing an edge with v, in order
+// around it.
+func (he *HalfEdges) VertexNeighbors(v uint32) []uint32 {
+	start := he.VertexEdge[v]
+	if start < 0 {
+		return nil
+	}
+	var out []uint32
+	for h := start; ; {
+		out = append(out, he.Dest(h))
+		prev := PrevHalfEdge(h)
+		h = he.Opposite[prev]
+		if h < 0 {
+			// Reached the boundary: the incoming edge closes the fan.
+			return append(out, he.Origin(prev))
+		}
+		if h == start {
+			return out
+		}
+	}
+}
+
+// IsBoundaryVertex reports whether v lies on the boundary of the mesh.
+func (he *HalfEdges) IsBoundaryVertex(v uint32) bool {
+	h := he.VertexEdge[v]
+	return h >= 0 && he.Opposite[h] < 0
+}
@@ -0,0 +1,64 @@
+package martini
+
+import "testing"
+
+func TestHalfEdges(t *testing.T) {
+	m, _ := NewMartini(3)
+	tile, _ := m.CreateTile(make([]float64, 9))
+	mesh := tile.ToMesh(-1)
+	he, err := NewHalfEdges(mesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boundary := 0
+	for h, o := range he.Opposite {
+		if o < 0 {
+			boundary++
+			continue
+		}
+		if he.Opposite[o] != h || he.Origin(o) != he.Dest(h) || he.Dest(o) != he.Origin(h) {
+			t.Fatalf("half-edge %d and its opposite %d disagree", h, o)
+		}
+	}
+	if boundary != 8 {
+		t.Errorf("got %d boundary half-edges, want 8", boundary)
+	}
+
+	centre := uint32(0)
+	for v := 0; v < mesh.NumVertices(); v++ {
+		if x, y, _ := mesh.Vertex(v); x == 1 && y == 1 {
+			centre = uint32(v)
+		}
+	}
+	if he.IsBoundaryVertex(centre) {
+		t.Error("centre on the boundary")
+	}
+	seen := map[uint32]bool{}
+	for _, n := range he.VertexNeighbors(centre) {
+		seen[n] = true
+	}
+	if len(seen) != 8 || len(he.VertexNeighbors(centre)) != 8 {
+		t.Errorf("centre neighbours %v", he.VertexNeighbors(centre))
+	}
+	for v := uint32(0); v < uint32(mesh.NumVertices()); v++ {
+		if v != centre && !he.IsBoundaryVertex(v) {
+			t.Errorf("vertex %d not on the boundary", v)
+		}
+	}
+	for tri := 0; tri < mesh.NumTriangles(); tri++ {
+		n := 0
+		for _, a := range he.Adjacent(tri) {
+			if a >= 0 {
+				n++
+			}
+		}
+		if n != 2 {
+			t.Errorf("triangle %d has %d neighbours, want 2", tri, n)
+		}
+	}
+
+	mesh.Triangles = append(mesh.Triangles, mesh.Triangles[:3]...)
+	if _, err := NewHalfEdges(mesh); err == nil {
+		t.Error("expected an error for a duplicated triangle")
+	}
+}
@@ -0,0 +1,39 @@
+package martini
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+)
+
+// Hash returns a hex SHA-256 digest of the vertex, triangle and attribute
+// buffers. It depends only on their contents, so it is stable across
+// processes and platforms.
+func (m *Mesh) Hash() string {
+	h := sha256.New()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(len(m.Vertices)))
+	h.Write(buf[:])
+	for _, v := range m.Vertices {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		h.Write(buf[:])
+	}
+	binary.LittleEndian.PutUint64(buf[:], uint64(len(m.Triangles)))
+	h.Write(buf[:])
+	for _, v := range m.Triangles {
+		binary.LittleEndian.PutUint32(buf[:4], v)
+		h.Write(buf[:4])
+	}
+	for _, a := range m.Attributes {
+		h.Write([]byte(a.Name))
+		binary.LittleEndian.PutUint64(buf[:], uint64(len(a.Values)))
+		h.Write(buf[:])
+		for _, v := range a.Values {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			h.Write(buf[:])
+		}
+	}
+	h.Write(m.Colors)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,23 @@
+package martini
+
+import "testing"
+
+func TestMeshHash(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	a := tile.ToMesh(5)
+	if a.Hash() != tile.ToMesh(5).Hash() {
+		t.Error("expected identical meshes to hash equally")
+	}
+	if a.Hash() == tile.ToMesh(1).Hash() {
+		t.Error("expected different meshes to hash differently")
+	}
+	b := tile.ToMesh(5)
+	b.Vertices[2] += 0.5
+	if a.Hash() == b.Hash() {
+		t.Error("expected height change to alter the hash")
+	}
+	if len(a.Hash()) != 64 {
+		t.Errorf("unexpected digest %q", a.Hash())
+	}
+}
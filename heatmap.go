@@ -0,0 +1,71 @@
+package martini
+
+import (
+	"image"
+	"math"
+)
+
+// Residuals returns, for every grid sample, the absolute difference
+// between the terrain and the mesh extracted at maxError.
+func (t *Tile) Residuals(maxError float64) []float64 {
+	size := t.Martini.GridSize
+	res := make([]float64, size*size)
+	forEachSample(t.ToMesh(maxError), size, func(_, i int, z float64) {
+		res[i] = math.Abs(t.Terrain[i] - z)
+	})
+	return res
+}
+
+// TriangleErrors returns, aligned with the triangles of a mesh extracted
+// from t in the grid frame, the error that stopped the subdivision of each:
+// the Errors value at the midpoint of its hypotenuse, which bounds the
+// interpolation error of the triangles it would have been split into. It
+// is zero for triangles of the finest level. Winding does not matter.
+func (t *Tile) TriangleErrors(m *Mesh) []float64 {
+	size := t.Martini.GridSize
+	errs := make([]float64, m.NumTriangles())
+	for i := range errs {
+		var p [3][2]int
+		for k := range p {
+			x, y, _ := m.Vertex(int(m.Triangles[3*i+k]))
+			p[k] = [2]int{int(x), int(y)}
+		}
+		// The hypotenuse is the longest edge.
+		var a, b [2]int
+		longest := -1
+		for k := range p {
+			u, v := p[k], p[(k+1)%3]
+			if d := (u[0]-v[0])*(u[0]-v[0]) + (u[1]-v[1])*(u[1]-v[1]); d > longest {
+				a, b, longest = u, v, d
+			}
+		}
+		if (a[0]+b[0])%2 != 0 || (a[1]+b[1])%2 != 0 {
+			continue
+		}
+		mx, my := (a[0]+b[0])/2, (a[1]+b[1])/2
+		if mx >= 0 && my >= 0 && mx < size && my < size {
+			errs[i] = t.Errors[my*size+mx]
+		}
+	}
+	return errs
+}
+
+// HeatmapImage color-maps a grid of non-negative values, such as a tile's
+// Errors or Residuals, from blue at zero to red at max. A max of zero
+// scales to the largest value. Use png.Encode to write it out.
+func HeatmapImage(values []float64, gridSize int, max float64) *image.RGBA {
+	if max <= 0 {
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, gridSize, gridSize))
+	for i, v := range values {
+		t := 0.0
+		if max > 0 {
+			t = v / max
+		}
+		img.SetRGBA(i%gridSize, i/gridSize, ramp(t))
+	}
+	return img
+}
@@ -0,0 +1,73 @@
+package martini
+
+import "testing"
+
+func TestResiduals(t *testing.T) {
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(testTerrain(33, hills))
+
+	res := tile.Residuals(10)
+	var max float64
+	for _, r := range res {
+		if r > max {
+			max = r
+		}
+	}
+	if max == 0 || max > 10 {
+		t.Errorf("expected residuals in (0, 10], got max %v", max)
+	}
+	for _, r := range tile.Residuals(0) {
+		if r > 1e-9 {
+			t.Fatalf("expected zero residual for the full mesh, got %v", r)
+		}
+	}
+}
+
+func TestHeatmapImage(t *testing.T) {
+	values := []float64{0, 1, 2, 4}
+	img := HeatmapImage(values, 2, 0)
+	if img.Bounds().Dx() != 2 {
+		t.Fatalf("unexpected size %v", img.Bounds())
+	}
+	if img.RGBAAt(0, 0) != ramp(0) || img.RGBAAt(1, 1) != ramp(1) {
+		t.Error("expected the ramp to span zero to the largest value")
+	}
+	if HeatmapImage(values, 2, 2).RGBAAt(1, 1) != ramp(1) {
+		t.Error("expected values above max to clamp")
+	}
+}
+
+func TestTileTriangleErrors(t *testing.T) {
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(testTerrain(33, hills))
+
+	mesh := tile.ToMesh(10)
+	errs := tile.TriangleErrors(mesh)
+	if len(errs) != mesh.NumTriangles() {
+		t.Fatalf("got %d errors for %d triangles", len(errs), mesh.NumTriangles())
+	}
+	var max float64
+	for _, e := range errs {
+		if e > 10 {
+			t.Fatalf("triangle error %v above maxError", e)
+		}
+		if e > max {
+			max = e
+		}
+	}
+	if max == 0 {
+		t.Error("expected some non-zero triangle errors")
+	}
+	mesh.FlipWinding()
+	for i, e := range tile.TriangleErrors(mesh) {
+		if e != errs[i] {
+			t.Fatalf("triangle %d: %v after flipping the winding, %v before", i, e, errs[i])
+		}
+	}
+	full := tile.ToMesh(0)
+	for _, e := range tile.TriangleErrors(full) {
+		if e != 0 {
+			t.Fatalf("expected zero errors for the full mesh, got %v", e)
+		}
+	}
+}
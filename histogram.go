@@ -0,0 +1,49 @@
+package martini
+
+import "math"
+
+// ErrorHistogram describes the distribution of a tile's Errors and the
+// mesh sizes they lead to, for plotting error against triangle count.
+type ErrorHistogram struct {
+	// Edges holds the bin boundaries, from zero to the largest error.
+	Edges []float64
+	// Counts[i] is the number of samples with an error in
+	// [Edges[i], Edges[i+1]), the last bin including the largest error.
+	// The four corners, which are never split, are not counted.
+	Counts []int
+	// Triangles[i] is the number of triangles of the mesh extracted with
+	// maxError Edges[i].
+	Triangles []int
+}
+
+// ErrorHistogram bins the tile's Errors into bins equal intervals.
+func (t *Tile) ErrorHistogram(bins int) *ErrorHistogram {
+	bins = maxInt(bins, 1)
+	size := t.Martini.GridSize
+	max := 0.0
+	for _, e := range t.Errors {
+		max = math.Max(max, e)
+	}
+	h := &ErrorHistogram{
+		Edges:     make([]float64, bins+1),
+		Counts:    make([]int, bins),
+		Triangles: make([]int, bins+1),
+	}
+	for i := range h.Edges {
+		h.Edges[i] = max * float64(i) / float64(bins)
+		h.Triangles[i] = t.EstimateTriangles(h.Edges[i])
+	}
+	last := size - 1
+	for i, e := range t.Errors {
+		x, y := i%size, i/size
+		if (x == 0 || x == last) && (y == 0 || y == last) {
+			continue
+		}
+		bin := 0
+		if max > 0 {
+			bin = minInt(int(e/max*float64(bins)), bins-1)
+		}
+		h.Counts[bin]++
+	}
+	return h
+}
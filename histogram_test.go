@@ -0,0 +1,33 @@
+package martini
+
+import "testing"
+
+func TestErrorHistogram(t *testing.T) {
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(testTerrain(33, hills))
+
+	h := tile.ErrorHistogram(8)
+	if len(h.Edges) != 9 || len(h.Counts) != 8 || len(h.Triangles) != 9 || h.Edges[0] != 0 {
+		t.Fatalf("bad histogram %+v", h)
+	}
+	total := 0
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != 33*33-4 {
+		t.Errorf("counted %d samples", total)
+	}
+	if h.Triangles[8] != 2 {
+		t.Errorf("got %d triangles at the largest error, want 2", h.Triangles[8])
+	}
+	for i := 1; i < len(h.Triangles); i++ {
+		if h.Triangles[i] > h.Triangles[i-1] {
+			t.Fatalf("triangle counts grow with maxError: %v", h.Triangles)
+		}
+	}
+
+	flat, _ := m.CreateTile(make([]float64, 33*33))
+	if h := flat.ErrorHistogram(4); h.Counts[0] != 33*33-4 || h.Triangles[4] != 2 {
+		t.Errorf("flat tile histogram %+v", h)
+	}
+}
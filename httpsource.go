@@ -0,0 +1,174 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HTTPSource is a TerrainSource fetching elevation PNG tiles from a URL
+// template containing {z}, {x} and {y}, such as a Terrain-RGB or Terrarium
+// tile service.
+type HTTPSource struct {
+	URLTemplate string
+	Encoding    Encoding
+	// RGB, when set, overrides Encoding with a custom channel layout.
+	RGB    *RGBEncoding
+	Client *http.Client
+	// MaxConcurrent bounds the number of requests in flight. Zero means 4.
+	MaxConcurrent int
+	// RequestsPerSecond paces requests when positive.
+	RequestsPerSecond float64
+	// Retries is the number of extra attempts after network errors, 429
+	// and 5xx responses, waiting Backoff, 2*Backoff, 4*Backoff, ...
+	Retries int
+	Backoff time.Duration
+	// CacheDir, if set, keeps downloaded tiles on disk.
+	CacheDir string
+
+	once sync.Once
+	sem  chan struct{}
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewHTTPSource(urlTemplate string, enc Encoding) *HTTPSource {
+	return &HTTPSource{
+		URLTemplate: urlTemplate,
+		Encoding:    enc,
+		Retries:     3,
+		Backoff:     500 * time.Millisecond,
+	}
+}
+
+func (s *HTTPSource) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	data, err := s.fetch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	if s.RGB != nil {
+		return s.RGB.Decode(img, gridSize)
+	}
+	return DecodeElevation(img, s.Encoding, gridSize)
+}
+
+func (s *HTTPSource) cachePath(id TileID) string {
+	return filepath.Join(s.CacheDir, strconv.Itoa(id.Z), strconv.Itoa(id.X), strconv.Itoa(id.Y)+".png")
+}
+
+func (s *HTTPSource) fetch(ctx context.Context, id TileID) ([]byte, error) {
+	if s.CacheDir != "" {
+		if data, err := ioutil.ReadFile(s.cachePath(id)); err == nil {
+			return data, nil
+		}
+	}
+
+	s.once.Do(func() {
+		n := s.MaxConcurrent
+		if n <= 0 {
+			n = 4
+		}
+		s.sem = make(chan struct{}, n)
+	})
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.sem }()
+
+	var data []byte
+	var err error
+	backoff := s.Backoff
+	for attempt := 0; ; attempt++ {
+		if err = s.wait(ctx); err != nil {
+			return nil, err
+		}
+		var retry bool
+		data, retry, err = s.get(ctx, id)
+		if err == nil || !retry || attempt >= s.Retries {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.CacheDir != "" {
+		p := s.cachePath(id)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err == nil {
+			ioutil.WriteFile(p, data, 0644)
+		}
+	}
+	return data, nil
+}
+
+// wait blocks until the rate limit allows another request.
+func (s *HTTPSource) wait(ctx context.Context) error {
+	if s.RequestsPerSecond <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / s.RequestsPerSecond)
+	s.mu.Lock()
+	now := time.Now()
+	at := s.next
+	if at.Before(now) {
+		at = now
+	}
+	s.next = at.Add(interval)
+	s.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *HTTPSource) get(ctx context.Context, id TileID) ([]byte, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, expandTileTemplate(s.URLTemplate, id), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retry, fmt.Errorf("tile %v: %s", id, resp.Status)
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	return data, false, nil
+}
@@ -0,0 +1,72 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func terrariumPNG(size int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, color.NRGBA{128, uint8(x), uint8(y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestHTTPSource(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch {
+		case r.URL.Path == "/missing/1/0/0.png":
+			http.NotFound(w, r)
+		case n == 1:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		default:
+			w.Write(terrariumPNG(16))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSource(srv.URL+"/{z}/{x}/{y}.png", EncodingTerrarium)
+	s.Backoff = time.Millisecond
+	s.CacheDir = testDir(t)
+
+	terrain, err := s.Terrain(context.Background(), TileID{3, 2, 1}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terrain[2] != 2 {
+		t.Errorf("unexpected height %v", terrain[2])
+	}
+	if requests != 2 {
+		t.Errorf("expected one retry, got %d requests", requests)
+	}
+
+	if _, err := s.Terrain(context.Background(), TileID{3, 2, 1}, 17); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Error("expected cached tile to be served from disk")
+	}
+
+	missing := NewHTTPSource(srv.URL+"/missing/{z}/{x}/{y}.png", EncodingTerrarium)
+	if _, err := missing.Terrain(context.Background(), TileID{1, 0, 0}, 17); err == nil {
+		t.Error("expected error for 404")
+	}
+	if requests != 3 {
+		t.Errorf("expected 404 not to be retried, got %d requests", requests)
+	}
+}
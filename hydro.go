@@ -0,0 +1,99 @@
+package martini
+
+import "math"
+
+// WaterBody is a polygon in grid coordinates, outer ring first followed by
+// any island rings, flattened to a water surface elevation.
+type WaterBody struct {
+	Rings     [][][2]float64
+	Elevation float64
+}
+
+func (w *WaterBody) contains(x, y float64) bool {
+	inside := false
+	for _, ring := range w.Rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func (w *WaterBody) distance(x, y float64) float64 {
+	d := math.Inf(1)
+	for _, ring := range w.Rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			d = math.Min(d, segmentDistance(x, y, ring[j], ring[i]))
+		}
+	}
+	return d
+}
+
+func segmentDistance(x, y float64, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((x-a[0])*dx+(y-a[1])*dy)/l))
+	}
+	ex, ey := a[0]+t*dx-x, a[1]+t*dy-y
+	return math.Sqrt(ex*ex + ey*ey)
+}
+
+func (w *WaterBody) bounds() (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, ring := range w.Rings {
+		for _, p := range ring {
+			minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+			minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+		}
+	}
+	return
+}
+
+// HydroFlatten sets the terrain inside every water body to its elevation
+// and blends the surrounding bankWidth grid cells smoothly back to the
+// original terrain. Later bodies win where bodies overlap.
+func HydroFlatten(terrain []float64, gridSize int, bodies []WaterBody, bankWidth float64) {
+	for b := range bodies {
+		body := &bodies[b]
+		minX, minY, maxX, maxY := body.bounds()
+		x0 := clampInt(int(math.Floor(minX-bankWidth)), 0, gridSize-1)
+		y0 := clampInt(int(math.Floor(minY-bankWidth)), 0, gridSize-1)
+		x1 := clampInt(int(math.Ceil(maxX+bankWidth)), 0, gridSize-1)
+		y1 := clampInt(int(math.Ceil(maxY+bankWidth)), 0, gridSize-1)
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				i := y*gridSize + x
+				fx, fy := float64(x), float64(y)
+				if body.contains(fx, fy) {
+					terrain[i] = body.Elevation
+					continue
+				}
+				if bankWidth <= 0 {
+					continue
+				}
+				d := body.distance(fx, fy)
+				if d >= bankWidth {
+					continue
+				}
+				t := d / bankWidth
+				t = t * t * (3 - 2*t)
+				terrain[i] = body.Elevation + (terrain[i]-body.Elevation)*t
+			}
+		}
+	}
+}
+
+// TileGridCoords converts a longitude/latitude to fractional grid
+// coordinates of an XYZ web mercator tile, for building water polygons.
+func TileGridCoords(id TileID, gridSize int, lng, lat float64) (float64, float64) {
+	mx, my := lngLatToMercator(lng, lat)
+	tileMeters := 2 * webMercatorHalf / float64(int(1)<<uint(id.Z))
+	x := (mx + webMercatorHalf - float64(id.X)*tileMeters) / tileMeters
+	y := (webMercatorHalf - my - float64(id.Y)*tileMeters) / tileMeters
+	return x * float64(gridSize-1), y * float64(gridSize-1)
+}
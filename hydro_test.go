@@ -0,0 +1,48 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHydroFlatten(t *testing.T) {
+	terrain := testTerrain(33, func(x, y int) float64 { return 100 })
+	lake := WaterBody{
+		Rings: [][][2]float64{
+			{{8, 8}, {24, 8}, {24, 24}, {8, 24}},
+			{{12, 12}, {20, 12}, {20, 20}, {12, 20}}, // island
+		},
+		Elevation: 50,
+	}
+	HydroFlatten(terrain, 33, []WaterBody{lake}, 4)
+
+	if terrain[10*33+10] != 50 {
+		t.Errorf("expected water level, got %v", terrain[10*33+10])
+	}
+	if terrain[16*33+16] != 100 {
+		t.Errorf("expected island to keep its height, got %v", terrain[16*33+16])
+	}
+	if v := terrain[16*33+6]; v <= 50 || v >= 100 {
+		t.Errorf("expected blended bank, got %v", v)
+	}
+	if terrain[16*33+2] != 100 || terrain[0] != 100 {
+		t.Error("expected terrain beyond the bank to be untouched")
+	}
+
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(terrain)
+	mesh := tile.ToMesh(0.5)
+	for i := 0; i < mesh.NumVertices(); i++ {
+		x, y, z := mesh.Vertex(i)
+		if x > 8 && x < 12 && y > 8 && y < 24 && z != 50 {
+			t.Fatalf("vertex %v,%v above water", x, y)
+		}
+	}
+}
+
+func TestTileGridCoords(t *testing.T) {
+	x, y := TileGridCoords(TileID{1, 1, 1}, 257, 90, -66.51326044311186)
+	if math.Abs(x-128) > 1e-6 || math.Abs(y-128) > 1e-6 {
+		t.Errorf("expected tile centre, got %v %v", x, y)
+	}
+}
@@ -0,0 +1,331 @@
+package martini
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// SLPKWriter writes an Esri I3S 1.7 IntegratedMesh scene layer package
+// from the tiles of a mesh pyramid, for use in ArcGIS. Add every tile, then
+// Close. Each tile becomes a node holding its mesh; coarser tiles switch to
+// their children once their bounding sphere covers ScreenThreshold pixels.
+type SLPKWriter struct {
+	// Name is the layer name.
+	Name string
+	// ScreenThreshold is the projected node diameter, in pixels, above
+	// which a node is replaced by its children. Zero means 512.
+	ScreenThreshold float64
+
+	zw     *zip.Writer
+	nodes  map[TileID]*i3sNode
+	extent Bounds
+}
+
+type i3sNode struct {
+	meta TileMeta
+	mbs  [4]float64
+}
+
+// NewSLPKWriter starts a scene layer package on w.
+func NewSLPKWriter(w io.Writer, name string) *SLPKWriter {
+	return &SLPKWriter{
+		Name:   name,
+		zw:     zip.NewWriter(w),
+		nodes:  make(map[TileID]*i3sNode),
+		extent: Bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)},
+	}
+}
+
+func i3sNodeID(id TileID) string {
+	return strconv.Itoa(id.Z) + "-" + strconv.Itoa(id.X) + "-" + strconv.Itoa(id.Y)
+}
+
+// put stores a gzip-compressed resource; the package itself is not
+// compressed, as I3S requires.
+func (s *SLPKWriter) put(name string, data []byte) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	w, err := s.zw.CreateHeader(&zip.FileHeader{Name: name + ".gz", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (s *SLPKWriter) putJSON(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.put(name, data)
+}
+
+// Add writes the geometry of one tile, given in grid units as returned by
+// ToMesh, with its metadata. The tile is placed with meta.Scheme.
+func (s *SLPKWriter) Add(meta TileMeta, m *Mesh, gridSize int) error {
+	id := meta.ID()
+	if _, ok := s.nodes[id]; ok {
+		return errors.New("Expected every tile to be added once")
+	}
+	if err := meta.Scheme.checkLngLat(); err != nil {
+		return err
+	}
+	nv := m.NumVertices()
+	if nv == 0 {
+		return errors.New("Expected a mesh with vertices")
+	}
+
+	// Vertices in degrees and meters, and a local metric frame for normals.
+	geo := make([][3]float64, nv)
+	minZ, maxZ := math.Inf(1), math.Inf(-1)
+	for i := range geo {
+		x, y, z := m.Vertex(i)
+		lng, lat := meta.Scheme.gridToLngLat(id, x, y, gridSize)
+		geo[i] = [3]float64{lng, lat, z}
+		minZ, maxZ = math.Min(minZ, z), math.Max(maxZ, z)
+	}
+	b := meta.Scheme.tileBounds(id)
+	center := [3]float64{(b.West + b.East) / 2, (b.South + b.North) / 2, (minZ + maxZ) / 2}
+	metersX := 111320 * math.Cos(center[1]*math.Pi/180)
+	const metersY = 110540.0
+	local := &Mesh{Vertices: make([]float64, 3*nv), Triangles: m.Triangles}
+	c := ecef(center[0], center[1], center[2])
+	radius := 0.0
+	for i, p := range geo {
+		local.Vertices[3*i] = (p[0] - center[0]) * metersX
+		local.Vertices[3*i+1] = (p[1] - center[1]) * metersY
+		local.Vertices[3*i+2] = p[2] - center[2]
+		radius = math.Max(radius, distance3(ecef(p[0], p[1], p[2]), c))
+	}
+	normals := vertexNormals(local, 2)
+
+	// Non-indexed triangles in the default geometry schema: header,
+	// positions as offsets from the centre, normals, uv0, colors, then the
+	// single feature's id and face range.
+	nt := m.NumTriangles()
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, [2]uint32{uint32(3 * nt), 1})
+	for _, v := range m.Triangles {
+		p := geo[v]
+		binary.Write(&buf, le, [3]float32{float32(p[0] - center[0]), float32(p[1] - center[1]), float32(p[2] - center[2])})
+	}
+	for _, v := range m.Triangles {
+		n := normals[v]
+		binary.Write(&buf, le, [3]float32{float32(n[0]), float32(n[1]), float32(n[2])})
+	}
+	max := float64(gridSize - 1)
+	for _, v := range m.Triangles {
+		x, y, _ := m.Vertex(int(v))
+		binary.Write(&buf, le, [2]float32{float32(x / max), float32(y / max)})
+	}
+	for _, v := range m.Triangles {
+		rgba := [4]uint8{255, 255, 255, 255}
+		if len(m.Colors) == 3*nv {
+			copy(rgba[:3], m.Colors[3*v:])
+		}
+		buf.Write(rgba[:])
+	}
+	binary.Write(&buf, le, uint64(0))
+	binary.Write(&buf, le, [2]uint32{0, uint32(maxInt(nt-1, 0))})
+	if err := s.put("nodes/"+i3sNodeID(id)+"/geometries/0.bin", buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.nodes[id] = &i3sNode{meta: meta, mbs: [4]float64{center[0], center[1], center[2], radius}}
+	s.extent.West = math.Min(s.extent.West, b.West)
+	s.extent.South = math.Min(s.extent.South, b.South)
+	s.extent.East = math.Max(s.extent.East, b.East)
+	s.extent.North = math.Max(s.extent.North, b.North)
+	return nil
+}
+
+type i3sRef struct {
+	ID   string     `json:"id"`
+	Href string     `json:"href"`
+	MBS  [4]float64 `json:"mbs"`
+}
+
+type i3sNodeIndex struct {
+	ID           string     `json:"id"`
+	Level        int        `json:"level"`
+	MBS          [4]float64 `json:"mbs"`
+	LODSelection []struct {
+		MetricType string  `json:"metricType"`
+		MaxError   float64 `json:"maxError"`
+	} `json:"lodSelection"`
+	ParentNode   *i3sRef  `json:"parentNode,omitempty"`
+	Children     []i3sRef `json:"children,omitempty"`
+	GeometryData []struct {
+		Href string `json:"href"`
+	} `json:"geometryData,omitempty"`
+}
+
+// Close writes the node index documents, the layer description and the
+// package metadata, and finishes the archive. It does not close the
+// underlying writer.
+func (s *SLPKWriter) Close() error {
+	if len(s.nodes) == 0 {
+		return errors.New("Expected at least one tile")
+	}
+	threshold := s.ScreenThreshold
+	if threshold == 0 {
+		threshold = 512
+	}
+	ids := make([]TileID, 0, len(s.nodes))
+	for id := range s.nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+
+	ref := func(id TileID) i3sRef {
+		name := i3sNodeID(id)
+		return i3sRef{ID: name, Href: "../" + name, MBS: s.nodes[id].mbs}
+	}
+	root := i3sNodeIndex{ID: "root"}
+	docs := make(map[TileID]*i3sNodeIndex, len(ids))
+	minLng, minLat := math.Inf(1), math.Inf(1)
+	maxLng, maxLat := math.Inf(-1), math.Inf(-1)
+	minZ, maxZ := math.Inf(1), math.Inf(-1)
+	for _, id := range ids {
+		n := s.nodes[id]
+		doc := &i3sNodeIndex{ID: i3sNodeID(id), MBS: n.mbs}
+		doc.GeometryData = append(doc.GeometryData, struct {
+			Href string `json:"href"`
+		}{"./geometries/0"})
+		parentRef := &i3sRef{ID: "root", Href: "../root"}
+		parent := &root
+		if p, ok := docs[id.Parent()]; ok && id.Z > 0 {
+			r := ref(id.Parent())
+			parentRef, parent = &r, p
+		}
+		doc.ParentNode = parentRef
+		doc.Level = parent.Level + 1
+		parent.Children = append(parent.Children, ref(id))
+		docs[id] = doc
+
+		minLng, maxLng = math.Min(minLng, n.mbs[0]), math.Max(maxLng, n.mbs[0])
+		minLat, maxLat = math.Min(minLat, n.mbs[1]), math.Max(maxLat, n.mbs[1])
+		minZ, maxZ = math.Min(minZ, n.mbs[2]-n.mbs[3]), math.Max(maxZ, n.mbs[2]+n.mbs[3])
+	}
+	root.MBS = [4]float64{(minLng + maxLng) / 2, (minLat + maxLat) / 2, (minZ + maxZ) / 2, 0}
+	rc := ecef(root.MBS[0], root.MBS[1], root.MBS[2])
+	for _, c := range root.Children {
+		root.MBS[3] = math.Max(root.MBS[3], distance3(rc, ecef(c.MBS[0], c.MBS[1], c.MBS[2]))+c.MBS[3])
+	}
+
+	for _, doc := range append([]*i3sNodeIndex{&root}, nodeDocs(docs, ids)...) {
+		// A node with children switches to them above the threshold;
+		// leaves are drawn at any size.
+		limit := threshold
+		if len(doc.Children) == 0 {
+			limit = math.MaxFloat32
+		}
+		doc.LODSelection = append(doc.LODSelection, struct {
+			MetricType string  `json:"metricType"`
+			MaxError   float64 `json:"maxError"`
+		}{"maxScreenThreshold", limit})
+		if err := s.putJSON("nodes/"+doc.ID+"/3dNodeIndexDocument.json", doc); err != nil {
+			return err
+		}
+	}
+
+	attr := func(typ string, n int) map[string]interface{} {
+		return map[string]interface{}{"valueType": typ, "valuesPerElement": n}
+	}
+	layer := map[string]interface{}{
+		"id":        0,
+		"name":      s.Name,
+		"version":   "1.7",
+		"layerType": "IntegratedMesh",
+		"spatialReference": map[string]int{
+			"wkid": 4326,
+		},
+		"heightModelInfo": map[string]string{
+			"heightModel": "gravity_related_height",
+			"heightUnit":  "meter",
+		},
+		"store": map[string]interface{}{
+			"id":                   "martini",
+			"profile":              "meshpyramids",
+			"version":              "1.7",
+			"resourcePattern":      []string{"3dNodeIndexDocument", "Geometry"},
+			"rootNode":             "./nodes/root",
+			"extent":               []float64{s.extent.West, s.extent.South, s.extent.East, s.extent.North},
+			"indexCRS":             "http://www.opengis.net/def/crs/EPSG/0/4326",
+			"vertexCRS":            "http://www.opengis.net/def/crs/EPSG/0/4326",
+			"normalReferenceFrame": "east-north-up",
+			"nidEncoding":          "application/vnd.esri.i3s.json+gzip; version=1.7",
+			"lodType":              "MeshPyramid",
+			"lodModel":             "node-switching",
+			"defaultGeometrySchema": map[string]interface{}{
+				"geometryType": "triangles",
+				"header": []map[string]string{
+					{"property": "vertexCount", "type": "UInt32"},
+					{"property": "featureCount", "type": "UInt32"},
+				},
+				"topology": "PerAttributeArray",
+				"ordering": []string{"position", "normal", "uv0", "color"},
+				"vertexAttributes": map[string]interface{}{
+					"position": attr("Float32", 3),
+					"normal":   attr("Float32", 3),
+					"uv0":      attr("Float32", 2),
+					"color":    attr("UInt8", 4),
+				},
+				"featureAttributeOrder": []string{"id", "faceRange"},
+				"featureAttributes": map[string]interface{}{
+					"id":        attr("UInt64", 1),
+					"faceRange": attr("UInt32", 2),
+				},
+			},
+		},
+	}
+	if err := s.putJSON("3dSceneLayer.json", layer); err != nil {
+		return err
+	}
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"folderPattern":           "basic",
+		"archiveCompressionType":  "STORE",
+		"resourceCompressionType": "GZIP",
+		"I3SVersion":              "1.7",
+		"nodeCount":               len(ids) + 1,
+	})
+	w, err := s.zw.CreateHeader(&zip.FileHeader{Name: "metadata.json", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(metadata); err != nil {
+		return err
+	}
+	return s.zw.Close()
+}
+
+func nodeDocs(docs map[TileID]*i3sNodeIndex, ids []TileID) []*i3sNodeIndex {
+	out := make([]*i3sNodeIndex, len(ids))
+	for i, id := range ids {
+		out[i] = docs[id]
+	}
+	return out
+}
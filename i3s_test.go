@@ -0,0 +1,114 @@
+package martini
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func readSLPKEntry(t *testing.T, zr *zip.Reader, name string) []byte {
+	t.Helper()
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		if f.Method != zip.Store {
+			t.Errorf("%s is compressed in the archive", name)
+		}
+		rc, _ := f.Open()
+		data, _ := ioutil.ReadAll(rc)
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _ := ioutil.ReadAll(gz)
+		return out
+	}
+	t.Fatalf("missing %s", name)
+	return nil
+}
+
+func TestSLPKWriter(t *testing.T) {
+	m, _ := NewMartini(17)
+	var buf bytes.Buffer
+	s := NewSLPKWriter(&buf, "terrain")
+	children := TileID{0, 0, 0}.Children()
+	ids := append([]TileID{{0, 0, 0}}, children[:]...)
+	meshes := map[TileID]*Mesh{}
+	for _, id := range ids {
+		tile, _ := m.CreateTile(testTerrain(17, func(x, y int) float64 { return 500 + hills(x+16*id.X, y+16*id.Y) }))
+		mesh := tile.ToMesh(5)
+		meshes[id] = mesh
+		if err := s.Add(NewTileMeta(id, mesh, nil, 5, ""), mesh, 17); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(NewTileMeta(ids[0], meshes[ids[0]], nil, 5, ""), meshes[ids[0]], 17); err == nil {
+		t.Error("expected error for a duplicate tile")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var layer struct {
+		LayerType string
+		Store     struct {
+			RootNode string
+			Extent   []float64
+		}
+	}
+	json.Unmarshal(readSLPKEntry(t, zr, "3dSceneLayer.json.gz"), &layer)
+	if layer.LayerType != "IntegratedMesh" || layer.Store.RootNode != "./nodes/root" || layer.Store.Extent[0] != -180 {
+		t.Errorf("unexpected layer %+v", layer)
+	}
+
+	var root, z0, z1 i3sNodeIndex
+	json.Unmarshal(readSLPKEntry(t, zr, "nodes/root/3dNodeIndexDocument.json.gz"), &root)
+	json.Unmarshal(readSLPKEntry(t, zr, "nodes/0-0-0/3dNodeIndexDocument.json.gz"), &z0)
+	json.Unmarshal(readSLPKEntry(t, zr, "nodes/1-1-1/3dNodeIndexDocument.json.gz"), &z1)
+	if len(root.Children) != 1 || len(z0.Children) != 4 || z0.Level != 1 || z1.Level != 2 || z1.ParentNode.ID != "0-0-0" {
+		t.Errorf("unexpected hierarchy: root %+v, z0 %+v, z1 %+v", root, z0, z1)
+	}
+	if z0.LODSelection[0].MaxError != 512 || root.MBS[3] < z0.MBS[3] {
+		t.Errorf("unexpected LOD or bounds: %+v %+v", z0.LODSelection, root.MBS)
+	}
+
+	geom := readSLPKEntry(t, zr, "nodes/1-1-1/geometries/0.bin.gz")
+	mesh := meshes[TileID{1, 1, 1}]
+	n := len(mesh.Triangles)
+	if got := binary.LittleEndian.Uint32(geom); int(got) != n {
+		t.Errorf("vertex count %d, want %d", got, n)
+	}
+	if want := 8 + n*(12+12+8+4) + 8 + 8; len(geom) != want {
+		t.Errorf("geometry is %d bytes, want %d", len(geom), want)
+	}
+}
+
+func TestSLPKWriterScheme(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	s := NewSLPKWriter(ioutil.Discard, "terrain")
+	meta := NewTileMeta(TileID{0, 1, 0}, mesh, nil, 5, "")
+	meta.Scheme = SchemeWorldCRS84Quad
+	meta.Bounds, _ = SchemeWorldCRS84Quad.Bounds(meta.ID())
+	if err := s.Add(meta, mesh, 17); err != nil {
+		t.Fatal(err)
+	}
+	// The eastern tile of WorldCRS84Quad is centred on 90°E at the equator.
+	if mbs := s.nodes[meta.ID()].mbs; math.Abs(mbs[0]-90) > 1e-9 || math.Abs(mbs[1]) > 1e-9 {
+		t.Errorf("node centred at %v, want 90, 0", mbs)
+	}
+	if s.extent != meta.Bounds {
+		t.Errorf("extent %+v, want %+v", s.extent, meta.Bounds)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !martini_unsafe
+// +build !martini_unsafe
+
+package martini
+
+// The accessors below are used by the inner loops of Update and GetMesh.
+// Building with -tags martini_unsafe replaces them with versions that skip
+// bounds checks; see index_unsafe.go.
+
+func loadF64(s []float64, i int) float64 {
+	return s[i]
+}
+
+func storeF64(s []float64, i int, v float64) {
+	s[i] = v
+}
+
+func loadU16(s []uint16, i int) uint16 {
+	return s[i]
+}
+
+func storeU16(s []uint16, i int, v uint16) {
+	s[i] = v
+}
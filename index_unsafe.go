@@ -0,0 +1,32 @@
+//go:build martini_unsafe
+// +build martini_unsafe
+
+package martini
+
+import "unsafe"
+
+// With the martini_unsafe tag the inner loops of Update and GetMesh index
+// their slices without bounds checks. The indices are derived from the
+// grid size, so this is only safe for tiles whose Terrain, Errors and
+// Martini.Indices have the lengths NewTile and NewMartini give them.
+
+func loadF64(s []float64, i int) float64 {
+	return *(*float64)(unsafe.Pointer(uintptr(sliceData(unsafe.Pointer(&s))) + uintptr(i)*8))
+}
+
+func storeF64(s []float64, i int, v float64) {
+	*(*float64)(unsafe.Pointer(uintptr(sliceData(unsafe.Pointer(&s))) + uintptr(i)*8)) = v
+}
+
+func loadU16(s []uint16, i int) uint16 {
+	return *(*uint16)(unsafe.Pointer(uintptr(sliceData(unsafe.Pointer(&s))) + uintptr(i)*2))
+}
+
+func storeU16(s []uint16, i int, v uint16) {
+	*(*uint16)(unsafe.Pointer(uintptr(sliceData(unsafe.Pointer(&s))) + uintptr(i)*2)) = v
+}
+
+// sliceData returns the array pointer of the slice header at p.
+func sliceData(p unsafe.Pointer) unsafe.Pointer {
+	return *(*unsafe.Pointer)(p)
+}
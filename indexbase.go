@@ -0,0 +1,36 @@
+package martini
+
+import "fmt"
+
+// IndexBase is the number an exported index list gives the first vertex.
+type IndexBase int
+
+const (
+	// IndexBaseZero is used by glTF, PLY and the other binary formats.
+	IndexBaseZero IndexBase = 0
+	// IndexBaseOne is used by Wavefront OBJ.
+	IndexBaseOne IndexBase = 1
+)
+
+// ExportIndices returns the triangle indices of m as written to a file
+// numbering vertices from base, after offset vertices of earlier meshes
+// in the same file. Indices past the vertices of m are an error.
+func (m *Mesh) ExportIndices(base IndexBase, offset int) ([]int, error) {
+	if base != IndexBaseZero && base != IndexBaseOne {
+		return nil, fmt.Errorf("Invalid index base %d", base)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("Invalid index offset %d", offset)
+	}
+	n := m.NumVertices()
+	first := int(base) + offset
+	out := make([]int, len(m.Triangles)/3*3)
+	for i := range out {
+		v := m.Triangles[i]
+		if int64(v) >= int64(n) {
+			return nil, fmt.Errorf("Triangle index %d out of range for %d vertices", v, n)
+		}
+		out[i] = first + int(v)
+	}
+	return out, nil
+}
@@ -0,0 +1,35 @@
+package martini
+
+import "testing"
+
+func TestExportIndices(t *testing.T) {
+	mesh := &Mesh{
+		Vertices:  []float64{0, 0, 1, 1, 0, 2, 0, 1, 3, 1, 1, 4},
+		Triangles: []uint32{0, 1, 2, 2, 1, 3},
+	}
+	for _, tc := range []struct {
+		base   IndexBase
+		offset int
+		first  int
+	}{{IndexBaseZero, 0, 0}, {IndexBaseOne, 0, 1}, {IndexBaseOne, 10, 11}} {
+		got, err := mesh.ExportIndices(tc.base, tc.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range mesh.Triangles {
+			if got[i] != tc.first+int(v) {
+				t.Fatalf("base %d offset %d: index %d is %d", tc.base, tc.offset, i, got[i])
+			}
+		}
+	}
+	if _, err := mesh.ExportIndices(2, 0); err == nil {
+		t.Error("expected error for index base 2")
+	}
+	if _, err := mesh.ExportIndices(IndexBaseZero, -1); err == nil {
+		t.Error("expected error for negative offset")
+	}
+	bad := &Mesh{Vertices: mesh.Vertices, Triangles: []uint32{0, 1, 4}}
+	if _, err := bad.ExportIndices(IndexBaseOne, 0); err == nil {
+		t.Error("expected error for index past the vertices")
+	}
+}
@@ -0,0 +1,123 @@
+package martini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Journal records the tiles a build has completed so that an interrupted
+// BuildPyramid can resume where it stopped. It is an append-only file with
+// the JSON TileMeta of one tile per line; a line cut short by a crash is
+// discarded when the journal is reopened.
+type Journal struct {
+	mu   sync.Mutex
+	f    *os.File
+	done map[TileID]TileMeta
+}
+
+// OpenJournal opens or creates the journal at path and loads the tiles it
+// lists.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{f: f, done: make(map[TileID]TileMeta)}
+	r := bufio.NewReader(f)
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var meta TileMeta
+		if json.Unmarshal(bytes.TrimSpace(line), &meta) != nil {
+			break
+		}
+		j.done[meta.ID()] = meta
+		good += int64(len(line))
+	}
+	// Drop a partial last record and append after the good ones.
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Done reports whether tile id has been recorded.
+func (j *Journal) Done(id TileID) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.done[id]
+	return ok
+}
+
+// Len returns the number of recorded tiles.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.done)
+}
+
+// Record appends a completed tile.
+func (j *Journal) Record(meta TileMeta) error {
+	line, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.done[meta.ID()] = meta
+	return nil
+}
+
+// Metas returns the recorded tiles ordered by zoom, x and y.
+func (j *Journal) Metas() []TileMeta {
+	j.mu.Lock()
+	metas := make([]TileMeta, 0, len(j.done))
+	for _, m := range j.done {
+		metas = append(metas, m)
+	}
+	j.mu.Unlock()
+	sort.Slice(metas, func(a, b int) bool {
+		ma, mb := metas[a], metas[b]
+		if ma.Zoom != mb.Zoom {
+			return ma.Zoom < mb.Zoom
+		}
+		if ma.X != mb.X {
+			return ma.X < mb.X
+		}
+		return ma.Y < mb.Y
+	})
+	return metas
+}
+
+// Sync flushes the journal to stable storage.
+func (j *Journal) Sync() error {
+	return j.f.Sync()
+}
+
+func (j *Journal) Close() error {
+	if err := j.f.Sync(); err != nil {
+		j.f.Close()
+		return err
+	}
+	return j.f.Close()
+}
@@ -0,0 +1,98 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestJournalResume(t *testing.T) {
+	dir := testDir(t)
+	path := filepath.Join(dir, "build.journal")
+	sink := &DirSink{Dir: filepath.Join(dir, "tiles"), Extension: "glb"}
+
+	var mu sync.Mutex
+	requested := make(map[TileID]int)
+	crash := true
+	source := TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		mu.Lock()
+		requested[id]++
+		fail := crash && id.Z == 2
+		mu.Unlock()
+		if fail {
+			return nil, errors.New("crash")
+		}
+		return testSource().Terrain(ctx, id, gridSize)
+	})
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := PyramidOptions{MinZoom: 0, MaxZoom: 2, GridSize: 17, MaxError: 5, Workers: 1, Journal: j, Format: Format3DTiles}
+	if err := BuildPyramid(context.Background(), source, sink, opts); err == nil {
+		t.Fatal("expected the build to fail")
+	}
+	if j.Len() != 5 {
+		t.Errorf("expected zooms 0 and 1 to be journaled, got %d tiles", j.Len())
+	}
+	j.Close()
+
+	// A record cut short by the crash is dropped.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"zoom":2,"x":0,`)
+	f.Close()
+
+	if j, err = OpenJournal(path); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if j.Len() != 5 || !j.Done(TileID{1, 1, 1}) || j.Done(TileID{2, 0, 0}) {
+		t.Fatalf("unexpected journal after reopening: %v", j.Metas())
+	}
+	mu.Lock()
+	crash = false
+	requested = make(map[TileID]int)
+	mu.Unlock()
+	var tileset bytes.Buffer
+	opts.Journal = j
+	opts.Tileset = &tileset
+	if err := BuildPyramid(context.Background(), source, sink, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 16 {
+		t.Errorf("expected only the 16 tiles of zoom 2 to be built, got %d", len(requested))
+	}
+	for id := range requested {
+		if id.Z != 2 {
+			t.Errorf("tile %v rebuilt", id)
+		}
+	}
+	if j.Len() != 21 {
+		t.Errorf("expected 21 journaled tiles, got %d", j.Len())
+	}
+	if _, err := os.Stat(filepath.Join(sink.Dir, "2", "3", "3.glb")); err != nil {
+		t.Error(err)
+	}
+
+	// The tileset covers the tiles of both runs.
+	var doc struct {
+		Root struct {
+			Children []json.RawMessage `json:"children"`
+		} `json:"root"`
+	}
+	if err := json.Unmarshal(tileset.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Root.Children) == 0 {
+		t.Error("expected tileset children")
+	}
+	if n := bytes.Count(tileset.Bytes(), []byte(`"zoom"`)); n != 21 {
+		t.Errorf("expected 21 tiles in the tileset, got %d", n)
+	}
+}
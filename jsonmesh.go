@@ -0,0 +1,101 @@
+package martini
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// MeshJSONFields lists the fields EncodeMeshJSON can write.
+var MeshJSONFields = []string{"numVertices", "numTriangles", "vertices", "triangles", "colors", "attributes"}
+
+// JSONOptions selects the fields written by EncodeMeshJSON and
+// NDJSONEncoder. Nil or empty Fields writes all of MeshJSONFields, leaving
+// out colors and attributes when the mesh has none.
+type JSONOptions struct {
+	Fields []string
+}
+
+func meshJSON(m *Mesh, opts *JSONOptions) (map[string]interface{}, error) {
+	fields := MeshJSONFields
+	all := opts == nil || len(opts.Fields) == 0
+	if !all {
+		fields = opts.Fields
+	}
+	obj := make(map[string]interface{}, len(fields)+1)
+	for _, f := range fields {
+		switch f {
+		case "numVertices":
+			obj[f] = m.NumVertices()
+		case "numTriangles":
+			obj[f] = m.NumTriangles()
+		case "vertices":
+			obj[f] = nonNil(m.Vertices)
+		case "triangles":
+			if m.Triangles == nil {
+				obj[f] = []uint32{}
+			} else {
+				obj[f] = m.Triangles
+			}
+		case "colors":
+			if all && m.Colors == nil {
+				continue
+			}
+			// Numbers rather than the base64 string of a []byte.
+			colors := make([]int, len(m.Colors))
+			for i, c := range m.Colors {
+				colors[i] = int(c)
+			}
+			obj[f] = colors
+		case "attributes":
+			if all && m.Attributes == nil {
+				continue
+			}
+			attrs := make(map[string][]float64, len(m.Attributes))
+			for _, a := range m.Attributes {
+				attrs[a.Name] = nonNil(a.Values)
+			}
+			obj[f] = attrs
+		default:
+			return nil, errors.New("Unknown mesh JSON field " + f)
+		}
+	}
+	return obj, nil
+}
+
+func nonNil(v []float64) []float64 {
+	if v == nil {
+		return []float64{}
+	}
+	return v
+}
+
+// EncodeMeshJSON writes the mesh as one JSON object with lower camel case
+// keys, suited to jq and web prototypes.
+func EncodeMeshJSON(w io.Writer, m *Mesh, opts *JSONOptions) error {
+	obj, err := meshJSON(m, opts)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(obj)
+}
+
+// NDJSONEncoder streams tile meshes as newline delimited JSON, one object
+// per tile with a "tile" key holding z/x/y.
+type NDJSONEncoder struct {
+	enc  *json.Encoder
+	opts *JSONOptions
+}
+
+func NewNDJSONEncoder(w io.Writer, opts *JSONOptions) *NDJSONEncoder {
+	return &NDJSONEncoder{enc: json.NewEncoder(w), opts: opts}
+}
+
+func (e *NDJSONEncoder) Encode(id TileID, m *Mesh) error {
+	obj, err := meshJSON(m, e.opts)
+	if err != nil {
+		return err
+	}
+	obj["tile"] = id.String()
+	return e.enc.Encode(obj)
+}
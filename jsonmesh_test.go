@@ -0,0 +1,59 @@
+package martini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeMeshJSON(t *testing.T) {
+	mesh := &Mesh{
+		Vertices:  []float64{0, 0, 1, 1, 0, 2, 0, 1, 3},
+		Triangles: []uint32{0, 1, 2},
+		Colors:    []uint8{255, 0, 0, 0, 255, 0, 0, 0, 255},
+	}
+	var buf bytes.Buffer
+	if err := EncodeMeshJSON(&buf, mesh, nil); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &got)
+	if got["numTriangles"].(float64) != 1 || len(got["vertices"].([]interface{})) != 9 {
+		t.Errorf("unexpected object %v", got)
+	}
+	if c := got["colors"].([]interface{}); c[0].(float64) != 255 {
+		t.Errorf("expected numeric colors, got %v", c)
+	}
+	if _, ok := got["attributes"]; ok {
+		t.Error("expected no attributes")
+	}
+
+	buf.Reset()
+	EncodeMeshJSON(&buf, mesh, &JSONOptions{Fields: []string{"numVertices"}})
+	if buf.String() != "{\"numVertices\":3}\n" {
+		t.Errorf("unexpected selection %q", buf.String())
+	}
+	if err := EncodeMeshJSON(&buf, mesh, &JSONOptions{Fields: []string{"normals"}}); err == nil {
+		t.Error("expected unknown field error")
+	}
+}
+
+func TestNDJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewNDJSONEncoder(&buf, &JSONOptions{Fields: []string{"numTriangles"}})
+	enc.Encode(TileID{1, 0, 1}, &Mesh{Triangles: []uint32{0, 1, 2}})
+	enc.Encode(TileID{1, 1, 1}, &Mesh{})
+
+	var lines []map[string]interface{}
+	for s := bufio.NewScanner(&buf); s.Scan(); {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &obj); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, obj)
+	}
+	if len(lines) != 2 || lines[0]["tile"] != "1/0/1" || lines[1]["numTriangles"].(float64) != 0 {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
@@ -0,0 +1,221 @@
+package martini
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// LASHeader holds the public header fields of a LAS file needed to read
+// its points.
+type LASHeader struct {
+	VersionMajor, VersionMinor uint8
+	PointFormat                uint8
+	PointCount                 uint64
+	Scale, Offset              [3]float64
+	Min, Max                   [3]float64
+}
+
+// LASPoint is a point in the coordinate system of the file.
+type LASPoint struct {
+	X, Y, Z        float64
+	Classification uint8
+}
+
+// LASClassGround is the ASPRS classification of ground points.
+const LASClassGround = 2
+
+// LASReader streams the points of an uncompressed LAS 1.0 to 1.4 file.
+// LAZ files are rejected: LASzip's arithmetic coding is not implemented,
+// so decompress them with laszip first.
+type LASReader struct {
+	Header LASHeader
+	r      *bufio.Reader
+	record []byte
+	read   uint64
+}
+
+// NewLASReader reads the header of a LAS file and positions r at its first
+// point.
+func NewLASReader(r io.Reader) (*LASReader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, 227)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, err
+	}
+	if string(head[:4]) != "LASF" {
+		return nil, errors.New("Expected a LAS file")
+	}
+	le := binary.LittleEndian
+	h := LASHeader{
+		VersionMajor: head[24],
+		VersionMinor: head[25],
+		PointFormat:  head[104],
+		PointCount:   uint64(le.Uint32(head[107:])),
+	}
+	if h.PointFormat&0xc0 != 0 {
+		return nil, errors.New("Expected an uncompressed LAS file, not LAZ; decompress it with laszip")
+	}
+	if h.PointFormat > 10 {
+		return nil, errors.New("Expected a LAS point format from 0 to 10")
+	}
+	for i := 0; i < 3; i++ {
+		h.Scale[i] = math.Float64frombits(le.Uint64(head[131+8*i:]))
+		h.Offset[i] = math.Float64frombits(le.Uint64(head[155+8*i:]))
+		h.Max[i] = math.Float64frombits(le.Uint64(head[179+16*i:]))
+		h.Min[i] = math.Float64frombits(le.Uint64(head[187+16*i:]))
+	}
+	headerSize := int(le.Uint16(head[94:]))
+	dataOffset := int(le.Uint32(head[96:]))
+	recordLen := int(le.Uint16(head[105:]))
+	if headerSize < 227 || dataOffset < headerSize || recordLen < 20 {
+		return nil, errors.New("Expected a consistent LAS header")
+	}
+	rest := make([]byte, headerSize-227)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, err
+	}
+	// Skip the variable length records without trusting dataOffset with
+	// an allocation.
+	if _, err := io.CopyN(ioutil.Discard, br, int64(dataOffset-headerSize)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	// LAS 1.4 moves counts over 2^32 into a 64-bit field.
+	if h.VersionMinor >= 4 && headerSize >= 255 && h.PointCount == 0 {
+		h.PointCount = le.Uint64(rest[247-227:])
+	}
+	return &LASReader{Header: h, r: br, record: make([]byte, recordLen)}, nil
+}
+
+// Next returns the next point, or io.EOF after the last one.
+func (l *LASReader) Next() (LASPoint, error) {
+	if l.read >= l.Header.PointCount {
+		return LASPoint{}, io.EOF
+	}
+	if _, err := io.ReadFull(l.r, l.record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return LASPoint{}, err
+	}
+	l.read++
+	le := binary.LittleEndian
+	h := &l.Header
+	p := LASPoint{
+		X: float64(int32(le.Uint32(l.record[0:])))*h.Scale[0] + h.Offset[0],
+		Y: float64(int32(le.Uint32(l.record[4:])))*h.Scale[1] + h.Offset[1],
+		Z: float64(int32(le.Uint32(l.record[8:])))*h.Scale[2] + h.Offset[2],
+	}
+	if h.PointFormat >= 6 {
+		p.Classification = l.record[16]
+	} else {
+		p.Classification = l.record[15] & 0x1f
+	}
+	return p, nil
+}
+
+// LASGridOptions configures GridLAS.
+type LASGridOptions struct {
+	GridSize int
+	// Bounds is MinX, MinY, MaxX, MaxY in the file's coordinate system.
+	// The zero value uses the extent in the header.
+	Bounds [4]float64
+	// Classes selects the point classes to keep; nil keeps ground only.
+	Classes []uint8
+	// Aggregation combines the points nearest to the same grid sample.
+	Aggregation Aggregation
+	// Fill interpolates samples without points; FillNone leaves them NaN.
+	Fill FillMethod
+}
+
+// GridLAS bins the points of a LAS file onto a gridSize*gridSize terrain
+// grid, north up, ready for NewTile. Every point goes to its nearest grid
+// sample.
+func GridLAS(r *LASReader, opts LASGridOptions) ([]float64, FillReport, error) {
+	size := opts.GridSize
+	if !validGridSize(size) {
+		return nil, FillReport{}, errors.New("Expected grid size to be 2^n+1")
+	}
+	b := opts.Bounds
+	if b == [4]float64{} {
+		h := r.Header
+		b = [4]float64{h.Min[0], h.Min[1], h.Max[0], h.Max[1]}
+	}
+	if !(b[2] > b[0] && b[3] > b[1]) {
+		return nil, FillReport{}, errors.New("Expected non-empty bounds")
+	}
+	var keep [256]bool
+	if opts.Classes == nil {
+		keep[LASClassGround] = true
+	}
+	for _, c := range opts.Classes {
+		keep[c] = true
+	}
+
+	n := size * size
+	count := make([]int, n)
+	sum := make([]float64, n)
+	min := make([]float64, n)
+	max := make([]float64, n)
+	dx := (b[2] - b[0]) / float64(size-1)
+	dy := (b[3] - b[1]) / float64(size-1)
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, FillReport{}, err
+		}
+		if !keep[p.Classification] {
+			continue
+		}
+		x := int(math.Round((p.X - b[0]) / dx))
+		y := int(math.Round((b[3] - p.Y) / dy))
+		if x < 0 || y < 0 || x >= size || y >= size {
+			continue
+		}
+		i := y*size + x
+		if count[i] == 0 {
+			min[i], max[i] = p.Z, p.Z
+		}
+		count[i]++
+		sum[i] += p.Z
+		min[i] = math.Min(min[i], p.Z)
+		max[i] = math.Max(max[i], p.Z)
+	}
+
+	terrain := make([]float64, n)
+	for i := range terrain {
+		if count[i] == 0 {
+			terrain[i] = math.NaN()
+			continue
+		}
+		mean := sum[i] / float64(count[i])
+		switch opts.Aggregation {
+		case AggregateMax:
+			terrain[i] = max[i]
+		case AggregateMin:
+			terrain[i] = min[i]
+		case AggregateExtreme:
+			if max[i]-mean >= mean-min[i] {
+				terrain[i] = max[i]
+			} else {
+				terrain[i] = min[i]
+			}
+		default:
+			terrain[i] = mean
+		}
+	}
+	if opts.Fill == FillNone {
+		return terrain, FillReport{}, nil
+	}
+	report, err := FillHoles(terrain, size, nil, opts.Fill)
+	return terrain, report, err
+}
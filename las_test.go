@@ -0,0 +1,135 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// writeLAS encodes points as a LAS file of the given minor version and
+// point format, with centimeter resolution.
+func writeLAS(minor, format uint8, points []LASPoint) []byte {
+	headerSize, recordLen := 227, 28
+	if minor >= 4 {
+		headerSize = 375
+	}
+	if format >= 6 {
+		recordLen = 30
+	}
+	head := make([]byte, headerSize)
+	le := binary.LittleEndian
+	copy(head, "LASF")
+	head[24], head[25] = 1, minor
+	le.PutUint16(head[94:], uint16(headerSize))
+	le.PutUint32(head[96:], uint32(headerSize))
+	head[104] = format
+	le.PutUint16(head[105:], uint16(recordLen))
+	if minor >= 4 {
+		le.PutUint64(head[247:], uint64(len(points)))
+	} else {
+		le.PutUint32(head[107:], uint32(len(points)))
+	}
+	min := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range points {
+		for i, v := range [3]float64{p.X, p.Y, p.Z} {
+			min[i], max[i] = math.Min(min[i], v), math.Max(max[i], v)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		le.PutUint64(head[131+8*i:], math.Float64bits(0.01))
+		le.PutUint64(head[179+16*i:], math.Float64bits(max[i]))
+		le.PutUint64(head[187+16*i:], math.Float64bits(min[i]))
+	}
+	buf := bytes.NewBuffer(head)
+	for _, p := range points {
+		rec := make([]byte, recordLen)
+		le.PutUint32(rec[0:], uint32(int32(math.Round(p.X*100))))
+		le.PutUint32(rec[4:], uint32(int32(math.Round(p.Y*100))))
+		le.PutUint32(rec[8:], uint32(int32(math.Round(p.Z*100))))
+		if format >= 6 {
+			rec[16] = p.Classification
+		} else {
+			rec[15] = p.Classification
+		}
+		buf.Write(rec)
+	}
+	return buf.Bytes()
+}
+
+func TestGridLAS(t *testing.T) {
+	// Ground points on a plane over 0..16, with one building point and a
+	// missing row at y = 8.
+	var points []LASPoint
+	for y := 0; y <= 16; y++ {
+		for x := 0; x <= 16; x++ {
+			if y == 8 {
+				continue
+			}
+			points = append(points, LASPoint{X: float64(x), Y: float64(y), Z: float64(x + y), Classification: LASClassGround})
+		}
+	}
+	points = append(points, LASPoint{X: 4, Y: 4, Z: 500, Classification: 6})
+
+	for _, v := range [][2]uint8{{2, 1}, {4, 6}} {
+		r, err := NewLASReader(bytes.NewReader(writeLAS(v[0], v[1], points)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Header.PointCount != uint64(len(points)) {
+			t.Fatalf("LAS 1.%d: point count %d", v[0], r.Header.PointCount)
+		}
+		terrain, report, err := GridLAS(r, LASGridOptions{GridSize: 17, Fill: FillLaplacian})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Voids != 17 || report.Filled != 17 {
+			t.Errorf("LAS 1.%d: fill report %+v", v[0], report)
+		}
+		for y := 0; y < 17; y++ {
+			for x := 0; x < 17; x++ {
+				// Row 0 is north, at the largest y.
+				tolerance := 0.01
+				if y == 8 {
+					tolerance = 1
+				}
+				if got, want := terrain[y*17+x], float64(x+16-y); math.Abs(got-want) > tolerance {
+					t.Fatalf("LAS 1.%d: sample (%d, %d) = %v, want %v", v[0], x, y, got, want)
+				}
+			}
+		}
+	}
+
+	r, _ := NewLASReader(bytes.NewReader(writeLAS(2, 1, points)))
+	terrain, _, _ := GridLAS(r, LASGridOptions{GridSize: 17, Classes: []uint8{LASClassGround, 6}, Aggregation: AggregateMax})
+	if terrain[12*17+4] != 500 {
+		t.Errorf("expected the building point with AggregateMax, got %v", terrain[12*17+4])
+	}
+	if !math.IsNaN(terrain[8*17]) {
+		t.Error("expected NaN voids without filling")
+	}
+
+	laz := writeLAS(2, 1, points)
+	laz[104] |= 0x80
+	if _, err := NewLASReader(bytes.NewReader(laz)); err == nil {
+		t.Error("expected LAZ to be rejected")
+	}
+	// A data offset past the end of the file is an error, not a 4 GiB
+	// allocation.
+	huge := writeLAS(2, 1, points)
+	binary.LittleEndian.PutUint32(huge[96:], math.MaxUint32)
+	if _, err := NewLASReader(bytes.NewReader(huge)); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected a truncated file, got %v", err)
+	}
+	short := writeLAS(2, 1, points)
+	r, _ = NewLASReader(bytes.NewReader(short[:len(short)-10]))
+	var err error
+	for err == nil {
+		_, err = r.Next()
+	}
+	if err == io.EOF {
+		t.Error("expected a truncated file error")
+	}
+}
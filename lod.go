@@ -0,0 +1,46 @@
+package martini
+
+import "math"
+
+// LODRing applies MaxError to the samples within Radius grid units of a
+// centre point. Distance is measured in the maximum norm, so rings are
+// squares aligned with the grid, as in a clipmap.
+type LODRing struct {
+	Radius   float64
+	MaxError float64
+}
+
+// ringTile returns a view of t where every sample uses the MaxError of the
+// first ring that contains it, and maxError outside all rings.
+func (t *Tile) ringTile(cx, cy float64, rings []LODRing, maxError float64) *Tile {
+	size := t.Martini.GridSize
+	thresholds := make([]float64, len(t.Terrain))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			d := math.Max(math.Abs(float64(x)-cx), math.Abs(float64(y)-cy))
+			e := maxError
+			for _, r := range rings {
+				if d <= r.Radius {
+					e = r.MaxError
+					break
+				}
+			}
+			thresholds[y*size+x] = e
+		}
+	}
+	return t.thresholdTile(thresholds)
+}
+
+// GetMeshRings is GetMesh with a different maxError for concentric rings
+// around the grid point (cx, cy). List rings from the innermost out; a
+// sample takes the first ring that contains it and maxError beyond the last
+// one. Fine centres with coarse edges and the reverse both work, and the
+// result is a single crack-free mesh.
+func (t *Tile) GetMeshRings(cx, cy float64, rings []LODRing, maxError float64) ([]uint16, []uint16) {
+	return t.ringTile(cx, cy, rings, maxError).GetMesh(1)
+}
+
+// ToMeshRings is ToMesh with concentric LOD rings; see GetMeshRings.
+func (t *Tile) ToMeshRings(cx, cy float64, rings []LODRing, maxError float64) *Mesh {
+	return t.ringTile(cx, cy, rings, maxError).ToMesh(1)
+}
@@ -0,0 +1,59 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+// tJunctions counts vertices lying strictly inside a triangle edge, which
+// would leave a crack once heights are applied.
+func tJunctions(m *Mesh) int {
+	n := 0
+	for i := 0; i < len(m.Triangles); i += 3 {
+		for k := 0; k < 3; k++ {
+			ax, ay, _ := m.Vertex(int(m.Triangles[i+k]))
+			bx, by, _ := m.Vertex(int(m.Triangles[i+(k+1)%3]))
+			for v := 0; v < m.NumVertices(); v++ {
+				x, y, _ := m.Vertex(v)
+				if (bx-ax)*(y-ay)-(by-ay)*(x-ax) != 0 {
+					continue
+				}
+				if d := (x-ax)*(bx-ax) + (y-ay)*(by-ay); d > 0 && d < (bx-ax)*(bx-ax)+(by-ay)*(by-ay) {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestMeshRings(t *testing.T) {
+	m, _ := NewMartini(65)
+	tile, _ := m.CreateTile(testTerrain(65, hills))
+
+	rings := []LODRing{{Radius: 8, MaxError: 0.5}, {Radius: 20, MaxError: 5}}
+	for _, rs := range [][]LODRing{rings, {{Radius: 8, MaxError: 50}}} {
+		mesh := tile.ToMeshRings(32, 32, rs, 10)
+		if n := tJunctions(mesh); n != 0 {
+			t.Errorf("%v: %d T-junctions", rs, n)
+		}
+		var near, far int
+		for i := 0; i < mesh.NumVertices(); i++ {
+			x, y, _ := mesh.Vertex(i)
+			switch d := math.Max(math.Abs(x-32), math.Abs(y-32)); {
+			case d < 8:
+				near++
+			case d > 24 && d <= 32:
+				far++
+			}
+		}
+		// Compare densities: 225 samples lie in the centre, 1824 in the band.
+		if fine := rs[0].MaxError < 1; fine != (near*1824 > far*225) {
+			t.Errorf("%v: %d vertices in the centre, %d at the edges", rs, near, far)
+		}
+	}
+
+	if got, ref := tile.ToMeshRings(32, 32, nil, 3), tile.ToMesh(3); !CompareMeshes(got, ref).Equal() {
+		t.Error("expected no rings to match ToMesh")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package martini
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var _ Logger = (*slog.Logger)(nil)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer(t)
+	s.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/5/0/0.json", nil))
+	if !strings.Contains(buf.String(), "tile=5/0/0") {
+		t.Errorf("expected structured failure log, got %q", buf.String())
+	}
+}
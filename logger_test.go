@@ -0,0 +1,42 @@
+package martini
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (l *recordingLogger) log(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, level+" "+msg+" "+fmt.Sprint(args...))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args) }
+
+func TestLogger(t *testing.T) {
+	l := &recordingLogger{}
+	tiles := []TileID{{1, 0, 0}, {5, 0, 0}}
+	opts := BatchOptions{GridSize: 17, MaxError: 5, Workers: 1, Logger: l, SlowTile: time.Nanosecond}
+	for range ProcessTiles(context.Background(), testSource(), tiles, opts) {
+	}
+
+	all := strings.Join(l.records, "\n")
+	if !strings.Contains(all, "ERROR tile failed tile5/0/0") {
+		t.Errorf("expected failure record, got:\n%s", all)
+	}
+	if !strings.Contains(all, "WARN slow tile tile1/0/0") {
+		t.Errorf("expected slow tile record, got:\n%s", all)
+	}
+}
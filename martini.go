@@ -2,18 +2,51 @@ package martini
 
 import (
 	"errors"
-	"math"
+	"image"
+	"sync"
 )
 
 type Martini struct {
 	GridSize           int
 	NumTriangles       int
 	NumParentTriangles int
-	Indices            []uint16
-	Coords             []uint16
+	// Indices is the scratch buffer of GetMesh, which makes a Martini
+	// unsafe for concurrent extraction. When nil, as for the Martinis of
+	// For, GetMesh takes buffers from a pool instead.
+	Indices []uint16
+	Coords  []uint16
+
+	alloc   Allocator
+	backend ErrorBackend
+	// scratch pools the Indices buffers of GetMesh when Indices is nil.
+	scratch sync.Pool
 }
 
 func NewMartini(gridSize int) (*Martini, error) {
+	return NewMartiniWithOptions(gridSize, nil)
+}
+
+// MartiniOptions tunes how the triangle hierarchy is stored.
+type MartiniOptions struct {
+	// Coords precomputes the Coords table, 8 bytes per triangle. Update
+	// does not need it; without it coordinates are derived from the
+	// triangle id when the hierarchy is serialized.
+	Coords bool
+	// Allocator provides the Coords, Indices and tile Errors buffers.
+	// Nil uses the Go heap.
+	Allocator Allocator
+	// Backend, when set, computes the error pyramid in Update instead of
+	// the CPU; see ErrorBackend.
+	Backend ErrorBackend
+}
+
+func NewMartiniWithOptions(gridSize int, opts *MartiniOptions) (*Martini, error) {
+	return newMartini(gridSize, opts, true)
+}
+
+// newMartini builds the hierarchy, without the Indices buffer for a shared
+// Martini.
+func newMartini(gridSize int, opts *MartiniOptions, indices bool) (*Martini, error) {
 	mt := Martini{}
 	mt.GridSize = gridSize
 	tileSize := gridSize - 1
@@ -22,51 +55,24 @@ func NewMartini(gridSize int) (*Martini, error) {
 	}
 	mt.NumTriangles = tileSize*tileSize*2 - 2
 	mt.NumParentTriangles = mt.NumTriangles - tileSize*tileSize
-	mt.Indices = make([]uint16, gridSize*gridSize)
-	mt.Coords = make([]uint16, mt.NumTriangles*4)
-	for i := 0; i < mt.NumTriangles; i++ {
-		id := i + 2
-		ax := 0
-		ay := 0
-		bx := 0
-		by := 0
-		cx := 0
-		cy := 0
-		if (id & 1) > 0 {
-			bx = tileSize
-			by = tileSize
-			cx = tileSize
-		} else {
-			ax = tileSize
-			ay = tileSize
-			cy = tileSize
-		}
-		id >>= 1
-		if id > 1 {
-			for {
-				mx := (ax + bx) >> 1
-				my := (ay + by) >> 1
-
-				if (id & 1) > 0 { // left half
-					bx = ax
-					by = ay
-					ax = cx
-					ay = cy
-				} else { // right half
-					ax = bx
-					ay = by
-					bx = cx
-					by = cy
-				}
-				cx = mx
-				cy = my
-				id >>= 1
-				if id <= 1 {
-					break
-				}
-			}
+	if opts != nil {
+		mt.alloc = opts.Allocator
+		mt.backend = opts.Backend
+	}
+	var err error
+	if indices {
+		if mt.Indices, err = allocUint16s(mt.allocator(), gridSize*gridSize); err != nil {
+			return nil, err
 		}
-
+	}
+	if opts == nil || !opts.Coords {
+		return &mt, nil
+	}
+	if mt.Coords, err = allocUint16s(mt.allocator(), mt.NumTriangles*4); err != nil {
+		return nil, err
+	}
+	for i := 0; i < mt.NumTriangles; i++ {
+		ax, ay, bx, by := triangleCoords(i, tileSize)
 		k := i * 4
 		mt.Coords[k+0] = uint16(ax)
 		mt.Coords[k+1] = uint16(ay)
@@ -76,6 +82,62 @@ func NewMartini(gridSize int) (*Martini, error) {
 	return &mt, nil
 }
 
+func triangleCoords(i, tileSize int) (int, int, int, int) {
+	id := i + 2
+	ax := 0
+	ay := 0
+	bx := 0
+	by := 0
+	cx := 0
+	cy := 0
+	if (id & 1) > 0 {
+		bx = tileSize
+		by = tileSize
+		cx = tileSize
+	} else {
+		ax = tileSize
+		ay = tileSize
+		cy = tileSize
+	}
+	id >>= 1
+	if id > 1 {
+		for {
+			mx := (ax + bx) >> 1
+			my := (ay + by) >> 1
+
+			if (id & 1) > 0 { // left half
+				bx = ax
+				by = ay
+				ax = cx
+				ay = cy
+			} else { // right half
+				ax = bx
+				ay = by
+				bx = cx
+				by = cy
+			}
+			cx = mx
+			cy = my
+			id >>= 1
+			if id <= 1 {
+				break
+			}
+		}
+	}
+	return ax, ay, bx, by
+}
+
+// triangle returns the hypotenuse endpoints of triangle i, computing them
+// when the hierarchy is compact.
+func (m *Martini) triangle(i int) (ax, ay, bx, by uint16) {
+	if m.Coords == nil {
+		a, b, c, d := triangleCoords(i, m.GridSize-1)
+		return uint16(a), uint16(b), uint16(c), uint16(d)
+	}
+	k := i * 4
+	return m.Coords[k+0], m.Coords[k+1], m.Coords[k+2], m.Coords[k+3]
+}
+
 func (m *Martini) CreateTile(terrain []float64) (*Tile, error) {
 	return NewTile(terrain, m)
 }
@@ -84,46 +146,68 @@ type Tile struct {
 	Terrain []float64
 	Martini *Martini
 	Errors  []float64
+	// Attributes are auxiliary rasters, such as landcover class, sampled
+	// at every vertex of the extracted meshes.
+	Attributes []Attribute
+	// Orthophoto, when set, is an image covering the same extent as the
+	// terrain, corner to corner, sampled for per-vertex colors.
+	Orthophoto image.Image
+	// FillReport counts the voids TileOptions.Fill found and filled.
+	FillReport FillReport
+
+	// thresholds, when set, holds a per-sample maxError that local errors
+	// are divided by before propagation; see GetMeshBathymetry.
+	thresholds []float64
+	// bias, when set, is added to the local error of every sample; see
+	// TileOptions.Curvature.
+	bias []float64
+	// curvature is the TileOptions.Curvature weight bias was computed
+	// with, so that Split can recompute it for the children.
+	curvature float64
+	// seed, when set during Update, supplies the errors a tile created by
+	// Split shares with its parent.
+	seed *splitSeed
 }
 
 func NewTile(terrain []float64, martini *Martini) (*Tile, error) {
+	return newTile(terrain, martini, nil)
+}
+
+func newTile(terrain []float64, martini *Martini, bias []float64) (*Tile, error) {
 	size := martini.GridSize
 	if len(terrain) != size*size {
 		return nil, errors.New("Expected terrain data of length ")
 	}
-	errors := make([]float64, len(terrain))
-	t := Tile{Terrain: terrain, Martini: martini, Errors: errors}
+	errs, err := allocFloat64s(martini.allocator(), len(terrain))
+	if err != nil {
+		return nil, err
+	}
+	t := Tile{Terrain: terrain, Martini: martini, Errors: errs, bias: bias}
 	t.Update()
 	return &t, nil
 }
 
-func (t *Tile) Update() {
-	m := t.Martini
-	size := m.GridSize
+// AddAttribute registers an auxiliary raster aligned with the terrain
+// whose values are carried through to the vertices of extracted meshes.
+func (t *Tile) AddAttribute(name string, values []float64) error {
+	if len(values) != len(t.Terrain) {
+		return errors.New("Expected attribute data of the same length as the terrain")
+	}
+	t.Attributes = append(t.Attributes, Attribute{Name: name, Values: values})
+	return nil
+}
 
-	for i := m.NumTriangles - 1; i >= 0; i-- {
-		k := i * 4
-		ax := m.Coords[k+0]
-		ay := m.Coords[k+1]
-		bx := m.Coords[k+2]
-		by := m.Coords[k+3]
-		mx := (ax + bx) >> 1
-		my := (ay + by) >> 1
-		cx := mx + my - ay
-		cy := my + ax - mx
-
-		interpolatedHeight := (t.Terrain[int(ay)*size+int(ax)] + t.Terrain[int(by)*size+int(bx)]) / 2
-		middleIndex := int(my)*size + int(mx)
-		middleError := math.Abs(interpolatedHeight - t.Terrain[middleIndex])
-
-		t.Errors[middleIndex] = math.Max(t.Errors[middleIndex], middleError)
-
-		if i < m.NumParentTriangles {
-			leftChildIndex := (int(ay+cy)>>1)*size + (int(ax+cx) >> 1)
-			rightChildIndex := (int(by+cy)>>1)*size + (int(bx+cx) >> 1)
-			t.Errors[middleIndex] = math.Max(math.Max(t.Errors[middleIndex], t.Errors[leftChildIndex]), t.Errors[rightChildIndex])
-		}
+// Update computes the error pyramid. Instead of walking the triangle
+// hierarchy by id, which strides unpredictably through Terrain, it sweeps
+// the grid level by level from the finest triangles up: every level's
+// hypotenuse midpoints form a regular lattice whose rows can be visited in
+// memory order, and a level only depends on the one below it.
+func (t *Tile) Update() {
+	if t.updateOnBackend() {
+		return
 	}
+	size := t.Martini.GridSize
+	t.sweep(func(pass func(y0, y1 int)) { pass(0, size) })
 }
 
 func abs(n int) int {
@@ -133,88 +217,104 @@ func abs(n int) int {
 	return n
 }
 
-func (t *Tile) countElements(ax, ay, bx, by, cx, cy int, maxError float64, numTriangles *int, numVertices *int) {
-	m := t.Martini
-	size := m.GridSize
+// meshTraversal holds the state of one GetMesh pass, so the recursion reads
+// locals instead of following t.Martini and out-parameters on every call.
+type meshTraversal struct {
+	errors   []float64
+	indices  []uint16
+	size     int
+	maxError float64
 
-	mx := (ax + bx) >> 1
-	my := (ay + by) >> 1
+	numVertices  int
+	numTriangles int
 
-	if abs(ax-cx)+abs(ay-cy) > 1 && t.Errors[my*size+mx] > maxError {
-		t.countElements(cx, cy, ax, ay, mx, my, maxError, numTriangles, numVertices)
-		t.countElements(bx, by, cx, cy, mx, my, maxError, numTriangles, numVertices)
-	} else {
-		if m.Indices[ay*size+ax] == 0 {
-			(*numVertices)++
-			m.Indices[ay*size+ax] = uint16(*numVertices)
-		}
-		if m.Indices[by*size+bx] == 0 {
-			(*numVertices)++
-			m.Indices[by*size+bx] = uint16(*numVertices)
-		}
-		if m.Indices[cy*size+cx] == 0 {
-			(*numVertices)++
-			m.Indices[cy*size+cx] = uint16(*numVertices)
-		}
-		(*numTriangles)++
-	}
+	vertices  []uint16
+	triangles []uint16
+	triIndex  int
 }
 
-func (t *Tile) processTriangle(ax, ay, bx, by, cx, cy int, maxError float64, triIndex *int, vertices, triangles []uint16) {
-	m := t.Martini
-	size := m.GridSize
-
-	mx := (ax + bx) >> 1
-	my := (ay + by) >> 1
-
-	if abs(ax-cx)+abs(ay-cy) > 1 && t.Errors[my*size+mx] > maxError {
-		t.processTriangle(cx, cy, ax, ay, mx, my, maxError, triIndex, vertices, triangles)
-		t.processTriangle(bx, by, cx, cy, mx, my, maxError, triIndex, vertices, triangles)
-
-	} else {
-		a := t.Martini.Indices[ay*size+ax] - 1
-		b := t.Martini.Indices[by*size+bx] - 1
-		c := t.Martini.Indices[cy*size+cx] - 1
-
-		vertices[2*a] = uint16(ax)
-		vertices[2*a+1] = uint16(ay)
+// split reports whether the triangle with hypotenuse a→b and apex c is
+// refined further, and returns the hypotenuse midpoint.
+func (tr *meshTraversal) split(ax, ay, bx, by, cx, cy int) (mx, my int, ok bool) {
+	mx = (ax + bx) >> 1
+	my = (ay + by) >> 1
+	return mx, my, abs(ax-cx)+abs(ay-cy) > 1 && loadF64(tr.errors, my*tr.size+mx) > tr.maxError
+}
 
-		vertices[2*b] = uint16(bx)
-		vertices[2*b+1] = uint16(by)
+// vertex numbers grid point (x, y) the first time it is reached.
+func (tr *meshTraversal) vertex(x, y int) {
+	if k := y*tr.size + x; loadU16(tr.indices, k) == 0 {
+		tr.numVertices++
+		storeU16(tr.indices, k, uint16(tr.numVertices))
+	}
+}
 
-		vertices[2*c] = uint16(cx)
-		vertices[2*c+1] = uint16(cy)
+func (tr *meshTraversal) countElements(ax, ay, bx, by, cx, cy int) {
+	if mx, my, ok := tr.split(ax, ay, bx, by, cx, cy); ok {
+		tr.countElements(cx, cy, ax, ay, mx, my)
+		tr.countElements(bx, by, cx, cy, mx, my)
+		return
+	}
+	tr.vertex(ax, ay)
+	tr.vertex(bx, by)
+	tr.vertex(cx, cy)
+	tr.numTriangles++
+}
 
-		triangles[*triIndex] = a
-		(*triIndex)++
-		triangles[*triIndex] = b
-		(*triIndex)++
-		triangles[*triIndex] = c
-		(*triIndex)++
+func (tr *meshTraversal) processTriangle(ax, ay, bx, by, cx, cy int) {
+	if mx, my, ok := tr.split(ax, ay, bx, by, cx, cy); ok {
+		tr.processTriangle(cx, cy, ax, ay, mx, my)
+		tr.processTriangle(bx, by, cx, cy, mx, my)
+		return
 	}
+	size := tr.size
+	a := int(loadU16(tr.indices, ay*size+ax) - 1)
+	b := int(loadU16(tr.indices, by*size+bx) - 1)
+	c := int(loadU16(tr.indices, cy*size+cx) - 1)
+
+	v := tr.vertices
+	storeU16(v, 2*a, uint16(ax))
+	storeU16(v, 2*a+1, uint16(ay))
+	storeU16(v, 2*b, uint16(bx))
+	storeU16(v, 2*b+1, uint16(by))
+	storeU16(v, 2*c, uint16(cx))
+	storeU16(v, 2*c+1, uint16(cy))
+
+	i := tr.triIndex
+	storeU16(tr.triangles, i, uint16(a))
+	storeU16(tr.triangles, i+1, uint16(b))
+	storeU16(tr.triangles, i+2, uint16(c))
+	tr.triIndex = i + 3
 }
 
 func (t *Tile) GetMesh(maxError float64) ([]uint16, []uint16) {
 	m := t.Martini
 	size := m.GridSize
-
-	numVertices := 0
-	numTriangles := 0
 	max := size - 1
 
-	for i := range m.Indices {
-		m.Indices[i] = 0
+	indices := m.Indices
+	if indices == nil {
+		buf, _ := m.scratch.Get().(*[]uint16)
+		if buf == nil {
+			buf = new([]uint16)
+			*buf = make([]uint16, size*size)
+		}
+		defer m.scratch.Put(buf)
+		indices = *buf
+	}
+	for i := range indices {
+		indices[i] = 0
 	}
 
-	t.countElements(0, 0, max, max, max, 0, maxError, &numTriangles, &numVertices)
-	t.countElements(max, max, 0, 0, 0, max, maxError, &numTriangles, &numVertices)
+	tr := meshTraversal{errors: t.Errors, indices: indices, size: size, maxError: maxError}
+	tr.countElements(0, 0, max, max, max, 0)
+	tr.countElements(max, max, 0, 0, 0, max)
 
-	vertices := make([]uint16, numVertices*2)
-	triangles := make([]uint16, numTriangles*3)
-	triIndex := 0
+	tr.vertices = make([]uint16, tr.numVertices*2)
+	tr.triangles = make([]uint16, tr.numTriangles*3)
 
-	t.processTriangle(0, 0, max, max, max, 0, maxError, &triIndex, vertices, triangles)
-	t.processTriangle(max, max, 0, 0, 0, max, maxError, &triIndex, vertices, triangles)
+	tr.processTriangle(0, 0, max, max, max, 0)
+	tr.processTriangle(max, max, 0, 0, 0, max)
 
-	return vertices, triangles
+	return tr.vertices, tr.triangles
 }
@@ -72,3 +72,53 @@ func TestGenerates(t *testing.T) {
 		t.Error("ss")
 	}
 }
+
+func TestCompactMartini(t *testing.T) {
+	terrain := testTerrain(65, hills)
+	full, _ := NewMartiniWithOptions(65, &MartiniOptions{Coords: true})
+	compact, err := NewMartini(65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compact.Coords != nil || len(full.Coords) != 4*full.NumTriangles {
+		t.Error("expected the Coords table only on request")
+	}
+
+	a, _ := full.CreateTile(terrain)
+	b, _ := compact.CreateTile(terrain)
+	for i := range a.Errors {
+		if a.Errors[i] != b.Errors[i] {
+			t.Fatalf("errors differ at %d", i)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	class := testTerrain(17, func(x, y int) float64 { return float64(x + 100*y) })
+	if err := tile.AddAttribute("class", class); err != nil {
+		t.Fatal(err)
+	}
+	if err := tile.AddAttribute("short", class[1:]); err == nil {
+		t.Error("expected length error")
+	}
+	mesh := tile.ToMesh(5)
+	if len(mesh.Attributes) != 1 || len(mesh.Attributes[0].Values) != mesh.NumVertices() {
+		t.Fatal("expected one attribute value per vertex")
+	}
+	for i, v := range mesh.Attributes[0].Values {
+		if x, y, _ := mesh.Vertex(i); v != x+100*y {
+			t.Fatalf("vertex %d: got %v", i, v)
+		}
+	}
+}
+
+func BenchmarkGetMesh(b *testing.B) {
+	m, _ := NewMartini(1025)
+	tile, _ := m.CreateTile(testTerrain(1025, hills))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tile.GetMesh(0.1)
+	}
+}
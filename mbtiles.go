@@ -0,0 +1,195 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/png"
+	"sync"
+)
+
+// MBTilesSource is a TerrainSource reading Terrain-RGB or Terrarium PNG
+// tiles from an MBTiles database. The package has no SQLite dependency: open
+// DB with the driver of your choice, for example
+// sql.Open("sqlite3", "terrain.mbtiles").
+//
+// MBTiles rows are numbered from the south (TMS); tile ids are XYZ as
+// everywhere else. Tiles of gridSize-1 pixels are completed with the first
+// column of the eastern and the first row of the southern neighbour so that
+// adjacent meshes share their edges. Without a neighbour the last row or
+// column repeats its predecessor, as DecodeElevation does.
+type MBTilesSource struct {
+	DB       *sql.DB
+	Encoding Encoding
+	// RGB, when set, overrides Encoding with a custom channel layout.
+	RGB *RGBEncoding
+}
+
+func (s *MBTilesSource) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	img, err := s.tile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, fmt.Errorf("tile %v: not found in MBTiles", id)
+	}
+	enc := s.RGB
+	if enc == nil {
+		enc = s.Encoding.RGB()
+	}
+	terrain, err := enc.Decode(img, gridSize)
+	if err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	if img.Bounds().Dx() == gridSize {
+		return terrain, nil
+	}
+
+	n := 1 << uint(id.Z)
+	last := gridSize - 1
+	neighbour := func(dx, dy int) ([]float64, error) {
+		if id.Y+dy >= n {
+			return nil, nil
+		}
+		// Wrap around the antimeridian.
+		img, err := s.tile(ctx, TileID{id.Z, (id.X + dx) % n, id.Y + dy})
+		if img == nil || err != nil {
+			return nil, err
+		}
+		return enc.Decode(img, gridSize)
+	}
+	east, err := neighbour(1, 0)
+	if err != nil {
+		return nil, err
+	}
+	south, err := neighbour(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if east != nil {
+		for y := 0; y < last; y++ {
+			terrain[y*gridSize+last] = east[y*gridSize]
+		}
+	}
+	if south != nil {
+		for x := 0; x < last; x++ {
+			terrain[last*gridSize+x] = south[x]
+		}
+	}
+	corner, err := neighbour(1, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case corner != nil:
+		terrain[last*gridSize+last] = corner[0]
+	case east != nil:
+		terrain[last*gridSize+last] = east[last*gridSize]
+	case south != nil:
+		terrain[last*gridSize+last] = south[last]
+	}
+	return terrain, nil
+}
+
+// tile returns the decoded image of a tile, or nil if the database does not
+// have it.
+func (s *MBTilesSource) tile(ctx context.Context, id TileID) (image.Image, error) {
+	var data []byte
+	row := (1 << uint(id.Z)) - 1 - id.Y
+	err := s.DB.QueryRowContext(ctx,
+		"SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?",
+		id.Z, id.X, row).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	// Some writers gzip every tile regardless of format.
+	if data, err = DecompressTile(data); err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	return img, nil
+}
+
+// Metadata returns the name/value pairs of the MBTiles metadata table, such
+// as format, bounds, minzoom and maxzoom.
+func (s *MBTilesSource) Metadata(ctx context.Context) (map[string]string, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT name, value FROM metadata")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	meta := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		meta[name] = value
+	}
+	return meta, rows.Err()
+}
+
+// MBTilesSink writes tiles to an MBTiles database, creating its tables on
+// the first tile. Like MBTilesSource it takes DB from the SQLite driver of
+// your choice, and numbers rows from the south.
+type MBTilesSink struct {
+	DB *sql.DB
+	// Format, if set, is recorded as the format metadata, such as
+	// "terrain" for quantized-mesh tiles.
+	Format string
+	// Compression, if set, pre-compresses the tiles and records the
+	// content encoding as contentEncoding metadata, as DirSink does.
+	Compression Compression
+
+	once sync.Once
+	err  error
+}
+
+func (s *MBTilesSink) WriteTile(ctx context.Context, id TileID, data []byte) error {
+	data, err := CompressTile(data, s.Compression)
+	if err != nil {
+		return err
+	}
+	s.once.Do(func() { s.err = s.create(ctx) })
+	if s.err != nil {
+		return s.err
+	}
+	row := (1 << uint(id.Z)) - 1 - id.Y
+	_, err = s.DB.ExecContext(ctx,
+		"INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)",
+		id.Z, id.X, row, data)
+	return err
+}
+
+// create sets up the tables of the MBTiles schema and the metadata.
+func (s *MBTilesSink) create(ctx context.Context) error {
+	for _, q := range []string{
+		"CREATE TABLE IF NOT EXISTS metadata (name TEXT, value TEXT)",
+		"CREATE TABLE IF NOT EXISTS tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS tile_index ON tiles (zoom_level, tile_column, tile_row)",
+	} {
+		if _, err := s.DB.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	for _, kv := range [][2]string{{"format", s.Format}, {"contentEncoding", s.Compression.ContentEncoding()}} {
+		if kv[1] == "" {
+			continue
+		}
+		if _, err := s.DB.ExecContext(ctx, "DELETE FROM metadata WHERE name = ?", kv[0]); err != nil {
+			return err
+		}
+		if _, err := s.DB.ExecContext(ctx, "INSERT INTO metadata (name, value) VALUES (?, ?)", kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
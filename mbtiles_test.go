@@ -0,0 +1,190 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// memMBTiles is a database/sql driver answering the queries of
+// MBTilesSource and MBTilesSink from memory, standing in for SQLite.
+type memMBTiles struct {
+	tiles    map[[3]int64][]byte
+	metadata map[string]string
+}
+
+func (d *memMBTiles) Open(string) (driver.Conn, error) { return d, nil }
+func (d *memMBTiles) Close() error                     { return nil }
+func (d *memMBTiles) Begin() (driver.Tx, error)        { return nil, errors.New("read only") }
+
+func (d *memMBTiles) Prepare(query string) (driver.Stmt, error) {
+	return &memStmt{d, query}, nil
+}
+
+type memStmt struct {
+	db    *memMBTiles
+	query string
+}
+
+func (s *memStmt) Close() error  { return nil }
+func (s *memStmt) NumInput() int { return -1 }
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "CREATE"):
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE INTO tiles"):
+		s.db.tiles[[3]int64{args[0].(int64), args[1].(int64), args[2].(int64)}] = args[3].([]byte)
+	case strings.HasPrefix(s.query, "DELETE FROM metadata"):
+		delete(s.db.metadata, args[0].(string))
+	case strings.HasPrefix(s.query, "INSERT INTO metadata"):
+		s.db.metadata[args[0].(string)] = args[1].(string)
+	default:
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.Contains(s.query, "FROM metadata") {
+		rows := &memRows{columns: []string{"name", "value"}}
+		for k, v := range s.db.metadata {
+			rows.values = append(rows.values, []driver.Value{k, v})
+		}
+		return rows, nil
+	}
+	rows := &memRows{columns: []string{"tile_data"}}
+	if data, ok := s.db.tiles[[3]int64{args[0].(int64), args[1].(int64), args[2].(int64)}]; ok {
+		rows.values = append(rows.values, []driver.Value{data})
+	}
+	return rows, nil
+}
+
+type memRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *memRows) Columns() []string { return r.columns }
+func (r *memRows) Close() error      { return nil }
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestMBTilesSource(t *testing.T) {
+	const gridSize = 17
+	height := func(gx, gy int) float64 { return float64(gx + 2*gy) }
+	db := &memMBTiles{tiles: make(map[[3]int64][]byte), metadata: map[string]string{"format": "png"}}
+	// Zoom 1 without the south-east tile.
+	for _, id := range []TileID{{1, 0, 0}, {1, 1, 0}, {1, 0, 1}} {
+		terrain := make([]float64, gridSize*gridSize)
+		for j := 0; j < gridSize; j++ {
+			for i := 0; i < gridSize; i++ {
+				terrain[j*gridSize+i] = height(id.X*16+i, id.Y*16+j)
+			}
+		}
+		var buf bytes.Buffer
+		if err := WriteElevationPNG(&buf, terrain, gridSize, EncodingTerrarium); err != nil {
+			t.Fatal(err)
+		}
+		db.tiles[[3]int64{1, int64(id.X), int64(1 - id.Y)}] = buf.Bytes()
+	}
+	name := fmt.Sprintf("martini-mbtiles-%p", db)
+	sql.Register(name, db)
+	conn, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s := &MBTilesSource{DB: conn, Encoding: EncodingTerrarium}
+
+	terrain, err := s.Terrain(context.Background(), TileID{1, 0, 0}, gridSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < gridSize; j++ {
+		for i := 0; i < gridSize; i++ {
+			want := height(i, j)
+			if i == 16 && j == 16 {
+				// No corner tile: repeat the eastern neighbour's last row.
+				want = height(16, 15)
+			}
+			if got := terrain[j*gridSize+i]; got != want {
+				t.Fatalf("sample %d,%d: got %v want %v", i, j, got, want)
+			}
+		}
+	}
+
+	// The missing southern neighbour is backfilled, the eastern one wraps.
+	terrain, err = s.Terrain(context.Background(), TileID{1, 1, 0}, gridSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terrain[16] != height(0, 0) || terrain[16*gridSize] != height(16, 15) {
+		t.Errorf("unexpected edges %v %v", terrain[16], terrain[16*gridSize])
+	}
+
+	if _, err := s.Terrain(context.Background(), TileID{1, 1, 1}, gridSize); err == nil {
+		t.Error("expected error for a missing tile")
+	}
+	meta, err := s.Metadata(context.Background())
+	if err != nil || meta["format"] != "png" {
+		t.Errorf("unexpected metadata %v %v", meta, err)
+	}
+}
+
+func TestMBTilesSink(t *testing.T) {
+	db := &memMBTiles{tiles: make(map[[3]int64][]byte), metadata: make(map[string]string)}
+	name := fmt.Sprintf("martini-mbtiles-%p", db)
+	sql.Register(name, db)
+	conn, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	terrain := testTerrain(17, hills)
+	var buf bytes.Buffer
+	if err := WriteElevationPNG(&buf, terrain, 17, EncodingTerrarium); err != nil {
+		t.Fatal(err)
+	}
+	sink := &MBTilesSink{DB: conn, Format: "png", Compression: CompressionGzip}
+	if err := sink.WriteTile(context.Background(), TileID{2, 1, 0}, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	stored := db.tiles[[3]int64{2, 1, 3}]
+	if len(stored) < 2 || stored[0] != 0x1f || stored[1] != 0x8b {
+		t.Fatal("expected the stored tile to be gzipped in TMS row 3")
+	}
+	if db.metadata["format"] != "png" || db.metadata["contentEncoding"] != "gzip" {
+		t.Errorf("unexpected metadata %v", db.metadata)
+	}
+
+	// MBTilesSource reads the gzipped tile back.
+	got, err := (&MBTilesSource{DB: conn, Encoding: EncodingTerrarium}).Terrain(context.Background(), TileID{2, 1, 0}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The PNG has 16 pixels; the last row and column repeat their
+	// predecessors without neighbours.
+	for i, h := range terrain {
+		if i%17 == 16 || i/17 == 16 {
+			continue
+		}
+		if math.Abs(got[i]-h) > 0.01 {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], h)
+		}
+	}
+}
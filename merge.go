@@ -0,0 +1,89 @@
+package martini
+
+import "math"
+
+// PlacedMesh positions a tile mesh inside a larger region.
+type PlacedMesh struct {
+	Mesh    *Mesh
+	OffsetX float64
+	OffsetY float64
+	OffsetZ float64
+}
+
+type vertexKey struct {
+	x, y, z float64
+}
+
+// MergeMeshes offsets every tile mesh by its placement and concatenates them
+// into one mesh. Vertices that land on exactly the same position, such as
+// those along shared tile edges, are welded into one, keeping the
+// attributes and colors of the first. Attributes are matched by name;
+// vertices of meshes without one get NaN, and vertices of meshes without
+// colors are black when others have them.
+func MergeMeshes(tiles []PlacedMesh) *Mesh {
+	merged := &Mesh{}
+	seen := make(map[vertexKey]uint32)
+	sources := make([]*Mesh, len(tiles))
+	for i, p := range tiles {
+		sources[i] = p.Mesh
+	}
+	data := newVertexData(merged, sources...)
+	for _, p := range tiles {
+		remap := make([]uint32, p.Mesh.NumVertices())
+		for i := range remap {
+			x, y, z := p.Mesh.Vertex(i)
+			k := vertexKey{x + p.OffsetX, y + p.OffsetY, z + p.OffsetZ}
+			idx, ok := seen[k]
+			if !ok {
+				idx = uint32(merged.NumVertices())
+				seen[k] = idx
+				merged.Vertices = append(merged.Vertices, k.x, k.y, k.z)
+				data.add(p.Mesh, i)
+			}
+			remap[i] = idx
+		}
+		for _, v := range p.Mesh.Triangles {
+			merged.Triangles = append(merged.Triangles, remap[v])
+		}
+	}
+	return merged
+}
+
+// vertexData fills the attributes and colors of a mesh built from the
+// vertices of several others, as described for MergeMeshes.
+type vertexData struct {
+	m      *Mesh
+	attrs  map[string]int
+	colors bool
+}
+
+// newVertexData gives m the union of the attributes of sources.
+func newVertexData(m *Mesh, sources ...*Mesh) *vertexData {
+	d := &vertexData{m: m, attrs: make(map[string]int)}
+	for _, src := range sources {
+		for _, a := range src.Attributes {
+			if _, ok := d.attrs[a.Name]; !ok {
+				d.attrs[a.Name] = len(m.Attributes)
+				m.Attributes = append(m.Attributes, Attribute{Name: a.Name})
+			}
+		}
+		d.colors = d.colors || len(src.Colors) > 0
+	}
+	return d
+}
+
+// add appends the attributes and colors of vertex i of src.
+func (d *vertexData) add(src *Mesh, i int) {
+	for j := range d.m.Attributes {
+		d.m.Attributes[j].Values = append(d.m.Attributes[j].Values, math.NaN())
+	}
+	for _, a := range src.Attributes {
+		values := d.m.Attributes[d.attrs[a.Name]].Values
+		values[len(values)-1] = a.Values[i]
+	}
+	if len(src.Colors) > 0 {
+		d.m.Colors = append(d.m.Colors, src.Colors[3*i:3*i+3]...)
+	} else if d.colors {
+		d.m.Colors = append(d.m.Colors, 0, 0, 0)
+	}
+}
@@ -0,0 +1,46 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMergeMeshes(t *testing.T) {
+	martini, _ := NewMartini(17)
+	west, _ := martini.CreateTile(testTerrain(17, hills))
+	east, _ := martini.CreateTile(testTerrain(17, func(x, y int) float64 { return hills(x+16, y) }))
+
+	a := west.ToMesh(0)
+	b := east.ToMesh(0)
+	merged := MergeMeshes([]PlacedMesh{{Mesh: a}, {Mesh: b, OffsetX: 16}})
+
+	if merged.NumTriangles() != a.NumTriangles()+b.NumTriangles() {
+		t.Errorf("expected %d triangles, got %d", a.NumTriangles()+b.NumTriangles(), merged.NumTriangles())
+	}
+	if want := 33 * 17; merged.NumVertices() != want {
+		t.Errorf("expected %d welded vertices, got %d", want, merged.NumVertices())
+	}
+
+	// Only the east tile has an attribute and colors.
+	b.Attributes = []Attribute{{Name: "class", Values: make([]float64, b.NumVertices())}}
+	b.Colors = make([]uint8, 3*b.NumVertices())
+	for i := range b.Attributes[0].Values {
+		b.Attributes[0].Values[i] = 1
+		b.Colors[3*i] = 255
+	}
+	merged = MergeMeshes([]PlacedMesh{{Mesh: a}, {Mesh: b, OffsetX: 16}})
+	if len(merged.Attributes) != 1 || len(merged.Attributes[0].Values) != merged.NumVertices() || len(merged.Colors) != 3*merged.NumVertices() {
+		t.Fatalf("attributes or colors not merged: %d attributes, %d colors", len(merged.Attributes), len(merged.Colors))
+	}
+	for i := 0; i < merged.NumVertices(); i++ {
+		// Vertices on the shared edge come from the west tile first.
+		x, _, _ := merged.Vertex(i)
+		east := x > 16
+		if v := merged.Attributes[0].Values[i]; east && v != 1 || !east && !math.IsNaN(v) {
+			t.Fatalf("vertex %d at x=%v has attribute %v", i, x, v)
+		}
+		if c := merged.Colors[3*i]; east != (c == 255) {
+			t.Fatalf("vertex %d at x=%v has red %d", i, x, c)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package martini
+
+// Mesh is a triangulated terrain surface. Vertices holds x, y, z triples in
+// grid units (x, y) and terrain units (z); Triangles holds three vertex
+// indices per triangle.
+type Mesh struct {
+	Vertices  []float64
+	Triangles []uint32
+	// Attributes holds extra per-vertex values sampled from the tile's
+	// auxiliary rasters.
+	Attributes []Attribute `json:",omitempty"`
+	// Colors holds r, g, b triples per vertex sampled from the tile's
+	// orthophoto.
+	Colors []uint8 `json:",omitempty"`
+}
+
+// Attribute is a named scalar per grid sample on a Tile, or per vertex on a
+// Mesh.
+type Attribute struct {
+	Name   string
+	Values []float64
+}
+
+func (m *Mesh) NumVertices() int {
+	return len(m.Vertices) / 3
+}
+
+func (m *Mesh) NumTriangles() int {
+	return len(m.Triangles) / 3
+}
+
+func (m *Mesh) Vertex(i int) (x, y, z float64) {
+	return m.Vertices[3*i], m.Vertices[3*i+1], m.Vertices[3*i+2]
+}
+
+// ToMesh extracts the mesh for maxError and lifts its vertices to the
+// terrain heights.
+func (t *Tile) ToMesh(maxError float64) *Mesh {
+	vertices, triangles := t.GetMesh(maxError)
+	indices := make([]uint32, len(triangles))
+	for i, v := range triangles {
+		indices[i] = uint32(v)
+	}
+	return t.liftMesh(vertices, indices)
+}
+
+// liftMesh builds a Mesh from grid x, y pairs, sampling heights,
+// attributes and colors at each vertex.
+func (t *Tile) liftMesh(vertices []uint16, triangles []uint32) *Mesh {
+	size := t.Martini.GridSize
+	mesh := &Mesh{
+		Vertices:  make([]float64, len(vertices)/2*3),
+		Triangles: triangles,
+	}
+	for i := 0; i < len(vertices)/2; i++ {
+		x := vertices[2*i]
+		y := vertices[2*i+1]
+		mesh.Vertices[3*i] = float64(x)
+		mesh.Vertices[3*i+1] = float64(y)
+		mesh.Vertices[3*i+2] = t.Terrain[int(y)*size+int(x)]
+	}
+	for _, a := range t.Attributes {
+		values := make([]float64, len(vertices)/2)
+		for i := range values {
+			values[i] = a.Values[int(vertices[2*i+1])*size+int(vertices[2*i])]
+		}
+		mesh.Attributes = append(mesh.Attributes, Attribute{Name: a.Name, Values: values})
+	}
+	if t.Orthophoto != nil {
+		mesh.Colors = make([]uint8, len(vertices)/2*3)
+		for i := 0; i < len(vertices)/2; i++ {
+			r, g, b := sampleColor(t.Orthophoto, size, float64(vertices[2*i]), float64(vertices[2*i+1]))
+			mesh.Colors[3*i], mesh.Colors[3*i+1], mesh.Colors[3*i+2] = r, g, b
+		}
+	}
+	return mesh
+}
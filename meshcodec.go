@@ -0,0 +1,165 @@
+package martini
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+var meshMagic = [4]byte{'M', 'R', 'M', 'S'}
+
+const meshVersion = 1
+
+const meshHasColors = 1 << 0
+
+// meshHeader starts the binary mesh format. Vertices follow as float64,
+// then triangles as uint32, colors as r, g, b bytes when flagged, and every
+// attribute as a uint32 name length, the name and float64 values. All
+// numbers are little-endian.
+type meshHeader struct {
+	Magic         [4]byte
+	Version       uint32
+	Flags         uint32
+	NumVertices   uint32
+	NumTriangles  uint32
+	NumAttributes uint32
+}
+
+// Marshal encodes the mesh in a compact versioned binary format, read back
+// by UnmarshalMesh.
+func (m *Mesh) Marshal() []byte {
+	nv := m.NumVertices()
+	size := 24 + 8*len(m.Vertices) + 4*len(m.Triangles)
+	h := meshHeader{
+		Magic:         meshMagic,
+		Version:       meshVersion,
+		NumVertices:   uint32(nv),
+		NumTriangles:  uint32(m.NumTriangles()),
+		NumAttributes: uint32(len(m.Attributes)),
+	}
+	if len(m.Colors) == 3*nv && nv > 0 {
+		h.Flags |= meshHasColors
+		size += len(m.Colors)
+	}
+	for _, a := range m.Attributes {
+		size += 4 + len(a.Name) + 8*nv
+	}
+
+	buf := make([]byte, size)
+	le := binary.LittleEndian
+	copy(buf, h.Magic[:])
+	le.PutUint32(buf[4:], h.Version)
+	le.PutUint32(buf[8:], h.Flags)
+	le.PutUint32(buf[12:], h.NumVertices)
+	le.PutUint32(buf[16:], h.NumTriangles)
+	le.PutUint32(buf[20:], h.NumAttributes)
+	p := 24
+	for _, v := range m.Vertices[:3*nv] {
+		le.PutUint64(buf[p:], math.Float64bits(v))
+		p += 8
+	}
+	for _, v := range m.Triangles[:3*m.NumTriangles()] {
+		le.PutUint32(buf[p:], v)
+		p += 4
+	}
+	if h.Flags&meshHasColors != 0 {
+		p += copy(buf[p:], m.Colors)
+	}
+	for _, a := range m.Attributes {
+		le.PutUint32(buf[p:], uint32(len(a.Name)))
+		p += 4
+		p += copy(buf[p:], a.Name)
+		for i := 0; i < nv; i++ {
+			le.PutUint64(buf[p:], math.Float64bits(a.Values[i]))
+			p += 8
+		}
+	}
+	return buf
+}
+
+var errShortMesh = errors.New("Unexpected end of mesh data")
+
+// UnmarshalMesh decodes a mesh encoded by Marshal.
+func UnmarshalMesh(data []byte) (*Mesh, error) {
+	if len(data) < 24 {
+		return nil, errShortMesh
+	}
+	le := binary.LittleEndian
+	if string(data[:4]) != string(meshMagic[:]) {
+		return nil, errors.New("Expected mesh magic")
+	}
+	if le.Uint32(data[4:]) != meshVersion {
+		return nil, errors.New("Unsupported mesh version")
+	}
+	flags := le.Uint32(data[8:])
+	nv := int(le.Uint32(data[12:]))
+	nt := int(le.Uint32(data[16:]))
+	na := int(le.Uint32(data[20:]))
+	data = data[24:]
+
+	need := 24*nv + 12*nt
+	if flags&meshHasColors != 0 {
+		need += 3 * nv
+	}
+	if len(data) < need || nv < 0 || nt < 0 {
+		return nil, errShortMesh
+	}
+	m := &Mesh{
+		Vertices:  make([]float64, 3*nv),
+		Triangles: make([]uint32, 3*nt),
+	}
+	for i := range m.Vertices {
+		m.Vertices[i] = math.Float64frombits(le.Uint64(data[8*i:]))
+	}
+	data = data[24*nv:]
+	for i := range m.Triangles {
+		m.Triangles[i] = le.Uint32(data[4*i:])
+		if int(m.Triangles[i]) >= nv {
+			return nil, errors.New("Triangle index out of range")
+		}
+	}
+	data = data[12*nt:]
+	if flags&meshHasColors != 0 {
+		m.Colors = append([]uint8(nil), data[:3*nv]...)
+		data = data[3*nv:]
+	}
+	for a := 0; a < na; a++ {
+		if len(data) < 4 {
+			return nil, errShortMesh
+		}
+		n := int(le.Uint32(data))
+		if len(data) < 4+n+8*nv {
+			return nil, errShortMesh
+		}
+		attr := Attribute{Name: string(data[4 : 4+n]), Values: make([]float64, nv)}
+		data = data[4+n:]
+		for i := range attr.Values {
+			attr.Values[i] = math.Float64frombits(le.Uint64(data[8*i:]))
+		}
+		data = data[8*nv:]
+		m.Attributes = append(m.Attributes, attr)
+	}
+	return m, nil
+}
+
+// ReadMesh reads a whole stream written with Marshal.
+func ReadMesh(r io.Reader) (*Mesh, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalMesh(data)
+}
+
+// FormatBinary serves meshes in the Marshal format.
+var FormatBinary = &Format{
+	Name:        "binary",
+	Extension:   "bin",
+	ContentType: "application/octet-stream",
+	Encode: func(w io.Writer, m *Mesh) error {
+		_, err := w.Write(m.Marshal())
+		return err
+	},
+}
@@ -0,0 +1,41 @@
+package martini
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalMesh(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	tile.AddAttribute("class", testTerrain(17, func(x, y int) float64 { return float64(x * y) }))
+	mesh := tile.ToMesh(5)
+	mesh.Colors = make([]uint8, 3*mesh.NumVertices())
+	for i := range mesh.Colors {
+		mesh.Colors[i] = uint8(i)
+	}
+
+	data := mesh.Marshal()
+	got, err := UnmarshalMesh(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, mesh) {
+		t.Fatal("round trip changed the mesh")
+	}
+	if got, _ := ReadMesh(bytes.NewReader((&Mesh{}).Marshal())); got.NumVertices() != 0 || got.Colors != nil {
+		t.Error("expected an empty mesh")
+	}
+
+	for _, n := range []int{0, 10, 24, len(data) - 1} {
+		if _, err := UnmarshalMesh(data[:n]); err == nil {
+			t.Errorf("expected error for %d bytes", n)
+		}
+	}
+	bad := append([]byte(nil), data...)
+	bad[4] = 9
+	if _, err := UnmarshalMesh(bad); err == nil {
+		t.Error("expected version error")
+	}
+}
@@ -0,0 +1,38 @@
+package martini
+
+import "errors"
+
+// Mesher turns a width*height grid of heights into a mesh whose maximum
+// error is at most maxError. Meshes are in the grid frame ToMesh uses.
+type Mesher interface {
+	Mesh(terrain []float64, width, height int, maxError float64) (*Mesh, error)
+}
+
+// Mesh implements Mesher with RTIN. The grid must be GridSize square. Like
+// GetMesh it reuses Indices, so it is not safe for concurrent use unless
+// the Martini comes from For.
+func (m *Martini) Mesh(terrain []float64, width, height int, maxError float64) (*Mesh, error) {
+	if width != m.GridSize || height != m.GridSize {
+		return nil, errors.New("Expected a grid of GridSize by GridSize samples")
+	}
+	tile, err := m.CreateTile(terrain)
+	if err != nil {
+		return nil, err
+	}
+	return tile.ToMesh(maxError), nil
+}
+
+// DelatinMesher implements Mesher with Delatin. It has no state and is safe
+// for concurrent use.
+type DelatinMesher struct{}
+
+func (DelatinMesher) Mesh(terrain []float64, width, height int, maxError float64) (*Mesh, error) {
+	d, err := NewDelatin(terrain, width, height)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Run(maxError); err != nil {
+		return nil, err
+	}
+	return d.ToMesh(), nil
+}
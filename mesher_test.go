@@ -0,0 +1,54 @@
+package martini
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMeshers(t *testing.T) {
+	terrain := testTerrain(33, hills)
+	m, _ := NewMartini(33)
+	for _, mesher := range []Mesher{m, DelatinMesher{}} {
+		mesh, err := mesher.Mesh(terrain, 33, 33, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range triangleErrors(mesh, terrain, 33) {
+			if e > 5+1e-9 {
+				t.Fatalf("%T: triangle error %v above 5", mesher, e)
+			}
+		}
+	}
+	if _, err := m.Mesh(terrain, 32, 33, 5); err == nil {
+		t.Error("expected size error")
+	}
+}
+
+func TestBatchMesher(t *testing.T) {
+	tiles := []TileID{{1, 0, 0}, {1, 1, 0}}
+	opts := BatchOptions{GridSize: 17, MaxError: 5, Workers: 2, Mesher: DelatinMesher{}, MeshOptions: &MeshOptions{FlipY: true}}
+	for r := range ProcessTiles(context.Background(), testSource(), tiles, opts) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		rtin, _, _ := generateTile(context.Background(), testSource(), mustMartini(t, 17), r.ID, BatchOptions{GridSize: 17, MaxError: 5})
+		if r.Mesh.NumTriangles() > rtin.NumTriangles() {
+			t.Errorf("expected Delatin to need at most as many triangles: %d vs %d", r.Mesh.NumTriangles(), rtin.NumTriangles())
+		}
+	}
+
+	s := newTestServer(t)
+	s.Mesher = DelatinMesher{}
+	if _, _, err := s.mesh(httptest.NewRequest("GET", "/1/1/0.json", nil), TileID{1, 1, 0}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustMartini(t *testing.T, gridSize int) *Martini {
+	m, err := NewMartini(gridSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,56 @@
+package martini
+
+import "math"
+
+// TileMeta describes a generated tile. Exporters that support it embed it
+// in their output: glTF node extras, and tileset.json.
+type TileMeta struct {
+	Zoom   int    `json:"zoom"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Bounds Bounds `json:"bounds"`
+	// MinHeight and MaxHeight are the height range of the mesh vertices.
+	MinHeight float64 `json:"minHeight"`
+	MaxHeight float64 `json:"maxHeight"`
+	// MaxError is the maxError the mesh was extracted with.
+	MaxError float64 `json:"maxError"`
+	// Source names the terrain the tile was built from.
+	Source string `json:"source,omitempty"`
+	// Stats, if set, summarizes the terrain the tile was meshed from.
+	Stats *ElevationStats `json:"stats,omitempty"`
+	// Scheme addresses the tile, for writers that place it on the globe;
+	// nil means SchemeXYZ. It is not serialized.
+	Scheme *TilingScheme `json:"-"`
+}
+
+// ID returns the tile the metadata describes.
+func (m *TileMeta) ID() TileID {
+	return TileID{Z: m.Zoom, X: m.X, Y: m.Y}
+}
+
+// NewTileMeta describes the mesh of tile id. Heights are read from the
+// mesh's up axis, as laid out by opts.
+func NewTileMeta(id TileID, mesh *Mesh, opts *MeshOptions, maxError float64, source string) TileMeta {
+	up := 2
+	if opts != nil {
+		up = opts.Axes.up()
+	}
+	heights := make([]float64, 0, mesh.NumVertices())
+	for i := up; i < len(mesh.Vertices); i += 3 {
+		heights = append(heights, mesh.Vertices[i])
+	}
+	min, max := 0.0, 0.0
+	if s := heightStats(heights); len(heights) > 0 && !math.IsNaN(s.Min) {
+		min, max = s.Min, s.Max
+	}
+	return TileMeta{
+		Zoom:      id.Z,
+		X:         id.X,
+		Y:         id.Y,
+		Bounds:    id.Bounds(),
+		MinHeight: min,
+		MaxHeight: max,
+		MaxError:  maxError,
+		Source:    source,
+	}
+}
@@ -0,0 +1,130 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestTileMeta(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	id := TileID{3, 2, 5}
+
+	meta := NewTileMeta(id, mesh, nil, 5, "srtm")
+	if meta.ID() != id || meta.Bounds != id.Bounds() || meta.MinHeight >= meta.MaxHeight || meta.Source != "srtm" {
+		t.Errorf("unexpected meta %+v", meta)
+	}
+	yup := tile.ToMeshWithOptions(5, &MeshOptions{Axes: AxesYUp})
+	if got := NewTileMeta(id, yup, &MeshOptions{Axes: AxesYUp}, 5, ""); got.MinHeight != meta.MinHeight || got.MaxHeight != meta.MaxHeight {
+		t.Errorf("y-up heights %v..%v, want %v..%v", got.MinHeight, got.MaxHeight, meta.MinHeight, meta.MaxHeight)
+	}
+
+	var buf bytes.Buffer
+	if err := FormatGLB.encode(&buf, mesh, &meta); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	var doc struct {
+		Nodes []struct {
+			Extras TileMeta `json:"extras"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(data[20:20+binary.LittleEndian.Uint32(data[12:])], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Nodes[0].Extras != meta {
+		t.Errorf("glTF extras %+v, want %+v", doc.Nodes[0].Extras, meta)
+	}
+}
+
+func TestBuildPyramidTileset(t *testing.T) {
+	var tileset bytes.Buffer
+	sink := &DirSink{Dir: testDir(t), Extension: "glb"}
+	opts := PyramidOptions{MinZoom: 0, MaxZoom: 2, GridSize: 17, MaxError: 5, Format: Format3DTiles, Source: "test", Tileset: &tileset}
+	if err := BuildPyramid(context.Background(), testSource(), sink, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	type node struct {
+		BoundingVolume struct {
+			Region [6]float64 `json:"region"`
+		} `json:"boundingVolume"`
+		GeometricError float64 `json:"geometricError"`
+		Content        struct {
+			URI string `json:"uri"`
+		} `json:"content"`
+		Children []json.RawMessage `json:"children"`
+		Extras   TileMeta          `json:"extras"`
+	}
+	var doc struct {
+		Asset struct {
+			Version string `json:"version"`
+		} `json:"asset"`
+		Root node `json:"root"`
+	}
+	if err := json.Unmarshal(tileset.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Asset.Version != "1.1" {
+		t.Errorf("tileset version %q, want 1.1 for glTF content", doc.Asset.Version)
+	}
+	if len(doc.Root.Children) != 1 {
+		t.Fatalf("expected the z0 tile under the root, got %d children", len(doc.Root.Children))
+	}
+	var z0 node
+	json.Unmarshal(doc.Root.Children[0], &z0)
+	if z0.Content.URI != "0/0/0.glb" || len(z0.Children) != 4 || z0.GeometricError != 5 || z0.Extras.Source != "test" {
+		t.Errorf("unexpected z0 node %+v", z0)
+	}
+	var z1 node
+	json.Unmarshal(z0.Children[0], &z1)
+	if len(z1.Children) != 4 {
+		t.Errorf("expected 4 z2 children, got %d", len(z1.Children))
+	}
+	r := z0.BoundingVolume.Region
+	if r[0] > -3.14 || r[2] < 3.14 || r[4] > z1.BoundingVolume.Region[4] {
+		t.Errorf("z0 region %v does not enclose its children", r)
+	}
+}
+
+func TestBuildPyramidTilesetFormat(t *testing.T) {
+	var tileset bytes.Buffer
+	opts := PyramidOptions{MinZoom: 0, MaxZoom: 0, GridSize: 17, MaxError: 5, Format: FormatGLB, Tileset: &tileset}
+	if err := BuildPyramid(context.Background(), testSource(), &DirSink{Dir: testDir(t)}, opts); err == nil {
+		t.Error("expected error for a tileset of grid-frame content")
+	}
+}
+
+func TestFormat3DTiles(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	id := TileID{Z: 10, X: 530, Y: 350}
+	meta := NewTileMeta(id, mesh, nil, 5, "")
+
+	var buf bytes.Buffer
+	if err := Format3DTiles.Encode(&buf, mesh); err == nil {
+		t.Error("expected error without tile metadata")
+	}
+	if err := Format3DTiles.encode(&buf, mesh, &meta); err != nil {
+		t.Fatal(err)
+	}
+	doc, pos := glbPositions(t, buf.Bytes())
+	tr := doc.Nodes[0].Translation
+	want, _ := mesh.ToECEF(id, 17, nil)
+	for i := 0; i < want.NumVertices(); i++ {
+		// 3D Tiles turns y-up glTF into z-up: (x, y, z) -> (x, -z, y).
+		x := float64(pos[3*i]) + tr[0]
+		y := -(float64(pos[3*i+2]) + tr[2])
+		z := float64(pos[3*i+1]) + tr[1]
+		wx, wy, wz := want.Vertex(i)
+		if math.Abs(x-wx) > 0.01 || math.Abs(y-wy) > 0.01 || math.Abs(z-wz) > 0.01 {
+			t.Fatalf("vertex %d at %v, %v, %v, want %v, %v, %v", i, x, y, z, wx, wy, wz)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package martini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds ...float64) histogram {
+	return histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(b, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func writeMetric(w io.Writer, name, kind, help string, v interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, v)
+}
+
+// Metrics collects tile generation statistics and exposes them in the
+// Prometheus text format. It can be shared by a Server and batch jobs.
+type Metrics struct {
+	// Cache, if set, has its statistics exported with every scrape. A
+	// Server with a Cache exports that one instead, as Reload replaces it.
+	Cache *MeshCache
+
+	mu        sync.Mutex
+	generated uint64
+	failed    uint64
+	latency   histogram
+	triangles histogram
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		latency:   newHistogram(.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10),
+		triangles: newHistogram(2, 32, 128, 512, 2048, 8192, 32768, 131072, 524288),
+	}
+}
+
+func (m *Metrics) observeTile(d time.Duration, mesh *Mesh) {
+	m.mu.Lock()
+	m.generated++
+	m.latency.observe(d.Seconds())
+	m.triangles.observe(float64(mesh.NumTriangles()))
+	m.mu.Unlock()
+}
+
+func (m *Metrics) observeError() {
+	m.mu.Lock()
+	m.failed++
+	m.mu.Unlock()
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	return m.write(w, m.Cache)
+}
+
+func (m *Metrics) write(w io.Writer, cache *MeshCache) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	m.mu.Lock()
+	writeMetric(cw, "martini_tiles_generated_total", "counter", "Tiles meshed.", m.generated)
+	writeMetric(cw, "martini_tile_errors_total", "counter", "Tiles that failed to load or mesh.", m.failed)
+	m.latency.write(cw, "martini_tile_generation_seconds", "Time spent loading and meshing a tile.")
+	m.triangles.write(cw, "martini_tile_triangles", "Triangles per generated tile.")
+	m.mu.Unlock()
+
+	if cache != nil {
+		s := cache.Stats()
+		writeMetric(cw, "martini_cache_hits_total", "counter", "Mesh cache hits.", s.Hits)
+		writeMetric(cw, "martini_cache_misses_total", "counter", "Mesh cache misses.", s.Misses)
+		writeMetric(cw, "martini_cache_evictions_total", "counter", "Mesh cache evictions.", s.Evictions)
+		writeMetric(cw, "martini_cache_entries", "gauge", "Meshes held by the cache.", s.Entries)
+		writeMetric(cw, "martini_cache_bytes", "gauge", "Approximate bytes held by the cache.", s.Bytes)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// serveMetrics serves s.Metrics with the statistics of the current Cache.
+func (s *Server) serveMetrics(w http.ResponseWriter) {
+	s.mu.RLock()
+	cache := s.Cache
+	s.mu.RUnlock()
+	if cache == nil {
+		cache = s.Metrics.Cache
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.Metrics.write(w, cache)
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,35 @@
+package martini
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.Cache = NewMeshCache(1 << 20)
+	tiles := []TileID{{1, 0, 0}, {1, 1, 0}, {5, 0, 0}}
+	opts := BatchOptions{GridSize: 17, MaxError: 5, Workers: 1, Cache: m.Cache, Metrics: m}
+	for range ProcessTiles(context.Background(), testSource(), tiles, opts) {
+	}
+
+	s := newTestServer(t)
+	s.Metrics = m
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"martini_tiles_generated_total 2\n",
+		"martini_tile_errors_total 1\n",
+		"martini_tile_generation_seconds_count 2\n",
+		`martini_tile_triangles_bucket{le="+Inf"} 2` + "\n",
+		"martini_cache_misses_total 3\n",
+		"# TYPE martini_tile_generation_seconds histogram\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package martini
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// MorphMeshes extracts meshes of the same tile at two epochs, a and b,
+// with a shared triangulation: the union of the refinements each would
+// get on its own at maxError. The meshes have identical vertices in x and y
+// and identical triangles, differing only in heights and attributes, so
+// that renderers can animate between them. Both tiles must have the same
+// grid size.
+func MorphMeshes(a, b *Tile, maxError float64) (*Mesh, *Mesh, error) {
+	if a.Martini.GridSize != b.Martini.GridSize {
+		return nil, nil, errors.New("Expected tiles of the same grid size")
+	}
+	// The elementwise maximum of two error pyramids is itself one: a
+	// triangle splits if it splits in either epoch.
+	errs := make([]float64, len(a.Errors))
+	for i := range errs {
+		errs[i] = math.Max(a.Errors[i], b.Errors[i])
+	}
+	union := &Tile{Terrain: a.Terrain, Martini: a.Martini, Errors: errs}
+	vertices, triangles := union.GetMesh(maxError)
+	indices := make([]uint32, len(triangles))
+	for i, v := range triangles {
+		indices[i] = uint32(v)
+	}
+	target := make([]uint32, len(indices))
+	copy(target, indices)
+	return a.liftMesh(vertices, indices), b.liftMesh(vertices, target), nil
+}
+
+// EncodeGLBMorph writes base as a binary glTF 2.0 mesh with target as a
+// morph target, stored as position displacements with an initial weight of
+// zero. The meshes must share their triangulation, as returned by
+// MorphMeshes.
+func EncodeGLBMorph(w io.Writer, base, target *Mesh) error {
+	if len(base.Vertices) != len(target.Vertices) || len(base.Triangles) != len(target.Triangles) {
+		return errors.New("Expected meshes with a shared triangulation")
+	}
+	var b gltfBuilder
+	mesh := b.addMesh(base, nil)
+	delta := make([]float64, len(base.Vertices))
+	for i := range delta {
+		delta[i] = target.Vertices[i] - base.Vertices[i]
+	}
+	prim := &b.doc.Meshes[mesh].Primitives[0]
+	prim.Targets = []map[string]int{{"POSITION": b.floats(delta, 3, "VEC3")}}
+	b.doc.Meshes[mesh].Weights = []float64{0}
+	return b.writeSingle(w, mesh, nil)
+}
@@ -0,0 +1,63 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMorphMeshes(t *testing.T) {
+	m, _ := NewMartini(33)
+	before, _ := m.CreateTile(testTerrain(33, hills))
+	after, _ := m.CreateTile(testTerrain(33, func(x, y int) float64 {
+		if x > 8 && x < 14 && y > 20 && y < 26 {
+			return hills(x, y) + 30
+		}
+		return hills(x, y)
+	}))
+
+	base, target, err := MorphMeshes(before, after, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(base.Triangles, target.Triangles) {
+		t.Fatal("expected a shared triangulation")
+	}
+	for i := 0; i < base.NumVertices(); i++ {
+		bx, by, bz := base.Vertex(i)
+		tx, ty, tz := target.Vertex(i)
+		if bx != tx || by != ty || bz != before.Terrain[int(by)*33+int(bx)] || tz != after.Terrain[int(ty)*33+int(tx)] {
+			t.Fatalf("vertex %d: base %v %v %v, target %v %v %v", i, bx, by, bz, tx, ty, tz)
+		}
+	}
+	// The union needs at least the vertices of each epoch on its own.
+	for _, tile := range []*Tile{before, after} {
+		if n := tile.ToMesh(5).NumVertices(); base.NumVertices() < n {
+			t.Errorf("union has %d vertices, an epoch alone %d", base.NumVertices(), n)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeGLBMorph(&buf, base, target); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	jsonLen := binary.LittleEndian.Uint32(data[12:])
+	var doc gltfDocument
+	if err := json.Unmarshal(data[20:20+jsonLen], &doc); err != nil {
+		t.Fatal(err)
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	if len(prim.Targets) != 1 || len(doc.Meshes[0].Weights) != 1 {
+		t.Fatalf("expected one morph target, got %+v", doc.Meshes[0])
+	}
+	if a := doc.Accessors[prim.Targets[0]["POSITION"]]; a.Count != base.NumVertices() || a.Max[2] != 30 {
+		t.Errorf("bad target accessor %+v", a)
+	}
+
+	if err := EncodeGLBMorph(&buf, base, before.ToMesh(50)); err == nil {
+		t.Error("expected error for meshes with different triangulations")
+	}
+}
@@ -0,0 +1,255 @@
+package martini
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// WindowReader reads w*h samples starting at pixel (x, y).
+type WindowReader interface {
+	Window(x, y, w, h int) ([]float64, error)
+}
+
+// DEMLevel is one resolution of a DEM.
+type DEMLevel struct {
+	Reader        WindowReader
+	Width, Height int
+}
+
+// DEM is a georeferenced elevation raster used as mosaic input. Levels[0]
+// is the full resolution; further levels are overviews of the same extent.
+type DEM struct {
+	Levels []DEMLevel
+	Geo    GeoTransform
+	// NoData, when not nil, marks void samples in addition to NaN. Nil
+	// keeps every height, sea level included.
+	NoData *float64
+}
+
+type cogLevelReader struct {
+	c     *COG
+	level int
+}
+
+func (r cogLevelReader) Window(x, y, w, h int) ([]float64, error) {
+	return r.c.ReadWindow(r.level, x, y, w, h)
+}
+
+// DEMFromCOG wraps a COG and its overviews.
+func DEMFromCOG(c *COG) *DEM {
+	d := &DEM{Geo: GeoTransform{c.OriginX, c.OriginY, c.PixelSizeX, c.PixelSizeY}}
+	if !math.IsNaN(c.NoData) {
+		noData := c.NoData
+		d.NoData = &noData
+	}
+	for l := 0; l < c.Levels(); l++ {
+		w, h := c.Size(l)
+		d.Levels = append(d.Levels, DEMLevel{Reader: cogLevelReader{c, l}, Width: w, Height: h})
+	}
+	return d
+}
+
+// DEMFromRaster wraps a memory-mapped raster with its georeference. noData
+// may be nil.
+func DEMFromRaster(r *MappedRaster, geo GeoTransform, noData *float64) *DEM {
+	return &DEM{
+		Levels: []DEMLevel{{Reader: r, Width: r.Layout.Width, Height: r.Layout.Height}},
+		Geo:    geo,
+		NoData: noData,
+	}
+}
+
+func (d *DEM) valid(v float64) bool {
+	return !math.IsNaN(v) && (d.NoData == nil || v != *d.NoData)
+}
+
+// OverlapMode selects how a Mosaic resolves samples covered by several DEMs.
+type OverlapMode int
+
+const (
+	// OverlapPriority takes the first DEM with data, in slice order.
+	OverlapPriority OverlapMode = iota
+	// OverlapBlend averages all DEMs with data, weighting each by the
+	// distance to its edge so seams fade out.
+	OverlapBlend
+)
+
+// Mosaic combines several DEMs in a common projection into one
+// TerrainSource for XYZ web mercator tiles. Each DEM is resampled bilinearly
+// from the coarsest overview that still matches the tile resolution.
+type Mosaic struct {
+	DEMs       []*DEM
+	Mode       OverlapMode
+	Projection Projection
+	// Fill is used where no DEM has data.
+	Fill float64
+}
+
+func (m *Mosaic) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	px, py, need := tileSamplePoints(id, gridSize, m.Projection)
+	n := len(px)
+	sum := make([]float64, n)
+	weight := make([]float64, n)
+
+	for _, d := range m.DEMs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if m.Mode == OverlapPriority && allPositive(weight) {
+			break
+		}
+		if err := m.accumulate(d, px, py, need, sum, weight); err != nil {
+			return nil, err
+		}
+	}
+
+	terrain := make([]float64, n)
+	for k := range terrain {
+		if weight[k] > 0 {
+			terrain[k] = sum[k] / weight[k]
+		} else {
+			terrain[k] = m.Fill
+		}
+	}
+	return terrain, nil
+}
+
+func allPositive(w []float64) bool {
+	for _, v := range w {
+		if v <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Mosaic) accumulate(d *DEM, px, py []float64, need float64, sum, weight []float64) error {
+	if len(d.Levels) == 0 {
+		return errors.New("Expected DEM with at least one level")
+	}
+	fullW := d.Levels[0].Width
+	level := 0
+	for l := 1; l < len(d.Levels); l++ {
+		if d.Geo.PixelSizeX*float64(fullW)/float64(d.Levels[l].Width) > need {
+			break
+		}
+		level = l
+	}
+	lv := d.Levels[level]
+	scale := float64(fullW) / float64(lv.Width)
+	sx, sy := d.Geo.PixelSizeX*scale, d.Geo.PixelSizeY*scale
+
+	// Pixel-centre coordinates and the window covering the points that fall
+	// on this DEM.
+	ix := make([]float64, len(px))
+	iy := make([]float64, len(px))
+	x0, y0, x1, y1 := lv.Width, lv.Height, -1, -1
+	for k := range px {
+		ix[k] = (px[k]-d.Geo.OriginX)/sx - 0.5
+		iy[k] = (d.Geo.OriginY-py[k])/sy - 0.5
+		if ix[k] < -0.5 || iy[k] < -0.5 || ix[k] > float64(lv.Width)-0.5 || iy[k] > float64(lv.Height)-0.5 {
+			continue
+		}
+		if m.Mode == OverlapPriority && weight[k] > 0 {
+			continue
+		}
+		fx, fy := int(math.Floor(ix[k])), int(math.Floor(iy[k]))
+		x0, y0 = minInt(x0, maxInt(fx, 0)), minInt(y0, maxInt(fy, 0))
+		x1, y1 = maxInt(x1, minInt(fx+1, lv.Width-1)), maxInt(y1, minInt(fy+1, lv.Height-1))
+	}
+	if x1 < x0 || y1 < y0 {
+		return nil
+	}
+	ww, wh := x1-x0+1, y1-y0+1
+	window, err := lv.Reader.Window(x0, y0, ww, wh)
+	if err != nil {
+		return err
+	}
+
+	for k := range px {
+		x, y := ix[k], iy[k]
+		if x < -0.5 || y < -0.5 || x > float64(lv.Width)-0.5 || y > float64(lv.Height)-0.5 {
+			continue
+		}
+		if m.Mode == OverlapPriority && weight[k] > 0 {
+			continue
+		}
+		v, ok := d.sample(window, ww, wh, x-float64(x0), y-float64(y0))
+		if !ok {
+			continue
+		}
+		w := 1.0
+		if m.Mode == OverlapBlend {
+			edge := math.Min(math.Min(x+0.5, float64(lv.Width)-0.5-x), math.Min(y+0.5, float64(lv.Height)-0.5-y))
+			w = math.Max(edge, 1e-3)
+		}
+		sum[k] += v * w
+		weight[k] += w
+	}
+	return nil
+}
+
+// sample interpolates bilinearly between the valid neighbours of (x, y).
+func (d *DEM) sample(grid []float64, w, h int, x, y float64) (float64, bool) {
+	x = math.Max(0, math.Min(x, float64(w-1)))
+	y = math.Max(0, math.Min(y, float64(h-1)))
+	ix, iy := int(x), int(y)
+	fx, fy := x-float64(ix), y-float64(iy)
+	var sum, total float64
+	for j := 0; j < 2; j++ {
+		for i := 0; i < 2; i++ {
+			gx, gy := minInt(ix+i, w-1), minInt(iy+j, h-1)
+			wx, wy := 1-fx, 1-fy
+			if i == 1 {
+				wx = fx
+			}
+			if j == 1 {
+				wy = fy
+			}
+			v := grid[gy*w+gx]
+			if wx*wy == 0 || !d.valid(v) {
+				continue
+			}
+			sum += v * wx * wy
+			total += wx * wy
+		}
+	}
+	if total == 0 {
+		// Exactly on a sample, which may itself be valid.
+		v := grid[int(math.Round(y))*w+int(math.Round(x))]
+		return v, d.valid(v)
+	}
+	return sum / total, true
+}
+
+// Bounds returns the longitude/latitude extent covered by the DEMs, for use
+// as PyramidOptions.Bounds.
+func (m *Mosaic) Bounds() Bounds {
+	b := Bounds{West: math.Inf(1), South: math.Inf(1), East: math.Inf(-1), North: math.Inf(-1)}
+	for _, d := range m.DEMs {
+		if len(d.Levels) == 0 {
+			continue
+		}
+		w, h := d.Levels[0].Width, d.Levels[0].Height
+		west, north := m.Projection.toLngLat(d.Geo.OriginX, d.Geo.OriginY)
+		east, south := m.Projection.toLngLat(d.Geo.OriginX+float64(w)*d.Geo.PixelSizeX, d.Geo.OriginY-float64(h)*d.Geo.PixelSizeY)
+		b.West, b.South = math.Min(b.West, west), math.Min(b.South, south)
+		b.East, b.North = math.Max(b.East, east), math.Max(b.North, north)
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
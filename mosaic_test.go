@@ -0,0 +1,89 @@
+package martini
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+type gridReader struct {
+	w, h int
+	v    []float64
+}
+
+func (g *gridReader) Window(x, y, w, h int) ([]float64, error) {
+	out := make([]float64, w*h)
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			out[j*w+i] = g.v[(y+j)*g.w+x+i]
+		}
+	}
+	return out, nil
+}
+
+func constantDEM(w, h int, value float64, geo GeoTransform) *DEM {
+	g := &gridReader{w: w, h: h, v: make([]float64, w*h)}
+	for i := range g.v {
+		g.v[i] = value
+	}
+	noData := -9999.0
+	return &DEM{Levels: []DEMLevel{{Reader: g, Width: w, Height: h}}, Geo: geo, NoData: &noData}
+}
+
+func TestMosaic(t *testing.T) {
+	px := 2 * webMercatorHalf / 64
+	// West covers the western half of the world, world the whole world.
+	west := constantDEM(32, 64, 100, GeoTransform{-webMercatorHalf, webMercatorHalf, px, px})
+	world := constantDEM(64, 64, 200, GeoTransform{-webMercatorHalf, webMercatorHalf, px, px})
+	west.Levels[0].Reader.(*gridReader).v[0] = -9999
+
+	m := &Mosaic{DEMs: []*DEM{west, world}}
+	terrain, err := m.Terrain(context.Background(), TileID{0, 0, 0}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terrain[8*17+2] != 100 || terrain[8*17+14] != 200 {
+		t.Errorf("unexpected priority samples %v %v", terrain[8*17+2], terrain[8*17+14])
+	}
+	if terrain[0] != 200 {
+		t.Errorf("expected nodata to fall through to the next DEM, got %v", terrain[0])
+	}
+
+	// Without NoData, sea level is data.
+	sea := constantDEM(64, 64, 0, world.Geo)
+	sea.NoData = nil
+	terrain, _ = (&Mosaic{DEMs: []*DEM{sea, world}}).Terrain(context.Background(), TileID{0, 0, 0}, 17)
+	if terrain[8*17+8] != 0 {
+		t.Errorf("expected sea level to be kept, got %v", terrain[8*17+8])
+	}
+
+	m.Mode = OverlapBlend
+	terrain, _ = m.Terrain(context.Background(), TileID{0, 0, 0}, 17)
+	if v := terrain[8*17+4]; v <= 100 || v >= 200 {
+		t.Errorf("expected blended sample, got %v", v)
+	}
+	if terrain[8*17+14] != 200 {
+		t.Errorf("expected single coverage outside the overlap, got %v", terrain[8*17+14])
+	}
+
+	empty := &Mosaic{DEMs: []*DEM{west}, Fill: -1}
+	terrain, _ = empty.Terrain(context.Background(), TileID{1, 1, 0}, 17)
+	if terrain[8*17+8] != -1 {
+		t.Errorf("expected fill value, got %v", terrain[8*17+8])
+	}
+
+	b := (&Mosaic{DEMs: []*DEM{west}}).Bounds()
+	if b.West != -180 || math.Abs(b.East) > 1e-9 || math.Abs(b.North-WorldBounds.North) > 1e-9 {
+		t.Errorf("unexpected bounds %+v", b)
+	}
+}
+
+func TestMosaicPyramid(t *testing.T) {
+	px := 2 * webMercatorHalf / 64
+	m := &Mosaic{DEMs: []*DEM{constantDEM(32, 64, 100, GeoTransform{-webMercatorHalf, webMercatorHalf, px, px})}}
+	sink := &DirSink{Dir: testDir(t), Extension: "json"}
+	opts := PyramidOptions{MinZoom: 1, MaxZoom: 1, Bounds: m.Bounds(), GridSize: 17, MaxError: 1}
+	if err := BuildPyramid(context.Background(), m, sink, opts); err != nil {
+		t.Fatal(err)
+	}
+}
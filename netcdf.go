@@ -0,0 +1,490 @@
+package martini
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// NetCDF classic format tags and types.
+const (
+	ncDimension = 0x0a
+	ncVariable  = 0x0b
+	ncAttribute = 0x0c
+
+	ncByte   = 1
+	ncChar   = 2
+	ncShort  = 3
+	ncInt    = 4
+	ncFloat  = 5
+	ncDouble = 6
+	ncUbyte  = 7
+	ncUshort = 8
+	ncUint   = 9
+	ncInt64  = 10
+	ncUint64 = 11
+)
+
+// NetCDFDim is a named dimension. Length is the number of records for the
+// unlimited dimension.
+type NetCDFDim struct {
+	Name      string
+	Length    int
+	Unlimited bool
+}
+
+// NetCDFVar is a variable of a NetCDF file. Attributes hold numeric values
+// as []float64 and text as string.
+type NetCDFVar struct {
+	Name       string
+	Dims       []int
+	Attributes map[string]interface{}
+
+	typ   int
+	begin int64
+}
+
+// NetCDF reads the classic, 64-bit offset and 64-bit data (CDF-5) NetCDF
+// formats. NetCDF-4 files are HDF5 containers and are not supported; convert
+// them with nccopy -k classic first.
+type NetCDF struct {
+	Dims       []NetCDFDim
+	Attributes map[string]interface{}
+	Vars       []*NetCDFVar
+
+	r       io.ReaderAt
+	version byte
+}
+
+// OpenNetCDF parses the header of a NetCDF file.
+func OpenNetCDF(r io.ReaderAt) (*NetCDF, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, err
+	}
+	if string(magic[1:]) == "HDF" {
+		return nil, errors.New("NetCDF-4/HDF5 files are not supported")
+	}
+	if string(magic[:3]) != "CDF" || (magic[3] != 1 && magic[3] != 2 && magic[3] != 5) {
+		return nil, errors.New("Expected NetCDF classic format")
+	}
+	f := &NetCDF{r: r, version: magic[3]}
+	h := &ncHeader{r: bufio.NewReader(io.NewSectionReader(r, 4, math.MaxInt64-4)), version: f.version}
+
+	numRecs := h.count()
+	if tag, n := h.list(); tag == ncDimension {
+		for i := 0; i < n && h.err == nil; i++ {
+			d := NetCDFDim{Name: h.name(), Length: h.count()}
+			if d.Length == 0 {
+				d.Unlimited, d.Length = true, numRecs
+			}
+			f.Dims = append(f.Dims, d)
+		}
+	}
+	f.Attributes = h.attributes()
+	if tag, n := h.list(); tag == ncVariable {
+		for i := 0; i < n && h.err == nil; i++ {
+			v := &NetCDFVar{Name: h.name()}
+			v.Dims = make([]int, minInt(h.count(), 1024))
+			for k := range v.Dims {
+				v.Dims[k] = h.count()
+				if v.Dims[k] >= len(f.Dims) {
+					h.fail(fmt.Errorf("NetCDF variable %s: invalid dimension id", v.Name))
+				}
+			}
+			v.Attributes = h.attributes()
+			v.typ = int(h.uint32())
+			h.count() // vsize
+			if f.version == 1 {
+				v.begin = int64(h.uint32())
+			} else {
+				v.begin = int64(h.uint64())
+			}
+			f.Vars = append(f.Vars, v)
+		}
+	}
+	if h.err != nil {
+		return nil, h.err
+	}
+	return f, nil
+}
+
+// Var returns the variable with the given name or nil.
+func (f *NetCDF) Var(name string) *NetCDFVar {
+	for _, v := range f.Vars {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// ncHeader decodes the big-endian header, keeping the first error.
+type ncHeader struct {
+	r       *bufio.Reader
+	version byte
+	err     error
+}
+
+func (h *ncHeader) fail(err error) {
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+func (h *ncHeader) read(n int) []byte {
+	b := make([]byte, n)
+	if h.err == nil {
+		if _, err := io.ReadFull(h.r, b); err != nil {
+			h.fail(errors.New("Truncated NetCDF header"))
+		}
+	}
+	return b
+}
+
+func (h *ncHeader) uint32() uint32 { return binary.BigEndian.Uint32(h.read(4)) }
+func (h *ncHeader) uint64() uint64 { return binary.BigEndian.Uint64(h.read(8)) }
+
+// count reads a non-negative count, 64 bits wide in CDF-5.
+func (h *ncHeader) count() int {
+	var n uint64
+	if h.version == 5 {
+		n = h.uint64()
+	} else {
+		n = uint64(h.uint32())
+	}
+	if n > 1<<40 {
+		h.fail(errors.New("Invalid NetCDF header count"))
+		return 0
+	}
+	return int(n)
+}
+
+// list reads the tag and length of a dimension, attribute or variable list.
+func (h *ncHeader) list() (int, int) {
+	tag := int(h.uint32())
+	n := h.count()
+	if n > 1<<16 {
+		h.fail(errors.New("Too many NetCDF header entries"))
+		return tag, 0
+	}
+	return tag, n
+}
+
+func (h *ncHeader) name() string {
+	n := h.count()
+	if n > 1<<12 {
+		h.fail(errors.New("NetCDF name too long"))
+		return ""
+	}
+	b := h.read((n + 3) &^ 3)
+	return string(b[:n])
+}
+
+func (h *ncHeader) attributes() map[string]interface{} {
+	attrs := make(map[string]interface{})
+	tag, n := h.list()
+	if tag != ncAttribute {
+		return attrs
+	}
+	for i := 0; i < n && h.err == nil; i++ {
+		name := h.name()
+		typ := int(h.uint32())
+		count := h.count()
+		size := ncTypeSize(typ)
+		if size == 0 || count > 1<<20 {
+			h.fail(fmt.Errorf("NetCDF attribute %s: unsupported type %d", name, typ))
+			break
+		}
+		b := h.read((count*size + 3) &^ 3)
+		if typ == ncChar {
+			attrs[name] = string(b[:count])
+			continue
+		}
+		values := make([]float64, count)
+		for k := range values {
+			values[k] = ncDecode(typ, b[k*size:])
+		}
+		attrs[name] = values
+	}
+	return attrs
+}
+
+func ncTypeSize(typ int) int {
+	switch typ {
+	case ncByte, ncChar, ncUbyte:
+		return 1
+	case ncShort, ncUshort:
+		return 2
+	case ncInt, ncFloat, ncUint:
+		return 4
+	case ncDouble, ncInt64, ncUint64:
+		return 8
+	}
+	return 0
+}
+
+func ncDecode(typ int, b []byte) float64 {
+	switch typ {
+	case ncByte:
+		return float64(int8(b[0]))
+	case ncUbyte, ncChar:
+		return float64(b[0])
+	case ncShort:
+		return float64(int16(binary.BigEndian.Uint16(b)))
+	case ncUshort:
+		return float64(binary.BigEndian.Uint16(b))
+	case ncInt:
+		return float64(int32(binary.BigEndian.Uint32(b)))
+	case ncUint:
+		return float64(binary.BigEndian.Uint32(b))
+	case ncFloat:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case ncInt64:
+		return float64(int64(binary.BigEndian.Uint64(b)))
+	case ncUint64:
+		return float64(binary.BigEndian.Uint64(b))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// readVar reads n values of v starting at element off of its first record.
+func (f *NetCDF) readVar(v *NetCDFVar, off, n int) ([]float64, error) {
+	size := ncTypeSize(v.typ)
+	if size == 0 || v.typ == ncChar {
+		return nil, fmt.Errorf("NetCDF variable %s: unsupported type %d", v.Name, v.typ)
+	}
+	b := make([]byte, n*size)
+	if _, err := f.r.ReadAt(b, v.begin+int64(off)*int64(size)); err != nil {
+		return nil, err
+	}
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = ncDecode(v.typ, b[i*size:])
+	}
+	return out, nil
+}
+
+// NetCDFOptions selects the elevation variable and its dimensions. Empty
+// names are guessed from common conventions such as GEBCO's elevation(lat,
+// lon).
+type NetCDFOptions struct {
+	Variable   string
+	XDim, YDim string
+}
+
+var (
+	ncElevationNames = []string{"elevation", "z", "Band1", "height", "altitude", "topo", "bathymetry"}
+	ncXNames         = []string{"lon", "longitude", "x", "X"}
+	ncYNames         = []string{"lat", "latitude", "y", "Y"}
+)
+
+// NetCDFGrid is a two dimensional elevation variable with its coordinates.
+// Rows run from north to south whatever the order in the file.
+type NetCDFGrid struct {
+	File          *NetCDF
+	Var           *NetCDFVar
+	Width, Height int
+	// X and Y are the sample coordinates of columns and rows, Y from north
+	// to south. Without coordinate variables they are 0, 1, ... for X and
+	// 0, -1, ... for Y, keeping the file's rows in order.
+	X, Y []float64
+
+	flip         bool
+	scale, shift float64
+	fill         []float64
+}
+
+// Grid locates the elevation variable described by opts. Its last two
+// dimensions must be YDim and XDim; any leading dimension, such as time,
+// must have length 1.
+func (f *NetCDF) Grid(opts NetCDFOptions) (*NetCDFGrid, error) {
+	xDim, yDim := f.dim(opts.XDim, ncXNames), f.dim(opts.YDim, ncYNames)
+	if xDim < 0 || yDim < 0 {
+		return nil, errors.New("Expected x and y dimensions in NetCDF file")
+	}
+	var v *NetCDFVar
+	if opts.Variable != "" {
+		if v = f.Var(opts.Variable); v == nil {
+			return nil, fmt.Errorf("NetCDF variable %s not found", opts.Variable)
+		}
+	} else {
+		for _, name := range ncElevationNames {
+			if v = f.Var(name); v != nil {
+				break
+			}
+		}
+		if v == nil {
+			// Fall back to the only variable over y and x.
+			for _, c := range f.Vars {
+				if n := len(c.Dims); n >= 2 && c.Dims[n-2] == yDim && c.Dims[n-1] == xDim {
+					if v != nil {
+						return nil, errors.New("Several NetCDF variables could be elevation, set NetCDFOptions.Variable")
+					}
+					v = c
+				}
+			}
+		}
+		if v == nil {
+			return nil, errors.New("Expected an elevation variable in NetCDF file")
+		}
+	}
+	n := len(v.Dims)
+	if n < 2 || v.Dims[n-2] != yDim || v.Dims[n-1] != xDim {
+		return nil, fmt.Errorf("NetCDF variable %s: expected dimensions (%s, %s)", v.Name, f.Dims[yDim].Name, f.Dims[xDim].Name)
+	}
+	for _, d := range v.Dims[:n-2] {
+		if l := f.Dims[d].Length; l < 1 || (l > 1 && !f.Dims[d].Unlimited) {
+			return nil, fmt.Errorf("NetCDF variable %s: dimension %s must have length 1", v.Name, f.Dims[d].Name)
+		}
+	}
+
+	g := &NetCDFGrid{File: f, Var: v, Width: f.Dims[xDim].Length, Height: f.Dims[yDim].Length, scale: 1}
+	if g.Width < 1 || g.Height < 1 {
+		return nil, fmt.Errorf("NetCDF variable %s is empty", v.Name)
+	}
+	var err error
+	if g.X, _, err = f.coordinate(xDim); err != nil {
+		return nil, err
+	}
+	var found bool
+	if g.Y, found, err = f.coordinate(yDim); err != nil {
+		return nil, err
+	}
+	if !found {
+		for i := range g.Y {
+			g.Y[i] = -g.Y[i]
+		}
+	}
+	if g.Height > 1 && g.Y[g.Height-1] > g.Y[0] {
+		g.flip = true
+		for i, j := 0, g.Height-1; i < j; i, j = i+1, j-1 {
+			g.Y[i], g.Y[j] = g.Y[j], g.Y[i]
+		}
+	}
+	if s, ok := v.Attributes["scale_factor"].([]float64); ok && len(s) == 1 {
+		g.scale = s[0]
+	}
+	if o, ok := v.Attributes["add_offset"].([]float64); ok && len(o) == 1 {
+		g.shift = o[0]
+	}
+	for _, name := range []string{"_FillValue", "missing_value"} {
+		if fv, ok := v.Attributes[name].([]float64); ok {
+			g.fill = append(g.fill, fv...)
+		}
+	}
+	return g, nil
+}
+
+// dim returns the index of the named dimension, or of the first candidate
+// present when name is empty, and -1 when there is none.
+func (f *NetCDF) dim(name string, candidates []string) int {
+	if name != "" {
+		candidates = []string{name}
+	}
+	for _, c := range candidates {
+		for i, d := range f.Dims {
+			if d.Name == c {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// coordinate reads the coordinate variable of a dimension, or returns the
+// indices 0, 1, ... when there is none.
+func (f *NetCDF) coordinate(dim int) ([]float64, bool, error) {
+	d := f.Dims[dim]
+	if v := f.Var(d.Name); v != nil && len(v.Dims) == 1 && v.Dims[0] == dim {
+		c, err := f.readVar(v, 0, d.Length)
+		return c, true, err
+	}
+	c := make([]float64, d.Length)
+	for i := range c {
+		c[i] = float64(i)
+	}
+	return c, false, nil
+}
+
+// ReadWindow returns the w*h samples starting at column x and row y, rows
+// counted from the north. Samples beyond the edge repeat the nearest edge
+// sample and fill values are NaN.
+func (g *NetCDFGrid) ReadWindow(x, y, w, h int) ([]float64, error) {
+	out := make([]float64, w*h)
+	x0 := clampInt(x, 0, g.Width-1)
+	x1 := clampInt(x+w, x0+1, g.Width)
+	for j := 0; j < h; j++ {
+		row := clampInt(y+j, 0, g.Height-1)
+		if g.flip {
+			row = g.Height - 1 - row
+		}
+		values, err := g.File.readVar(g.Var, row*g.Width+x0, x1-x0)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < w; i++ {
+			v := values[clampInt(x+i, x0, x1-1)-x0]
+			for _, fv := range g.fill {
+				if v == fv {
+					v = math.NaN()
+				}
+			}
+			out[j*w+i] = v*g.scale + g.shift
+		}
+	}
+	return out, nil
+}
+
+// NetCDFSource is a TerrainSource sampling XYZ web mercator tiles from a
+// regularly spaced NetCDF grid, such as GEBCO with ProjectionGeographic.
+type NetCDFSource struct {
+	Grid       *NetCDFGrid
+	Projection Projection
+}
+
+func (s *NetCDFSource) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	g := s.Grid
+	px, py, _ := tileSamplePoints(id, gridSize, s.Projection)
+	dx, dy := 1.0, 1.0
+	if g.Width > 1 {
+		dx = (g.X[g.Width-1] - g.X[0]) / float64(g.Width-1)
+	}
+	if g.Height > 1 {
+		dy = (g.Y[0] - g.Y[g.Height-1]) / float64(g.Height-1)
+	}
+
+	minPX, minPY := math.Inf(1), math.Inf(1)
+	maxPX, maxPY := math.Inf(-1), math.Inf(-1)
+	for k := range px {
+		px[k] = (px[k] - g.X[0]) / dx
+		py[k] = (g.Y[0] - py[k]) / dy
+		minPX, maxPX = math.Min(minPX, px[k]), math.Max(maxPX, px[k])
+		minPY, maxPY = math.Min(minPY, py[k]), math.Max(maxPY, py[k])
+	}
+	x0 := int(math.Max(math.Floor(minPX), 0))
+	y0 := int(math.Max(math.Floor(minPY), 0))
+	x1 := int(math.Min(math.Floor(maxPX)+1, float64(g.Width-1)))
+	y1 := int(math.Min(math.Floor(maxPY)+1, float64(g.Height-1)))
+	if x1 < x0 || y1 < y0 {
+		return nil, fmt.Errorf("tile %v: outside of the NetCDF extent", id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ww, wh := x1-x0+1, y1-y0+1
+	window, err := g.ReadWindow(x0, y0, ww, wh)
+	if err != nil {
+		return nil, err
+	}
+
+	terrain := make([]float64, gridSize*gridSize)
+	for k := range terrain {
+		terrain[k] = bilinear(window, ww, wh, px[k]-float64(x0), py[k]-float64(y0))
+	}
+	return terrain, nil
+}
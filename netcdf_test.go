@@ -0,0 +1,198 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+type testNCVar struct {
+	name  string
+	dims  []int
+	attrs map[string][]float64
+	typ   int
+	data  []float64
+}
+
+// writeNetCDF encodes a classic (version 1) or 64-bit offset (version 2)
+// NetCDF file. A dimension of length 0 is the record dimension, holding a
+// single record.
+func writeNetCDF(version byte, dimNames []string, dimLens []int, vars []testNCVar) []byte {
+	var h bytes.Buffer
+	u32 := func(v uint32) { binary.Write(&h, binary.BigEndian, v) }
+	name := func(s string) {
+		u32(uint32(len(s)))
+		h.WriteString(s)
+		h.Write(make([]byte, (4-len(s)%4)%4))
+	}
+	h.WriteString("CDF")
+	h.WriteByte(version)
+	u32(1)
+	u32(ncDimension)
+	u32(uint32(len(dimNames)))
+	for i, n := range dimNames {
+		name(n)
+		u32(uint32(dimLens[i]))
+	}
+	u32(0)
+	u32(0)
+
+	encode := func(typ int, values []float64) []byte {
+		var b bytes.Buffer
+		for _, v := range values {
+			switch typ {
+			case ncShort:
+				binary.Write(&b, binary.BigEndian, int16(v))
+			case ncFloat:
+				binary.Write(&b, binary.BigEndian, float32(v))
+			default:
+				binary.Write(&b, binary.BigEndian, v)
+			}
+		}
+		b.Write(make([]byte, (4-b.Len()%4)%4))
+		return b.Bytes()
+	}
+	data := make([][]byte, len(vars))
+	begins := make([]int, len(vars))
+	u32(ncVariable)
+	u32(uint32(len(vars)))
+	for i, v := range vars {
+		name(v.name)
+		u32(uint32(len(v.dims)))
+		for _, d := range v.dims {
+			u32(uint32(d))
+		}
+		if len(v.attrs) == 0 {
+			u32(0)
+			u32(0)
+		} else {
+			u32(ncAttribute)
+			u32(uint32(len(v.attrs)))
+			for k, a := range v.attrs {
+				name(k)
+				u32(ncDouble)
+				u32(uint32(len(a)))
+				h.Write(encode(ncDouble, a))
+			}
+		}
+		u32(uint32(v.typ))
+		data[i] = encode(v.typ, v.data)
+		u32(uint32(len(data[i])))
+		begins[i] = h.Len()
+		if version == 1 {
+			u32(0)
+		} else {
+			binary.Write(&h, binary.BigEndian, uint64(0))
+		}
+	}
+	out := h.Bytes()
+	for i := range vars {
+		if version == 1 {
+			binary.BigEndian.PutUint32(out[begins[i]:], uint32(len(out)))
+		} else {
+			binary.BigEndian.PutUint64(out[begins[i]:], uint64(len(out)))
+		}
+		out = append(out, data[i]...)
+	}
+	return out
+}
+
+func TestNetCDFGrid(t *testing.T) {
+	// elevation(time, lat, lon) with latitudes running south to north,
+	// packed as shorts with a fill value.
+	lat := []float64{10, 20, 30}
+	lon := []float64{0, 1, 2, 3}
+	elevation := []float64{
+		0, 2, 4, 6, // lat 10
+		10, 12, -32768, 16, // lat 20
+		20, 22, 24, 26, // lat 30
+	}
+	file := writeNetCDF(2, []string{"time", "lat", "lon"}, []int{0, 3, 4}, []testNCVar{
+		{name: "lat", dims: []int{1}, typ: ncDouble, data: lat},
+		{name: "lon", dims: []int{2}, typ: ncFloat, data: lon},
+		{name: "elevation", dims: []int{0, 1, 2}, typ: ncShort, data: elevation, attrs: map[string][]float64{
+			"scale_factor": {0.5}, "add_offset": {-100}, "_FillValue": {-32768},
+		}},
+	})
+	f, err := OpenNetCDF(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Dims) != 3 || !f.Dims[0].Unlimited || f.Dims[0].Length != 1 {
+		t.Fatalf("unexpected dimensions %+v", f.Dims)
+	}
+	g, err := f.Grid(NetCDFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Width != 4 || g.Height != 3 || g.Y[0] != 30 || g.Y[2] != 10 || g.X[3] != 3 {
+		t.Fatalf("unexpected grid %dx%d %v %v", g.Width, g.Height, g.X, g.Y)
+	}
+	window, err := g.ReadWindow(2, 0, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{-88, -87, -87, math.NaN(), -92, -92}
+	for i, w := range want {
+		if got := window[i]; got != w && !(math.IsNaN(got) && math.IsNaN(w)) {
+			t.Fatalf("window %v, want %v", window, want)
+		}
+	}
+
+	if _, err := f.Grid(NetCDFOptions{Variable: "depth"}); err == nil {
+		t.Error("expected error for a missing variable")
+	}
+	if _, err := f.Grid(NetCDFOptions{Variable: "lat"}); err == nil {
+		t.Error("expected error for a one dimensional variable")
+	}
+	if _, err := OpenNetCDF(bytes.NewReader([]byte("\x89HDF\r\n\x1a\n"))); err == nil {
+		t.Error("expected error for NetCDF-4")
+	}
+	if _, err := OpenNetCDF(bytes.NewReader(file[:40])); err == nil {
+		t.Error("expected error for a truncated header")
+	}
+}
+
+func TestNetCDFSource(t *testing.T) {
+	// A global geographic grid at 10 degrees, elevation linear in lon and
+	// lat so bilinear sampling is exact.
+	var lat, lon, z []float64
+	for y := -90; y <= 90; y += 10 {
+		lat = append(lat, float64(y))
+	}
+	for x := -180; x <= 180; x += 10 {
+		lon = append(lon, float64(x))
+	}
+	for _, y := range lat {
+		for _, x := range lon {
+			z = append(z, 2*x+y)
+		}
+	}
+	file := writeNetCDF(1, []string{"y", "x"}, []int{len(lat), len(lon)}, []testNCVar{
+		{name: "x", dims: []int{1}, typ: ncDouble, data: lon},
+		{name: "y", dims: []int{0}, typ: ncDouble, data: lat},
+		{name: "z", dims: []int{0, 1}, typ: ncDouble, data: z},
+	})
+	f, err := OpenNetCDF(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := f.Grid(NetCDFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &NetCDFSource{Grid: g, Projection: ProjectionGeographic}
+	id := TileID{2, 1, 1}
+	terrain, err := source.Terrain(context.Background(), id, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	px, py, _ := tileSamplePoints(id, 9, ProjectionGeographic)
+	for k := range terrain {
+		if want := 2*px[k] + py[k]; math.Abs(terrain[k]-want) > 1e-9 {
+			t.Fatalf("sample %d: got %v want %v", k, terrain[k], want)
+		}
+	}
+}
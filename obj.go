@@ -0,0 +1,44 @@
+package martini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// EncodeOBJ writes m as a Wavefront OBJ file. Vertex colors, when m has
+// them, follow the positions as r g b in 0..1, an extension most readers
+// accept.
+func EncodeOBJ(w io.Writer, m *Mesh) error {
+	indices, err := m.ExportIndices(IndexBaseOne, 0)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	fmt.Fprintf(bw, "# generated by go-martini\n")
+	colors := len(m.Colors) >= 3*m.NumVertices()
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, z := m.Vertex(i)
+		fmt.Fprintf(bw, "v %s %s %s", f(x), f(y), f(z))
+		if colors {
+			c := m.Colors[3*i : 3*i+3]
+			fmt.Fprintf(bw, " %s %s %s", f(float64(c[0])/255), f(float64(c[1])/255), f(float64(c[2])/255))
+		}
+		bw.WriteByte('\n')
+	}
+	for i := 0; i+2 < len(indices); i += 3 {
+		fmt.Fprintf(bw, "f %d %d %d\n", indices[i], indices[i+1], indices[i+2])
+	}
+	return bw.Flush()
+}
+
+var FormatOBJ = &Format{
+	Name:        "obj",
+	Extension:   "obj",
+	ContentType: "model/obj",
+	Encode:      EncodeOBJ,
+}
@@ -0,0 +1,28 @@
+package martini
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeOBJ(t *testing.T) {
+	mesh := &Mesh{
+		Vertices:  []float64{0, 0, 1, 1, 0, 2, 0, 1, 3},
+		Triangles: []uint32{0, 1, 2},
+		Colors:    []uint8{255, 0, 0, 0, 255, 0, 0, 0, 255},
+	}
+	var buf bytes.Buffer
+	if err := EncodeOBJ(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	want := "# generated by go-martini\nv 0 0 1 1 0 0\nv 1 0 2 0 1 0\nv 0 1 3 0 0 1\nf 1 2 3\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s", buf.String())
+	}
+
+	mesh.Triangles = []uint32{0, 1, 3}
+	if err := EncodeOBJ(&buf, mesh); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected range error, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package martini
+
+// Winding selects the triangle orientation of an extracted mesh. A triangle
+// (a, b, c) is counter-clockwise when (b-a)×(c-a) points along the up axis,
+// the convention used by OpenGL and glTF.
+type Winding int
+
+const (
+	WindingNative Winding = iota
+	WindingCCW
+	WindingCW
+)
+
+// Axes selects how grid x, grid y and height map onto the output axes.
+type Axes int
+
+const (
+	// AxesZUp keeps grid x, grid y and height as x, y, z.
+	AxesZUp Axes = iota
+	// AxesYUp is right-handed with height on y: (x, height, -y).
+	AxesYUp
+	// AxesYUpLeftHanded is left-handed with height on y: (x, height, y).
+	AxesYUpLeftHanded
+)
+
+// MeshOptions controls how a tile mesh is laid out on extraction. The zero
+// value reproduces ToMesh.
+type MeshOptions struct {
+	Winding Winding
+	Axes    Axes
+	// FlipY mirrors grid rows so that row 0, the top of an image, ends up
+	// at the largest y. Use it to turn image-space heightmaps into
+	// north-up map space.
+	FlipY bool
+}
+
+func (a Axes) up() int {
+	if a == AxesZUp {
+		return 2
+	}
+	return 1
+}
+
+func (a Axes) apply(x, y, z float64) (float64, float64, float64) {
+	switch a {
+	case AxesYUp:
+		return x, z, -y
+	case AxesYUpLeftHanded:
+		return x, z, y
+	}
+	return x, y, z
+}
+
+// ToMeshWithOptions extracts the mesh for maxError and applies the axis
+// convention and winding order in opts.
+func (t *Tile) ToMeshWithOptions(maxError float64, opts *MeshOptions) *Mesh {
+	mesh := t.ToMesh(maxError)
+	mesh.applyOptions(t.Martini.GridSize, opts)
+	return mesh
+}
+
+// applyOptions lays out a mesh in the grid frame according to opts.
+func (m *Mesh) applyOptions(gridSize int, opts *MeshOptions) {
+	if opts == nil {
+		return
+	}
+	if opts.FlipY {
+		max := float64(gridSize - 1)
+		for i := 1; i < len(m.Vertices); i += 3 {
+			m.Vertices[i] = max - m.Vertices[i]
+		}
+	}
+	m.apply(opts)
+}
+
+func (m *Mesh) apply(opts *MeshOptions) {
+	for i := 0; i+2 < len(m.Vertices); i += 3 {
+		m.Vertices[i], m.Vertices[i+1], m.Vertices[i+2] = opts.Axes.apply(m.Vertices[i], m.Vertices[i+1], m.Vertices[i+2])
+	}
+	if opts.Winding == WindingNative || m.NumTriangles() == 0 {
+		return
+	}
+	if m.isCCW(opts.Axes.up()) != (opts.Winding == WindingCCW) {
+		m.FlipWinding()
+	}
+}
+
+// isCCW reports the orientation of the first non-degenerate triangle. RTIN
+// subdivision preserves orientation, so all triangles of an extracted mesh
+// share it.
+func (m *Mesh) isCCW(up int) bool {
+	for t := 0; t < m.NumTriangles(); t++ {
+		a := m.Triangles[3*t : 3*t+3]
+		var p [3][3]float64
+		for k := 0; k < 3; k++ {
+			p[k][0], p[k][1], p[k][2] = m.Vertex(int(a[k]))
+		}
+		u := [3]float64{p[1][0] - p[0][0], p[1][1] - p[0][1], p[1][2] - p[0][2]}
+		v := [3]float64{p[2][0] - p[0][0], p[2][1] - p[0][1], p[2][2] - p[0][2]}
+		i, j := (up+1)%3, (up+2)%3
+		n := u[i]*v[j] - u[j]*v[i]
+		if n != 0 {
+			return n > 0
+		}
+	}
+	return true
+}
+
+// FlipWinding reverses the orientation of every triangle.
+func (m *Mesh) FlipWinding() {
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		m.Triangles[i+1], m.Triangles[i+2] = m.Triangles[i+2], m.Triangles[i+1]
+	}
+}
+
+// FlipTerrainY mirrors the rows of a terrain grid in place.
+func FlipTerrainY(terrain []float64, gridSize int) {
+	for top, bottom := 0, gridSize-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a := terrain[top*gridSize : (top+1)*gridSize]
+		b := terrain[bottom*gridSize : (bottom+1)*gridSize]
+		for x := range a {
+			a[x], b[x] = b[x], a[x]
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package martini
+
+import "testing"
+
+func TestMeshOptions(t *testing.T) {
+	martini, _ := NewMartini(17)
+	tile, _ := martini.CreateTile(testTerrain(17, hills))
+
+	for _, axes := range []Axes{AxesZUp, AxesYUp, AxesYUpLeftHanded} {
+		for _, w := range []Winding{WindingCCW, WindingCW} {
+			m := tile.ToMeshWithOptions(10, &MeshOptions{Winding: w, Axes: axes})
+			if m.isCCW(axes.up()) != (w == WindingCCW) {
+				t.Errorf("axes %d winding %d: wrong orientation", axes, w)
+			}
+		}
+	}
+
+	m := tile.ToMeshWithOptions(10, &MeshOptions{Axes: AxesYUp})
+	ref := tile.ToMesh(10)
+	for i := 0; i < ref.NumVertices(); i++ {
+		x, y, z := ref.Vertex(i)
+		gx, gy, gz := m.Vertex(i)
+		if gx != x || gy != z || gz != -y {
+			t.Fatalf("vertex %d not converted to y-up", i)
+		}
+	}
+}
+
+func TestFlipY(t *testing.T) {
+	martini, _ := NewMartini(17)
+	terrain := testTerrain(17, hills)
+	tile, _ := martini.CreateTile(terrain)
+
+	ref := tile.ToMesh(10)
+	m := tile.ToMeshWithOptions(10, &MeshOptions{FlipY: true, Winding: WindingCCW})
+	if !m.isCCW(2) {
+		t.Error("expected counter-clockwise triangles after flip")
+	}
+	for i := 0; i < ref.NumVertices(); i++ {
+		_, y, z := ref.Vertex(i)
+		_, fy, fz := m.Vertex(i)
+		if fy != 16-y || fz != z {
+			t.Fatalf("vertex %d not flipped", i)
+		}
+	}
+
+	flipped := append([]float64(nil), terrain...)
+	FlipTerrainY(flipped, 17)
+	if flipped[0] != terrain[16*17] || flipped[16*17+5] != terrain[5] || flipped[8*17+3] != terrain[8*17+3] {
+		t.Error("terrain rows not mirrored")
+	}
+}
@@ -0,0 +1,48 @@
+package martini
+
+import (
+	"context"
+	"errors"
+)
+
+// ParentTerrain builds the terrain of a parent tile from its four
+// children, given in the order of TileID.Children, by joining them along
+// their shared edges and downsampling the result with agg. Where children
+// disagree on a shared edge, the earlier child wins.
+func ParentTerrain(children [4][]float64, gridSize int, agg Aggregation) ([]float64, error) {
+	for _, c := range children {
+		if len(c) != gridSize*gridSize {
+			return nil, errors.New("Expected four children of length gridSize*gridSize")
+		}
+	}
+	half := gridSize - 1
+	size := 2*half + 1
+	joined := make([]float64, size*size)
+	for q := 3; q >= 0; q-- {
+		ox, oy := (q%2)*half, (q/2)*half
+		for y := 0; y < gridSize; y++ {
+			copy(joined[(oy+y)*size+ox:], children[q][y*gridSize:(y+1)*gridSize])
+		}
+	}
+	return Downsample(joined, size, gridSize, agg)
+}
+
+// ParentSource synthesizes every tile from its four children in Source,
+// the way pyramid builders produce low zoom levels from the highest one.
+// Wrap it around itself to go up several levels.
+type ParentSource struct {
+	Source      TerrainSource
+	Aggregation Aggregation
+}
+
+func (s *ParentSource) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	var children [4][]float64
+	for i, c := range id.Children() {
+		terrain, err := s.Source.Terrain(ctx, c, gridSize)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = terrain
+	}
+	return ParentTerrain(children, gridSize, s.Aggregation)
+}
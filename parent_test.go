@@ -0,0 +1,45 @@
+package martini
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParentTerrain(t *testing.T) {
+	plane := func(x, y int) float64 { return 3*float64(x) - 2*float64(y) }
+	source := TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		return testTerrain(gridSize, func(x, y int) float64 {
+			return plane(x+id.X*(gridSize-1), y+id.Y*(gridSize-1))
+		}), nil
+	})
+
+	parent, err := (&ParentSource{Source: source}).Terrain(context.Background(), TileID{4, 3, 5}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Interior footprints are symmetric, so the mean of a plane is exact.
+	for y := 1; y < 16; y++ {
+		for x := 1; x < 16; x++ {
+			if got, want := parent[y*17+x], plane(2*(x+3*16), 2*(y+5*16)); got != want {
+				t.Fatalf("sample (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	var children [4][]float64
+	for i, c := range (TileID{1, 0, 0}).Children() {
+		children[i], _ = source.Terrain(context.Background(), c, 17)
+	}
+	max, _ := ParentTerrain(children, 17, AggregateMax)
+	mean, _ := ParentTerrain(children, 17, AggregateMean)
+	for i := range max {
+		if max[i] < mean[i] {
+			t.Fatalf("max %v below mean %v at %d", max[i], mean[i], i)
+		}
+	}
+
+	children[2] = children[2][:10]
+	if _, err := ParentTerrain(children, 17, AggregateMean); err == nil {
+		t.Error("expected error for a short child")
+	}
+}
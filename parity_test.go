@@ -0,0 +1,53 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+// jsErrors mirrors the update of the JavaScript martini, which keeps
+// terrain and errors in Float32Arrays and does the arithmetic in float64.
+func jsErrors(terrain []float32, m *Martini) []float32 {
+	size := m.GridSize
+	errs := make([]float32, len(terrain))
+	for i := m.NumTriangles - 1; i >= 0; i-- {
+		ax, ay, bx, by := m.triangle(i)
+		mx := (ax + bx) >> 1
+		my := (ay + by) >> 1
+		cx := mx + my - ay
+		cy := my + ax - mx
+
+		interpolatedHeight := (float64(terrain[int(ay)*size+int(ax)]) + float64(terrain[int(by)*size+int(bx)])) / 2
+		middleIndex := int(my)*size + int(mx)
+		middleError := math.Abs(interpolatedHeight - float64(terrain[middleIndex]))
+		errs[middleIndex] = float32(math.Max(float64(errs[middleIndex]), middleError))
+
+		if i < m.NumParentTriangles {
+			leftChildIndex := (int(ay+cy)>>1)*size + (int(ax+cx) >> 1)
+			rightChildIndex := (int(by+cy)>>1)*size + (int(bx+cx) >> 1)
+			errs[middleIndex] = float32(math.Max(math.Max(float64(errs[middleIndex]), float64(errs[leftChildIndex])), float64(errs[rightChildIndex])))
+		}
+	}
+	return errs
+}
+
+func TestFloat32Parity(t *testing.T) {
+	terrain, _ := LoadPngData("./tests/fuji.png")
+	m, _ := NewMartini(513)
+	tile, err := m.CreateTileWithOptions(terrain, &TileOptions{Float32: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terrain32 := make([]float32, len(terrain))
+	for i, h := range terrain {
+		terrain32[i] = float32(h)
+	}
+	want := jsErrors(terrain32, m)
+	for i := range want {
+		if tile.Errors[i] != float64(want[i]) {
+			t.Fatalf("error %d is %v, want %v", i, tile.Errors[i], want[i])
+		}
+	}
+
+}
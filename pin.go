@@ -0,0 +1,46 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// pinnedTile returns a view of t extracted at 1 whose pinned samples have
+// an infinite error, forcing the triangles above them to split.
+func (t *Tile) pinnedTile(maxError float64, pins [][2]int) (*Tile, error) {
+	size := t.Martini.GridSize
+	bias := make([]float64, len(t.Terrain))
+	copy(bias, t.bias)
+	for _, p := range pins {
+		if p[0] < 0 || p[1] < 0 || p[0] >= size || p[1] >= size {
+			return nil, errors.New("Expected pinned points inside the grid")
+		}
+		bias[p[1]*size+p[0]] = math.Inf(1)
+	}
+	thresholds := make([]float64, len(t.Terrain))
+	for i := range thresholds {
+		thresholds[i] = maxError
+	}
+	return t.view(thresholds, bias), nil
+}
+
+// GetMeshPinned is GetMesh with a set of grid points, such as survey
+// markers or runway thresholds, that always become vertices regardless of
+// maxError. The mesh is refined around them just enough to stay crack-free.
+func (t *Tile) GetMeshPinned(maxError float64, pins [][2]int) ([]uint16, []uint16, error) {
+	w, err := t.pinnedTile(maxError, pins)
+	if err != nil {
+		return nil, nil, err
+	}
+	vertices, triangles := w.GetMesh(1)
+	return vertices, triangles, nil
+}
+
+// ToMeshPinned is ToMesh with pinned grid points; see GetMeshPinned.
+func (t *Tile) ToMeshPinned(maxError float64, pins [][2]int) (*Mesh, error) {
+	w, err := t.pinnedTile(maxError, pins)
+	if err != nil {
+		return nil, err
+	}
+	return w.ToMesh(1), nil
+}
@@ -0,0 +1,36 @@
+package martini
+
+import "testing"
+
+func TestMeshPinned(t *testing.T) {
+	m, _ := NewMartini(65)
+	// Flat terrain reduces to two triangles unless points are pinned.
+	tile, _ := m.CreateTile(make([]float64, 65*65))
+
+	pins := [][2]int{{17, 5}, {40, 41}, {0, 33}}
+	mesh, err := tile.ToMeshPinned(100, pins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pins {
+		found := false
+		for i := 0; i < mesh.NumVertices(); i++ {
+			if x, y, _ := mesh.Vertex(i); x == float64(p[0]) && y == float64(p[1]) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("pinned point %v is not a vertex", p)
+		}
+	}
+	if n := tJunctions(mesh); n != 0 {
+		t.Errorf("%d T-junctions", n)
+	}
+
+	if got, _ := tile.ToMeshPinned(100, nil); got.NumTriangles() != 2 {
+		t.Errorf("expected 2 triangles without pins, got %d", got.NumTriangles())
+	}
+	if _, _, err := tile.GetMeshPinned(1, [][2]int{{65, 0}}); err == nil {
+		t.Error("expected error for a pin outside the grid")
+	}
+}
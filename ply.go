@@ -0,0 +1,104 @@
+package martini
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// EncodePLY writes m as a binary little-endian PLY file. Attributes become
+// extra float vertex properties and colors red, green and blue uchar
+// properties.
+func EncodePLY(w io.Writer, m *Mesh) error {
+	return encodePLY(w, m, nil, false)
+}
+
+// EncodePLYDouble is EncodePLY with positions stored as doubles, keeping
+// the full precision of ECEF or projected coordinates.
+func EncodePLYDouble(w io.Writer, m *Mesh) error {
+	return encodePLY(w, m, nil, true)
+}
+
+// EncodePLYQuantized is EncodePLY with positions quantized to bits bits,
+// stored as uchar or ushort properties. Header comments
+//
+//	comment quantization offset <x> <y> <z>
+//	comment quantization scale <x> <y> <z>
+//
+// give the Quantization recovering the coordinates.
+func EncodePLYQuantized(w io.Writer, m *Mesh, bits int) error {
+	q := NewQuantization(m, bits)
+	return encodePLY(w, m, &q, false)
+}
+
+func encodePLY(w io.Writer, m *Mesh, q *Quantization, double bool) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ply\nformat binary_little_endian 1.0\ncomment generated by go-martini\n")
+	position := "float"
+	if double {
+		position = "double"
+	}
+	if q != nil {
+		fmt.Fprintf(bw, "comment quantization offset %v %v %v\n", q.Offset[0], q.Offset[1], q.Offset[2])
+		fmt.Fprintf(bw, "comment quantization scale %v %v %v\n", q.Scale[0], q.Scale[1], q.Scale[2])
+		position = "ushort"
+		if q.Bits <= 8 {
+			position = "uchar"
+		}
+	}
+	fmt.Fprintf(bw, "element vertex %d\nproperty %[2]s x\nproperty %[2]s y\nproperty %[2]s z\n", m.NumVertices(), position)
+	colors := len(m.Colors) == 3*m.NumVertices()
+	if colors {
+		fmt.Fprintf(bw, "property uchar red\nproperty uchar green\nproperty uchar blue\n")
+	}
+	for _, a := range m.Attributes {
+		fmt.Fprintf(bw, "property float %s\n", a.Name)
+	}
+	fmt.Fprintf(bw, "element face %d\nproperty list uchar uint vertex_indices\nend_header\n", m.NumTriangles())
+
+	var buf [8]byte
+	putFloat := func(v float64) {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(v)))
+		bw.Write(buf[:4])
+	}
+	for i := 0; i < m.NumVertices(); i++ {
+		for c := 0; c < 3; c++ {
+			v := m.Vertices[3*i+c]
+			switch {
+			case q == nil && double:
+				binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+				bw.Write(buf[:])
+			case q == nil:
+				putFloat(v)
+			case q.Bits <= 8:
+				bw.WriteByte(byte(q.Quantize(v, c)))
+			default:
+				binary.LittleEndian.PutUint16(buf[:], q.Quantize(v, c))
+				bw.Write(buf[:2])
+			}
+		}
+		if colors {
+			bw.Write(m.Colors[3*i : 3*i+3])
+		}
+		for _, a := range m.Attributes {
+			putFloat(a.Values[i])
+		}
+	}
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		bw.WriteByte(3)
+		for _, v := range m.Triangles[i : i+3] {
+			binary.LittleEndian.PutUint32(buf[:], v)
+			bw.Write(buf[:4])
+		}
+	}
+	return bw.Flush()
+}
+
+var FormatPLY = &Format{
+	Name:        "ply",
+	Extension:   "ply",
+	ContentType: "application/octet-stream",
+	Encode:      EncodePLY,
+}
@@ -0,0 +1,81 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestEncodePLY(t *testing.T) {
+	mesh := &Mesh{
+		Vertices:   []float64{0, 0, 1, 1, 0, 2, 0, 1, 3},
+		Triangles:  []uint32{0, 1, 2},
+		Attributes: []Attribute{{Name: "temperature", Values: []float64{10, 11, 12}}},
+	}
+	var buf bytes.Buffer
+	if err := EncodePLY(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	header := "end_header\n"
+	i := bytes.Index(buf.Bytes(), []byte(header))
+	if i < 0 || !bytes.Contains(buf.Bytes()[:i], []byte("property float temperature\n")) {
+		t.Fatalf("bad header:\n%s", buf.String())
+	}
+	if n := buf.Len() - i - len(header); n != 3*4*4+1+3*4 {
+		t.Errorf("unexpected body size %d", n)
+	}
+}
+
+func TestEncodePLYQuantized(t *testing.T) {
+	mesh := &Mesh{
+		Vertices:  []float64{0, 0, 100, 2, 0, 200, 0, 2, 300},
+		Triangles: []uint32{0, 1, 2},
+	}
+	var buf bytes.Buffer
+	if err := EncodePLYQuantized(&buf, mesh, 16); err != nil {
+		t.Fatal(err)
+	}
+	header := "end_header\n"
+	i := bytes.Index(buf.Bytes(), []byte(header))
+	for _, line := range []string{
+		"comment quantization offset 0 0 100\n",
+		fmt.Sprintf("comment quantization scale %v %v %v\n", 2.0/65535, 2.0/65535, 200.0/65535),
+		"property ushort z\n",
+	} {
+		if i < 0 || !bytes.Contains(buf.Bytes()[:i], []byte(line)) {
+			t.Fatalf("missing %q in header:\n%s", line, buf.String())
+		}
+	}
+	body := buf.Bytes()[i+len(header):]
+	if len(body) != 3*3*2+1+3*4 {
+		t.Fatalf("unexpected body size %d", len(body))
+	}
+	if z := binary.LittleEndian.Uint16(body[3*2*2+4:]); z != 65535 {
+		t.Errorf("last height quantized to %d", z)
+	}
+}
+
+func TestEncodePLYDouble(t *testing.T) {
+	mesh := &Mesh{
+		Vertices:  []float64{6378137.123456, 0, 1, 1, 0, 2, 0, 1, 3},
+		Triangles: []uint32{0, 1, 2},
+	}
+	var buf bytes.Buffer
+	if err := EncodePLYDouble(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	header := "end_header\n"
+	i := bytes.Index(buf.Bytes(), []byte(header))
+	if i < 0 || !bytes.Contains(buf.Bytes()[:i], []byte("property double x\n")) {
+		t.Fatalf("bad header:\n%s", buf.String())
+	}
+	body := buf.Bytes()[i+len(header):]
+	if len(body) != 3*3*8+1+3*4 {
+		t.Fatalf("unexpected body size %d", len(body))
+	}
+	if x := math.Float64frombits(binary.LittleEndian.Uint64(body)); x != mesh.Vertices[0] {
+		t.Errorf("x is %v", x)
+	}
+}
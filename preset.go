@@ -0,0 +1,68 @@
+package martini
+
+import (
+	"fmt"
+	"math"
+)
+
+// QualityPreset chooses maxError and tile options for a purpose, so that
+// callers need not reason about the error metric. maxError follows the
+// ground size of a grid cell, which halves with every zoom level and
+// shrinks with the cosine of the latitude on web mercator tiles.
+type QualityPreset struct {
+	Name string
+	// CellFraction is maxError as a fraction of a grid cell's ground size.
+	CellFraction float64
+	// MinError bounds maxError from below, in meters, so that the finest
+	// zoom levels do not mesh sensor noise.
+	MinError float64
+	// Curvature is used as TileOptions.Curvature.
+	Curvature float64
+}
+
+var (
+	// PresetWeb suits streaming to web viewers, at about the error Cesium
+	// accepts for its terrain.
+	PresetWeb = &QualityPreset{Name: "web", CellFraction: 0.25, MinError: 0.5}
+	// PresetPrint keeps fine detail and sharp ridges for 3D printing.
+	PresetPrint = &QualityPreset{Name: "print", CellFraction: 0.05, MinError: 0.1, Curvature: 0.5}
+	// PresetSimulation stays close to the source heights for analysis.
+	PresetSimulation = &QualityPreset{Name: "simulation", CellFraction: 0.01, MinError: 0.01}
+)
+
+// Preset returns the built-in preset called name: web, print or
+// simulation.
+func Preset(name string) (*QualityPreset, error) {
+	for _, p := range []*QualityPreset{PresetWeb, PresetPrint, PresetSimulation} {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown quality preset %q", name)
+}
+
+// MaxError returns the maxError, in meters, for web mercator tiles of zoom
+// z around latitude lat meshed on gridSize grids.
+func (p *QualityPreset) MaxError(z int, lat float64, gridSize int) float64 {
+	cell := 2 * webMercatorHalf * math.Cos(lat*math.Pi/180) / float64(int64(1)<<uint(z)) / float64(gridSize-1)
+	return math.Max(p.CellFraction*cell, p.MinError)
+}
+
+// Apply sets the maxError and tile options of a pyramid build, for the
+// latitude at the middle of opts.Bounds. Set GridSize and Bounds first.
+func (p *QualityPreset) Apply(opts *PyramidOptions) {
+	b := opts.Bounds
+	lat := (b.South + b.North) / 2
+	gridSize := opts.GridSize
+	opts.MaxErrorForZoom = func(z int) float64 {
+		return p.MaxError(z, lat, gridSize)
+	}
+	if p.Curvature > 0 {
+		tileOpts := TileOptions{}
+		if opts.TileOptions != nil {
+			tileOpts = *opts.TileOptions
+		}
+		tileOpts.Curvature = p.Curvature
+		opts.TileOptions = &tileOpts
+	}
+}
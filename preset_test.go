@@ -0,0 +1,36 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQualityPreset(t *testing.T) {
+	p, err := Preset("web")
+	if err != nil || p != PresetWeb {
+		t.Fatalf("Preset(web) = %v, %v", p, err)
+	}
+	if _, err := Preset("nope"); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+
+	e0, e1 := p.MaxError(5, 0, 257), p.MaxError(6, 0, 257)
+	if math.Abs(e0-2*e1) > 1e-9 {
+		t.Errorf("maxError %v at z5, %v at z6: want halving", e0, e1)
+	}
+	if e := p.MaxError(5, 60, 257); math.Abs(e-e0/2) > 1e-9 {
+		t.Errorf("maxError %v at 60°, want %v", e, e0/2)
+	}
+	if e := p.MaxError(24, 0, 257); e != p.MinError {
+		t.Errorf("maxError %v at z24, want the floor %v", e, p.MinError)
+	}
+
+	opts := PyramidOptions{GridSize: 257, Bounds: Bounds{0, 50, 10, 70}, TileOptions: &TileOptions{Smoothing: 1}}
+	PresetPrint.Apply(&opts)
+	if opts.MaxErrorForZoom(8) != PresetPrint.MaxError(8, 60, 257) {
+		t.Error("maxError not taken at the middle of the bounds")
+	}
+	if opts.TileOptions.Curvature != PresetPrint.Curvature || opts.TileOptions.Smoothing != 1 {
+		t.Errorf("tile options %+v", opts.TileOptions)
+	}
+}
@@ -0,0 +1,116 @@
+package martini
+
+import (
+	"io"
+	"net/http"
+)
+
+// FormatPNG serves a shaded preview of each tile mesh.
+var FormatPNG = &Format{
+	Name:        "png",
+	Extension:   "png",
+	ContentType: "image/png",
+	Encode: func(w io.Writer, m *Mesh) error {
+		return RenderPNG(w, m, &RenderOptions{Width: 256, Shading: ShadingGouraud, Color: ColorElevation})
+	},
+}
+
+// FormatSVG serves a wireframe of each tile mesh.
+var FormatSVG = &Format{
+	Name:        "svg",
+	Extension:   "svg",
+	ContentType: "image/svg+xml",
+	Encode: func(w io.Writer, m *Mesh) error {
+		return RenderSVG(w, m, &SVGOptions{Width: 256, Color: ColorElevation, Stroke: "#0008"})
+	},
+}
+
+func (s *Server) servePreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, previewHTML)
+}
+
+// previewHTML is a self-contained slippy map over the png and svg formats
+// with a maxError slider.
+const previewHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>martini preview</title>
+<style>
+html, body { margin: 0; height: 100%; overflow: hidden; font: 13px sans-serif; background: #222; }
+#map { position: absolute; inset: 0; cursor: grab; }
+#map img { position: absolute; width: 256px; height: 256px; user-select: none; -webkit-user-drag: none; }
+#panel { position: absolute; top: 8px; left: 8px; padding: 8px; background: #fffd; border-radius: 4px; }
+</style>
+</head>
+<body>
+<div id="map"></div>
+<div id="panel">
+<label>maxError <input id="err" type="range" min="0" max="50" step="0.5" value="5"> <span id="errv">5</span></label><br>
+<label><input id="wire" type="checkbox"> wireframe</label>
+<span id="pos"></span>
+</div>
+<script>
+var map = document.getElementById("map");
+var state = {z: 0, cx: 0.5, cy: 0.5, err: 5, wire: false};
+var tiles = {};
+
+function url(z, x, y) {
+	return "/" + z + "/" + x + "/" + y + "." + (state.wire ? "svg" : "png") + "?maxError=" + state.err;
+}
+
+function render() {
+	var n = 1 << state.z, w = map.clientWidth, h = map.clientHeight;
+	var ox = w / 2 - state.cx * 256, oy = h / 2 - state.cy * 256;
+	var seen = {};
+	for (var y = Math.max(0, Math.floor(-oy / 256)); y < Math.min(n, Math.ceil((h - oy) / 256)); y++) {
+		for (var x = Math.max(0, Math.floor(-ox / 256)); x < Math.min(n, Math.ceil((w - ox) / 256)); x++) {
+			var key = state.z + "/" + x + "/" + y, img = tiles[key];
+			if (!img) {
+				img = tiles[key] = document.createElement("img");
+				map.appendChild(img);
+			}
+			var src = url(state.z, x, y);
+			if (img.getAttribute("src") !== src) img.src = src;
+			img.style.left = (ox + x * 256) + "px";
+			img.style.top = (oy + y * 256) + "px";
+			seen[key] = true;
+		}
+	}
+	for (var k in tiles) {
+		if (!seen[k]) { map.removeChild(tiles[k]); delete tiles[k]; }
+	}
+	document.getElementById("pos").textContent = " z" + state.z;
+}
+
+var drag = null;
+map.onmousedown = function (e) { drag = {x: e.clientX, y: e.clientY}; };
+window.onmouseup = function () { drag = null; };
+window.onmousemove = function (e) {
+	if (!drag) return;
+	state.cx -= (e.clientX - drag.x) / 256;
+	state.cy -= (e.clientY - drag.y) / 256;
+	drag = {x: e.clientX, y: e.clientY};
+	render();
+};
+map.onwheel = function (e) {
+	e.preventDefault();
+	if (e.deltaY < 0 && state.z < 22) { state.z++; state.cx *= 2; state.cy *= 2; }
+	if (e.deltaY > 0 && state.z > 0) { state.z--; state.cx /= 2; state.cy /= 2; }
+	render();
+};
+var timer;
+document.getElementById("err").oninput = function (e) {
+	state.err = e.target.value;
+	document.getElementById("errv").textContent = state.err;
+	clearTimeout(timer);
+	timer = setTimeout(render, 150);
+};
+document.getElementById("wire").onchange = function (e) { state.wire = e.target.checked; render(); };
+window.onresize = render;
+render();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,49 @@
+package martini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	s := newTestServer(t)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected no viewer without Preview, got %d", rec.Code)
+	}
+
+	s.Preview = true
+	s.Formats[FormatPNG.Extension] = FormatPNG
+	s.Formats[FormatSVG.Extension] = FormatSVG
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "maxError") {
+		t.Fatalf("expected viewer page, got %d", rec.Code)
+	}
+
+	count := func(query string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", "/1/1/0.svg"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", query, rec.Code, rec.Body)
+		}
+		return strings.Count(rec.Body.String(), "<polygon")
+	}
+	if coarse, fine := count("?maxError=50"), count("?maxError=0"); coarse >= fine {
+		t.Errorf("expected maxError override to refine: %d vs %d triangles", coarse, fine)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/1/1/0.png?maxError=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad maxError, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/1/1/0.png", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expected png tile, got %d", rec.Code)
+	}
+}
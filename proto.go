@@ -0,0 +1,302 @@
+package martini
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// This file implements the protobuf wire format of martini.proto by hand,
+// so the package keeps no dependencies. Repeated scalars are written packed
+// and accepted both packed and unpacked.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errBadProto = errors.New("Malformed protobuf data")
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field<<3|wire))
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendPackedDoubles(b []byte, field int, values []float64) []byte {
+	if len(values) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(8*len(values)))
+	var buf [8]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+func consumeVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << uint(7*i)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, -1
+}
+
+// protoField is one decoded field: its number, wire type and either its
+// varint or fixed value or its length-delimited payload.
+type protoField struct {
+	num, wire int
+	value     uint64
+	data      []byte
+}
+
+// forEachField calls fn for every field in a message.
+func forEachField(b []byte, fn func(f protoField) error) error {
+	for len(b) > 0 {
+		tag, n := consumeVarint(b)
+		if n < 0 {
+			return errBadProto
+		}
+		b = b[n:]
+		f := protoField{num: int(tag >> 3), wire: int(tag & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.value, n = consumeVarint(b); n < 0 {
+				return errBadProto
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return errBadProto
+			}
+			f.value, n = binary.LittleEndian.Uint64(b), 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return errBadProto
+			}
+			f.value, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case wireBytes:
+			l, m := consumeVarint(b)
+			if m < 0 || uint64(len(b)-m) < l {
+				return errBadProto
+			}
+			f.data, n = b[m:m+int(l)], m+int(l)
+		default:
+			return errBadProto
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f protoField) doubles(dst []float64) ([]float64, error) {
+	switch f.wire {
+	case wireFixed64:
+		return append(dst, math.Float64frombits(f.value)), nil
+	case wireBytes:
+		if len(f.data)%8 != 0 {
+			return nil, errBadProto
+		}
+		for i := 0; i < len(f.data); i += 8 {
+			dst = append(dst, math.Float64frombits(binary.LittleEndian.Uint64(f.data[i:])))
+		}
+		return dst, nil
+	}
+	return nil, errBadProto
+}
+
+func (f protoField) uint32s(dst []uint32) ([]uint32, error) {
+	switch f.wire {
+	case wireVarint:
+		return append(dst, uint32(f.value)), nil
+	case wireBytes:
+		for b := f.data; len(b) > 0; {
+			v, n := consumeVarint(b)
+			if n < 0 {
+				return nil, errBadProto
+			}
+			dst = append(dst, uint32(v))
+			b = b[n:]
+		}
+		return dst, nil
+	}
+	return nil, errBadProto
+}
+
+func (m *Mesh) appendProto(b []byte) []byte {
+	b = appendPackedDoubles(b, 1, m.Vertices)
+	if len(m.Triangles) > 0 {
+		var packed []byte
+		for _, v := range m.Triangles {
+			packed = appendVarint(packed, uint64(v))
+		}
+		b = appendBytesField(b, 2, packed)
+	}
+	if len(m.Colors) > 0 {
+		b = appendBytesField(b, 3, m.Colors)
+	}
+	for _, a := range m.Attributes {
+		msg := appendBytesField(nil, 1, []byte(a.Name))
+		msg = appendPackedDoubles(msg, 2, a.Values)
+		b = appendBytesField(b, 4, msg)
+	}
+	return b
+}
+
+// MarshalProto encodes the mesh as a martini.Mesh protobuf message.
+func (m *Mesh) MarshalProto() []byte {
+	return m.appendProto(nil)
+}
+
+// UnmarshalProtoMesh decodes a martini.Mesh protobuf message.
+func UnmarshalProtoMesh(data []byte) (*Mesh, error) {
+	m := &Mesh{}
+	err := forEachField(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.Vertices, err = f.doubles(m.Vertices)
+		case 2:
+			m.Triangles, err = f.uint32s(m.Triangles)
+		case 3:
+			m.Colors = append(m.Colors, f.data...)
+		case 4:
+			var a Attribute
+			err = forEachField(f.data, func(f protoField) error {
+				var err error
+				switch f.num {
+				case 1:
+					a.Name = string(f.data)
+				case 2:
+					a.Values, err = f.doubles(a.Values)
+				}
+				return err
+			})
+			m.Attributes = append(m.Attributes, a)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	nv := m.NumVertices()
+	if len(m.Vertices)%3 != 0 {
+		return nil, errors.New("Expected vertices as x, y, z triples")
+	}
+	for _, v := range m.Triangles {
+		if int(v) >= nv {
+			return nil, errors.New("Triangle index out of range")
+		}
+	}
+	if len(m.Colors) != 0 && len(m.Colors) != 3*nv {
+		return nil, errors.New("Expected an r, g, b color per vertex")
+	}
+	for _, a := range m.Attributes {
+		if len(a.Values) != nv {
+			return nil, fmt.Errorf("Expected a value of attribute %q per vertex", a.Name)
+		}
+	}
+	return m, nil
+}
+
+// ProtoTile is a mesh with the tile metadata of the martini.Tile message.
+type ProtoTile struct {
+	ID       TileID
+	GridSize int
+	MaxError float64
+	Mesh     *Mesh
+}
+
+// MarshalProto encodes the tile as a martini.Tile protobuf message.
+func (t *ProtoTile) MarshalProto() []byte {
+	var id []byte
+	for i, v := range []int{t.ID.Z, t.ID.X, t.ID.Y} {
+		if v != 0 {
+			id = appendTag(id, i+1, wireVarint)
+			id = appendVarint(id, uint64(v))
+		}
+	}
+	b := appendBytesField(nil, 1, id)
+	if t.GridSize != 0 {
+		b = appendTag(b, 2, wireVarint)
+		b = appendVarint(b, uint64(t.GridSize))
+	}
+	if t.MaxError != 0 {
+		b = appendTag(b, 3, wireFixed64)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(t.MaxError))
+		b = append(b, buf[:]...)
+	}
+	if t.Mesh != nil {
+		b = appendBytesField(b, 4, t.Mesh.appendProto(nil))
+	}
+	return b
+}
+
+// UnmarshalProtoTile decodes a martini.Tile protobuf message.
+func UnmarshalProtoTile(data []byte) (*ProtoTile, error) {
+	t := &ProtoTile{}
+	err := forEachField(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			err = forEachField(f.data, func(f protoField) error {
+				switch f.num {
+				case 1:
+					t.ID.Z = int(f.value)
+				case 2:
+					t.ID.X = int(f.value)
+				case 3:
+					t.ID.Y = int(f.value)
+				}
+				return nil
+			})
+		case 2:
+			t.GridSize = int(f.value)
+		case 3:
+			t.MaxError = math.Float64frombits(f.value)
+		case 4:
+			t.Mesh, err = UnmarshalProtoMesh(f.data)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// FormatProto serves meshes as martini.Mesh protobuf messages.
+var FormatProto = &Format{
+	Name:        "protobuf",
+	Extension:   "pb",
+	ContentType: "application/x-protobuf",
+	Encode: func(w io.Writer, m *Mesh) error {
+		_, err := w.Write(m.MarshalProto())
+		return err
+	},
+}
@@ -0,0 +1,72 @@
+package martini
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProtoMesh(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	tile.AddAttribute("class", testTerrain(17, func(x, y int) float64 { return float64(x - y) }))
+	mesh := tile.ToMesh(5)
+	mesh.Colors = make([]uint8, 3*mesh.NumVertices())
+	for i := range mesh.Colors {
+		mesh.Colors[i] = uint8(i)
+	}
+
+	got, err := UnmarshalProtoMesh(mesh.MarshalProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, mesh) {
+		t.Fatal("round trip changed the mesh")
+	}
+
+	pt := &ProtoTile{ID: TileID{3, 0, 5}, GridSize: 17, MaxError: 5, Mesh: mesh}
+	gotTile, err := UnmarshalProtoTile(pt.MarshalProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotTile, pt) {
+		t.Fatalf("tile round trip: got %+v", gotTile)
+	}
+
+	if _, err := UnmarshalProtoMesh(mesh.MarshalProto()[:10]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+func TestProtoUnpacked(t *testing.T) {
+	// vertices = 1.5, 1.5, 1.5 and triangles = 0 as unpacked fields, as
+	// older encoders may write them.
+	var data []byte
+	for i := 0; i < 3; i++ {
+		data = appendTag(data, 1, wireFixed64)
+		data = append(data, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f)
+	}
+	data = appendTag(data, 2, wireVarint)
+	m, err := UnmarshalProtoMesh(appendVarint(data, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Vertices, []float64{1.5, 1.5, 1.5}) || !reflect.DeepEqual(m.Triangles, []uint32{0}) {
+		t.Errorf("got %v %v", m.Vertices, m.Triangles)
+	}
+
+	// There is a single vertex.
+	if _, err := UnmarshalProtoMesh(appendVarint(data, 1)); err == nil {
+		t.Error("expected error for a triangle index out of range")
+	}
+
+	mesh := &Mesh{Vertices: []float64{0, 0, 0, 1, 0, 0, 0, 1, 0}, Triangles: []uint32{0, 1, 2}}
+	for name, bad := range map[string]*Mesh{
+		"vertices":   {Vertices: mesh.Vertices[:8], Triangles: []uint32{0, 1, 1}},
+		"colors":     {Vertices: mesh.Vertices, Triangles: mesh.Triangles, Colors: []uint8{1, 2, 3}},
+		"attributes": {Vertices: mesh.Vertices, Triangles: mesh.Triangles, Attributes: []Attribute{{Name: "a", Values: []float64{1}}}},
+	} {
+		if _, err := UnmarshalProtoMesh(bad.MarshalProto()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TileSink stores encoded tiles.
+type TileSink interface {
+	WriteTile(ctx context.Context, id TileID, data []byte) error
+}
+
+// DirSink writes tiles to Dir/z/x/y.Extension.
+type DirSink struct {
+	Dir       string
+	Extension string
+	// Compression, if set, pre-compresses the tiles and records the
+	// content encoding in Dir/metadata.json for the server in front.
+	Compression Compression
+
+	once sync.Once
+	err  error
+}
+
+func (s *DirSink) WriteTile(ctx context.Context, id TileID, data []byte) error {
+	data, err := CompressTile(data, s.Compression)
+	if err != nil {
+		return err
+	}
+	if s.Compression != CompressionNone {
+		s.once.Do(func() { s.err = s.writeMetadata() })
+		if s.err != nil {
+			return s.err
+		}
+	}
+	dir := filepath.Join(s.Dir, strconv.Itoa(id.Z), strconv.Itoa(id.X))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(id.Y)+"."+s.Extension), data, 0644)
+}
+
+func (s *DirSink) writeMetadata() error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(map[string]string{"contentEncoding": s.Compression.ContentEncoding()})
+	return ioutil.WriteFile(filepath.Join(s.Dir, "metadata.json"), data, 0644)
+}
+
+// Bounds is a longitude/latitude rectangle in degrees.
+type Bounds struct {
+	West, South, East, North float64
+}
+
+var WorldBounds = Bounds{-180, -85.0511287798066, 180, 85.0511287798066}
+
+// TilesInBounds lists the XYZ web mercator tiles of zoom z that intersect b.
+func TilesInBounds(z int, b Bounds) []TileID {
+	n := 1 << uint(z)
+	x0, y0 := lngLatToTile(b.West, b.North, n)
+	x1, y1 := lngLatToTile(b.East, b.South, n)
+	var ids []TileID
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			ids = append(ids, TileID{Z: z, X: x, Y: y})
+		}
+	}
+	return ids
+}
+
+func lngLatToTile(lng, lat float64, n int) (int, int) {
+	lat = math.Max(math.Min(lat, WorldBounds.North), WorldBounds.South)
+	fx := (lng + 180) / 360 * float64(n)
+	r := lat * math.Pi / 180
+	fy := (1 - math.Log(math.Tan(r)+1/math.Cos(r))/math.Pi) / 2 * float64(n)
+	clamp := func(v float64) int {
+		i := int(math.Floor(v))
+		if i < 0 {
+			return 0
+		}
+		if i >= n {
+			return n - 1
+		}
+		return i
+	}
+	return clamp(fx), clamp(fy)
+}
+
+// PyramidOptions configures BuildPyramid.
+type PyramidOptions struct {
+	MinZoom, MaxZoom int
+	// Bounds restricts the build to a region. The zero value builds the
+	// whole world.
+	Bounds Bounds
+	// Scheme addresses the tiles; nil means SchemeXYZ. The source is
+	// queried and the sink written with the scheme's tile ids, so wrap
+	// XYZ sources with Scheme.XYZSource.
+	Scheme   *TilingScheme
+	GridSize int
+	MaxError float64
+	// MaxErrorForZoom overrides MaxError per zoom level when set.
+	MaxErrorForZoom func(z int) float64
+	// Mesher replaces RTIN; see BatchOptions.
+	Mesher      Mesher
+	Workers     int
+	TileOptions *TileOptions
+	MeshOptions *MeshOptions
+	// Format encodes the meshes; nil means FormatJSON. Formats that support
+	// it embed each tile's TileMeta.
+	Format *Format
+	// Source names the terrain in the tile metadata.
+	Source string
+	// Tileset, if set, receives a 3D Tiles tileset.json for the built
+	// tiles once the build is complete. It needs Format3DTiles.
+	Tileset io.Writer
+	// Journal, if set, makes the build resumable: tiles it lists are
+	// skipped and every written tile is recorded in it. The journal is
+	// synced after each zoom level.
+	Journal *Journal
+	// DiskCache lets repeated builds skip meshing tiles whose terrain has
+	// not changed.
+	DiskCache *DiskCache
+	// Metrics, if set, records generated tiles and failures.
+	Metrics *Metrics
+	// Logger, if set, receives progress, tile failures and tiles slower
+	// than SlowTile.
+	Logger   Logger
+	SlowTile time.Duration
+	// Stats records the ElevationStats of every tile's terrain in its
+	// TileMeta, except for tiles that are entirely NODATA.
+	Stats bool
+}
+
+// BuildPyramid meshes every tile of the requested zoom levels and writes it
+// to sink. It stops at the first error.
+func BuildPyramid(ctx context.Context, source TerrainSource, sink TileSink, opts PyramidOptions) error {
+	if opts.MaxZoom < opts.MinZoom {
+		return errors.New("Expected MaxZoom to be at least MinZoom")
+	}
+	bounds := opts.Bounds
+	if bounds == (Bounds{}) {
+		bounds = WorldBounds
+	}
+	format := opts.Format
+	if format == nil {
+		format = FormatJSON
+	}
+	if opts.Tileset != nil && format != Format3DTiles {
+		return errors.New("Expected Format3DTiles to write a tileset")
+	}
+	log := loggerOrNop(opts.Logger)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var metas []TileMeta
+
+	for z := opts.MinZoom; z <= opts.MaxZoom; z++ {
+		maxError := opts.MaxError
+		if opts.MaxErrorForZoom != nil {
+			maxError = opts.MaxErrorForZoom(z)
+		}
+		batch := BatchOptions{
+			GridSize:    opts.GridSize,
+			MaxError:    maxError,
+			Mesher:      opts.Mesher,
+			Workers:     opts.Workers,
+			TileOptions: opts.TileOptions,
+			MeshOptions: opts.MeshOptions,
+			DiskCache:   opts.DiskCache,
+			Metrics:     opts.Metrics,
+			Logger:      opts.Logger,
+			SlowTile:    opts.SlowTile,
+			Stats:       opts.Stats,
+		}
+		start := time.Now()
+		var tiles []TileID
+		if opts.Scheme != nil {
+			tiles = opts.Scheme.TilesInBounds(z, bounds)
+		} else {
+			tiles = TilesInBounds(z, bounds)
+		}
+		total := len(tiles)
+		if opts.Journal != nil {
+			todo := tiles[:0]
+			for _, id := range tiles {
+				if !opts.Journal.Done(id) {
+					todo = append(todo, id)
+				}
+			}
+			if skipped := total - len(todo); skipped > 0 {
+				log.Info("resuming zoom level", "zoom", z, "skipped", skipped)
+			}
+			tiles = todo
+		}
+		for r := range ProcessTiles(ctx, source, tiles, batch) {
+			if r.Err != nil {
+				return r.Err
+			}
+			meta := NewTileMeta(r.ID, r.Mesh, opts.MeshOptions, maxError, opts.Source)
+			if opts.Scheme != nil {
+				meta.Bounds, _ = opts.Scheme.Bounds(r.ID)
+				meta.Scheme = opts.Scheme
+			}
+			// NaN statistics cannot be encoded as JSON.
+			if r.Stats != nil && r.Stats.NoDataPercent < 100 {
+				meta.Stats = r.Stats
+			}
+			var buf bytes.Buffer
+			if err := format.encode(&buf, r.Mesh, &meta); err != nil {
+				return err
+			}
+			if opts.Tileset != nil && opts.Journal == nil {
+				metas = append(metas, meta)
+			}
+			if err := sink.WriteTile(ctx, r.ID, buf.Bytes()); err != nil {
+				return err
+			}
+			if opts.Journal != nil {
+				if err := opts.Journal.Record(meta); err != nil {
+					return err
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.Journal != nil {
+			if err := opts.Journal.Sync(); err != nil {
+				return err
+			}
+		}
+		log.Info("zoom level done", "zoom", z, "tiles", total, "duration", time.Since(start))
+	}
+	if opts.Tileset != nil && opts.Journal != nil {
+		// Include the tiles written before the build resumed.
+		for _, m := range opts.Journal.Metas() {
+			if m.Zoom >= opts.MinZoom && m.Zoom <= opts.MaxZoom {
+				metas = append(metas, m)
+			}
+		}
+	}
+	if opts.Tileset != nil {
+		return WriteTileset(opts.Tileset, metas, format.Extension)
+	}
+	return nil
+}
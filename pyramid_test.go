@@ -0,0 +1,50 @@
+package martini
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTilesInBounds(t *testing.T) {
+	if n := len(TilesInBounds(2, WorldBounds)); n != 16 {
+		t.Errorf("expected 16 tiles at z2, got %d", n)
+	}
+	ids := TilesInBounds(10, Bounds{138.5, 35.2, 138.9, 35.5})
+	for _, id := range ids {
+		if id.X < 905 || id.X > 907 || id.Y < 403 || id.Y > 404 {
+			t.Errorf("unexpected tile %v", id)
+		}
+	}
+	if len(ids) != 6 {
+		t.Errorf("expected 6 tiles, got %v", ids)
+	}
+}
+
+func TestBuildPyramid(t *testing.T) {
+	dir := testDir(t)
+	cache, _ := NewDiskCache(filepath.Join(dir, "cache"))
+	sink := &DirSink{Dir: filepath.Join(dir, "tiles"), Extension: "json"}
+	opts := PyramidOptions{MinZoom: 0, MaxZoom: 1, GridSize: 17, MaxError: 5, DiskCache: cache}
+
+	if err := BuildPyramid(context.Background(), testSource(), sink, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"0/0/0.json", "1/0/0.json", "1/1/1.json"} {
+		if _, err := os.Stat(filepath.Join(sink.Dir, p)); err != nil {
+			t.Error(err)
+		}
+	}
+	// The test source returns the same terrain for 0/0/0 and 1/0/0.
+	entries, _ := filepath.Glob(filepath.Join(cache.Dir, "*", "*.mesh"))
+	if len(entries) != 4 {
+		t.Errorf("expected 4 cache entries, got %d", len(entries))
+	}
+
+	opts.MaxZoom = 4
+	opts.MinZoom = 4
+	if err := BuildPyramid(context.Background(), testSource(), sink, opts); err == nil {
+		t.Error("expected source error")
+	}
+}
@@ -0,0 +1,56 @@
+package martini
+
+import "math"
+
+// Quantization maps vertex positions to unsigned integers of Bits bits,
+// spanning the bounding box of a mesh on each axis. A coordinate is
+// recovered as Offset + q*Scale; heights are then within Scale[2]/2 of the
+// original.
+type Quantization struct {
+	Bits   int        `json:"bits"`
+	Offset [3]float64 `json:"offset"`
+	Scale  [3]float64 `json:"scale"`
+}
+
+// NewQuantization fits the vertices of m into bits-bit integers, at most
+// 16 bits.
+func NewQuantization(m *Mesh, bits int) Quantization {
+	bits = clampInt(bits, 1, 16)
+	q := Quantization{Bits: bits}
+	steps := float64(uint32(1)<<uint(bits) - 1)
+	for c := 0; c < 3; c++ {
+		min, max := math.Inf(1), math.Inf(-1)
+		for i := c; i < len(m.Vertices); i += 3 {
+			min, max = math.Min(min, m.Vertices[i]), math.Max(max, m.Vertices[i])
+		}
+		if min > max {
+			min, max = 0, 0
+		}
+		q.Offset[c] = min
+		q.Scale[c] = 1
+		if max > min {
+			q.Scale[c] = (max - min) / steps
+		}
+	}
+	return q
+}
+
+// Quantize returns the integer nearest to v on axis c, clamped to Bits.
+func (q Quantization) Quantize(v float64, c int) uint16 {
+	n := math.Round((v - q.Offset[c]) / q.Scale[c])
+	return uint16(math.Max(0, math.Min(n, float64(uint32(1)<<uint(q.Bits)-1))))
+}
+
+// Dequantize inverts Quantize on axis c.
+func (q Quantization) Dequantize(n uint16, c int) float64 {
+	return q.Offset[c] + float64(n)*q.Scale[c]
+}
+
+// positions returns the quantized x, y, z of every vertex of m.
+func (q Quantization) positions(m *Mesh) []uint16 {
+	out := make([]uint16, len(m.Vertices))
+	for i, v := range m.Vertices {
+		out[i] = q.Quantize(v, i%3)
+	}
+	return out
+}
@@ -0,0 +1,28 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantization(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(1)
+
+	q := NewQuantization(mesh, 16)
+	for i, v := range mesh.Vertices {
+		c := i % 3
+		if got := q.Dequantize(q.Quantize(v, c), c); math.Abs(got-v) > q.Scale[c]/2+1e-9 {
+			t.Fatalf("vertex %d axis %d: got %v, want %v", i/3, c, got, v)
+		}
+	}
+	if q.Quantize(q.Offset[2]-1, 2) != 0 || q.Quantize(1e9, 2) != 65535 {
+		t.Error("out of range values not clamped")
+	}
+
+	flat := NewQuantization(&Mesh{Vertices: []float64{0, 0, 5, 1, 0, 5}}, 8)
+	if flat.Scale[2] != 1 || flat.Dequantize(flat.Quantize(5, 2), 2) != 5 {
+		t.Errorf("flat axis: %+v", flat)
+	}
+}
@@ -0,0 +1,414 @@
+package martini
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+
+	quantizedMax = 32767
+	// quantizedMetadataExtension is the extension id of the quantized-mesh
+	// metadata extension.
+	quantizedMetadataExtension = 4
+)
+
+// ecef converts longitude, latitude in degrees and height in meters to
+// WGS84 earth-centred, earth-fixed coordinates.
+func ecef(lng, lat, h float64) [3]float64 {
+	e2 := wgs84F * (2 - wgs84F)
+	phi, lambda := lat*math.Pi/180, lng*math.Pi/180
+	n := wgs84A / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return [3]float64{
+		(n + h) * math.Cos(phi) * math.Cos(lambda),
+		(n + h) * math.Cos(phi) * math.Sin(lambda),
+		(n*(1-e2) + h) * math.Sin(phi),
+	}
+}
+
+// QuantizedMeshHeader is the fixed 88-byte header of a quantized-mesh tile.
+type QuantizedMeshHeader struct {
+	Center               [3]float64
+	MinHeight, MaxHeight float32
+	// BoundingSphere is the centre and radius.
+	BoundingSphere   [4]float64
+	HorizonOcclusion [3]float64
+}
+
+// QuantizedMesh is a decoded quantized-mesh-1.0 tile. U and V run from 0 at
+// the west and south edges to 32767, heights from MinHeight to MaxHeight.
+type QuantizedMesh struct {
+	Header                   QuantizedMeshHeader
+	U, V, Heights            []uint16
+	Triangles                []uint32
+	West, South, East, North []uint32
+	// Metadata is the JSON of the metadata extension, if present.
+	Metadata json.RawMessage
+}
+
+// QuantizedMeshOptions configures EncodeQuantizedMesh.
+type QuantizedMeshOptions struct {
+	// Metadata, if set, is written as JSON in the metadata extension, e.g.
+	// the data source and acquisition date of the tile.
+	Metadata interface{}
+	// Scheme addresses the tile; nil means SchemeXYZ.
+	Scheme *TilingScheme
+}
+
+// EncodeQuantizedMesh writes a mesh in grid units, as returned by ToMesh,
+// as a Cesium quantized-mesh-1.0 tile placed on tile id, with heights in
+// meters. The tile is an XYZ web mercator tile unless opts gives a Scheme.
+func EncodeQuantizedMesh(w io.Writer, m *Mesh, id TileID, gridSize int, opts *QuantizedMeshOptions) error {
+	nv := m.NumVertices()
+	if nv == 0 {
+		return errors.New("Expected a mesh with vertices")
+	}
+	var scheme *TilingScheme
+	if opts != nil {
+		scheme = opts.Scheme
+	}
+	if err := scheme.checkLngLat(); err != nil {
+		return err
+	}
+	for _, v := range m.Triangles {
+		if int(v) >= nv {
+			return errors.New("Expected triangle indices within the vertices")
+		}
+	}
+	m = renumberByFirstUse(m)
+	max := float64(gridSize - 1)
+	minH, maxH := math.Inf(1), math.Inf(-1)
+	for i := 0; i < nv; i++ {
+		_, _, z := m.Vertex(i)
+		minH = math.Min(minH, z)
+		maxH = math.Max(maxH, z)
+	}
+
+	var h QuantizedMeshHeader
+	h.MinHeight, h.MaxHeight = float32(minH), float32(maxH)
+	lng, lat := scheme.gridToLngLat(id, max/2, max/2, gridSize)
+	h.Center = ecef(lng, lat, (minH+maxH)/2)
+	points := make([][3]float64, nv)
+	radius := 0.0
+	for i := range points {
+		x, y, z := m.Vertex(i)
+		if x < 0 || y < 0 || x > max || y > max {
+			return errors.New("Expected a mesh in grid units")
+		}
+		lng, lat := scheme.gridToLngLat(id, x, y, gridSize)
+		points[i] = ecef(lng, lat, z)
+		radius = math.Max(radius, distance3(points[i], h.Center))
+	}
+	h.BoundingSphere = [4]float64{h.Center[0], h.Center[1], h.Center[2], radius}
+	h.HorizonOcclusion = horizonOcclusionPoint(h.Center, points)
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	binary.Write(bw, le, &h)
+
+	binary.Write(bw, le, uint32(nv))
+	quantize := func(c int, scale, offset, span float64) {
+		prev := 0
+		for i := 0; i < nv; i++ {
+			v := 0
+			if span > 0 {
+				v = int(math.Round((m.Vertices[3*i+c]*scale + offset) / span * quantizedMax))
+			}
+			d := v - prev
+			binary.Write(bw, le, uint16((d<<1)^(d>>31)))
+			prev = v
+		}
+	}
+	quantize(0, 1, 0, max)
+	quantize(1, -1, max, max)
+	quantize(2, 1, -minH, maxH-minH)
+
+	wide := nv > 65536
+	index := func(v uint32) {
+		if wide {
+			binary.Write(bw, le, v)
+		} else {
+			binary.Write(bw, le, uint16(v))
+		}
+	}
+	// The header and vertex data end on an even offset; 32-bit indices
+	// also need a multiple of four.
+	if wide && (88+4+6*nv)%4 != 0 {
+		bw.Write([]byte{0, 0})
+	}
+	binary.Write(bw, le, uint32(m.NumTriangles()))
+	highest := uint32(0)
+	for _, v := range m.Triangles {
+		index(highest - v)
+		if v == highest {
+			highest++
+		}
+	}
+
+	for _, e := range []Edge{EdgeWest, EdgeSouth, EdgeEast, EdgeNorth} {
+		var edge []uint32
+		for i := 0; i < nv; i++ {
+			x, y, _ := m.Vertex(i)
+			if (e == EdgeWest && x == 0) || (e == EdgeEast && x == max) ||
+				(e == EdgeNorth && y == 0) || (e == EdgeSouth && y == max) {
+				edge = append(edge, uint32(i))
+			}
+		}
+		binary.Write(bw, le, uint32(len(edge)))
+		for _, v := range edge {
+			index(v)
+		}
+	}
+
+	if opts != nil && opts.Metadata != nil {
+		js, err := json.Marshal(opts.Metadata)
+		if err != nil {
+			return err
+		}
+		bw.WriteByte(quantizedMetadataExtension)
+		binary.Write(bw, le, uint32(4+len(js)))
+		binary.Write(bw, le, uint32(len(js)))
+		bw.Write(js)
+	}
+	return bw.Flush()
+}
+
+// renumberByFirstUse returns m with its vertices numbered in the order the
+// triangles first reference them, as the high-water mark encoding of
+// quantized-mesh indices requires. Unreferenced vertices go last. m is
+// returned as is when it is already in that order.
+func renumberByFirstUse(m *Mesh) *Mesh {
+	nv := m.NumVertices()
+	remap := make([]int, nv)
+	for i := range remap {
+		remap[i] = -1
+	}
+	next := 0
+	inOrder := true
+	for _, v := range m.Triangles {
+		if remap[v] < 0 {
+			inOrder = inOrder && int(v) == next
+			remap[v] = next
+			next++
+		}
+	}
+	for i := range remap {
+		if remap[i] < 0 {
+			inOrder = inOrder && i == next
+			remap[i] = next
+			next++
+		}
+	}
+	if inOrder {
+		return m
+	}
+
+	out := &Mesh{
+		Vertices:  make([]float64, len(m.Vertices)),
+		Triangles: make([]uint32, len(m.Triangles)),
+	}
+	for i, j := range remap {
+		copy(out.Vertices[3*j:3*j+3], m.Vertices[3*i:3*i+3])
+	}
+	for i, v := range m.Triangles {
+		out.Triangles[i] = uint32(remap[v])
+	}
+	for _, a := range m.Attributes {
+		values := make([]float64, len(a.Values))
+		for i, j := range remap {
+			if i < len(a.Values) && j < len(values) {
+				values[j] = a.Values[i]
+			}
+		}
+		out.Attributes = append(out.Attributes, Attribute{Name: a.Name, Values: values})
+	}
+	if len(m.Colors) == 3*nv {
+		out.Colors = make([]uint8, len(m.Colors))
+		for i, j := range remap {
+			copy(out.Colors[3*j:3*j+3], m.Colors[3*i:3*i+3])
+		}
+	}
+	return out
+}
+
+func distance3(a, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+// horizonOcclusionPoint computes the point, in ellipsoid-scaled ECEF
+// coordinates along the direction of center, from which all points are
+// visible exactly when it is, following Cesium's
+// EllipsoidalOccluder.computeHorizonCullingPoint.
+func horizonOcclusionPoint(center [3]float64, points [][3]float64) [3]float64 {
+	scale := [3]float64{1 / wgs84A, 1 / wgs84A, 1 / wgs84B}
+	dir := normalize3([3]float64{center[0] * scale[0], center[1] * scale[1], center[2] * scale[2]})
+	magnitude := 0.0
+	for _, p := range points {
+		s := [3]float64{p[0] * scale[0], p[1] * scale[1], p[2] * scale[2]}
+		m2 := s[0]*s[0] + s[1]*s[1] + s[2]*s[2]
+		m := math.Sqrt(m2)
+		d := [3]float64{s[0] / m, s[1] / m, s[2] / m}
+		m2, m = math.Max(1, m2), math.Max(1, m)
+		cosAlpha := d[0]*dir[0] + d[1]*dir[1] + d[2]*dir[2]
+		cross := [3]float64{d[1]*dir[2] - d[2]*dir[1], d[2]*dir[0] - d[0]*dir[2], d[0]*dir[1] - d[1]*dir[0]}
+		sinAlpha := math.Sqrt(cross[0]*cross[0] + cross[1]*cross[1] + cross[2]*cross[2])
+		cosBeta := 1 / m
+		sinBeta := math.Sqrt(m2-1) * cosBeta
+		magnitude = math.Max(magnitude, 1/(cosAlpha*cosBeta-sinAlpha*sinBeta))
+	}
+	return [3]float64{dir[0] * magnitude, dir[1] * magnitude, dir[2] * magnitude}
+}
+
+// DecodeQuantizedMesh reads a quantized-mesh-1.0 tile, keeping the metadata
+// extension and skipping any others.
+func DecodeQuantizedMesh(r io.Reader) (*QuantizedMesh, error) {
+	br := bufio.NewReader(r)
+	le := binary.LittleEndian
+	q := &QuantizedMesh{}
+	if err := binary.Read(br, le, &q.Header); err != nil {
+		return nil, err
+	}
+	var nv uint32
+	if err := binary.Read(br, le, &nv); err != nil {
+		return nil, err
+	}
+	if nv > 1<<26 {
+		return nil, errors.New("Expected a plausible vertex count")
+	}
+	for _, dst := range []*[]uint16{&q.U, &q.V, &q.Heights} {
+		raw := make([]uint16, nv)
+		if err := binary.Read(br, le, raw); err != nil {
+			return nil, err
+		}
+		v := 0
+		for i, z := range raw {
+			v += int(z>>1) ^ -int(z&1)
+			raw[i] = uint16(v)
+		}
+		*dst = raw
+	}
+
+	wide := nv > 65536
+	if wide && (88+4+6*nv)%4 != 0 {
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+	indices := func() ([]uint32, error) {
+		var n uint32
+		if err := binary.Read(br, le, &n); err != nil {
+			return nil, err
+		}
+		if n > 1<<28 {
+			return nil, errors.New("Expected a plausible index count")
+		}
+		out := make([]uint32, n)
+		if wide {
+			return out, binary.Read(br, le, out)
+		}
+		narrow := make([]uint16, n)
+		if err := binary.Read(br, le, narrow); err != nil {
+			return nil, err
+		}
+		for i, v := range narrow {
+			out[i] = uint32(v)
+		}
+		return out, nil
+	}
+
+	var nt uint32
+	if err := binary.Read(br, le, &nt); err != nil {
+		return nil, err
+	}
+	q.Triangles = make([]uint32, 3*int(nt))
+	if wide {
+		if err := binary.Read(br, le, q.Triangles); err != nil {
+			return nil, err
+		}
+	} else {
+		narrow := make([]uint16, len(q.Triangles))
+		if err := binary.Read(br, le, narrow); err != nil {
+			return nil, err
+		}
+		for i, v := range narrow {
+			q.Triangles[i] = uint32(v)
+		}
+	}
+	highest := uint32(0)
+	for i, code := range q.Triangles {
+		q.Triangles[i] = highest - code
+		if code == 0 {
+			highest++
+		}
+	}
+
+	for _, dst := range []*[]uint32{&q.West, &q.South, &q.East, &q.North} {
+		edge, err := indices()
+		if err != nil {
+			return nil, err
+		}
+		*dst = edge
+	}
+
+	for {
+		id, err := br.ReadByte()
+		if err == io.EOF {
+			return q, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var length uint32
+		if err := binary.Read(br, le, &length); err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, err
+		}
+		if id == quantizedMetadataExtension && length >= 4 {
+			n := le.Uint32(data)
+			if int(n) > len(data)-4 {
+				return nil, errors.New("Expected metadata within its extension")
+			}
+			q.Metadata = json.RawMessage(data[4 : 4+n])
+		}
+	}
+}
+
+// meshGridSize returns the grid size of a mesh in grid units, taken from
+// its extent, which covers the whole tile for meshes from ToMesh.
+func meshGridSize(m *Mesh) int {
+	size := 0.0
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, _ := m.Vertex(i)
+		size = math.Max(size, math.Max(x, y))
+	}
+	return int(size) + 1
+}
+
+// encodeQuantizedMeshMeta writes a quantized-mesh tile with meta as its
+// metadata.
+func encodeQuantizedMeshMeta(w io.Writer, m *Mesh, meta *TileMeta) error {
+	return EncodeQuantizedMesh(w, m, meta.ID(), meshGridSize(m), &QuantizedMeshOptions{Metadata: meta, Scheme: meta.Scheme})
+}
+
+// FormatQuantizedMesh encodes tiles as quantized-mesh with their TileMeta
+// in the metadata extension. It needs the tile's metadata, which
+// BuildPyramid provides, and meshes in grid units.
+var FormatQuantizedMesh = &Format{
+	Name:        "quantized-mesh",
+	Extension:   "terrain",
+	ContentType: "application/vnd.quantized-mesh",
+	Encode: func(w io.Writer, m *Mesh) error {
+		return errors.New("Expected tile metadata to place a quantized-mesh tile")
+	},
+	EncodeMeta: encodeQuantizedMeshMeta,
+}
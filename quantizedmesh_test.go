@@ -0,0 +1,150 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestQuantizedMesh(t *testing.T) {
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(testTerrain(33, func(x, y int) float64 { return 1000 + hills(x, y) }))
+	mesh := tile.ToMesh(5)
+	id := TileID{10, 906, 404}
+
+	metadata := map[string]string{"source": "lidar", "acquired": "2019-04-01"}
+	var buf bytes.Buffer
+	if err := EncodeQuantizedMesh(&buf, mesh, id, 33, &QuantizedMeshOptions{Metadata: metadata}); err != nil {
+		t.Fatal(err)
+	}
+	q, err := DecodeQuantizedMesh(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(q.Triangles, mesh.Triangles) {
+		t.Error("triangles differ after round trip")
+	}
+	span := float64(q.Header.MaxHeight - q.Header.MinHeight)
+	for i := 0; i < mesh.NumVertices(); i++ {
+		x, y, z := mesh.Vertex(i)
+		if math.Abs(float64(q.U[i])/32767*32-x) > 1e-3 || math.Abs(32-float64(q.V[i])/32767*32-y) > 1e-3 {
+			t.Fatalf("vertex %d at %d, %d, want %v, %v", i, q.U[i], q.V[i], x, y)
+		}
+		if h := float64(q.Header.MinHeight) + float64(q.Heights[i])/32767*span; math.Abs(h-z) > span/32767 {
+			t.Fatalf("vertex %d height %v, want %v", i, h, z)
+		}
+	}
+	for _, e := range [][]uint32{q.West, q.South, q.East, q.North} {
+		if len(e) < 2 {
+			t.Errorf("expected edge vertices, got %v", e)
+		}
+	}
+	var got map[string]string
+	if err := json.Unmarshal(q.Metadata, &got); err != nil || !reflect.DeepEqual(got, metadata) {
+		t.Errorf("metadata = %s (%v)", q.Metadata, err)
+	}
+	center := q.Header.Center
+	if r := math.Sqrt(center[0]*center[0] + center[1]*center[1] + center[2]*center[2]); r < 6.3e6 || r > 6.4e6 || q.Header.BoundingSphere[3] <= 0 {
+		t.Errorf("implausible header %+v", q.Header)
+	}
+	if h := q.Header.HorizonOcclusion; math.Sqrt(h[0]*h[0]+h[1]*h[1]+h[2]*h[2]) < 1 {
+		t.Errorf("horizon occlusion point %v inside the ellipsoid", h)
+	}
+
+	// Meshes over 65536 vertices use 32-bit indices.
+	big, _ := NewMartini(257)
+	bigTile, _ := big.CreateTile(testTerrain(257, hills))
+	bigMesh := bigTile.ToMesh(0)
+	buf.Reset()
+	if err := EncodeQuantizedMesh(&buf, bigMesh, id, 257, nil); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := DecodeQuantizedMesh(&buf); err != nil || !reflect.DeepEqual(q.Triangles, bigMesh.Triangles) || q.Metadata != nil {
+		t.Errorf("32-bit round trip failed: %v", err)
+	}
+}
+
+func TestQuantizedMeshDelatinRoundTrip(t *testing.T) {
+	mesh, err := DelatinMesher{}.Mesh(testTerrain(65, hills), 65, 65, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeQuantizedMesh(&buf, mesh, TileID{10, 906, 404}, 65, nil); err != nil {
+		t.Fatal(err)
+	}
+	q, err := DecodeQuantizedMesh(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Triangles) != len(mesh.Triangles) {
+		t.Fatalf("got %d indices, want %d", len(q.Triangles), len(mesh.Triangles))
+	}
+	// Vertices are renumbered, so compare the corners of every triangle.
+	for i, v := range mesh.Triangles {
+		x, y, _ := mesh.Vertex(int(v))
+		u, w := float64(q.U[q.Triangles[i]])/32767*64, 64-float64(q.V[q.Triangles[i]])/32767*64
+		if math.Abs(u-x) > 1e-2 || math.Abs(w-y) > 1e-2 {
+			t.Fatalf("corner %d at %v, %v, want %v, %v", i, u, w, x, y)
+		}
+	}
+}
+
+func TestFormatQuantizedMesh(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	meta := NewTileMeta(TileID{2, 1, 1}, mesh, nil, 5, "srtm")
+
+	var buf bytes.Buffer
+	if err := FormatQuantizedMesh.Encode(&buf, mesh); err == nil {
+		t.Error("expected error without tile metadata")
+	}
+	if err := FormatQuantizedMesh.encode(&buf, mesh, &meta); err != nil {
+		t.Fatal(err)
+	}
+	q, err := DecodeQuantizedMesh(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TileMeta
+	if err := json.Unmarshal(q.Metadata, &got); err != nil || got != meta {
+		t.Errorf("metadata %+v, want %+v", got, meta)
+	}
+}
+
+func TestQuantizedMeshScheme(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	encode := func(id TileID, scheme *TilingScheme) []byte {
+		var buf bytes.Buffer
+		if err := EncodeQuantizedMesh(&buf, mesh, id, 17, &QuantizedMeshOptions{Scheme: scheme}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	// A TMS tile is placed like the XYZ tile it is.
+	if !bytes.Equal(encode(TileID{2, 1, 2}, SchemeTMS), encode(TileID{2, 1, 1}, nil)) {
+		t.Error("TMS tile placed differently from its XYZ tile")
+	}
+
+	// The western tile of WorldCRS84Quad is centred on 90°W at the equator.
+	q, err := DecodeQuantizedMesh(bytes.NewReader(encode(TileID{0, 0, 0}, SchemeWorldCRS84Quad)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ecef(-90, 0, float64(q.Header.MinHeight+q.Header.MaxHeight)/2)
+	if distance3(q.Header.Center, want) > 1 {
+		t.Errorf("center %v, want %v", q.Header.Center, want)
+	}
+
+	custom := &TilingScheme{Extent: [4]float64{0, 0, 1, 1}, MatrixWidth: 1, MatrixHeight: 1}
+	if err := EncodeQuantizedMesh(ioutil.Discard, mesh, TileID{}, 17, &QuantizedMeshOptions{Scheme: custom}); err == nil {
+		t.Error("expected error for a scheme without ToLngLat")
+	}
+}
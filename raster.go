@@ -0,0 +1,178 @@
+package martini
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+)
+
+// SampleType is the binary type of raster samples.
+type SampleType int
+
+const (
+	SampleInt16 SampleType = iota
+	SampleUint16
+	SampleInt32
+	SampleFloat32
+	SampleFloat64
+)
+
+func (t SampleType) size() int {
+	switch t {
+	case SampleInt16, SampleUint16:
+		return 2
+	case SampleInt32, SampleFloat32:
+		return 4
+	}
+	return 8
+}
+
+func (t SampleType) decode(order binary.ByteOrder, b []byte) float64 {
+	switch t {
+	case SampleInt16:
+		return float64(int16(order.Uint16(b)))
+	case SampleUint16:
+		return float64(order.Uint16(b))
+	case SampleInt32:
+		return float64(int32(order.Uint32(b)))
+	case SampleFloat32:
+		return float64(math.Float32frombits(order.Uint32(b)))
+	}
+	return math.Float64frombits(order.Uint64(b))
+}
+
+// RasterLayout describes a headerless row-major raster file such as ESRI
+// .bil/.flt or a raw GDAL ENVI export.
+type RasterLayout struct {
+	Width, Height int
+	Sample        SampleType
+	ByteOrder     binary.ByteOrder
+	// Offset is the number of bytes preceding the first sample.
+	Offset int64
+}
+
+// MappedRaster gives random access to a raster file without loading it. On
+// Unix systems the file is memory-mapped; elsewhere rows are read on demand.
+type MappedRaster struct {
+	Layout RasterLayout
+
+	f    *os.File
+	data []byte
+}
+
+func OpenMappedRaster(path string, layout RasterLayout) (*MappedRaster, error) {
+	if layout.Width <= 0 || layout.Height <= 0 {
+		return nil, errors.New("Expected positive raster dimensions")
+	}
+	if layout.ByteOrder == nil {
+		layout.ByteOrder = binary.LittleEndian
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size := layout.Offset + int64(layout.Width)*int64(layout.Height)*int64(layout.Sample.size())
+	if fi.Size() < size {
+		f.Close()
+		return nil, errors.New("Raster file is smaller than its layout")
+	}
+	r := &MappedRaster{Layout: layout, f: f}
+	if r.data, err = mmapFile(f, size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *MappedRaster) Close() error {
+	var err error
+	if r.data != nil {
+		err = munmapFile(r.data)
+		r.data = nil
+	}
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readRow decodes n samples of row y starting at column x into dst.
+func (r *MappedRaster) readRow(dst []float64, x, y int, scratch []byte) error {
+	l := r.Layout
+	ss := l.Sample.size()
+	off := l.Offset + (int64(y)*int64(l.Width)+int64(x))*int64(ss)
+	var b []byte
+	if r.data != nil {
+		b = r.data[off : off+int64(len(dst)*ss)]
+	} else {
+		b = scratch[:len(dst)*ss]
+		if _, err := r.f.ReadAt(b, off); err != nil {
+			return err
+		}
+	}
+	for i := range dst {
+		dst[i] = l.Sample.decode(l.ByteOrder, b[i*ss:])
+	}
+	return nil
+}
+
+// Window copies the w*h samples starting at pixel (x, y) into a row-major
+// grid. Samples beyond the raster edge repeat the nearest edge sample.
+func (r *MappedRaster) Window(x, y, w, h int) ([]float64, error) {
+	l := r.Layout
+	out := make([]float64, w*h)
+	x0 := clampInt(x, 0, l.Width-1)
+	x1 := clampInt(x+w, x0+1, l.Width)
+	inside := make([]float64, x1-x0)
+	scratch := make([]byte, len(inside)*l.Sample.size())
+	for j := 0; j < h; j++ {
+		row := out[j*w : (j+1)*w]
+		if x0 == x && x1 == x+w {
+			inside = row
+		}
+		if err := r.readRow(inside, x0, clampInt(y+j, 0, l.Height-1), scratch); err != nil {
+			return nil, err
+		}
+		if x0 == x && x1 == x+w {
+			continue
+		}
+		for i := range row {
+			row[i] = inside[clampInt(x+i, x0, x1-1)-x0]
+		}
+	}
+	return out, nil
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// RasterSource is a TerrainSource cutting a raster into tiles at its native
+// resolution: tile x, y covers the gridSize pixels starting at
+// x*(gridSize-1), y*(gridSize-1), so neighbours share their edge samples.
+// The zoom level is ignored.
+type RasterSource struct {
+	Raster *MappedRaster
+}
+
+func (s *RasterSource) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	step := gridSize - 1
+	l := s.Raster.Layout
+	if id.X < 0 || id.Y < 0 || id.X*step >= l.Width || id.Y*step >= l.Height {
+		return nil, errors.New("Tile outside of the raster")
+	}
+	return s.Raster.Window(id.X*step, id.Y*step, gridSize, gridSize)
+}
@@ -0,0 +1,63 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestRaster(t *testing.T, w, h int) string {
+	var buf bytes.Buffer
+	buf.Write([]byte("HDR!"))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			binary.Write(&buf, binary.BigEndian, int16(100*y+x))
+		}
+	}
+	path := filepath.Join(testDir(t), "dem.bil")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMappedRaster(t *testing.T) {
+	path := writeTestRaster(t, 40, 30)
+	r, err := OpenMappedRaster(path, RasterLayout{Width: 40, Height: 30, Sample: SampleInt16, ByteOrder: binary.BigEndian, Offset: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	win, err := r.Window(5, 7, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if win[0] != 705 || win[2*4+3] != 908 {
+		t.Errorf("unexpected window %v", win)
+	}
+
+	win, _ = r.Window(38, 28, 4, 4)
+	if win[0] != 2838 || win[3] != 2839 || win[15] != 2939 {
+		t.Errorf("expected edge samples to repeat, got %v", win)
+	}
+
+	source := &RasterSource{Raster: r}
+	terrain, err := source.Terrain(context.Background(), TileID{0, 1, 1}, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terrain[0] != 1616 || terrain[16*17+13] != 2929 {
+		t.Errorf("unexpected tile samples %v %v", terrain[0], terrain[16*17+13])
+	}
+	if _, err := source.Terrain(context.Background(), TileID{0, 3, 0}, 17); err == nil {
+		t.Error("expected error outside of the raster")
+	}
+
+	if _, err := OpenMappedRaster(path, RasterLayout{Width: 400, Height: 30, Sample: SampleInt16}); err == nil {
+		t.Error("expected error for oversized layout")
+	}
+}
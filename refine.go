@@ -0,0 +1,98 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+var errNotTileMesh = errors.New("Expected a mesh extracted from the tile in grid units")
+
+// meshBuilder grows a grid-space mesh, reusing vertices by position.
+type meshBuilder struct {
+	t         *Tile
+	index     map[int]uint32
+	vertices  []uint16
+	triangles []uint32
+}
+
+func (b *meshBuilder) vertex(x, y int) uint32 {
+	k := y*b.t.Martini.GridSize + x
+	if i, ok := b.index[k]; ok {
+		return i
+	}
+	i := uint32(len(b.vertices) / 2)
+	b.index[k] = i
+	b.vertices = append(b.vertices, uint16(x), uint16(y))
+	return i
+}
+
+// split walks the hierarchy below triangle (a, b, c), right-angled at c,
+// exactly as GetMesh does.
+func (b *meshBuilder) split(ax, ay, bx, by, cx, cy int, maxError float64) {
+	mx := (ax + bx) >> 1
+	my := (ay + by) >> 1
+	if abs(ax-cx)+abs(ay-cy) > 1 && b.t.Errors[my*b.t.Martini.GridSize+mx] > maxError {
+		b.split(cx, cy, ax, ay, mx, my, maxError)
+		b.split(bx, by, cx, cy, mx, my, maxError)
+		return
+	}
+	b.triangles = append(b.triangles, b.vertex(ax, ay), b.vertex(bx, by), b.vertex(cx, cy))
+}
+
+// gridVertices reads the vertices of a grid-space mesh into a builder so
+// that they keep their indices.
+func (t *Tile) gridVertices(m *Mesh) (*meshBuilder, error) {
+	max := float64(t.Martini.GridSize - 1)
+	b := &meshBuilder{t: t, index: make(map[int]uint32, m.NumVertices())}
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, _ := m.Vertex(i)
+		if x < 0 || y < 0 || x > max || y > max || x != math.Trunc(x) || y != math.Trunc(y) {
+			return nil, errNotTileMesh
+		}
+		if b.vertex(int(x), int(y)) != uint32(i) {
+			return nil, errNotTileMesh
+		}
+	}
+	return b, nil
+}
+
+// Refine tightens a mesh extracted from t to newMaxError. Only the
+// triangles that need splitting are subdivided: the vertices of m keep
+// their indices and new vertices are appended after them, so a GPU vertex
+// buffer can be extended instead of replaced. The result equals
+// t.ToMesh(newMaxError) up to vertex and triangle order. m must be in grid
+// units, as returned by ToMesh, and is not modified.
+func (m *Mesh) Refine(t *Tile, newMaxError float64) (*Mesh, error) {
+	b, err := t.gridVertices(m)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		var p [3][2]int
+		for k := range p {
+			v := int(m.Triangles[i+k])
+			if v >= m.NumVertices() {
+				return nil, errNotTileMesh
+			}
+			p[k] = [2]int{int(b.vertices[2*v]), int(b.vertices[2*v+1])}
+		}
+		// Rotate the right-angled corner to the end, keeping the winding.
+		for k := 0; k < 3 && !rightAngle(p[2], p[0], p[1]); k++ {
+			p[0], p[1], p[2] = p[1], p[2], p[0]
+		}
+		if !rightAngle(p[2], p[0], p[1]) {
+			return nil, errNotTileMesh
+		}
+		b.split(p[0][0], p[0][1], p[1][0], p[1][1], p[2][0], p[2][1], newMaxError)
+	}
+	return t.liftMesh(b.vertices, b.triangles), nil
+}
+
+// rightAngle reports whether a, c, b is a right isosceles triangle with its
+// right angle at c and a hypotenuse midpoint on the grid.
+func rightAngle(c, a, b [2]int) bool {
+	ux, uy := a[0]-c[0], a[1]-c[1]
+	vx, vy := b[0]-c[0], b[1]-c[1]
+	return ux*vx+uy*vy == 0 && ux*ux+uy*uy == vx*vx+vy*vy && ux*ux+uy*uy > 0 &&
+		(a[0]+b[0])%2 == 0 && (a[1]+b[1])%2 == 0
+}
@@ -0,0 +1,35 @@
+package martini
+
+import "testing"
+
+func TestRefine(t *testing.T) {
+	martini, _ := NewMartini(65)
+	tile, _ := martini.CreateTile(testTerrain(65, hills))
+
+	coarse := tile.ToMesh(50)
+	refined, err := coarse.Refine(tile, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range coarse.Vertices {
+		if refined.Vertices[i] != coarse.Vertices[i] {
+			t.Fatalf("vertex component %d moved", i)
+		}
+	}
+	if c := CompareMeshes(refined, tile.ToMesh(5)); !c.Equal() {
+		t.Errorf("refined mesh differs from direct extraction: %+v", c)
+	}
+	if refined.NumVertices() <= coarse.NumVertices() {
+		t.Error("expected refinement to add vertices")
+	}
+
+	same, _ := coarse.Refine(tile, 100)
+	if c := CompareMeshes(same, coarse); !c.Equal() {
+		t.Errorf("refining to a larger error changed the mesh: %+v", c)
+	}
+
+	bad := &Mesh{Vertices: []float64{0, 0, 0, 1.5, 0, 0, 0, 1, 0}, Triangles: []uint32{0, 1, 2}}
+	if _, err := bad.Refine(tile, 1); err == nil {
+		t.Error("expected error for a mesh off the grid")
+	}
+}
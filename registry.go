@@ -0,0 +1,29 @@
+package martini
+
+import "sync"
+
+var registry struct {
+	mu       sync.Mutex
+	martinis map[int]*Martini
+}
+
+// For returns the process-wide Martini for gridSize, creating it on first
+// use. It is compact and has no Indices, so it is safe to share between
+// goroutines: tiles may be created and meshed concurrently, and only the
+// Martini's fields must not be modified.
+func For(gridSize int) (*Martini, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if m, ok := registry.martinis[gridSize]; ok {
+		return m, nil
+	}
+	m, err := newMartini(gridSize, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if registry.martinis == nil {
+		registry.martinis = make(map[int]*Martini)
+	}
+	registry.martinis[gridSize] = m
+	return m, nil
+}
@@ -0,0 +1,50 @@
+package martini
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	m, err := For(65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := For(65); again != m {
+		t.Error("expected the same Martini for the same grid size")
+	}
+	if _, err := For(64); err == nil {
+		t.Error("expected error for invalid grid size")
+	}
+
+	ref, _ := NewMartini(65)
+	refTile, _ := ref.CreateTile(testTerrain(65, hills))
+	wantV, wantT := refTile.GetMesh(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tile, err := m.CreateTile(testTerrain(65, hills))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for k := 0; k < 10; k++ {
+				v, tri := tile.GetMesh(2)
+				if len(v) != len(wantV) || len(tri) != len(wantT) {
+					t.Error("mesh differs from an unshared Martini")
+					return
+				}
+				for j := range tri {
+					if tri[j] != wantT[j] {
+						t.Error("mesh differs from an unshared Martini")
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
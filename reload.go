@@ -0,0 +1,174 @@
+package martini
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ServerConfig holds the Server settings that can change while it runs.
+// Reload replaces all of them at once.
+type ServerConfig struct {
+	MaxError float64 `json:"maxError"`
+	// MaxErrorByZoom overrides MaxError for the listed zoom levels.
+	MaxErrorByZoom map[int]float64 `json:"maxErrorByZoom,omitempty"`
+	// Preset names a QualityPreset choosing maxError for the zoom levels
+	// MaxErrorByZoom does not list, at the equator. Its tile options are
+	// not applied.
+	Preset string `json:"preset,omitempty"`
+	// SourceURL and SourceEncoding ("terrain-rgb" or "terrarium") switch
+	// to an HTTPSource. Source does the same from Go. When neither is set
+	// the current source is kept.
+	SourceURL      string        `json:"sourceUrl,omitempty"`
+	SourceEncoding string        `json:"sourceEncoding,omitempty"`
+	Source         TerrainSource `json:"-"`
+	// CacheBytes bounds the in-memory mesh cache; zero disables it. When
+	// nil the current cache is kept.
+	CacheBytes *int64 `json:"cacheBytes,omitempty"`
+}
+
+// ParseEncoding returns the Encoding named "terrain-rgb" or "terrarium".
+func ParseEncoding(name string) (Encoding, error) {
+	switch name {
+	case "terrain-rgb", "":
+		return EncodingTerrainRGB, nil
+	case "terrarium":
+		return EncodingTerrarium, nil
+	}
+	return 0, fmt.Errorf("Unknown elevation encoding %q", name)
+}
+
+// Reload applies cfg to a running server. Requests in flight finish with
+// the previous settings. The mesh cache is replaced when the source or the
+// cache size changes, so no stale meshes are served, and LastModified, if
+// set, moves to the time of the reload so that clients revalidate.
+func (s *Server) Reload(cfg ServerConfig) error {
+	if cfg.MaxError < 0 || cfg.CacheBytes != nil && *cfg.CacheBytes < 0 {
+		return errors.New("Expected a non-negative maxError and cacheBytes")
+	}
+	for z, e := range cfg.MaxErrorByZoom {
+		if z < 0 || e < 0 {
+			return errors.New("Expected non-negative zoom levels and maxError")
+		}
+	}
+	source := cfg.Source
+	if source == nil && cfg.SourceURL != "" {
+		enc, err := ParseEncoding(cfg.SourceEncoding)
+		if err != nil {
+			return err
+		}
+		source = NewHTTPSource(cfg.SourceURL, enc)
+	}
+	var preset *QualityPreset
+	if cfg.Preset != "" {
+		var err error
+		if preset, err = Preset(cfg.Preset); err != nil {
+			return err
+		}
+	}
+	var forZoom func(z int) float64
+	if len(cfg.MaxErrorByZoom) > 0 || preset != nil {
+		byZoom := make(map[int]float64, len(cfg.MaxErrorByZoom))
+		for z, e := range cfg.MaxErrorByZoom {
+			byZoom[z] = e
+		}
+		fallback, gridSize := cfg.MaxError, s.GridSize
+		forZoom = func(z int) float64 {
+			if e, ok := byZoom[z]; ok {
+				return e
+			}
+			if preset != nil {
+				return preset.MaxError(z, 0, gridSize)
+			}
+			return fallback
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.MaxError = cfg.MaxError
+	s.MaxErrorForZoom = forZoom
+	s.config = cfg
+	s.config.Source = nil
+	cacheBytes := int64(0)
+	if s.Cache != nil {
+		cacheBytes = s.Cache.maxBytes
+	}
+	resize := cfg.CacheBytes != nil && *cfg.CacheBytes != cacheBytes
+	if resize {
+		cacheBytes = *cfg.CacheBytes
+	}
+	if source != nil {
+		s.Source = source
+	}
+	if source != nil || resize {
+		s.Cache = nil
+		if cacheBytes > 0 {
+			s.Cache = NewMeshCache(cacheBytes)
+		}
+	}
+	if !s.LastModified.IsZero() {
+		s.LastModified = time.Now()
+	}
+	return nil
+}
+
+// ReloadFile reads a JSON ServerConfig from path and applies it.
+func (s *Server) ReloadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg ServerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return s.Reload(cfg)
+}
+
+// serveAdmin answers /admin/config: GET returns the last applied
+// ServerConfig, POST or PUT applies a new one.
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.RLock()
+		cfg := s.config
+		cfg.MaxError = s.MaxError
+		cacheBytes := int64(0)
+		if s.Cache != nil {
+			cacheBytes = s.Cache.maxBytes
+		}
+		cfg.CacheBytes = &cacheBytes
+		s.mu.RUnlock()
+		data, _ := json.Marshal(cfg)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case http.MethodPost, http.MethodPut:
+		var cfg ServerConfig
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&cfg); err != nil {
+			http.Error(w, "Invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.Reload(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		loggerOrNop(s.Logger).Info("configuration reloaded", "maxError", cfg.MaxError)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
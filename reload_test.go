@@ -0,0 +1,159 @@
+package martini
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServerReload(t *testing.T) {
+	s := newTestServer(t)
+	s.Cache = NewMeshCache(1 << 20)
+	s.AdminToken = "secret"
+	triangles := func() int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", "/2/1/1.json", nil))
+		var m struct {
+			Triangles []int `json:"triangles"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+			t.Fatalf("%d: %s", rec.Code, rec.Body)
+		}
+		return len(m.Triangles)
+	}
+	coarse := triangles()
+
+	// Tiles keep being served while the configuration changes.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, httptest.NewRequest("GET", "/2/0/1.json", nil))
+			}
+		}()
+	}
+	cacheBytes := int64(1 << 20)
+	if err := s.Reload(ServerConfig{MaxError: 5, MaxErrorByZoom: map[int]float64{2: 0.5}, CacheBytes: &cacheBytes}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if fine := triangles(); fine <= coarse {
+		t.Errorf("expected more triangles at the new zoom 2 maxError, got %d and %d", fine, coarse)
+	}
+
+	var calls int
+	flat := TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		calls++
+		return make([]float64, gridSize*gridSize), nil
+	})
+	cache := s.Cache
+	if err := s.Reload(ServerConfig{MaxError: 5, Source: flat, CacheBytes: &cacheBytes}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cache == cache {
+		t.Error("expected a new cache for the new source")
+	}
+	if n := triangles(); n != 2*3 || calls != 1 {
+		t.Errorf("expected a flat tile from the new source, got %d indices", n)
+	}
+
+	admin := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/config", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := admin("GET", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rec.Code)
+	}
+	if rec := admin("POST", "secret", `{"maxError": 2, "cacheBytes": 0}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+	var cfg ServerConfig
+	json.Unmarshal(admin("GET", "secret", "").Body.Bytes(), &cfg)
+	if cfg.MaxError != 2 || s.Cache != nil {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if rec := admin("POST", "secret", `{"maxError": -1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+
+	path := filepath.Join(testDir(t), "config.json")
+	ioutil.WriteFile(path, []byte(`{"maxError": 3, "sourceUrl": "http://example.com/{z}/{x}/{y}.png", "sourceEncoding": "terrarium"}`), 0644)
+	if err := s.ReloadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if hs, ok := s.Source.(*HTTPSource); !ok || hs.Encoding != EncodingTerrarium || s.MaxError != 3 {
+		t.Errorf("unexpected source %T after ReloadFile", s.Source)
+	}
+	ioutil.WriteFile(path, []byte(`{"sourceUrl": "x", "sourceEncoding": "png"}`), 0644)
+	if err := s.ReloadFile(path); err == nil {
+		t.Error("expected error for an unknown encoding")
+	}
+}
+
+func TestServerReloadPreset(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.Reload(ServerConfig{Preset: "simulation", MaxErrorByZoom: map[int]float64{3: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	if e := s.MaxErrorForZoom(3); e != 7 {
+		t.Errorf("zoom 3: maxError %v, want the override", e)
+	}
+	if e := s.MaxErrorForZoom(2); e != PresetSimulation.MaxError(2, 0, 17) {
+		t.Errorf("zoom 2: maxError %v", e)
+	}
+	if err := s.Reload(ServerConfig{Preset: "nope"}); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
+
+func TestServerReloadKeepsCacheAndBumpsLastModified(t *testing.T) {
+	s := newTestServer(t)
+	s.Cache = NewMeshCache(1 << 20)
+	s.LastModified = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	get := func() int {
+		req := httptest.NewRequest("GET", "/2/1/1.json", nil)
+		req.Header.Set("If-Modified-Since", "Fri, 01 Mar 2024 12:00:00 GMT")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := get(); code != http.StatusNotModified {
+		t.Fatalf("expected 304 before the reload, got %d", code)
+	}
+	cache := s.Cache
+	if err := s.Reload(ServerConfig{MaxError: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cache != cache {
+		t.Error("expected a config without cacheBytes to keep the cache")
+	}
+	if code := get(); code != http.StatusOK {
+		t.Errorf("expected 200 after the reload, got %d", code)
+	}
+
+	// Metrics follow the cache Reload swaps in.
+	s.Metrics = NewMetrics()
+	s.Metrics.Cache = cache
+	cacheBytes := int64(2 << 20)
+	if err := s.Reload(ServerConfig{MaxError: 2, CacheBytes: &cacheBytes}); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "martini_cache_entries 0\n") {
+		t.Errorf("expected the new cache in the metrics:\n%s", body)
+	}
+}
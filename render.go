@@ -0,0 +1,172 @@
+package martini
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Shading selects how RenderImage lights triangles.
+type Shading int
+
+const (
+	// ShadingFlat lights every triangle by its face normal.
+	ShadingFlat Shading = iota
+	// ShadingGouraud lights vertices by their averaged normals and
+	// interpolates across triangles.
+	ShadingGouraud
+)
+
+// RenderOptions controls RenderImage. The zero value renders a 512 pixel
+// wide flat shaded grey image lit from the north-west.
+type RenderOptions struct {
+	Width   int
+	Shading Shading
+	// Light points from the surface towards the light, in the mesh frame
+	// with y growing southwards. Zero means (-1, -1, 1).
+	Light [3]float64
+	// Exaggeration scales heights before lighting. Zero means 1.
+	Exaggeration float64
+	// Color is ColorNone for grey or ColorElevation for a height ramp.
+	Color ColorBy
+}
+
+// RenderImage rasterizes a mesh in its grid frame, viewed from above, with
+// simple Lambert lighting. Pixels not covered by the mesh are transparent.
+func RenderImage(m *Mesh, opts *RenderOptions) *image.RGBA {
+	if opts == nil {
+		opts = &RenderOptions{}
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = 512
+	}
+	light := opts.Light
+	if light == [3]float64{} {
+		light = [3]float64{-1, -1, 1}
+	}
+	light = normalize3(light)
+	exaggeration := opts.Exaggeration
+	if exaggeration == 0 {
+		exaggeration = 1
+	}
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	minZ, maxZ := math.Inf(1), math.Inf(-1)
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, z := m.Vertex(i)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		minZ, maxZ = math.Min(minZ, z), math.Max(maxZ, z)
+	}
+	scale := 1.0
+	if maxX > minX {
+		scale = float64(width) / (maxX - minX)
+	}
+	height := 1
+	if maxY > minY {
+		height = int(math.Ceil((maxY - minY) * scale))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	intensity := func(n [3]float64) float64 {
+		d := n[0]*light[0] + n[1]*light[1] + n[2]*light[2]
+		return 0.2 + 0.8*math.Max(0, d)
+	}
+	faceNormal := func(t int) [3]float64 {
+		var p [3][3]float64
+		for k := range p {
+			x, y, z := m.Vertex(int(m.Triangles[3*t+k]))
+			p[k] = [3]float64{x, y, z * exaggeration}
+		}
+		u := [3]float64{p[1][0] - p[0][0], p[1][1] - p[0][1], p[1][2] - p[0][2]}
+		v := [3]float64{p[2][0] - p[0][0], p[2][1] - p[0][1], p[2][2] - p[0][2]}
+		n := [3]float64{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+		if n[2] < 0 {
+			n = [3]float64{-n[0], -n[1], -n[2]}
+		}
+		return n
+	}
+
+	var vertexLight []float64
+	if opts.Shading == ShadingGouraud {
+		sums := make([][3]float64, m.NumVertices())
+		for t := 0; t < m.NumTriangles(); t++ {
+			n := faceNormal(t)
+			for k := 0; k < 3; k++ {
+				s := &sums[m.Triangles[3*t+k]]
+				s[0], s[1], s[2] = s[0]+n[0], s[1]+n[1], s[2]+n[2]
+			}
+		}
+		vertexLight = make([]float64, len(sums))
+		for i, n := range sums {
+			vertexLight[i] = intensity(normalize3(n))
+		}
+	}
+
+	base := func(z float64) color.RGBA {
+		if opts.Color == ColorElevation && maxZ > minZ {
+			return ramp((z - minZ) / (maxZ - minZ))
+		}
+		return color.RGBA{200, 200, 200, 255}
+	}
+
+	for t := 0; t < m.NumTriangles(); t++ {
+		var sx, sy, sz, li [3]float64
+		for k := 0; k < 3; k++ {
+			i := int(m.Triangles[3*t+k])
+			x, y, z := m.Vertex(i)
+			sx[k], sy[k], sz[k] = (x-minX)*scale, (y-minY)*scale, z
+			if vertexLight != nil {
+				li[k] = vertexLight[i]
+			}
+		}
+		if vertexLight == nil {
+			l := intensity(normalize3(faceNormal(t)))
+			li = [3]float64{l, l, l}
+		}
+		det := (sy[1]-sy[2])*(sx[0]-sx[2]) + (sx[2]-sx[1])*(sy[0]-sy[2])
+		if det == 0 {
+			continue
+		}
+		x0 := clampInt(int(math.Floor(math.Min(sx[0], math.Min(sx[1], sx[2])))), 0, width-1)
+		x1 := clampInt(int(math.Ceil(math.Max(sx[0], math.Max(sx[1], sx[2])))), 0, width-1)
+		y0 := clampInt(int(math.Floor(math.Min(sy[0], math.Min(sy[1], sy[2])))), 0, height-1)
+		y1 := clampInt(int(math.Ceil(math.Max(sy[0], math.Max(sy[1], sy[2])))), 0, height-1)
+		for py := y0; py <= y1; py++ {
+			for px := x0; px <= x1; px++ {
+				cx, cy := float64(px)+0.5, float64(py)+0.5
+				a := ((sy[1]-sy[2])*(cx-sx[2]) + (sx[2]-sx[1])*(cy-sy[2])) / det
+				b := ((sy[2]-sy[0])*(cx-sx[2]) + (sx[0]-sx[2])*(cy-sy[2])) / det
+				c := 1 - a - b
+				if a < 0 || b < 0 || c < 0 {
+					continue
+				}
+				l := a*li[0] + b*li[1] + c*li[2]
+				col := base(a*sz[0] + b*sz[1] + c*sz[2])
+				img.SetRGBA(px, py, color.RGBA{
+					uint8(math.Min(255, float64(col.R)*l)),
+					uint8(math.Min(255, float64(col.G)*l)),
+					uint8(math.Min(255, float64(col.B)*l)),
+					255,
+				})
+			}
+		}
+	}
+	return img
+}
+
+// RenderPNG writes RenderImage as a PNG.
+func RenderPNG(w io.Writer, m *Mesh, opts *RenderOptions) error {
+	return png.Encode(w, RenderImage(m, opts))
+}
+
+func normalize3(v [3]float64) [3]float64 {
+	l := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	if l == 0 {
+		return v
+	}
+	return [3]float64{v[0] / l, v[1] / l, v[2] / l}
+}
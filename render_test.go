@@ -0,0 +1,44 @@
+package martini
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestRenderImage(t *testing.T) {
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(testTerrain(33, hills))
+	mesh := tile.ToMesh(5)
+
+	img := RenderImage(mesh, &RenderOptions{Width: 64})
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Fatalf("unexpected size %v", b)
+	}
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if img.RGBAAt(x, y).A != 255 {
+				t.Fatalf("pixel %d,%d not covered", x, y)
+			}
+		}
+	}
+
+	// The slope falls towards +x, so light from +x is brighter.
+	ramp := &Mesh{
+		Vertices:  []float64{0, 0, 10, 1, 0, 0, 1, 1, 0, 0, 1, 10},
+		Triangles: []uint32{0, 1, 2, 0, 2, 3},
+	}
+	lit := RenderImage(ramp, &RenderOptions{Width: 8, Light: [3]float64{1, 0, 1}}).RGBAAt(4, 4)
+	dark := RenderImage(ramp, &RenderOptions{Width: 8, Light: [3]float64{-1, 0, 1}}).RGBAAt(4, 4)
+	if lit.R <= dark.R {
+		t.Errorf("expected %v brighter than %v", lit, dark)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderPNG(&buf, mesh, &RenderOptions{Width: 32, Shading: ShadingGouraud, Color: ColorElevation}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
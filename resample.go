@@ -0,0 +1,86 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// Kernel is a resampling filter.
+type Kernel int
+
+const (
+	KernelBilinear Kernel = iota
+	// KernelBicubic is the Catmull-Rom cubic convolution.
+	KernelBicubic
+)
+
+func validGridSize(gridSize int) bool {
+	tileSize := gridSize - 1
+	return tileSize >= 1 && tileSize&(tileSize-1) == 0
+}
+
+// GridSizeFor returns the smallest 2^n+1 grid size holding n samples.
+func GridSizeFor(n int) int {
+	size := 1
+	for size+1 < n {
+		size *= 2
+	}
+	return size + 1
+}
+
+// Resample maps a width*height raster onto a gridSize*gridSize terrain grid
+// with corners aligned, so that the first and last samples of every row and
+// column are preserved. It works for up- and downsampling alike.
+func Resample(src []float64, width, height, gridSize int, k Kernel) ([]float64, error) {
+	if len(src) != width*height || width < 1 || height < 1 {
+		return nil, errors.New("Expected source data of length width*height")
+	}
+	if !validGridSize(gridSize) {
+		return nil, errors.New("Expected grid size to be 2^n+1")
+	}
+	sx := float64(width-1) / float64(gridSize-1)
+	sy := float64(height-1) / float64(gridSize-1)
+	out := make([]float64, gridSize*gridSize)
+	for j := 0; j < gridSize; j++ {
+		y := float64(j) * sy
+		for i := 0; i < gridSize; i++ {
+			x := float64(i) * sx
+			if k == KernelBicubic {
+				out[j*gridSize+i] = bicubic(src, width, height, x, y)
+			} else {
+				out[j*gridSize+i] = bilinear(src, width, height, x, y)
+			}
+		}
+	}
+	return out, nil
+}
+
+func cubicWeight(t float64) float64 {
+	t = math.Abs(t)
+	switch {
+	case t < 1:
+		return 1.5*t*t*t - 2.5*t*t + 1
+	case t < 2:
+		return -0.5*t*t*t + 2.5*t*t - 4*t + 2
+	}
+	return 0
+}
+
+// bicubic samples a w*h grid at (x, y) with Catmull-Rom weights, repeating
+// edge samples beyond the grid.
+func bicubic(grid []float64, w, h int, x, y float64) float64 {
+	ix, iy := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(ix), y-float64(iy)
+	var sum float64
+	for j := -1; j <= 2; j++ {
+		gy := clampInt(iy+j, 0, h-1)
+		wy := cubicWeight(float64(j) - fy)
+		var row float64
+		for i := -1; i <= 2; i++ {
+			gx := clampInt(ix+i, 0, w-1)
+			row += grid[gy*w+gx] * cubicWeight(float64(i)-fx)
+		}
+		sum += row * wy
+	}
+	return sum
+}
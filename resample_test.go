@@ -0,0 +1,50 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGridSizeFor(t *testing.T) {
+	for n, want := range map[int]int{1: 2, 2: 2, 3: 3, 256: 257, 257: 257, 1201: 2049} {
+		if got := GridSizeFor(n); got != want {
+			t.Errorf("GridSizeFor(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	w, h := 121, 91
+	src := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			src[y*w+x] = 3*float64(x) - 2*float64(y)
+		}
+	}
+
+	for _, k := range []Kernel{KernelBilinear, KernelBicubic} {
+		out, err := Resample(src, w, h, 65, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Both kernels reproduce linear surfaces exactly.
+		for j := 0; j < 65; j++ {
+			for i := 0; i < 65; i++ {
+				want := 3*float64(i)*120/64 - 2*float64(j)*90/64
+				if math.Abs(out[j*65+i]-want) > 1e-9 {
+					t.Fatalf("kernel %d at %d,%d: got %v want %v", k, i, j, out[j*65+i], want)
+				}
+			}
+		}
+		if out[0] != src[0] || out[65*65-1] != src[w*h-1] {
+			t.Errorf("kernel %d: corners not preserved", k)
+		}
+	}
+
+	if _, err := Resample(src, w, h, 64, KernelBilinear); err == nil {
+		t.Error("expected error for grid size 64")
+	}
+	if _, err := Resample(src[:10], w, h, 65, KernelBilinear); err == nil {
+		t.Error("expected error for short source")
+	}
+}
@@ -0,0 +1,130 @@
+package martini
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// ToECEF returns a copy of m, in grid units as returned by ToMesh, with
+// its vertices converted to WGS84 earth-centred, earth-fixed meters on
+// tile id of scheme; nil means SchemeXYZ. Heights are taken as meters
+// above the ellipsoid.
+func (m *Mesh) ToECEF(id TileID, gridSize int, scheme *TilingScheme) (*Mesh, error) {
+	if err := scheme.checkLngLat(); err != nil {
+		return nil, err
+	}
+	max := float64(gridSize - 1)
+	out := *m
+	out.Vertices = make([]float64, len(m.Vertices))
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, z := m.Vertex(i)
+		if x < 0 || y < 0 || x > max || y > max {
+			return nil, errors.New("Expected a mesh in grid units")
+		}
+		lng, lat := scheme.gridToLngLat(id, x, y, gridSize)
+		p := ecef(lng, lat, z)
+		copy(out.Vertices[3*i:], p[:])
+	}
+	return &out, nil
+}
+
+// Center returns the centre of the bounding box of m.
+func (m *Mesh) Center() [3]float64 {
+	var c [3]float64
+	if m.NumVertices() == 0 {
+		return c
+	}
+	for k := range c {
+		min, max := math.Inf(1), math.Inf(-1)
+		for i := k; i < len(m.Vertices); i += 3 {
+			min, max = math.Min(min, m.Vertices[i]), math.Max(max, m.Vertices[i])
+		}
+		c[k] = (min + max) / 2
+	}
+	return c
+}
+
+// RTC selects how EncodeGLBWithOptions keeps the precision of large
+// coordinates, such as ECEF, that float32 positions would round to
+// decimeters.
+type RTC int
+
+const (
+	// RTCNone stores positions as they are.
+	RTCNone RTC = iota
+	// RTCTranslation stores positions relative to the centre and moves
+	// the node there, which every glTF reader honors. The translation
+	// itself is float64 in the JSON.
+	RTCTranslation
+	// RTCCesium stores positions relative to the centre and records it in
+	// the CESIUM_RTC extension, as Cesium 3D Tiles expect.
+	RTCCesium
+)
+
+// GLBOptions configures EncodeGLBWithOptions.
+type GLBOptions struct {
+	RTC RTC
+	// Center is the origin positions are stored relative to. Nil uses the
+	// centre of the mesh's bounding box.
+	Center *[3]float64
+	// Quantize stores positions and texture coordinates as 16-bit
+	// integers, as EncodeGLBQuantized does. The node translation then
+	// holds the quantization offset, which already keeps the precision
+	// of RTCTranslation.
+	Quantize bool
+	// Meta, if set, is stored in the extras of the mesh's node.
+	Meta *TileMeta
+}
+
+// EncodeGLBWithOptions is EncodeGLB with the positions relative to a
+// centre, subtracted in double precision, and quantized when opts asks for
+// it.
+func EncodeGLBWithOptions(w io.Writer, m *Mesh, opts *GLBOptions) error {
+	if opts == nil {
+		opts = &GLBOptions{}
+	}
+	if opts.RTC != RTCNone && opts.RTC != RTCTranslation && opts.RTC != RTCCesium {
+		return errors.New("Unknown RTC mode")
+	}
+	var b gltfBuilder
+	node := gltfNode{}
+	if opts.Meta != nil {
+		node.Extras = opts.Meta
+	}
+	var center [3]float64
+	if opts.RTC == RTCCesium || (opts.RTC == RTCTranslation && !opts.Quantize) {
+		center = m.Center()
+		if opts.Center != nil {
+			center = *opts.Center
+		}
+		rel := *m
+		rel.Vertices = make([]float64, len(m.Vertices))
+		for i, v := range m.Vertices {
+			rel.Vertices[i] = v - center[i%3]
+		}
+		m = &rel
+	}
+	if opts.Quantize {
+		q := NewQuantization(m, 16)
+		b.doc.ExtensionsUsed = append(b.doc.ExtensionsUsed, "KHR_mesh_quantization")
+		node.Mesh = b.addMesh(m, &q)
+		node.Translation, node.Scale = q.Offset[:], q.Scale[:]
+		node.Extras = quantizedExtras{opts.Meta, q}
+	} else {
+		node.Mesh = b.addMesh(m, nil)
+		if opts.RTC == RTCTranslation {
+			node.Translation = center[:]
+		}
+	}
+	if opts.RTC == RTCCesium {
+		b.doc.ExtensionsUsed = append(b.doc.ExtensionsUsed, "CESIUM_RTC")
+		b.doc.Extensions = map[string]interface{}{
+			"CESIUM_RTC": struct {
+				Center [3]float64 `json:"center"`
+			}{center},
+		}
+	}
+	b.doc.ExtensionsRequired = b.doc.ExtensionsUsed
+	return b.writeNode(w, node)
+}
@@ -0,0 +1,157 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// glbPositions decodes the document and the float32 positions of a GLB
+// written by gltfBuilder.
+func glbPositions(t *testing.T, data []byte) (gltfDocument, []float32) {
+	jsonLen := binary.LittleEndian.Uint32(data[12:])
+	var doc gltfDocument
+	if err := json.Unmarshal(data[20:20+jsonLen], &doc); err != nil {
+		t.Fatal(err)
+	}
+	bin := data[20+jsonLen+8:]
+	acc := doc.Accessors[doc.Meshes[0].Primitives[0].Attributes["POSITION"]]
+	view := doc.BufferViews[acc.BufferView]
+	out := make([]float32, 3*acc.Count)
+	binary.Read(bytes.NewReader(bin[view.ByteOffset:view.ByteOffset+view.ByteLength]), binary.LittleEndian, out)
+	return doc, out
+}
+
+func TestMeshToECEF(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	id := TileID{Z: 10, X: 530, Y: 350}
+	geo, err := mesh.ToECEF(id, 17, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < geo.NumVertices(); i++ {
+		x, y, z := geo.Vertex(i)
+		if r := math.Sqrt(x*x + y*y + z*z); r < wgs84B || r > wgs84A+1000 {
+			t.Fatalf("vertex %d at %v m from the earth's centre", i, r)
+		}
+	}
+	if &geo.Triangles[0] != &mesh.Triangles[0] || mesh.Vertices[0] > 16 {
+		t.Error("expected a copy sharing the triangles")
+	}
+	if _, err := mesh.ToECEF(id, 9, nil); err == nil {
+		t.Error("expected error for a mesh outside the grid")
+	}
+}
+
+func TestEncodeGLBWithOptionsRTC(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	geo, _ := tile.ToMesh(5).ToECEF(TileID{Z: 14, X: 8500, Y: 5600}, 17, nil)
+
+	worst := func(opts *GLBOptions) float64 {
+		var buf bytes.Buffer
+		if err := EncodeGLBWithOptions(&buf, geo, opts); err != nil {
+			t.Fatal(err)
+		}
+		doc, pos := glbPositions(t, buf.Bytes())
+		var center [3]float64
+		if len(doc.Nodes[0].Translation) == 3 {
+			copy(center[:], doc.Nodes[0].Translation)
+		}
+		if opts.RTC == RTCCesium {
+			ext, _ := doc.Extensions["CESIUM_RTC"].(map[string]interface{})
+			c, _ := ext["center"].([]interface{})
+			if len(c) != 3 || len(doc.ExtensionsRequired) != 1 {
+				t.Fatalf("bad CESIUM_RTC extension %v", doc.Extensions)
+			}
+			for k := range center {
+				center[k] = c[k].(float64)
+			}
+		}
+		d := 0.0
+		for i, v := range pos {
+			d = math.Max(d, math.Abs(float64(v)+center[i%3]-geo.Vertices[i]))
+		}
+		return d
+	}
+	if d := worst(&GLBOptions{}); d < 0.01 {
+		t.Errorf("expected float32 ECEF positions to lose centimeters, worst %v", d)
+	}
+	for _, rtc := range []RTC{RTCTranslation, RTCCesium} {
+		if d := worst(&GLBOptions{RTC: rtc}); d > 0.001 {
+			t.Errorf("RTC %d: worst position error %v m", rtc, d)
+		}
+	}
+	if err := EncodeGLBWithOptions(&bytes.Buffer{}, geo, &GLBOptions{RTC: 9}); err == nil {
+		t.Error("expected error for unknown RTC mode")
+	}
+}
+
+func TestMeshToECEFScheme(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	xyz, _ := mesh.ToECEF(TileID{2, 1, 1}, 17, nil)
+	tms, err := mesh.ToECEF(TileID{2, 1, 2}, 17, SchemeTMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range xyz.Vertices {
+		if tms.Vertices[i] != v {
+			t.Fatalf("TMS tile placed differently from its XYZ tile at %d", i)
+		}
+	}
+	// The north west corner of the eastern WorldCRS84Quad tile is 0°, 90°N.
+	geo, _ := mesh.ToECEF(TileID{0, 1, 0}, 17, SchemeWorldCRS84Quad)
+	for i := 0; i < mesh.NumVertices(); i++ {
+		if x, y, z := mesh.Vertex(i); x == 0 && y == 0 {
+			if d := distance3([3]float64{geo.Vertices[3*i], geo.Vertices[3*i+1], geo.Vertices[3*i+2]}, ecef(0, 90, z)); d > 1e-6 {
+				t.Errorf("corner %v m from the pole", d)
+			}
+		}
+	}
+}
+
+func TestEncodeGLBWithOptionsQuantized(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	geo, _ := tile.ToMesh(5).ToECEF(TileID{Z: 14, X: 8500, Y: 5600}, 17, nil)
+
+	for _, rtc := range []RTC{RTCNone, RTCTranslation, RTCCesium} {
+		var buf bytes.Buffer
+		if err := EncodeGLBWithOptions(&buf, geo, &GLBOptions{RTC: rtc, Quantize: true}); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		jsonLen := binary.LittleEndian.Uint32(data[12:])
+		var doc gltfDocument
+		if err := json.Unmarshal(data[20:20+jsonLen], &doc); err != nil {
+			t.Fatal(err)
+		}
+		var center [3]float64
+		if rtc == RTCCesium {
+			c := doc.Extensions["CESIUM_RTC"].(map[string]interface{})["center"].([]interface{})
+			for k := range center {
+				center[k] = c[k].(float64)
+			}
+		}
+		if len(doc.ExtensionsRequired) == 0 || doc.ExtensionsRequired[0] != "KHR_mesh_quantization" {
+			t.Fatalf("RTC %d: extensions %v", rtc, doc.ExtensionsRequired)
+		}
+		node := doc.Nodes[0]
+		view := doc.BufferViews[doc.Accessors[doc.Meshes[0].Primitives[0].Attributes["POSITION"]].BufferView]
+		pos := data[20+jsonLen+8+uint32(view.ByteOffset):]
+		for i := 0; i < geo.NumVertices(); i++ {
+			for c := 0; c < 3; c++ {
+				v := float64(binary.LittleEndian.Uint16(pos[8*i+2*c:]))*node.Scale[c] + node.Translation[c] + center[c]
+				if d := math.Abs(v - geo.Vertices[3*i+c]); d > node.Scale[c] {
+					t.Fatalf("RTC %d: vertex %d off by %v m", rtc, i, d)
+				}
+			}
+		}
+	}
+}
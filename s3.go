@@ -0,0 +1,268 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Client is a minimal client for S3-compatible object storage, signing
+// requests with AWS Signature Version 4.
+type S3Client struct {
+	// Endpoint is the service URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// PathStyle addresses the bucket as Endpoint/Bucket/key instead of
+	// Bucket.host/key; most self-hosted services need it.
+	PathStyle    bool
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Client       *http.Client
+
+	now func() time.Time
+}
+
+func (c *S3Client) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	key = strings.TrimPrefix(key, "/")
+	if c.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.Bucket + "/" + key
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return u, nil
+}
+
+func (c *S3Client) do(ctx context.Context, method, key string, body []byte, header http.Header) (*http.Response, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req, body)
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req.WithContext(ctx))
+}
+
+// GetObject downloads an object.
+func (c *S3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 get %s: %s", key, resp.Status)
+	}
+	return data, err
+}
+
+// PutObject uploads an object.
+func (c *S3Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return c.putObject(ctx, key, data, header)
+}
+
+func (c *S3Client) putObject(ctx context.Context, key string, data []byte, header http.Header) error {
+	resp, err := c.do(ctx, http.MethodPut, key, data, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 put %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsEscape percent-encodes everything but unreserved characters.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sign adds the SigV4 headers. Host, Range, Content-Type and all x-amz-*
+// headers are signed.
+func (c *S3Client) sign(req *http.Request, body []byte) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	payload := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "range" || lk == "content-type" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		vs := query[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			params = append(params, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+
+	path := req.URL.EscapedPath()
+	if p, err := url.PathUnescape(path); err == nil {
+		path = awsEscape(p, true)
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// S3Source is a TerrainSource reading elevation PNG tiles from object
+// storage. KeyTemplate contains {z}, {x} and {y}.
+type S3Source struct {
+	Client      *S3Client
+	KeyTemplate string
+	Encoding    Encoding
+	// RGB, when set, overrides Encoding with a custom channel layout.
+	RGB *RGBEncoding
+}
+
+func (s *S3Source) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	data, err := s.Client.GetObject(ctx, expandTileTemplate(s.KeyTemplate, id))
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	if s.RGB != nil {
+		return s.RGB.Decode(img, gridSize)
+	}
+	return DecodeElevation(img, s.Encoding, gridSize)
+}
+
+// S3Sink is a TileSink writing tiles to object storage under
+// Prefix/z/x/y.Extension.
+type S3Sink struct {
+	Client      *S3Client
+	Prefix      string
+	Extension   string
+	ContentType string
+	// Compression, if set, pre-compresses the tiles and stores them with
+	// the matching Content-Encoding.
+	Compression Compression
+}
+
+func (s *S3Sink) WriteTile(ctx context.Context, id TileID, data []byte) error {
+	key := fmt.Sprintf("%d/%d/%d.%s", id.Z, id.X, id.Y, s.Extension)
+	if s.Prefix != "" {
+		key = strings.TrimSuffix(s.Prefix, "/") + "/" + key
+	}
+	data, err := CompressTile(data, s.Compression)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if s.ContentType != "" {
+		header.Set("Content-Type", s.ContentType)
+	}
+	if enc := s.Compression.ContentEncoding(); enc != "" {
+		header.Set("Content-Encoding", enc)
+	}
+	return s.Client.putObject(ctx, key, data, header)
+}
@@ -0,0 +1,198 @@
+package martini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Scene is a region made of many placed tiles, exported as one asset with
+// EncodeSceneGLB or EncodeSceneOBJ.
+type Scene struct {
+	Tiles     []SceneTile
+	Materials []SceneMaterial
+}
+
+// SceneTile is a tile mesh, in its own coordinates, and its placement in
+// the scene.
+type SceneTile struct {
+	Name string
+	PlacedMesh
+	// Material indexes Scene.Materials; negative means none.
+	Material int
+	// UVs, if set, holds u, v per vertex, v growing downwards, in place
+	// of the coordinates draped over the material's extent, such as the
+	// atlas coordinates of MergeTextured.
+	UVs []float64
+}
+
+// SceneMaterial is shared by the tiles that reference it. Its texture is
+// draped over the placed extent of all of them, so one image can cover a
+// whole region, or a single tile when only that tile uses the material.
+type SceneMaterial struct {
+	Name string
+	// Texture is the URI of the image, relative to the exported file.
+	// Empty means an untextured material.
+	Texture string
+}
+
+// sceneUVs returns the texture coordinates of every tile: its UVs, or
+// coordinates spanning the extent of the tiles sharing its material, with v
+// growing with grid y. Other tiles without a textured material get none.
+func (s *Scene) sceneUVs() [][]float64 {
+	type extent struct{ minX, minY, maxX, maxY float64 }
+	extents := make([]extent, len(s.Materials))
+	for i := range extents {
+		extents[i] = extent{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	}
+	textured := func(t *SceneTile) bool {
+		return t.Material >= 0 && t.Material < len(s.Materials) && s.Materials[t.Material].Texture != ""
+	}
+	for _, t := range s.Tiles {
+		if t.UVs != nil || !textured(&t) {
+			continue
+		}
+		e := &extents[t.Material]
+		for i := 0; i < t.Mesh.NumVertices(); i++ {
+			x, y, _ := t.Mesh.Vertex(i)
+			x, y = x+t.OffsetX, y+t.OffsetY
+			e.minX, e.maxX = math.Min(e.minX, x), math.Max(e.maxX, x)
+			e.minY, e.maxY = math.Min(e.minY, y), math.Max(e.maxY, y)
+		}
+	}
+	uvs := make([][]float64, len(s.Tiles))
+	for i, t := range s.Tiles {
+		if t.UVs != nil {
+			uvs[i] = t.UVs
+			continue
+		}
+		if !textured(&t) {
+			continue
+		}
+		e := extents[t.Material]
+		spanX, spanY := math.Max(e.maxX-e.minX, 1e-300), math.Max(e.maxY-e.minY, 1e-300)
+		uv := make([]float64, 2*t.Mesh.NumVertices())
+		for k := 0; k < t.Mesh.NumVertices(); k++ {
+			x, y, _ := t.Mesh.Vertex(k)
+			uv[2*k] = (x + t.OffsetX - e.minX) / spanX
+			uv[2*k+1] = (y + t.OffsetY - e.minY) / spanY
+		}
+		uvs[i] = uv
+	}
+	return uvs
+}
+
+// EncodeSceneGLB writes s as a binary glTF 2.0 file with one named node per
+// tile, translated by its placement, and one material per SceneMaterial
+// referencing its texture as an external image.
+func EncodeSceneGLB(w io.Writer, s *Scene) error {
+	var b gltfBuilder
+	textures := make(map[string]int)
+	for _, m := range s.Materials {
+		mat := gltfMaterial{Name: m.Name}
+		if m.Texture != "" {
+			tex, ok := textures[m.Texture]
+			if !ok {
+				b.doc.Images = append(b.doc.Images, gltfImage{URI: m.Texture})
+				tex = len(b.doc.Textures)
+				b.doc.Textures = append(b.doc.Textures, gltfTexture{Source: len(b.doc.Images) - 1})
+				textures[m.Texture] = tex
+			}
+			mat.PBR.BaseColorTexture = &struct {
+				Index int `json:"index"`
+			}{tex}
+		}
+		b.doc.Materials = append(b.doc.Materials, mat)
+	}
+
+	uvs := s.sceneUVs()
+	nodes := make([]int, len(s.Tiles))
+	for i, t := range s.Tiles {
+		mesh := b.addMesh(t.Mesh, nil)
+		prim := &b.doc.Meshes[mesh].Primitives[0]
+		if uvs[i] != nil {
+			prim.Attributes["TEXCOORD_0"] = b.floats(uvs[i], 2, "VEC2")
+		}
+		if t.Material >= 0 && t.Material < len(s.Materials) {
+			material := t.Material
+			prim.Material = &material
+		}
+		node := gltfNode{Name: t.Name, Mesh: mesh}
+		if t.OffsetX != 0 || t.OffsetY != 0 || t.OffsetZ != 0 {
+			node.Translation = []float64{t.OffsetX, t.OffsetY, t.OffsetZ}
+		}
+		b.doc.Nodes = append(b.doc.Nodes, node)
+		nodes[i] = i
+	}
+	b.doc.Scenes = append(b.doc.Scenes, struct {
+		Nodes []int `json:"nodes"`
+	}{nodes})
+	return b.writeGLB(w)
+}
+
+// EncodeSceneOBJ writes s as a Wavefront OBJ file with one object per tile,
+// its vertices placed, using the materials of mtllib, which
+// EncodeSceneMTL writes.
+func EncodeSceneOBJ(w io.Writer, s *Scene, mtllib string) error {
+	bw := bufio.NewWriter(w)
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	fmt.Fprintf(bw, "# generated by go-martini\n")
+	if mtllib != "" {
+		fmt.Fprintf(bw, "mtllib %s\n", mtllib)
+	}
+	uvs := s.sceneUVs()
+	base, baseUV := 0, 0
+	for i, t := range s.Tiles {
+		indices, err := t.Mesh.ExportIndices(IndexBaseOne, base)
+		if err != nil {
+			return err
+		}
+		name := t.Name
+		if name == "" {
+			name = "tile" + strconv.Itoa(i)
+		}
+		fmt.Fprintf(bw, "o %s\n", name)
+		for k := 0; k < t.Mesh.NumVertices(); k++ {
+			x, y, z := t.Mesh.Vertex(k)
+			fmt.Fprintf(bw, "v %s %s %s\n", f(x+t.OffsetX), f(y+t.OffsetY), f(z+t.OffsetZ))
+		}
+		uv := uvs[i]
+		// OBJ puts v = 0 at the bottom of the image.
+		for k := 0; k+1 < len(uv); k += 2 {
+			fmt.Fprintf(bw, "vt %s %s\n", f(uv[k]), f(1-uv[k+1]))
+		}
+		if t.Material >= 0 && t.Material < len(s.Materials) {
+			fmt.Fprintf(bw, "usemtl %s\n", s.Materials[t.Material].Name)
+		}
+		for k := 0; k+2 < len(indices); k += 3 {
+			bw.WriteString("f")
+			for _, v := range indices[k : k+3] {
+				if uv != nil {
+					fmt.Fprintf(bw, " %d/%d", v, v-base+baseUV)
+				} else {
+					fmt.Fprintf(bw, " %d", v)
+				}
+			}
+			bw.WriteByte('\n')
+		}
+		base += t.Mesh.NumVertices()
+		baseUV += len(uv) / 2
+	}
+	return bw.Flush()
+}
+
+// EncodeSceneMTL writes the materials of s as a Wavefront MTL file.
+func EncodeSceneMTL(w io.Writer, s *Scene) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range s.Materials {
+		fmt.Fprintf(bw, "newmtl %s\nKd 1 1 1\n", m.Name)
+		if m.Texture != "" {
+			fmt.Fprintf(bw, "map_Kd %s\n", m.Texture)
+		}
+	}
+	return bw.Flush()
+}
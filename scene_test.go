@@ -0,0 +1,92 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testScene() *Scene {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+	return &Scene{
+		Tiles: []SceneTile{
+			{Name: "a", PlacedMesh: PlacedMesh{Mesh: mesh}},
+			{Name: "b", PlacedMesh: PlacedMesh{Mesh: mesh, OffsetX: 16}},
+			{Name: "c", PlacedMesh: PlacedMesh{Mesh: mesh, OffsetY: 16}, Material: -1},
+		},
+		Materials: []SceneMaterial{{Name: "ortho", Texture: "ortho.png"}},
+	}
+}
+
+func TestEncodeSceneGLB(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeSceneGLB(&buf, testScene()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	var doc gltfDocument
+	if err := json.Unmarshal(data[20:20+binary.LittleEndian.Uint32(data[12:])], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Nodes) != 3 || len(doc.Scenes[0].Nodes) != 3 || len(doc.Images) != 1 || doc.Images[0].URI != "ortho.png" {
+		t.Fatalf("bad document %+v", doc)
+	}
+	if n := doc.Nodes[1]; n.Name != "b" || len(n.Translation) != 3 || n.Translation[0] != 16 {
+		t.Errorf("bad node %+v", n)
+	}
+	// The shared texture spans both textured tiles side by side.
+	for i, want := range [][2]float64{{0, 0.5}, {0.5, 1}} {
+		prim := doc.Meshes[doc.Nodes[i].Mesh].Primitives[0]
+		uv := doc.Accessors[prim.Attributes["TEXCOORD_0"]]
+		if prim.Material == nil || *prim.Material != 0 || uv.Min[0] != want[0] || uv.Max[0] != want[1] || uv.Max[1] != 1 {
+			t.Errorf("tile %d: material %v, uv range %v-%v", i, prim.Material, uv.Min, uv.Max)
+		}
+	}
+	prim := doc.Meshes[doc.Nodes[2].Mesh].Primitives[0]
+	if _, ok := prim.Attributes["TEXCOORD_0"]; ok || prim.Material != nil {
+		t.Error("untextured tile has texture coordinates or a material")
+	}
+}
+
+func TestEncodeSceneOBJ(t *testing.T) {
+	s := testScene()
+	var obj, mtl bytes.Buffer
+	if err := EncodeSceneOBJ(&obj, s, "scene.mtl"); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncodeSceneMTL(&mtl, s); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	var last string
+	for _, line := range strings.Split(obj.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			counts[fields[0]]++
+			if fields[0] == "f" {
+				last = line
+			}
+		}
+	}
+	mesh := s.Tiles[0].Mesh
+	if counts["o"] != 3 || counts["v"] != 3*mesh.NumVertices() || counts["vt"] != 2*mesh.NumVertices() ||
+		counts["f"] != 3*mesh.NumTriangles() || counts["usemtl"] != 2 || counts["mtllib"] != 1 {
+		t.Errorf("unexpected OBJ statements %v", counts)
+	}
+	// Faces of the second tile follow the vertices of the first.
+	n := mesh.NumVertices()
+	v := mesh.Triangles[0] + uint32(n) + 1
+	if first := fmt.Sprintf("f %d/%d ", v, v); !strings.Contains(obj.String(), "\n"+first) {
+		t.Errorf("expected a face starting %q", first)
+	}
+	if strings.Contains(last, "/") {
+		t.Errorf("untextured face %q has texture indices", last)
+	}
+	if !strings.Contains(mtl.String(), "newmtl ortho\n") || !strings.Contains(mtl.String(), "map_Kd ortho.png\n") {
+		t.Errorf("bad MTL:\n%s", mtl.String())
+	}
+}
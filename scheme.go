@@ -0,0 +1,268 @@
+package martini
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// TilingScheme describes how tiles are addressed: a quadtree over a
+// rectangle of a coordinate reference system, each zoom level doubling the
+// number of tile columns and rows.
+type TilingScheme struct {
+	// Name identifies the scheme, as the WMTS TileMatrixSet identifier.
+	Name string
+	// CRS names the coordinate reference system, such as "EPSG:3857".
+	CRS string
+	// Extent is the area covered by the scheme in CRS units: min x, min y,
+	// max x, max y.
+	Extent [4]float64
+	// MatrixWidth and MatrixHeight are the number of tiles at zoom 0.
+	MatrixWidth, MatrixHeight int
+	// BottomUp numbers rows from the south, as TMS does. Otherwise row 0
+	// is the northernmost.
+	BottomUp bool
+	// FromLngLat and ToLngLat convert between degrees and CRS units. They
+	// may be nil for a custom grid, which then cannot be restricted with
+	// Bounds and has no geographic tile bounds.
+	FromLngLat, ToLngLat func(x, y float64) (float64, float64)
+}
+
+var (
+	// SchemeXYZ is the slippy map scheme used throughout the package, the
+	// WebMercatorQuad of OGC WMTS.
+	SchemeXYZ = &TilingScheme{
+		Name:         "WebMercatorQuad",
+		CRS:          "EPSG:3857",
+		Extent:       [4]float64{-webMercatorHalf, -webMercatorHalf, webMercatorHalf, webMercatorHalf},
+		MatrixWidth:  1,
+		MatrixHeight: 1,
+		FromLngLat:   lngLatToMercator,
+		ToLngLat:     mercatorToLngLat,
+	}
+	// SchemeTMS is SchemeXYZ with rows numbered from the south.
+	SchemeTMS = &TilingScheme{
+		Name:         "WebMercatorQuadTMS",
+		CRS:          "EPSG:3857",
+		Extent:       SchemeXYZ.Extent,
+		MatrixWidth:  1,
+		MatrixHeight: 1,
+		BottomUp:     true,
+		FromLngLat:   lngLatToMercator,
+		ToLngLat:     mercatorToLngLat,
+	}
+	// SchemeWorldCRS84Quad is the geographic WMTS scheme with two tiles at
+	// zoom 0.
+	SchemeWorldCRS84Quad = &TilingScheme{
+		Name:         "WorldCRS84Quad",
+		CRS:          "EPSG:4326",
+		Extent:       [4]float64{-180, -90, 180, 90},
+		MatrixWidth:  2,
+		MatrixHeight: 1,
+		FromLngLat:   identityLngLat,
+		ToLngLat:     identityLngLat,
+	}
+)
+
+func identityLngLat(x, y float64) (float64, float64) { return x, y }
+
+// Matrix returns the number of tile columns and rows at zoom z.
+func (s *TilingScheme) Matrix(z int) (int, int) {
+	return s.MatrixWidth << uint(z), s.MatrixHeight << uint(z)
+}
+
+// Valid reports whether id addresses a tile of the scheme.
+func (s *TilingScheme) Valid(id TileID) bool {
+	if id.Z < 0 || id.Z > 30 {
+		return false
+	}
+	w, h := s.Matrix(id.Z)
+	return id.X >= 0 && id.Y >= 0 && id.X < w && id.Y < h
+}
+
+// tileSize returns the width and height of the tiles of zoom z in CRS
+// units.
+func (s *TilingScheme) tileSize(z int) (float64, float64) {
+	w, h := s.Matrix(z)
+	return (s.Extent[2] - s.Extent[0]) / float64(w), (s.Extent[3] - s.Extent[1]) / float64(h)
+}
+
+// TileExtent returns the min x, min y, max x, max y of a tile in CRS units.
+func (s *TilingScheme) TileExtent(id TileID) [4]float64 {
+	tw, th := s.tileSize(id.Z)
+	_, h := s.Matrix(id.Z)
+	row := id.Y
+	if !s.BottomUp {
+		row = h - 1 - id.Y
+	}
+	minX, minY := s.Extent[0]+float64(id.X)*tw, s.Extent[1]+float64(row)*th
+	return [4]float64{minX, minY, minX + tw, minY + th}
+}
+
+// SamplePoints returns the CRS coordinates of the gridSize*gridSize samples
+// of a tile, row by row from the north west corner.
+func (s *TilingScheme) SamplePoints(id TileID, gridSize int) ([]float64, []float64) {
+	e := s.TileExtent(id)
+	sx := (e[2] - e[0]) / float64(gridSize-1)
+	sy := (e[3] - e[1]) / float64(gridSize-1)
+	px := make([]float64, gridSize*gridSize)
+	py := make([]float64, gridSize*gridSize)
+	for j := 0; j < gridSize; j++ {
+		for i := 0; i < gridSize; i++ {
+			px[j*gridSize+i] = e[0] + float64(i)*sx
+			py[j*gridSize+i] = e[3] - float64(j)*sy
+		}
+	}
+	return px, py
+}
+
+// tileRange returns the columns and rows, counted from the south, of the
+// tiles of zoom z intersecting a CRS rectangle.
+func (s *TilingScheme) tileRange(z int, extent [4]float64) (x0, x1, r0, r1 int) {
+	tw, th := s.tileSize(z)
+	w, h := s.Matrix(z)
+	col := func(x float64) int { return clampInt(int(math.Floor((x-s.Extent[0])/tw)), 0, w-1) }
+	row := func(y float64) int { return clampInt(int(math.Floor((y-s.Extent[1])/th)), 0, h-1) }
+	x0, x1 = col(extent[0]), col(math.Nextafter(extent[2], math.Inf(-1)))
+	r0, r1 = row(extent[1]), row(math.Nextafter(extent[3], math.Inf(-1)))
+	return x0, x1, r0, r1
+}
+
+// TilesInExtent lists the tiles of zoom z intersecting a CRS rectangle.
+func (s *TilingScheme) TilesInExtent(z int, extent [4]float64) []TileID {
+	_, h := s.Matrix(z)
+	x0, x1, r0, r1 := s.tileRange(z, extent)
+	var ids []TileID
+	for x := x0; x <= x1; x++ {
+		for r := r1; r >= r0; r-- {
+			y := r
+			if !s.BottomUp {
+				y = h - 1 - r
+			}
+			ids = append(ids, TileID{Z: z, X: x, Y: y})
+		}
+	}
+	return ids
+}
+
+// TilesInBounds lists the tiles of zoom z intersecting a longitude/latitude
+// rectangle, or all tiles of z when the scheme has no FromLngLat.
+func (s *TilingScheme) TilesInBounds(z int, b Bounds) []TileID {
+	return s.TilesInExtent(z, s.boundsExtent(b))
+}
+
+// countTilesInBounds is the number of tiles TilesInBounds lists, without
+// listing them.
+func (s *TilingScheme) countTilesInBounds(z int, b Bounds) int64 {
+	x0, x1, r0, r1 := s.tileRange(z, s.boundsExtent(b))
+	if x1 < x0 || r1 < r0 {
+		return 0
+	}
+	return int64(x1-x0+1) * int64(r1-r0+1)
+}
+
+// boundsExtent returns the CRS rectangle covering a longitude/latitude
+// rectangle, or the whole scheme when it has no FromLngLat.
+func (s *TilingScheme) boundsExtent(b Bounds) [4]float64 {
+	if s.FromLngLat == nil {
+		return s.Extent
+	}
+	if s.CRS == SchemeXYZ.CRS {
+		b.South = math.Max(b.South, WorldBounds.South)
+		b.North = math.Min(b.North, WorldBounds.North)
+	}
+	// Project points along the edges, which need not stay straight.
+	extent := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for i := 0; i <= 8; i++ {
+		t := float64(i) / 8
+		lng := b.West + (b.East-b.West)*t
+		lat := b.South + (b.North-b.South)*t
+		for _, p := range [4][2]float64{{lng, b.South}, {lng, b.North}, {b.West, lat}, {b.East, lat}} {
+			x, y := s.FromLngLat(p[0], p[1])
+			extent[0], extent[1] = math.Min(extent[0], x), math.Min(extent[1], y)
+			extent[2], extent[3] = math.Max(extent[2], x), math.Max(extent[3], y)
+		}
+	}
+	return extent
+}
+
+// Bounds returns the longitude/latitude extent of a tile, or false when
+// the scheme has no ToLngLat.
+func (s *TilingScheme) Bounds(id TileID) (Bounds, bool) {
+	if s.ToLngLat == nil {
+		return Bounds{}, false
+	}
+	e := s.TileExtent(id)
+	b := Bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for i := 0; i <= 8; i++ {
+		t := float64(i) / 8
+		x := e[0] + (e[2]-e[0])*t
+		y := e[1] + (e[3]-e[1])*t
+		for _, p := range [4][2]float64{{x, e[1]}, {x, e[3]}, {e[0], y}, {e[2], y}} {
+			lng, lat := s.ToLngLat(p[0], p[1])
+			b.West, b.South = math.Min(b.West, lng), math.Min(b.South, lat)
+			b.East, b.North = math.Max(b.East, lng), math.Max(b.North, lat)
+		}
+	}
+	return b, true
+}
+
+// gridToLngLat converts grid coordinates of tile id, with y growing
+// southwards, to longitude/latitude. A nil scheme is SchemeXYZ.
+func (s *TilingScheme) gridToLngLat(id TileID, x, y float64, gridSize int) (float64, float64) {
+	if s == nil {
+		return id.gridToLngLat(x, y, gridSize)
+	}
+	e := s.TileExtent(id)
+	max := float64(gridSize - 1)
+	return s.ToLngLat(e[0]+(e[2]-e[0])*x/max, e[3]-(e[3]-e[1])*y/max)
+}
+
+// checkLngLat reports an error when tiles of s cannot be placed on the
+// globe. A nil scheme is SchemeXYZ.
+func (s *TilingScheme) checkLngLat() error {
+	if s != nil && s.ToLngLat == nil {
+		return errors.New("Expected a tiling scheme with ToLngLat")
+	}
+	return nil
+}
+
+// tileBounds is Bounds for a scheme that may be nil, meaning SchemeXYZ.
+func (s *TilingScheme) tileBounds(id TileID) Bounds {
+	if s == nil {
+		return id.Bounds()
+	}
+	b, _ := s.Bounds(id)
+	return b
+}
+
+// isWebMercator reports whether the scheme has the tiles of SchemeXYZ,
+// whatever its row order.
+func (s *TilingScheme) isWebMercator() bool {
+	return s.CRS == SchemeXYZ.CRS && s.Extent == SchemeXYZ.Extent && s.MatrixWidth == 1 && s.MatrixHeight == 1
+}
+
+// XYZ converts a tile of a web mercator scheme, such as SchemeTMS, to the
+// XYZ id used by the package's sources and exporters.
+func (s *TilingScheme) XYZ(id TileID) (TileID, error) {
+	if !s.isWebMercator() {
+		return TileID{}, errors.New("Expected a web mercator tiling scheme")
+	}
+	if s.BottomUp {
+		id.Y = (1 << uint(id.Z)) - 1 - id.Y
+	}
+	return id, nil
+}
+
+// XYZSource adapts a source of XYZ tiles, such as HTTPSource or COGSource,
+// to a web mercator scheme, so that builds and servers using the scheme's
+// ids can read from it.
+func (s *TilingScheme) XYZSource(source TerrainSource) TerrainSource {
+	return TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		xyz, err := s.XYZ(id)
+		if err != nil {
+			return nil, err
+		}
+		return source.Terrain(ctx, xyz, gridSize)
+	})
+}
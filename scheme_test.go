@@ -0,0 +1,122 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTilingScheme(t *testing.T) {
+	b := Bounds{West: -10.5, South: 35.2, East: 30.1, North: 60.7}
+	for z := 0; z < 6; z++ {
+		if got, want := SchemeXYZ.TilesInBounds(z, b), TilesInBounds(z, b); !reflect.DeepEqual(got, want) {
+			t.Errorf("zoom %d: got %v want %v", z, got, want)
+		}
+		for _, s := range []*TilingScheme{SchemeXYZ, SchemeWorldCRS84Quad} {
+			if n := s.countTilesInBounds(z, b); n != int64(len(s.TilesInBounds(z, b))) {
+				t.Errorf("%s zoom %d: counted %d tiles", s.Name, z, n)
+			}
+		}
+	}
+
+	xyz, err := SchemeTMS.XYZ(TileID{2, 1, 0})
+	if err != nil || xyz != (TileID{2, 1, 3}) {
+		t.Errorf("unexpected XYZ id %v %v", xyz, err)
+	}
+	if SchemeTMS.TileExtent(TileID{2, 1, 0}) != SchemeXYZ.TileExtent(xyz) {
+		t.Error("expected TMS and XYZ tiles to cover the same area")
+	}
+	if got, _ := SchemeXYZ.Bounds(TileID{3, 5, 2}); math.Abs(got.North-TileID{3, 5, 2}.Bounds().North) > 1e-9 {
+		t.Errorf("unexpected bounds %v", got)
+	}
+
+	// Two tiles at zoom 0, each 90 degrees at zoom 1.
+	if w, h := SchemeWorldCRS84Quad.Matrix(1); w != 4 || h != 2 {
+		t.Errorf("unexpected matrix %dx%d", w, h)
+	}
+	if got, _ := SchemeWorldCRS84Quad.Bounds(TileID{1, 3, 0}); got != (Bounds{90, 0, 180, 90}) {
+		t.Errorf("unexpected bounds %v", got)
+	}
+	if _, err := SchemeWorldCRS84Quad.XYZ(TileID{0, 0, 0}); err == nil {
+		t.Error("expected error converting a geographic tile to XYZ")
+	}
+
+	// A national grid without geographic conversion.
+	national := &TilingScheme{Name: "grid", CRS: "EPSG:27700", Extent: [4]float64{0, 0, 700000, 1400000}, MatrixWidth: 1, MatrixHeight: 2}
+	if tiles := national.TilesInBounds(1, WorldBounds); len(tiles) != 8 {
+		t.Errorf("expected all 8 tiles, got %v", tiles)
+	}
+	if tiles := national.TilesInExtent(2, [4]float64{100000, 100000, 200000, 200000}); !reflect.DeepEqual(tiles, []TileID{{2, 0, 6}, {2, 0, 7}, {2, 1, 6}, {2, 1, 7}}) {
+		t.Errorf("unexpected tiles %v", tiles)
+	}
+	px, py := national.SamplePoints(TileID{0, 0, 1}, 3)
+	if px[0] != 0 || py[0] != 700000 || px[8] != 700000 || py[8] != 0 {
+		t.Errorf("unexpected sample points %v %v", px, py)
+	}
+	if _, ok := national.Bounds(TileID{0, 0, 0}); ok {
+		t.Error("expected no geographic bounds")
+	}
+}
+
+func TestPyramidScheme(t *testing.T) {
+	dir := testDir(t)
+	xyz := &DirSink{Dir: filepath.Join(dir, "xyz"), Extension: "json"}
+	tms := &DirSink{Dir: filepath.Join(dir, "tms"), Extension: "json"}
+	opts := PyramidOptions{MinZoom: 1, MaxZoom: 1, GridSize: 17, MaxError: 5}
+	if err := BuildPyramid(context.Background(), testSource(), xyz, opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.Scheme = SchemeTMS
+	if err := BuildPyramid(context.Background(), SchemeTMS.XYZSource(testSource()), tms, opts); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := ioutil.ReadFile(filepath.Join(xyz.Dir, "1", "0", "1.json"))
+	b, err := ioutil.ReadFile(filepath.Join(tms.Dir, "1", "0", "0.json"))
+	if err != nil || !bytes.Equal(a, b) {
+		t.Error("expected the TMS tile 1/0/0 to be the XYZ tile 1/0/1")
+	}
+	var tileset bytes.Buffer
+	opts.Format, opts.Tileset = Format3DTiles, &tileset
+	glb := &DirSink{Dir: filepath.Join(dir, "glb"), Extension: "glb"}
+	if err := BuildPyramid(context.Background(), SchemeTMS.XYZSource(testSource()), glb, opts); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Root struct {
+			Children []struct {
+				Extras TileMeta `json:"extras"`
+			} `json:"children"`
+		} `json:"root"`
+	}
+	if err := json.Unmarshal(tileset.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Root.Children) != 4 {
+		t.Fatalf("expected 4 tiles, got %d", len(doc.Root.Children))
+	}
+	for _, c := range doc.Root.Children {
+		// Row 0 is the southern half.
+		if m := c.Extras; m.Y == 0 && (m.Bounds.North != 0 || m.Bounds.South > -85) {
+			t.Errorf("unexpected TMS bounds %+v", m)
+		}
+	}
+
+	s, _ := NewServer(testSource(), 17, 5)
+	s.Scheme = SchemeWorldCRS84Quad
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/0/1/0.json", nil))
+	if rec.Code != 200 {
+		t.Errorf("expected the second zoom 0 tile to exist, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/0/0/1.json", nil))
+	if rec.Code != 404 {
+		t.Errorf("expected 404 outside the matrix, got %d", rec.Code)
+	}
+}
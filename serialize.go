@@ -0,0 +1,98 @@
+package martini
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var martiniMagic = [4]byte{'M', 'R', 'T', 'N'}
+
+const martiniVersion = 1
+
+type martiniHeader struct {
+	Magic        [4]byte
+	Version      uint32
+	GridSize     uint32
+	NumTriangles uint32
+}
+
+// WriteTo writes the precomputed triangle hierarchy in a little-endian
+// binary layout: a 16 byte header followed by the raw Coords table.
+func (m *Martini) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	h := martiniHeader{
+		Magic:        martiniMagic,
+		Version:      martiniVersion,
+		GridSize:     uint32(m.GridSize),
+		NumTriangles: uint32(m.NumTriangles),
+	}
+	if err := binary.Write(bw, binary.LittleEndian, &h); err != nil {
+		return 0, err
+	}
+	var buf [8]byte
+	for i := 0; i < m.NumTriangles; i++ {
+		ax, ay, bx, by := m.triangle(i)
+		binary.LittleEndian.PutUint16(buf[0:], ax)
+		binary.LittleEndian.PutUint16(buf[2:], ay)
+		binary.LittleEndian.PutUint16(buf[4:], bx)
+		binary.LittleEndian.PutUint16(buf[6:], by)
+		if _, err := bw.Write(buf[:]); err != nil {
+			return 0, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(16 + 8*m.NumTriangles), nil
+}
+
+// maxSerializedGridSize bounds the grid size ReadMartini accepts, the
+// largest whose coordinates fit the uint16 table.
+const maxSerializedGridSize = 1<<15 + 1
+
+// ReadMartini loads a hierarchy written by WriteTo, with its Coords table,
+// without recomputing the triangle coordinates.
+func ReadMartini(r io.Reader) (*Martini, error) {
+	br := bufio.NewReader(r)
+	var h martiniHeader
+	if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.Magic != martiniMagic {
+		return nil, errors.New("Expected martini hierarchy magic")
+	}
+	if h.Version != martiniVersion {
+		return nil, errors.New("Unsupported martini hierarchy version")
+	}
+	gridSize := int(h.GridSize)
+	tileSize := gridSize - 1
+	if tileSize < 1 || (tileSize&(tileSize-1)) > 0 || gridSize > maxSerializedGridSize {
+		return nil, fmt.Errorf("Expected grid size to be 2^n+1 up to %d", maxSerializedGridSize)
+	}
+	mt := Martini{GridSize: gridSize}
+	mt.NumTriangles = tileSize*tileSize*2 - 2
+	mt.NumParentTriangles = mt.NumTriangles - tileSize*tileSize
+	if int(h.NumTriangles) != mt.NumTriangles {
+		return nil, errors.New("Triangle count does not match grid size")
+	}
+	// The table grows as it is read, so a short input cannot claim a
+	// large allocation.
+	var buf [8]byte
+	for i := 0; i < mt.NumTriangles; i++ {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return nil, err
+		}
+		for k := 0; k < 8; k += 2 {
+			v := binary.LittleEndian.Uint16(buf[k:])
+			if int(v) > tileSize {
+				return nil, fmt.Errorf("Triangle %d coordinate %d out of range", i, v)
+			}
+			mt.Coords = append(mt.Coords, v)
+		}
+	}
+	mt.Indices = make([]uint16, gridSize*gridSize)
+	return &mt, nil
+}
@@ -0,0 +1,54 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestMartiniRoundTrip(t *testing.T) {
+	m, _ := NewMartiniWithOptions(65, &MartiniOptions{Coords: true})
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+
+	data := buf.Bytes()
+	loaded, err := ReadMartini(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, loaded) {
+		t.Error("loaded hierarchy differs")
+	}
+	var compact bytes.Buffer
+	c, _ := NewMartini(65)
+	c.WriteTo(&compact)
+	if !bytes.Equal(compact.Bytes(), data) {
+		t.Error("expected the compact hierarchy to write the same table")
+	}
+
+	bad := append([]byte(nil), data...)
+	bad[16] = 65
+	if _, err := ReadMartini(bytes.NewReader(bad)); err == nil {
+		t.Error("expected error for a coordinate out of range")
+	}
+	huge := append([]byte(nil), data[:16]...)
+	binary.LittleEndian.PutUint32(huge[8:], 1<<20+1)
+	if _, err := ReadMartini(bytes.NewReader(huge)); err == nil {
+		t.Error("expected error for an oversized grid")
+	}
+
+	data[0] = 'X'
+	if _, err := ReadMartini(bytes.NewReader(data)); err == nil {
+		t.Error("expected error for bad magic")
+	}
+	if _, err := ReadMartini(bytes.NewReader(data[:10])); err == nil {
+		t.Error("expected error for truncated input")
+	}
+}
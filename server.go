@@ -0,0 +1,327 @@
+package martini
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server serves tile meshes at /{z}/{x}/{y}.{ext}, where ext selects one of
+// Formats.
+type Server struct {
+	Source   TerrainSource
+	GridSize int
+	MaxError float64
+	// MaxErrorForZoom overrides MaxError per zoom level when set.
+	MaxErrorForZoom func(z int) float64
+	// Mesher replaces RTIN; see BatchOptions.
+	Mesher      Mesher
+	TileOptions *TileOptions
+	MeshOptions *MeshOptions
+	// Cache, if set, holds recently generated meshes.
+	Cache *MeshCache
+	// DiskCache, if set, keeps meshes across restarts and can be shared by
+	// several servers and filled ahead of time with Warm.
+	DiskCache *DiskCache
+	// Formats maps file extensions to encodings.
+	Formats map[string]*Format
+	// Metrics, if set, records generated tiles and is served at /metrics.
+	Metrics *Metrics
+	// Logger, if set, receives tile failures and tiles slower than
+	// SlowTile.
+	Logger   Logger
+	SlowTile time.Duration
+	// Preview serves an interactive viewer at / and lets requests override
+	// the error threshold with a maxError query parameter. The viewer
+	// needs FormatPNG and FormatSVG in Formats.
+	Preview bool
+	// Scheme, if set, validates tile paths against a tiling scheme other
+	// than XYZ. Source receives the scheme's tile ids.
+	Scheme *TilingScheme
+	// WMTS, if set, serves a WMTS GetCapabilities document describing
+	// the meshes at /WMTSCapabilities.xml and for KVP GetCapabilities
+	// requests.
+	WMTS *WMTSLayer
+	// CacheControl, if set, is sent with tiles and 304 responses, for
+	// example "public, max-age=86400". Errors are never cached.
+	CacheControl string
+	// LastModified, if set, is sent as the Last-Modified time of every
+	// tile, typically the release date of the terrain. If-Modified-Since
+	// requests are then answered without generating the mesh. Reload
+	// advances it.
+	LastModified time.Time
+	// MaxBatchTiles, if positive, enables POST /batch, which meshes up to
+	// that many tiles per request; see BatchRequest.
+	MaxBatchTiles int
+	// AdminToken, if set, enables /admin/config to read and Reload the
+	// settings, authenticated with "Authorization: Bearer AdminToken".
+	AdminToken string
+	// MaxConcurrent bounds the meshes generated at once. Requests beyond
+	// it wait up to QueueTimeout for a slot, then get 503. Zero means no
+	// limit. Cached meshes are served without a slot.
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+	// RateLimit, if positive, allows each client that many tiles per
+	// second with bursts of RateBurst, answering 429 beyond. Clients are
+	// told apart by their X-API-Key header when it is one of APIKeys, or
+	// else by their IP address. A zero RateBurst allows a second's worth
+	// of tiles, and at least MaxBatchTiles so that full batches are not
+	// always refused.
+	RateLimit float64
+	RateBurst int
+	APIKeys   map[string]bool
+
+	slots     chan struct{}
+	slotsOnce sync.Once
+	limiter   rateLimiter
+	// mu guards the settings Reload changes: Source, MaxError,
+	// MaxErrorForZoom, Cache and LastModified.
+	mu     sync.RWMutex
+	config ServerConfig
+}
+
+var errBadRequest = errors.New("Expected a non-negative maxError")
+
+func NewServer(source TerrainSource, gridSize int, maxError float64) (*Server, error) {
+	if _, err := For(gridSize); err != nil {
+		return nil, err
+	}
+	return &Server{
+		Source:   source,
+		GridSize: gridSize,
+		MaxError: maxError,
+		Formats:  map[string]*Format{FormatJSON.Extension: FormatJSON},
+	}, nil
+}
+
+func parseTilePath(p string, scheme *TilingScheme) (TileID, string, bool) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) != 3 {
+		return TileID{}, "", false
+	}
+	dot := strings.LastIndexByte(parts[2], '.')
+	if dot < 0 {
+		return TileID{}, "", false
+	}
+	ext := parts[2][dot+1:]
+	parts[2] = parts[2][:dot]
+	var v [3]int
+	for i, s := range parts {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return TileID{}, "", false
+		}
+		v[i] = n
+	}
+	id := TileID{Z: v[0], X: v[1], Y: v[2]}
+	if !scheme.Valid(id) {
+		return TileID{}, "", false
+	}
+	return id, ext, true
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Metrics != nil && r.URL.Path == "/metrics" {
+		s.serveMetrics(w)
+		return
+	}
+	if s.WMTS != nil && isCapabilitiesRequest(r) {
+		s.serveCapabilities(w, r)
+		return
+	}
+	if s.AdminToken != "" && r.URL.Path == "/admin/config" {
+		s.serveAdmin(w, r)
+		return
+	}
+	if s.MaxBatchTiles > 0 && r.URL.Path == "/batch" {
+		s.serveBatch(w, r)
+		return
+	}
+	if s.Preview && r.URL.Path == "/" {
+		s.servePreview(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	scheme := s.Scheme
+	if scheme == nil {
+		scheme = SchemeXYZ
+	}
+	id, ext, ok := parseTilePath(r.URL.Path, scheme)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	format, ok := s.Formats[ext]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.rateLimit(w, r, 1) {
+		return
+	}
+
+	if s.CacheControl != "" {
+		w.Header().Set("Cache-Control", s.CacheControl)
+	}
+	s.mu.RLock()
+	lastModified := s.LastModified
+	s.mu.RUnlock()
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		// If-None-Match takes precedence when both are present.
+		if r.Header.Get("If-None-Match") == "" && notModifiedSince(r.Header.Get("If-Modified-Since"), lastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	mesh, maxError, err := s.mesh(r, id)
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("Last-Modified")
+	}
+	if err == errBadRequest {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == errBusy {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	// Each format is a different representation of the mesh.
+	etag := `"` + mesh.Hash() + "." + ext + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := s.encode(&buf, format, id, mesh, maxError); err != nil {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("Last-Modified")
+		s.internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if r.Method == http.MethodGet {
+		w.Write(buf.Bytes())
+	}
+}
+
+// mesh returns the mesh of tile id and the maxError it was extracted with.
+func (s *Server) mesh(r *http.Request, id TileID) (*Mesh, float64, error) {
+	s.mu.RLock()
+	source, maxError, forZoom, cache := s.Source, s.MaxError, s.MaxErrorForZoom, s.Cache
+	s.mu.RUnlock()
+	if forZoom != nil {
+		maxError = forZoom(id.Z)
+	}
+	if q := r.URL.Query().Get("maxError"); s.Preview && q != "" {
+		v, err := strconv.ParseFloat(q, 64)
+		if err != nil || v < 0 {
+			return nil, 0, errBadRequest
+		}
+		maxError = v
+	}
+	opts := s.batchOptions(maxError, cache)
+	if cache != nil {
+		if mesh, ok := cache.Get(opts.cacheKey(id)); ok {
+			return mesh, maxError, nil
+		}
+	}
+	release, err := s.admit(r.Context())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	var martini *Martini
+	if s.Mesher == nil {
+		if martini, err = For(s.GridSize); err != nil {
+			return nil, 0, err
+		}
+	}
+	mesh, _, err := processTile(r.Context(), source, martini, id, opts)
+	return mesh, maxError, err
+}
+
+// encode writes mesh in format, with the metadata of tile id for the
+// formats that embed or need it.
+func (s *Server) encode(w io.Writer, format *Format, id TileID, mesh *Mesh, maxError float64) error {
+	meta := NewTileMeta(id, mesh, s.MeshOptions, maxError, "")
+	if s.Scheme != nil {
+		meta.Bounds, _ = s.Scheme.Bounds(id)
+		meta.Scheme = s.Scheme
+	}
+	return format.encode(w, mesh, &meta)
+}
+
+// internalError logs err and answers 500 without revealing it, since it
+// may name files, hosts or buckets.
+func (s *Server) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, s.errorText(r, err), http.StatusInternalServerError)
+}
+
+// errorText returns the text of an error meant for the client, and logs
+// and replaces those that are not.
+func (s *Server) errorText(r *http.Request, err error) string {
+	if err == errBadRequest || err == errBusy {
+		return err.Error()
+	}
+	loggerOrNop(s.Logger).Error("request failed", "path", r.URL.Path, "err", err)
+	return http.StatusText(http.StatusInternalServerError)
+}
+
+// batchOptions meshes tiles with the server's settings.
+func (s *Server) batchOptions(maxError float64, cache *MeshCache) BatchOptions {
+	return BatchOptions{
+		GridSize:    s.GridSize,
+		MaxError:    maxError,
+		Mesher:      s.Mesher,
+		TileOptions: s.TileOptions,
+		MeshOptions: s.MeshOptions,
+		Cache:       cache,
+		DiskCache:   s.DiskCache,
+		Metrics:     s.Metrics,
+		Logger:      s.Logger,
+		SlowTile:    s.SlowTile,
+	}
+}
+
+// notModifiedSince reports whether an If-Modified-Since header is not
+// before modified, compared to the second.
+func notModifiedSince(header string, modified time.Time) bool {
+	if header == "" {
+		return false
+	}
+	t, err := http.ParseTime(header)
+	return err == nil && !modified.Truncate(time.Second).After(t)
+}
+
+// etagMatch implements the weak comparison of If-None-Match.
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package martini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s, err := NewServer(testSource(), 17, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestServer(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/1/1/0.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag")
+	}
+
+	req := httptest.NewRequest("GET", "/1/1/0.json", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("expected 304 without body, got %d", rec.Code)
+	}
+
+	for path, code := range map[string]int{
+		"/1/2/0.json": http.StatusNotFound,
+		"/1/1/0.xyz":  http.StatusNotFound,
+		"/1/1.json":   http.StatusNotFound,
+		"/5/0/0.json": http.StatusInternalServerError,
+	} {
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, rec.Code)
+		}
+	}
+}
+
+func TestServerQuantizedMesh(t *testing.T) {
+	s := newTestServer(t)
+	s.Formats[FormatQuantizedMesh.Extension] = FormatQuantizedMesh
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/1/1/0.terrain", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	q, err := DecodeQuantizedMesh(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta TileMeta
+	if err := json.Unmarshal(q.Metadata, &meta); err != nil || meta.ID() != (TileID{1, 1, 0}) || meta.MaxError != 5 {
+		t.Errorf("metadata %s (%v)", q.Metadata, err)
+	}
+}
+
+func TestServerCacheHeaders(t *testing.T) {
+	s := newTestServer(t)
+	s.Formats[FormatGLB.Extension] = FormatGLB
+	s.CacheControl = "public, max-age=3600"
+	s.LastModified = time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	logger := &recordingLogger{}
+	s.Logger = logger
+	var generated int
+	source := s.Source
+	s.Source = TerrainSourceFunc(func(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+		generated++
+		return source.Terrain(ctx, id, gridSize)
+	})
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get("/1/1/0.json")
+	if rec.Header().Get("Cache-Control") != "public, max-age=3600" || rec.Header().Get("Last-Modified") != "Fri, 01 Mar 2024 12:00:00 GMT" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+	if glb := get("/1/1/0.glb"); glb.Header().Get("ETag") == rec.Header().Get("ETag") {
+		t.Error("expected formats to have distinct ETags")
+	}
+
+	generated = 0
+	rec = get("/1/1/0.json", "If-Modified-Since", "Fri, 01 Mar 2024 12:00:00 GMT")
+	if rec.Code != http.StatusNotModified || generated != 0 || rec.Header().Get("Cache-Control") == "" {
+		t.Errorf("expected 304 without meshing, got %d after %d tiles", rec.Code, generated)
+	}
+	if rec = get("/1/1/0.json", "If-Modified-Since", "Thu, 29 Feb 2024 12:00:00 GMT"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for an older copy, got %d", rec.Code)
+	}
+	// A stale ETag wins over a recent date.
+	rec = get("/1/1/0.json", "If-Modified-Since", "Fri, 01 Mar 2024 12:00:00 GMT", "If-None-Match", `"stale"`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected If-None-Match to take precedence, got %d", rec.Code)
+	}
+
+	if rec = get("/5/0/0.json"); rec.Code != http.StatusInternalServerError || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("expected an uncached error, got %d %v", rec.Code, rec.Header())
+	}
+	// The source's error is logged, not sent.
+	if body := strings.TrimSpace(rec.Body.String()); body != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("expected a generic error body, got %q", body)
+	}
+	if all := strings.Join(logger.records, "\n"); !strings.Contains(all, "ERROR request failed path/5/0/0.json") {
+		t.Errorf("expected the error to be logged, got:\n%s", all)
+	}
+}
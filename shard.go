@@ -0,0 +1,149 @@
+package martini
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// shardRecord is an index entry: tile x, y, offset and length in the shard.
+const shardRecordSize = 20
+
+// ShardSink is a TileSink packing tiles into shard files instead of one file
+// per tile, so that global builds do not create millions of files. Each
+// zoom level is cut into squares of 2^ShardBits by 2^ShardBits tiles; the
+// tiles of a square are appended to Dir/z/sx-sy.shard and indexed in
+// Dir/z/sx-sy.index. Index records are appended as tiles are written, so a
+// shard stays readable after a crash; a tile written twice is read back
+// from its latest record. Read tiles back with ShardReader.
+type ShardSink struct {
+	Dir string
+	// ShardBits sets the shard size. Zero means 6, 4096 tiles per shard.
+	ShardBits uint
+
+	mu sync.Mutex
+}
+
+func shardBits(bits uint) uint {
+	if bits == 0 {
+		return 6
+	}
+	return bits
+}
+
+// shardPath returns the path of the shard holding id, without extension.
+func shardPath(dir string, bits uint, id TileID) string {
+	bits = shardBits(bits)
+	return filepath.Join(dir, strconv.Itoa(id.Z), fmt.Sprintf("%d-%d", id.X>>bits, id.Y>>bits))
+}
+
+func (s *ShardSink) WriteTile(ctx context.Context, id TileID, data []byte) error {
+	base := shardPath(s.Dir, s.ShardBits, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(base+".shard", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	var rec [shardRecordSize]byte
+	binary.LittleEndian.PutUint32(rec[0:], uint32(id.X))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(id.Y))
+	binary.LittleEndian.PutUint64(rec[8:], uint64(offset))
+	binary.LittleEndian.PutUint32(rec[16:], uint32(len(data)))
+	idx, err := os.OpenFile(base+".index", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = idx.Write(rec[:])
+	if cerr := idx.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type shardEntry struct {
+	offset int64
+	length int
+}
+
+// ShardReader reads tiles written by a ShardSink with the same Dir and
+// ShardBits. Shard indexes are loaded on first use and kept in memory.
+type ShardReader struct {
+	Dir       string
+	ShardBits uint
+
+	mu      sync.Mutex
+	indexes map[string]map[[2]int]shardEntry
+}
+
+// ReadTile returns the data of a tile, or an error satisfying
+// os.IsNotExist if it was never written.
+func (r *ShardReader) ReadTile(id TileID) ([]byte, error) {
+	base := shardPath(r.Dir, r.ShardBits, id)
+	index, err := r.index(base)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := index[[2]int{id.X, id.Y}]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: base + ".shard", Err: os.ErrNotExist}
+	}
+	f, err := os.Open(base + ".shard")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data := make([]byte, e.length)
+	if _, err := f.ReadAt(data, e.offset); err != nil {
+		return nil, fmt.Errorf("tile %v: %v", id, err)
+	}
+	return data, nil
+}
+
+func (r *ShardReader) index(base string) (map[[2]int]shardEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if index, ok := r.indexes[base]; ok {
+		return index, nil
+	}
+	raw, err := ioutil.ReadFile(base + ".index")
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[[2]int]shardEntry, len(raw)/shardRecordSize)
+	// A partial last record, left by a crash, is ignored.
+	for i := 0; i+shardRecordSize <= len(raw); i += shardRecordSize {
+		rec := raw[i:]
+		x := int(binary.LittleEndian.Uint32(rec[0:]))
+		y := int(binary.LittleEndian.Uint32(rec[4:]))
+		index[[2]int{x, y}] = shardEntry{
+			offset: int64(binary.LittleEndian.Uint64(rec[8:])),
+			length: int(binary.LittleEndian.Uint32(rec[16:])),
+		}
+	}
+	if r.indexes == nil {
+		r.indexes = make(map[string]map[[2]int]shardEntry)
+	}
+	r.indexes[base] = index
+	return index, nil
+}
@@ -0,0 +1,64 @@
+package martini
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShardSink(t *testing.T) {
+	dir := testDir(t)
+	sink := &ShardSink{Dir: filepath.Join(dir, "shards"), ShardBits: 1}
+	plain := &DirSink{Dir: filepath.Join(dir, "tiles"), Extension: "json"}
+	opts := PyramidOptions{MinZoom: 0, MaxZoom: 2, GridSize: 17, MaxError: 5}
+	if err := BuildPyramid(context.Background(), testSource(), sink, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := BuildPyramid(context.Background(), testSource(), plain, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Zoom 2 has 16 tiles in 4 shards of 2x2.
+	shards, _ := filepath.Glob(filepath.Join(sink.Dir, "2", "*.shard"))
+	if len(shards) != 4 {
+		t.Errorf("expected 4 shards at zoom 2, got %d", len(shards))
+	}
+	r := &ShardReader{Dir: sink.Dir, ShardBits: 1}
+	for z := 0; z <= 2; z++ {
+		for _, id := range TilesInBounds(z, WorldBounds) {
+			got, err := r.ReadTile(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := ioutil.ReadFile(filepath.Join(plain.Dir, id.String()+".json"))
+			if !bytes.Equal(got, want) {
+				t.Fatalf("tile %v differs from the directory layout", id)
+			}
+		}
+	}
+	if _, err := r.ReadTile(TileID{2, 3, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadTile(TileID{3, 0, 0}); !os.IsNotExist(err) {
+		t.Errorf("expected a missing tile, got %v", err)
+	}
+
+	// Rewriting a tile supersedes it; a torn index record is ignored.
+	id := TileID{2, 1, 2}
+	if err := sink.WriteTile(context.Background(), id, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(shardPath(sink.Dir, 1, id)+".index", os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+	r = &ShardReader{Dir: sink.Dir, ShardBits: 1}
+	if got, err := r.ReadTile(id); err != nil || string(got) != "new" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := r.ReadTile(TileID{2, 0, 2}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,136 @@
+package martini
+
+import "math"
+
+// TileOptions configures terrain preprocessing applied before the error
+// pyramid is built.
+type TileOptions struct {
+	// Fill interpolates void samples: NaN and, when set, NoData.
+	Fill   FillMethod
+	NoData *float64
+	// Smoothing is the standard deviation, in grid cells, of a Gaussian
+	// filter that suppresses sensor noise. Zero disables it.
+	Smoothing float64
+	// Curvature blends local curvature into the error metric: every
+	// sample's error is increased by Curvature times its largest second
+	// difference along the rows, columns and diagonals. Ridges, valleys and
+	// terrace edges then survive simplification even where their height
+	// error is small. Zero disables it.
+	Curvature float64
+	// Float32 reproduces the JavaScript martini bit for bit, which keeps
+	// terrain and errors in Float32Arrays: terrain is rounded to float32
+	// and errors are stored as float32. Since rounding is monotonic,
+	// rounding the propagated errors once matches JS rounding every store.
+	Float32 bool
+}
+
+// NewTileWithOptions preprocesses a copy of terrain according to opts and
+// creates the tile from it. The tile's FillReport counts the voids found
+// and filled.
+func NewTileWithOptions(terrain []float64, martini *Martini, opts *TileOptions) (*Tile, error) {
+	terrain, report, err := prepareTerrain(terrain, martini.GridSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	tile, err := martini.createPreparedTile(terrain, opts)
+	if err != nil {
+		return nil, err
+	}
+	tile.FillReport = report
+	return tile, nil
+}
+
+// createPreparedTile creates a tile from terrain that has already been
+// through prepareTerrain, applying the options that affect errors.
+func (m *Martini) createPreparedTile(terrain []float64, opts *TileOptions) (*Tile, error) {
+	if opts == nil {
+		return NewTile(terrain, m)
+	}
+	var bias []float64
+	if opts.Curvature > 0 && len(terrain) == m.GridSize*m.GridSize {
+		bias = curvatureBias(terrain, m.GridSize, opts.Curvature)
+	}
+	tile, err := newTile(terrain, m, bias)
+	if err != nil {
+		return nil, err
+	}
+	if bias != nil {
+		tile.curvature = opts.Curvature
+	}
+	if opts.Float32 {
+		roundFloat32(tile.Errors)
+	}
+	return tile, nil
+}
+
+func roundFloat32(values []float64) {
+	for i, v := range values {
+		values[i] = float64(float32(v))
+	}
+}
+
+func (m *Martini) CreateTileWithOptions(terrain []float64, opts *TileOptions) (*Tile, error) {
+	return NewTileWithOptions(terrain, m, opts)
+}
+
+// prepareTerrain returns terrain preprocessed by opts, leaving the input
+// untouched, and the report of filling its voids.
+func prepareTerrain(terrain []float64, gridSize int, opts *TileOptions) ([]float64, FillReport, error) {
+	var report FillReport
+	if opts == nil || len(terrain) != gridSize*gridSize {
+		return terrain, report, nil
+	}
+	if opts.Fill != FillNone {
+		terrain = append([]float64(nil), terrain...)
+		var err error
+		if report, err = FillHoles(terrain, gridSize, opts.NoData, opts.Fill); err != nil {
+			return nil, report, err
+		}
+	}
+	if opts.Smoothing > 0 {
+		terrain = SmoothTerrain(terrain, gridSize, opts.Smoothing)
+	}
+	if opts.Float32 {
+		terrain = append([]float64(nil), terrain...)
+		roundFloat32(terrain)
+	}
+	return terrain, report, nil
+}
+
+// SmoothTerrain returns a copy of terrain convolved with a Gaussian of the
+// given sigma in grid cells. Samples beyond the edges repeat the edge.
+func SmoothTerrain(terrain []float64, gridSize int, sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	var total float64
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	tmp := make([]float64, len(terrain))
+	out := make([]float64, len(terrain))
+	for y := 0; y < gridSize; y++ {
+		for x := 0; x < gridSize; x++ {
+			var v float64
+			for k, w := range kernel {
+				v += w * terrain[y*gridSize+clampInt(x+k-radius, 0, gridSize-1)]
+			}
+			tmp[y*gridSize+x] = v
+		}
+	}
+	for y := 0; y < gridSize; y++ {
+		for x := 0; x < gridSize; x++ {
+			var v float64
+			for k, w := range kernel {
+				v += w * tmp[clampInt(y+k-radius, 0, gridSize-1)*gridSize+x]
+			}
+			out[y*gridSize+x] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSmoothTerrain(t *testing.T) {
+	constant := testTerrain(17, func(x, y int) float64 { return 42 })
+	for i, v := range SmoothTerrain(constant, 17, 2) {
+		if math.Abs(v-42) > 1e-9 {
+			t.Fatalf("sample %d changed to %v", i, v)
+		}
+	}
+
+	noisy := testTerrain(33, func(x, y int) float64 { return float64(x) + 5*float64((x*7+y*13)%3-1) })
+	m, _ := NewMartini(33)
+	raw, _ := m.CreateTile(noisy)
+	smooth, err := m.CreateTileWithOptions(noisy, &TileOptions{Smoothing: 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noisy[5] != float64(5)+5*float64((35)%3-1) {
+		t.Error("expected input terrain to be left untouched")
+	}
+	if a, b := raw.ToMesh(1).NumTriangles(), smooth.ToMesh(1).NumTriangles(); b >= a {
+		t.Errorf("expected smoothing to reduce triangles, got %d -> %d", a, b)
+	}
+}
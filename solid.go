@@ -0,0 +1,78 @@
+package martini
+
+import (
+	"errors"
+	"math"
+)
+
+// Solid closes m, laid out Z up as by ToMesh, into a watertight solid for
+// 3D printing: vertical walls run down from every boundary loop to a flat
+// base baseThickness below the lowest vertex. The base repeats the surface
+// triangulation flattened, so it is watertight whatever the outline and
+// holes. The triangles of the solid face outwards, so its signed volume is
+// positive, whatever the winding of m; attributes and colors of the base
+// vertices are those of the surface vertices above them.
+func (m *Mesh) Solid(baseThickness float64) (*Mesh, error) {
+	if !(baseThickness > 0) {
+		return nil, errors.New("Expected a positive base thickness")
+	}
+	n := m.NumVertices()
+	base := math.Inf(1)
+	for i := 2; i < len(m.Vertices); i += 3 {
+		base = math.Min(base, m.Vertices[i])
+	}
+	base -= baseThickness
+
+	solid := &Mesh{
+		Vertices:  make([]float64, 0, 2*len(m.Vertices)),
+		Triangles: make([]uint32, 0, 2*len(m.Triangles)),
+	}
+	solid.Vertices = append(solid.Vertices, m.Vertices...)
+	for i := 0; i < n; i++ {
+		solid.Vertices = append(solid.Vertices, m.Vertices[3*i], m.Vertices[3*i+1], base)
+	}
+	for _, a := range m.Attributes {
+		values := append(append(make([]float64, 0, 2*n), a.Values...), a.Values...)
+		solid.Attributes = append(solid.Attributes, Attribute{Name: a.Name, Values: values})
+	}
+	if len(m.Colors) > 0 {
+		solid.Colors = append(append(make([]uint8, 0, 2*len(m.Colors)), m.Colors...), m.Colors...)
+	}
+
+	// Vertex v of the surface is v+n on the base. Every edge a→b of the
+	// surface is matched by b→a in a wall or in the reversed base.
+	solid.Triangles = append(solid.Triangles, m.Triangles...)
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		a, b, c := m.Triangles[i], m.Triangles[i+1], m.Triangles[i+2]
+		solid.Triangles = append(solid.Triangles, a+uint32(n), c+uint32(n), b+uint32(n))
+	}
+	for _, loop := range m.BoundaryLoops() {
+		for i, a := range loop {
+			b := loop[(i+1)%len(loop)]
+			solid.Triangles = append(solid.Triangles,
+				b, a, a+uint32(n),
+				b, a+uint32(n), b+uint32(n))
+		}
+	}
+	// ToMesh is y-down, so its triangles wind clockwise about +z and the
+	// solid built from them faces inwards.
+	if solid.signedVolume() < 0 {
+		for i := 0; i+2 < len(solid.Triangles); i += 3 {
+			solid.Triangles[i+1], solid.Triangles[i+2] = solid.Triangles[i+2], solid.Triangles[i+1]
+		}
+	}
+	return solid, nil
+}
+
+// signedVolume returns the volume enclosed by a closed mesh, positive when
+// its triangles face outwards.
+func (m *Mesh) signedVolume() float64 {
+	v := 0.0
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		ax, ay, az := m.Vertex(int(m.Triangles[i]))
+		bx, by, bz := m.Vertex(int(m.Triangles[i+1]))
+		cx, cy, cz := m.Vertex(int(m.Triangles[i+2]))
+		v += ax*(by*cz-bz*cy) + ay*(bz*cx-bx*cz) + az*(bx*cy-by*cx)
+	}
+	return v / 6
+}
@@ -0,0 +1,65 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSolid(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	tile.AddAttribute("class", make([]float64, 17*17))
+	mesh := tile.ToMesh(5)
+
+	solid, err := mesh.Solid(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := Validate(solid); !r.Valid() {
+		t.Fatalf("invalid solid: %+v", r)
+	}
+	if loops := solid.BoundaryLoops(); len(loops) != 0 {
+		t.Fatalf("solid has %d boundary loops", len(loops))
+	}
+	he, err := NewHalfEdges(solid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h, o := range he.Opposite {
+		if o < 0 {
+			t.Fatalf("half-edge %d has no twin", h)
+		}
+	}
+	if len(solid.Attributes[0].Values) != solid.NumVertices() {
+		t.Error("attributes not extended to the base")
+	}
+
+	// The enclosed volume is the column under the surface down to the
+	// base: the signed volume of a closed mesh, positive as the solid
+	// faces outwards.
+	want := 0.0
+	minZ := math.Inf(1)
+	for i := 2; i < len(mesh.Vertices); i += 3 {
+		minZ = math.Min(minZ, mesh.Vertices[i])
+	}
+	for i := 0; i < len(mesh.Triangles); i += 3 {
+		ax, ay, az := mesh.Vertex(int(mesh.Triangles[i]))
+		bx, by, bz := mesh.Vertex(int(mesh.Triangles[i+1]))
+		cx, cy, cz := mesh.Vertex(int(mesh.Triangles[i+2]))
+		area := math.Abs((bx-ax)*(cy-ay)-(by-ay)*(cx-ax)) / 2
+		want += area * ((az+bz+cz)/3 - minZ + 2)
+	}
+	if volume := solid.signedVolume(); math.Abs(volume-want) > 1e-6*want {
+		t.Errorf("volume %v, want %v", volume, want)
+	}
+
+	// A counter-clockwise surface gives the same outward solid.
+	ccw := tile.ToMeshWithOptions(5, &MeshOptions{Winding: WindingCCW})
+	if s, _ := ccw.Solid(2); math.Abs(s.signedVolume()-want) > 1e-6*want {
+		t.Errorf("counter-clockwise volume %v, want %v", s.signedVolume(), want)
+	}
+
+	if _, err := mesh.Solid(0); err == nil {
+		t.Error("expected an error for a zero base thickness")
+	}
+}
@@ -0,0 +1,38 @@
+package martini
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TileID addresses a tile in a z/x/y pyramid.
+type TileID struct {
+	Z, X, Y int
+}
+
+func (id TileID) String() string {
+	return fmt.Sprintf("%d/%d/%d", id.Z, id.X, id.Y)
+}
+
+// TerrainSource provides the gridSize*gridSize terrain samples of a tile.
+type TerrainSource interface {
+	Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error)
+}
+
+// TerrainSourceFunc adapts a function to a TerrainSource.
+type TerrainSourceFunc func(ctx context.Context, id TileID, gridSize int) ([]float64, error)
+
+func (f TerrainSourceFunc) Terrain(ctx context.Context, id TileID, gridSize int) ([]float64, error) {
+	return f(ctx, id, gridSize)
+}
+
+// expandTileTemplate substitutes {z}, {x} and {y} in a URL or key template.
+func expandTileTemplate(template string, id TileID) string {
+	return strings.NewReplacer(
+		"{z}", strconv.Itoa(id.Z),
+		"{x}", strconv.Itoa(id.X),
+		"{y}", strconv.Itoa(id.Y),
+	).Replace(template)
+}
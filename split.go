@@ -0,0 +1,99 @@
+package martini
+
+// Children returns the four tiles one zoom level below id, in the order
+// north-west, north-east, south-west, south-east.
+func (id TileID) Children() [4]TileID {
+	z, x, y := id.Z+1, 2*id.X, 2*id.Y
+	return [4]TileID{{z, x, y}, {z, x + 1, y}, {z, x, y + 1}, {z, x + 1, y + 1}}
+}
+
+// Parent returns the tile one zoom level above id.
+func (id TileID) Parent() TileID {
+	return TileID{id.Z - 1, id.X / 2, id.Y / 2}
+}
+
+// upsampleQuadrant doubles the resolution of quadrant q of a grid, in the
+// order of TileID.Children, interpolating bilinearly between samples.
+func upsampleQuadrant(src []float64, gridSize, q int) []float64 {
+	half := (gridSize - 1) / 2
+	ox, oy := (q%2)*half, (q/2)*half
+	out := make([]float64, len(src))
+	at := func(x, y int) float64 {
+		return src[(oy+y)*gridSize+ox+x]
+	}
+	for y := 0; y < gridSize; y++ {
+		for x := 0; x < gridSize; x++ {
+			x0, y0 := x/2, y/2
+			x1, y1 := (x+1)/2, (y+1)/2
+			out[y*gridSize+x] = (at(x0, y0) + at(x1, y0) + at(x0, y1) + at(x1, y1)) / 4
+		}
+	}
+	return out
+}
+
+// splitSeed maps the error pyramid of a tile onto one of its quadrants.
+// Child samples with even coordinates are parent samples, so every child
+// triangle whose corners and midpoint are all even is a parent triangle
+// with the same local error, and the parent's error already holds the
+// maximum over its descendants that are parent triangles too.
+type splitSeed struct {
+	errors []float64
+	q      int
+}
+
+// at returns the parent's error at child grid point (x, y).
+func (s *splitSeed) at(x, y, size int) float64 {
+	half := (size - 1) / 2
+	return s.errors[((s.q/2)*half+y/2)*size+(s.q%2)*half+x/2]
+}
+
+// sharesSquares reports whether the squares of side 2*side are parent
+// squares. Those of side 2 split parent cells, and the diagonal of the
+// whole tile runs across the parent's in the north-east and south-west
+// quadrants.
+func (s *splitSeed) sharesSquares(side, size int) bool {
+	if s == nil || side < 2 {
+		return false
+	}
+	return 2*side < size-1 || (s.q%2+s.q/2)&1 == 0
+}
+
+// sharesDiamond reports whether the diamond with midpoint (x, y) is a
+// parent's. On the border of the quadrant the parent's error also covers
+// the triangle in the neighbouring quadrant.
+func (s *splitSeed) sharesDiamond(x, y, size int) bool {
+	return s != nil && x > 0 && y > 0 && x < size-1 && y < size-1
+}
+
+// Split returns the four tiles covering the quadrants of t at twice the
+// resolution, in the order of TileID.Children. Their terrain and attributes
+// are upsampled bilinearly from t, so they carry no more detail than t
+// until replaced by real data, which makes them a cheap stand-in while a
+// pyramid is built top-down. The children share t's Martini. Their error
+// pyramids are seeded from t's, so only the triangles inside t's cells and
+// along the quadrant borders are evaluated; with TileOptions.Curvature the
+// bias is recomputed at the children's resolution instead, and their
+// pyramids with it.
+func (t *Tile) Split() ([4]*Tile, error) {
+	var children [4]*Tile
+	size := t.Martini.GridSize
+	for q := range children {
+		child := &Tile{Terrain: upsampleQuadrant(t.Terrain, size, q), Martini: t.Martini, curvature: t.curvature}
+		if t.curvature > 0 {
+			child.bias = curvatureBias(child.Terrain, size, t.curvature)
+		} else if t.bias == nil {
+			child.seed = &splitSeed{errors: t.Errors, q: q}
+		}
+		var err error
+		if child.Errors, err = allocFloat64s(t.Martini.allocator(), len(child.Terrain)); err != nil {
+			return children, err
+		}
+		child.Update()
+		child.seed = nil
+		for _, a := range t.Attributes {
+			child.Attributes = append(child.Attributes, Attribute{Name: a.Name, Values: upsampleQuadrant(a.Values, size, q)})
+		}
+		children[q] = child
+	}
+	return children, nil
+}
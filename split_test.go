@@ -0,0 +1,56 @@
+package martini
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	m, _ := NewMartini(33)
+	tile, _ := m.CreateTile(testTerrain(33, hills))
+	tile.AddAttribute("slope", testTerrain(33, func(x, y int) float64 { return float64(x + y) }))
+
+	children, err := tile.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for q, child := range children {
+		ox, oy := (q%2)*16, (q/2)*16
+		for y := 0; y < 33; y += 2 {
+			for x := 0; x < 33; x += 2 {
+				want := tile.Terrain[(oy+y/2)*33+ox+x/2]
+				if got := child.Terrain[y*33+x]; got != want {
+					t.Fatalf("child %d sample (%d, %d) = %v, want %v", q, x, y, got, want)
+				}
+			}
+		}
+		if got, want := child.Attributes[0].Values[32*33+32], float64(ox+oy+32); got != want {
+			t.Errorf("child %d attribute corner = %v, want %v", q, got, want)
+		}
+		// The seeded pyramid is the one computed from scratch.
+		fresh, _ := m.CreateTile(child.Terrain)
+		for i, e := range fresh.Errors {
+			if child.Errors[i] != e {
+				t.Fatalf("child %d error %d = %v, want %v", q, i, child.Errors[i], e)
+			}
+		}
+	}
+
+	curved, _ := m.CreateTileWithOptions(testTerrain(33, hills), &TileOptions{Curvature: 2})
+	children, err = curved.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for q, child := range children {
+		fresh, _ := m.CreateTileWithOptions(child.Terrain, &TileOptions{Curvature: 2})
+		for i, e := range fresh.Errors {
+			if child.Errors[i] != e {
+				t.Fatalf("curved child %d error %d = %v, want %v", q, i, child.Errors[i], e)
+			}
+		}
+	}
+
+	id := TileID{3, 5, 2}
+	for _, c := range id.Children() {
+		if c.Parent() != id {
+			t.Errorf("parent of %v is %v, want %v", c, c.Parent(), id)
+		}
+	}
+}
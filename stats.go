@@ -0,0 +1,73 @@
+package martini
+
+import "math"
+
+// HypsometricLevels is the number of elevation levels in
+// ElevationStats.Hypsometry.
+const HypsometricLevels = 11
+
+// ElevationStats summarizes the heights of a terrain tile. NaN samples are
+// NODATA and excluded from everything but NoDataPercent; when all samples
+// are NODATA the other fields are NaN.
+type ElevationStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	// Hypsometry is the hypsometric curve: Hypsometry[i] is the fraction
+	// of valid samples at or above Min + i/(HypsometricLevels-1) of the
+	// way to Max.
+	Hypsometry    []float64 `json:"hypsometry"`
+	NoDataPercent float64   `json:"noDataPercent"`
+}
+
+// TerrainStats computes the elevation statistics of a gridSize*gridSize
+// terrain tile.
+func TerrainStats(terrain []float64, gridSize int) ElevationStats {
+	return heightStats(terrain[:gridSize*gridSize])
+}
+
+// heightStats computes the statistics of any set of heights.
+func heightStats(heights []float64) ElevationStats {
+	nan := math.NaN()
+	s := ElevationStats{Min: math.Inf(1), Max: math.Inf(-1)}
+	n, sum, sum2 := 0, 0.0, 0.0
+	for _, h := range heights {
+		if math.IsNaN(h) {
+			continue
+		}
+		n++
+		sum += h
+		sum2 += h * h
+		s.Min = math.Min(s.Min, h)
+		s.Max = math.Max(s.Max, h)
+	}
+	s.NoDataPercent = 100 * float64(len(heights)-n) / float64(len(heights))
+	if n == 0 {
+		s.Min, s.Max, s.Mean, s.StdDev = nan, nan, nan, nan
+		return s
+	}
+	s.Mean = sum / float64(n)
+	s.StdDev = math.Sqrt(math.Max(sum2/float64(n)-s.Mean*s.Mean, 0))
+
+	counts := make([]int, HypsometricLevels)
+	span := s.Max - s.Min
+	for _, h := range heights {
+		if math.IsNaN(h) {
+			continue
+		}
+		// Count h at every level it reaches; the top level only holds Max.
+		top := HypsometricLevels - 1
+		if span > 0 && h < s.Max {
+			top = minInt(int((h-s.Min)/span*float64(HypsometricLevels-1)), HypsometricLevels-2)
+		}
+		counts[top]++
+	}
+	s.Hypsometry = make([]float64, HypsometricLevels)
+	above := 0
+	for i := HypsometricLevels - 1; i >= 0; i-- {
+		above += counts[i]
+		s.Hypsometry[i] = float64(above) / float64(n)
+	}
+	return s
+}
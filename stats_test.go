@@ -0,0 +1,69 @@
+package martini
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestTerrainStats(t *testing.T) {
+	// A ramp from 0 to 100 along x, with the last column missing.
+	terrain := testTerrain(5, func(x, y int) float64 {
+		if x == 4 {
+			return math.NaN()
+		}
+		return float64(x) * 100 / 3
+	})
+	s := TerrainStats(terrain, 5)
+	if s.Min != 0 || s.Max != 100 || math.Abs(s.Mean-50) > 1e-9 || s.NoDataPercent != 20 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if want := math.Sqrt((50*50 + 50.0/3*50.0/3) / 2); math.Abs(s.StdDev-want) > 1e-9 {
+		t.Errorf("stddev = %v, want %v", s.StdDev, want)
+	}
+	if s.Hypsometry[0] != 1 || s.Hypsometry[HypsometricLevels-1] != 0.25 {
+		t.Errorf("hypsometry = %v", s.Hypsometry)
+	}
+	for i := 1; i < len(s.Hypsometry); i++ {
+		if s.Hypsometry[i] > s.Hypsometry[i-1] {
+			t.Fatalf("hypsometry increases at %d: %v", i, s.Hypsometry)
+		}
+	}
+
+	flat := TerrainStats(make([]float64, 9), 3)
+	if flat.StdDev != 0 || flat.Hypsometry[HypsometricLevels-1] != 1 {
+		t.Errorf("flat stats %+v", flat)
+	}
+	empty := TerrainStats(testTerrain(3, func(x, y int) float64 { return math.NaN() }), 3)
+	if !math.IsNaN(empty.Mean) || empty.NoDataPercent != 100 {
+		t.Errorf("empty stats %+v", empty)
+	}
+}
+
+func TestBuildPyramidStats(t *testing.T) {
+	dir := testDir(t)
+	journal, err := OpenJournal(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	sink := &DirSink{Dir: filepath.Join(dir, "tiles"), Extension: "json"}
+	opts := PyramidOptions{MaxZoom: 1, GridSize: 17, MaxError: 5, Journal: journal, Stats: true}
+	if err := BuildPyramid(context.Background(), testSource(), sink, opts); err != nil {
+		t.Fatal(err)
+	}
+	metas := journal.Metas()
+	if len(metas) != 5 {
+		t.Fatalf("expected 5 tiles, got %d", len(metas))
+	}
+	for _, m := range metas {
+		if m.Stats == nil {
+			t.Fatalf("tile %d/%d/%d has no stats", m.Zoom, m.X, m.Y)
+		}
+		// The mesh vertices are samples of the terrain.
+		if m.Stats.Min > m.MinHeight || m.Stats.Max < m.MaxHeight || len(m.Stats.Hypsometry) != HypsometricLevels {
+			t.Errorf("tile %d/%d/%d: stats %+v, heights %v..%v", m.Zoom, m.X, m.Y, *m.Stats, m.MinHeight, m.MaxHeight)
+		}
+	}
+}
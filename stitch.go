@@ -0,0 +1,116 @@
+package martini
+
+import (
+	"errors"
+	"sort"
+)
+
+// Edge names a side of a tile. Grid y grows towards EdgeSouth.
+type Edge int
+
+const (
+	EdgeNorth Edge = iota
+	EdgeEast
+	EdgeSouth
+	EdgeWest
+)
+
+func (e Edge) Opposite() Edge {
+	return (e + 2) % 4
+}
+
+type edgeVertex struct {
+	t       float64
+	x, y, z float64
+	// i is the index of the vertex in its mesh.
+	i int
+}
+
+func collectEdge(m *Mesh, e Edge, size float64) []edgeVertex {
+	var out []edgeVertex
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, z := m.Vertex(i)
+		on := false
+		t := x
+		switch e {
+		case EdgeNorth:
+			on = y == 0
+		case EdgeSouth:
+			on = y == size
+		case EdgeWest:
+			on, t = x == 0, y
+		case EdgeEast:
+			on, t = x == size, y
+		}
+		if on {
+			out = append(out, edgeVertex{t: t, x: x, y: y, z: z, i: i})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].t < out[j].t })
+	return out
+}
+
+// Stitch generates the triangle strip that closes the gap between mesh a and
+// its neighbour b, which touches a along edge e. Both meshes must come from
+// tiles with the given grid size but may be extracted at different maxError
+// values. The returned mesh is expressed in the coordinate frame of a and
+// keeps the attributes and colors of the edge vertices, matched as by
+// MergeMeshes.
+func Stitch(a, b *Mesh, e Edge, gridSize int) (*Mesh, error) {
+	size := float64(gridSize - 1)
+	ea := collectEdge(a, e, size)
+	eb := collectEdge(b, e.Opposite(), size)
+	if len(ea) < 2 || len(eb) < 2 {
+		return nil, errors.New("Expected both meshes to have vertices along the shared edge")
+	}
+
+	dx, dy := 0.0, 0.0
+	switch e {
+	case EdgeNorth:
+		dy = -size
+	case EdgeSouth:
+		dy = size
+	case EdgeWest:
+		dx = -size
+	case EdgeEast:
+		dx = size
+	}
+	for i := range eb {
+		eb[i].x += dx
+		eb[i].y += dy
+	}
+
+	mesh := &Mesh{}
+	data := newVertexData(mesh, a, b)
+	for _, v := range ea {
+		mesh.Vertices = append(mesh.Vertices, v.x, v.y, v.z)
+		data.add(a, v.i)
+	}
+	for _, v := range eb {
+		mesh.Vertices = append(mesh.Vertices, v.x, v.y, v.z)
+		data.add(b, v.i)
+	}
+
+	same := func(p, q edgeVertex) bool {
+		return p.t == q.t && p.z == q.z
+	}
+	emit := func(p, q, r int, vp, vq, vr edgeVertex) {
+		if same(vp, vq) || same(vq, vr) || same(vp, vr) {
+			return
+		}
+		mesh.Triangles = append(mesh.Triangles, uint32(p), uint32(q), uint32(r))
+	}
+
+	off := len(ea)
+	i, j := 0, 0
+	for i < len(ea)-1 || j < len(eb)-1 {
+		if j == len(eb)-1 || (i < len(ea)-1 && ea[i+1].t <= eb[j+1].t) {
+			emit(i, off+j, i+1, ea[i], eb[j], ea[i+1])
+			i++
+		} else {
+			emit(i, off+j, off+j+1, ea[i], eb[j], eb[j+1])
+			j++
+		}
+	}
+	return mesh, nil
+}
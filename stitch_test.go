@@ -0,0 +1,67 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+func testTerrain(gridSize int, f func(x, y int) float64) []float64 {
+	terrain := make([]float64, gridSize*gridSize)
+	for y := 0; y < gridSize; y++ {
+		for x := 0; x < gridSize; x++ {
+			terrain[y*gridSize+x] = f(x, y)
+		}
+	}
+	return terrain
+}
+
+func hills(x, y int) float64 {
+	return 100*math.Sin(float64(x)/5) + 80*math.Cos(float64(y)/7) + float64(x*y)/10
+}
+
+func TestStitch(t *testing.T) {
+	martini, _ := NewMartini(33)
+	west, _ := martini.CreateTile(testTerrain(33, hills))
+	east, _ := martini.CreateTile(testTerrain(33, func(x, y int) float64 { return hills(x+32, y) }))
+
+	fine := west.ToMesh(0)
+	coarse := east.ToMesh(50)
+
+	strip, err := Stitch(fine, coarse, EdgeEast, 33)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < strip.NumVertices(); i++ {
+		if x, _, _ := strip.Vertex(i); x != 32 {
+			t.Fatalf("vertex %d off the shared edge: x=%v", i, x)
+		}
+	}
+	if strip.NumTriangles() == 0 {
+		t.Error("expected stitching triangles")
+	}
+	for _, idx := range strip.Triangles {
+		if int(idx) >= strip.NumVertices() {
+			t.Fatalf("index %d out of range", idx)
+		}
+	}
+
+	// Attributes and colors follow the edge vertices of both meshes.
+	row := testTerrain(33, func(x, y int) float64 { return float64(y) })
+	west.AddAttribute("row", row)
+	east.AddAttribute("row", row)
+	fine, coarse = west.ToMesh(0), east.ToMesh(50)
+	coarse.Colors = make([]uint8, 3*coarse.NumVertices())
+	strip, _ = Stitch(fine, coarse, EdgeEast, 33)
+	if len(strip.Attributes) != 1 || len(strip.Colors) != 3*strip.NumVertices() {
+		t.Fatalf("attributes or colors dropped: %d attributes, %d colors", len(strip.Attributes), len(strip.Colors))
+	}
+	for i := 0; i < strip.NumVertices(); i++ {
+		if _, y, _ := strip.Vertex(i); strip.Attributes[0].Values[i] != y {
+			t.Fatalf("vertex %d at y=%v has row %v", i, y, strip.Attributes[0].Values[i])
+		}
+	}
+
+	if _, err := Stitch(fine, &Mesh{}, EdgeEast, 33); err == nil {
+		t.Error("expected error for mesh without edge vertices")
+	}
+}
@@ -0,0 +1,189 @@
+package martini
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// ColorBy selects how rendered triangles are filled.
+type ColorBy int
+
+const (
+	ColorNone ColorBy = iota
+	// ColorElevation fills by the mean height of the triangle.
+	ColorElevation
+	// ColorError fills by the largest difference between the triangle and
+	// the terrain samples it covers; SVGOptions.Terrain must be set.
+	ColorError
+)
+
+// SVGOptions controls RenderSVG. The zero value draws a 512 pixel wide
+// black wireframe.
+type SVGOptions struct {
+	Width       int
+	Stroke      string
+	StrokeWidth float64
+	Color       ColorBy
+	// Terrain is the grid the mesh was extracted from, needed for
+	// ColorError.
+	Terrain  []float64
+	GridSize int
+}
+
+// RenderSVG draws the triangulation of a mesh in its grid frame, viewed
+// from above with y growing downwards.
+func RenderSVG(w io.Writer, m *Mesh, opts *SVGOptions) error {
+	if opts == nil {
+		opts = &SVGOptions{}
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = 512
+	}
+	stroke := opts.Stroke
+	if stroke == "" {
+		stroke = "black"
+	}
+	strokeWidth := opts.StrokeWidth
+	if strokeWidth <= 0 {
+		strokeWidth = 0.5
+	}
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, _ := m.Vertex(i)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	scale := 1.0
+	if maxX > minX {
+		scale = float64(width) / (maxX - minX)
+	}
+	height := 0
+	if maxY > minY {
+		height = int(math.Ceil((maxY - minY) * scale))
+	}
+
+	var values []float64
+	switch opts.Color {
+	case ColorElevation:
+		values = make([]float64, m.NumTriangles())
+		for t := range values {
+			for k := 0; k < 3; k++ {
+				_, _, z := m.Vertex(int(m.Triangles[3*t+k]))
+				values[t] += z / 3
+			}
+		}
+	case ColorError:
+		values = triangleErrors(m, opts.Terrain, opts.GridSize)
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	fmt.Fprintf(bw, "<g stroke=\"%s\" stroke-width=\"%g\" stroke-linejoin=\"round\">\n", stroke, strokeWidth)
+	for t := 0; t < m.NumTriangles(); t++ {
+		fill := "none"
+		if values != nil {
+			v := 0.0
+			if hi > lo {
+				v = (values[t] - lo) / (hi - lo)
+			}
+			c := ramp(v)
+			fill = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+		}
+		bw.WriteString("<polygon points=\"")
+		for k := 0; k < 3; k++ {
+			x, y, _ := m.Vertex(int(m.Triangles[3*t+k]))
+			if k > 0 {
+				bw.WriteByte(' ')
+			}
+			fmt.Fprintf(bw, "%.2f,%.2f", (x-minX)*scale, (y-minY)*scale)
+		}
+		fmt.Fprintf(bw, "\" fill=\"%s\"/>\n", fill)
+	}
+	bw.WriteString("</g>\n</svg>\n")
+	return bw.Flush()
+}
+
+// ramp maps t in [0, 1] through blue, cyan, green, yellow and red.
+func ramp(t float64) color.RGBA {
+	stops := []color.RGBA{
+		{49, 54, 149, 255},
+		{69, 170, 210, 255},
+		{102, 189, 99, 255},
+		{254, 224, 84, 255},
+		{215, 48, 39, 255},
+	}
+	t = math.Max(0, math.Min(1, t)) * float64(len(stops)-1)
+	i := int(t)
+	if i >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+	f := t - float64(i)
+	a, b := stops[i], stops[i+1]
+	mix := func(p, q uint8) uint8 {
+		return uint8(math.Round(float64(p) + f*(float64(q)-float64(p))))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// triangleErrors returns, per triangle of a mesh in its grid frame, the
+// largest absolute difference between the terrain samples it covers and
+// the plane through its vertices.
+func triangleErrors(m *Mesh, terrain []float64, gridSize int) []float64 {
+	errs := make([]float64, m.NumTriangles())
+	if len(terrain) != gridSize*gridSize {
+		return errs
+	}
+	forEachSample(m, gridSize, func(t, i int, z float64) {
+		errs[t] = math.Max(errs[t], math.Abs(terrain[i]-z))
+	})
+	return errs
+}
+
+// forEachSample calls fn with the triangle, grid index and interpolated
+// height of every grid sample covered by a triangle of a mesh in its grid
+// frame. Samples on shared edges are visited once per triangle.
+func forEachSample(m *Mesh, gridSize int, fn func(t, i int, z float64)) {
+	for t := 0; t < m.NumTriangles(); t++ {
+		var p [3][3]float64
+		for k := range p {
+			p[k][0], p[k][1], p[k][2] = m.Vertex(int(m.Triangles[3*t+k]))
+		}
+		triangleSamples(p, gridSize, func(i int, z float64) { fn(t, i, z) })
+	}
+}
+
+// triangleSamples calls fn with the grid index and interpolated height of
+// every grid sample covered by the triangle p.
+func triangleSamples(p [3][3]float64, gridSize int, fn func(i int, z float64)) {
+	ax, ay, az := p[0][0], p[0][1], p[0][2]
+	bx, by, bz := p[1][0], p[1][1], p[1][2]
+	cx, cy, cz := p[2][0], p[2][1], p[2][2]
+	det := (by-cy)*(ax-cx) + (cx-bx)*(ay-cy)
+	if det == 0 {
+		return
+	}
+	x0 := clampInt(int(math.Min(ax, math.Min(bx, cx))), 0, gridSize-1)
+	x1 := clampInt(int(math.Max(ax, math.Max(bx, cx))), 0, gridSize-1)
+	y0 := clampInt(int(math.Min(ay, math.Min(by, cy))), 0, gridSize-1)
+	y1 := clampInt(int(math.Max(ay, math.Max(by, cy))), 0, gridSize-1)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			px, py := float64(x), float64(y)
+			u := ((by-cy)*(px-cx) + (cx-bx)*(py-cy)) / det
+			v := ((cy-ay)*(px-cx) + (ax-cx)*(py-cy)) / det
+			if u < -1e-9 || v < -1e-9 || u+v > 1+1e-9 {
+				continue
+			}
+			fn(y*gridSize+x, u*az+v*bz+(1-u-v)*cz)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package martini
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestRenderSVG(t *testing.T) {
+	terrain := testTerrain(17, hills)
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(terrain)
+	mesh := tile.ToMesh(10)
+
+	var buf bytes.Buffer
+	if err := RenderSVG(&buf, mesh, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(interface{})); err != nil {
+		t.Fatalf("invalid SVG: %v", err)
+	}
+	if n := strings.Count(buf.String(), "<polygon"); n != mesh.NumTriangles() {
+		t.Errorf("expected %d polygons, got %d", mesh.NumTriangles(), n)
+	}
+	if !strings.Contains(buf.String(), `width="512" height="512"`) {
+		t.Error("expected default size")
+	}
+
+	buf.Reset()
+	RenderSVG(&buf, mesh, &SVGOptions{Color: ColorError, Terrain: terrain, GridSize: 17})
+	if strings.Contains(buf.String(), `fill="none"`) {
+		t.Error("expected filled triangles")
+	}
+}
+
+func TestTriangleErrors(t *testing.T) {
+	terrain := testTerrain(17, hills)
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(terrain)
+	for _, e := range triangleErrors(tile.ToMesh(10), terrain, 17) {
+		if e > 10 {
+			t.Fatalf("triangle error %v above maxError", e)
+		}
+	}
+	for _, e := range triangleErrors(tile.ToMesh(0), terrain, 17) {
+		if e > 1e-9 {
+			t.Fatalf("expected exact full mesh, got error %v", e)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package martini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+type tilesetNode struct {
+	BoundingVolume struct {
+		Region [6]float64 `json:"region"`
+	} `json:"boundingVolume"`
+	GeometricError float64 `json:"geometricError"`
+	Refine         string  `json:"refine,omitempty"`
+	Content        *struct {
+		URI string `json:"uri"`
+	} `json:"content,omitempty"`
+	Children []*tilesetNode `json:"children,omitempty"`
+	Extras   *TileMeta      `json:"extras,omitempty"`
+}
+
+func (n *tilesetNode) extend(m *TileMeta) {
+	r := &n.BoundingVolume.Region
+	rad := math.Pi / 180
+	r[0] = math.Min(r[0], m.Bounds.West*rad)
+	r[1] = math.Min(r[1], m.Bounds.South*rad)
+	r[2] = math.Max(r[2], m.Bounds.East*rad)
+	r[3] = math.Max(r[3], m.Bounds.North*rad)
+	r[4] = math.Min(r[4], m.MinHeight)
+	r[5] = math.Max(r[5], m.MaxHeight)
+}
+
+func emptyTilesetNode() *tilesetNode {
+	n := &tilesetNode{}
+	inf := math.Inf(1)
+	n.BoundingVolume.Region = [6]float64{inf, inf, -inf, -inf, inf, -inf}
+	return n
+}
+
+// Format3DTiles encodes tiles as glTF in WGS84 earth-centred, earth-fixed
+// meters, the content WriteTileset expects. Positions are stored relative
+// to the tile centre, which becomes the node translation, and y-up as
+// glTF requires; 3D Tiles turns them back to z-up. Like
+// FormatQuantizedMesh, it needs the tile's metadata and meshes in grid
+// units.
+var Format3DTiles = &Format{
+	Name:        "3dtiles",
+	Extension:   "glb",
+	ContentType: "model/gltf-binary",
+	Encode: func(w io.Writer, m *Mesh) error {
+		return errors.New("Expected tile metadata to place a 3D Tiles tile")
+	},
+	EncodeMeta: encode3DTilesMeta,
+}
+
+func encode3DTilesMeta(w io.Writer, m *Mesh, meta *TileMeta) error {
+	e, err := m.ToECEF(meta.ID(), meshGridSize(m), meta.Scheme)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(e.Vertices); i += 3 {
+		e.Vertices[i+1], e.Vertices[i+2] = e.Vertices[i+2], -e.Vertices[i+1]
+	}
+	return EncodeGLBWithOptions(w, e, &GLBOptions{RTC: RTCTranslation, Meta: meta})
+}
+
+// WriteTileset writes a 3D Tiles 1.1 tileset.json for the tiles described
+// by metas, whose glTF content, as written by Format3DTiles, lives at
+// z/x/y.extension relative to it. Tiles are nested by zoom level under
+// their parent when it is present, and carry their TileMeta as extras. A
+// tile's geometric error is the maxError it was meshed at, or zero for
+// tiles without children.
+func WriteTileset(w io.Writer, metas []TileMeta, extension string) error {
+	if len(metas) == 0 {
+		return errors.New("Expected at least one tile")
+	}
+	sorted := make([]TileMeta, len(metas))
+	copy(sorted, metas)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Zoom != b.Zoom {
+			return a.Zoom < b.Zoom
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+
+	root := emptyTilesetNode()
+	root.Refine = "REPLACE"
+	nodes := make(map[TileID]*tilesetNode, len(sorted))
+	for i := range sorted {
+		m := &sorted[i]
+		n := emptyTilesetNode()
+		n.extend(m)
+		n.Content = &struct {
+			URI string `json:"uri"`
+		}{fmt.Sprintf("%d/%d/%d.%s", m.Zoom, m.X, m.Y, extension)}
+		n.Extras = m
+		nodes[m.ID()] = n
+
+		parent, ok := nodes[m.ID().Parent()]
+		if m.Zoom == 0 || !ok {
+			parent = root
+		}
+		parent.Children = append(parent.Children, n)
+		root.extend(m)
+		root.GeometricError = math.Max(root.GeometricError, m.MaxError)
+	}
+	// Finer tiles come last; walking backwards grows parents after their
+	// children are complete.
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if n := nodes[sorted[i].ID()]; len(n.Children) > 0 {
+			n.GeometricError = n.Extras.MaxError
+			for _, c := range n.Children {
+				n.BoundingVolume.Region = unionRegion(n.BoundingVolume.Region, c.BoundingVolume.Region)
+			}
+		}
+	}
+
+	doc := struct {
+		Asset struct {
+			Version string `json:"version"`
+		} `json:"asset"`
+		GeometricError float64      `json:"geometricError"`
+		Root           *tilesetNode `json:"root"`
+	}{GeometricError: root.GeometricError, Root: root}
+	doc.Asset.Version = "1.1"
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&doc)
+}
+
+func unionRegion(a, b [6]float64) [6]float64 {
+	return [6]float64{
+		math.Min(a[0], b[0]), math.Min(a[1], b[1]),
+		math.Max(a[2], b[2]), math.Max(a[3], b[3]),
+		math.Min(a[4], b[4]), math.Max(a[5], b[5]),
+	}
+}
@@ -0,0 +1,185 @@
+package martini
+
+import "math"
+
+// sweep runs the passes of Update from the finest level up. Each pass
+// writes only its own lattice of midpoints, so its rows are independent;
+// run executes a pass over the rows [y0, y1) it chooses and returns when
+// it is complete.
+func (t *Tile) sweep(run func(pass func(y0, y1 int))) {
+	tileSize := t.Martini.GridSize - 1
+	run(t.updateLeaves)
+	for s := 1; 2*s <= tileSize; s *= 2 {
+		s := s
+		run(func(y0, y1 int) { t.updateSquares(s, y0, y1) })
+		if 4*s <= tileSize {
+			run(func(y0, y1 int) { t.updateDiamonds(2*s, y0, y1) })
+		}
+	}
+}
+
+// updateLeaves computes the errors of the leaf triangles in rows [y0, y1).
+// Their hypotenuses are the axis-aligned edges of length 2, so the
+// midpoints are the grid points with odd x on even rows (horizontal
+// hypotenuse) and odd y on even columns (vertical hypotenuse), and the
+// error is a three point stencil along a row or between three rows.
+func (t *Tile) updateLeaves(y0, y1 int) {
+	size := t.Martini.GridSize
+	if size < 3 {
+		return
+	}
+	terrain := t.Terrain
+	errs := t.Errors
+	scratch := make([]float64, size)
+
+	for y := y0; y < y1; y++ {
+		row := terrain[y*size : (y+1)*size]
+		out := errs[y*size : (y+1)*size]
+		if y&1 == 0 {
+			midErrors(scratch[1:size-1], row[:size-2], row[1:size-1], row[2:])
+			t.adjustRow(scratch, y*size)
+			for x := 1; x < size-1; x += 2 {
+				out[x] = math.Max(out[x], scratch[x])
+			}
+		} else {
+			midErrors(scratch, terrain[(y-1)*size:y*size], row, terrain[(y+1)*size:(y+2)*size])
+			t.adjustRow(scratch, y*size)
+			for x := 0; x < size; x += 2 {
+				out[x] = math.Max(out[x], scratch[x])
+			}
+		}
+	}
+}
+
+// updateSquares handles the triangles whose legs are axis-aligned with
+// length 2s, centred in rows [y0, y1). Each pair covers a square of side 2s
+// and shares the square's diagonal as hypotenuse; the diagonals alternate
+// in a checkerboard. The children's midpoints are the midpoints of the
+// square's four edges.
+func (t *Tile) updateSquares(s, y0, y1 int) {
+	size := t.Martini.GridSize
+	terrain := t.Terrain
+	errs := t.Errors
+
+	seeded := t.seed.sharesSquares(s, size)
+	for y := s + (maxInt(y0-s, 0)+2*s-1)/(2*s)*(2*s); y < y1; y += 2 * s {
+		for x := s; x < size; x += 2 * s {
+			m := y*size + x
+			var e float64
+			if seeded {
+				e = t.seed.at(x, y, size)
+			} else {
+				var a, b int
+				if (x/(2*s)+y/(2*s))&1 == 0 {
+					a, b = (y-s)*size+x-s, (y+s)*size+x+s
+				} else {
+					a, b = (y-s)*size+x+s, (y+s)*size+x-s
+				}
+				e = math.Abs((loadF64(terrain, a)+loadF64(terrain, b))/2 - loadF64(terrain, m))
+				if t.bias != nil || t.thresholds != nil {
+					e = t.adjustError(e, m)
+				}
+			}
+			e = math.Max(loadF64(errs, m), e)
+			e = math.Max(e, loadF64(errs, m-s))
+			e = math.Max(e, loadF64(errs, m+s))
+			e = math.Max(e, loadF64(errs, m-s*size))
+			e = math.Max(e, loadF64(errs, m+s*size))
+			storeF64(errs, m, e)
+		}
+	}
+}
+
+// updateDiamonds handles the triangles whose hypotenuse is an axis-aligned
+// edge of length 2s, with its midpoint in rows [y0, y1), and the apex at
+// the centre of an adjacent square. The children's midpoints lie
+// diagonally at distance s/2 on either side of the edge, clipped to the
+// grid.
+func (t *Tile) updateDiamonds(s, y0, y1 int) {
+	size := t.Martini.GridSize
+	terrain := t.Terrain
+	errs := t.Errors
+	h := s / 2
+
+	for y := (y0 + s - 1) / s * s; y < y1; y += s {
+		x0, da := s, 1
+		if (y/s)&1 == 1 {
+			x0, da = 0, size
+		}
+		for x := x0; x < size; x += 2 * s {
+			m := y*size + x
+			var e float64
+			if t.seed.sharesDiamond(x, y, size) {
+				e = t.seed.at(x, y, size)
+			} else {
+				e = math.Abs((loadF64(terrain, m-s*da)+loadF64(terrain, m+s*da))/2 - loadF64(terrain, m))
+				if t.bias != nil || t.thresholds != nil {
+					e = t.adjustError(e, m)
+				}
+			}
+			e = math.Max(loadF64(errs, m), e)
+			if y >= h {
+				if x >= h {
+					e = math.Max(e, loadF64(errs, m-h*size-h))
+				}
+				if x+h < size {
+					e = math.Max(e, loadF64(errs, m-h*size+h))
+				}
+			}
+			if y+h < size {
+				if x >= h {
+					e = math.Max(e, loadF64(errs, m+h*size-h))
+				}
+				if x+h < size {
+					e = math.Max(e, loadF64(errs, m+h*size+h))
+				}
+			}
+			storeF64(errs, m, e)
+		}
+	}
+}
+
+// adjustRow applies adjustError to a row of local errors starting at grid
+// index base.
+func (t *Tile) adjustRow(row []float64, base int) {
+	if t.bias == nil && t.thresholds == nil {
+		return
+	}
+	for i, e := range row {
+		row[i] = t.adjustError(e, base+i)
+	}
+}
+
+// adjustError adds the tile's per-sample bias to the local error at grid
+// index i and divides it by the per-sample threshold, if the tile has them.
+func (t *Tile) adjustError(e float64, i int) float64 {
+	if t.bias != nil {
+		e += t.bias[i]
+	}
+	if t.thresholds != nil {
+		e = normalizeError(e, t.thresholds[i])
+	}
+	return e
+}
+
+// normalizeError scales e so that it exceeds 1 exactly when it exceeds
+// threshold, which may be zero.
+func normalizeError(e, threshold float64) float64 {
+	if e == 0 {
+		return 0
+	}
+	return e / threshold
+}
+
+// midErrors sets dst[i] to |(a[i]+b[i])/2 - m[i]|. a, m and b must be at
+// least as long as dst.
+var midErrors = midErrorsGeneric
+
+func midErrorsGeneric(dst, a, m, b []float64) {
+	a = a[:len(dst)]
+	m = m[:len(dst)]
+	b = b[:len(dst)]
+	for i := range dst {
+		dst[i] = math.Abs((a[i]+b[i])/2 - m[i])
+	}
+}
@@ -0,0 +1,43 @@
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+package martini
+
+func init() {
+	if hasAVX2() {
+		midErrors = midErrorsChecked
+	}
+}
+
+func midErrorsChecked(dst, a, m, b []float64) {
+	if len(a) < len(dst) || len(m) < len(dst) || len(b) < len(dst) {
+		panic("martini: midErrors operand too short")
+	}
+	midErrorsAVX2(dst, a, m, b)
+}
+
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	osxsave := ecx1&(1<<27) != 0
+	avx := ecx1&(1<<28) != 0
+	if !osxsave || !avx {
+		return false
+	}
+	// The OS must save both XMM and YMM state.
+	if eax, _ := xgetbv(); eax&6 != 6 {
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
+
+//go:noescape
+func midErrorsAVX2(dst, a, m, b []float64)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
@@ -0,0 +1,71 @@
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+#include "textflag.h"
+
+// func midErrorsAVX2(dst, a, m, b []float64)
+TEXT ·midErrorsAVX2(SB), NOSPLIT, $0-96
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	MOVQ m_base+48(FP), DX
+	MOVQ b_base+72(FP), BX
+
+	// Y15 = 0.5, Y14 = sign bit clear mask
+	MOVQ $0x3FE0000000000000, AX
+	MOVQ AX, X15
+	VPBROADCASTQ X15, Y15
+	MOVQ $0x7FFFFFFFFFFFFFFF, AX
+	MOVQ AX, X14
+	VPBROADCASTQ X14, Y14
+
+	XORQ R8, R8
+
+loop4:
+	MOVQ CX, R9
+	SUBQ R8, R9
+	CMPQ R9, $4
+	JLT  tail
+	VMOVUPD (SI)(R8*8), Y0
+	VADDPD  (BX)(R8*8), Y0, Y0
+	VMULPD  Y15, Y0, Y0
+	VSUBPD  (DX)(R8*8), Y0, Y0
+	VANDPD  Y14, Y0, Y0
+	VMOVUPD Y0, (DI)(R8*8)
+	ADDQ    $4, R8
+	JMP     loop4
+
+tail:
+	CMPQ   R8, CX
+	JGE    done
+	VMOVSD (SI)(R8*8), X0
+	VADDSD (BX)(R8*8), X0, X0
+	VMULSD X15, X0, X0
+	VSUBSD (DX)(R8*8), X0, X0
+	VANDPD X14, X0, X0
+	VMOVSD X0, (DI)(R8*8)
+	INCQ   R8
+	JMP    tail
+
+done:
+	VZEROUPPER
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
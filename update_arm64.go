@@ -0,0 +1,19 @@
+//go:build arm64 && !purego
+// +build arm64,!purego
+
+package martini
+
+// Advanced SIMD is part of every arm64 core, so it needs no detection.
+func init() {
+	midErrors = midErrorsChecked
+}
+
+func midErrorsChecked(dst, a, m, b []float64) {
+	if len(a) < len(dst) || len(m) < len(dst) || len(b) < len(dst) {
+		panic("martini: midErrors operand too short")
+	}
+	midErrorsNEON(dst, a, m, b)
+}
+
+//go:noescape
+func midErrorsNEON(dst, a, m, b []float64)
@@ -0,0 +1,47 @@
+//go:build arm64 && !purego
+// +build arm64,!purego
+
+#include "textflag.h"
+
+// The assembler has no floating-point vector arithmetic, so FADD, FMUL,
+// FSUB and FABS on V0.2D are encoded by hand.
+
+// func midErrorsNEON(dst, a, m, b []float64)
+TEXT ·midErrorsNEON(SB), NOSPLIT, $0-96
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R1
+	MOVD a_base+24(FP), R2
+	MOVD m_base+48(FP), R3
+	MOVD b_base+72(FP), R4
+
+	// V31 = 0.5 in both lanes
+	MOVD $0x3FE0000000000000, R5
+	VDUP R5, V31.D2
+
+loop2:
+	CMP    $2, R1
+	BLT    tail
+	VLD1.P 16(R2), [V0.D2]
+	VLD1.P 16(R4), [V1.D2]
+	VLD1.P 16(R3), [V2.D2]
+	WORD   $0x4E61D400         // FADD V0.2D, V0.2D, V1.2D
+	WORD   $0x6E7FDC00         // FMUL V0.2D, V0.2D, V31.2D
+	WORD   $0x4EE2D400         // FSUB V0.2D, V0.2D, V2.2D
+	WORD   $0x4EE0F800         // FABS V0.2D, V0.2D
+	VST1.P [V0.D2], 16(R0)
+	SUB    $2, R1
+	B      loop2
+
+tail:
+	CBZ   R1, done
+	FMOVD (R2), F0
+	FMOVD (R4), F1
+	FMOVD (R3), F2
+	FADDD F1, F0, F0
+	FMULD F31, F0, F0
+	FSUBD F2, F0, F0
+	FABSD F0, F0
+	FMOVD F0, (R0)
+
+done:
+	RET
@@ -0,0 +1,74 @@
+package martini
+
+import (
+	"math"
+	"testing"
+)
+
+// referenceErrors is the original per-triangle error pass.
+func referenceErrors(terrain []float64, m *Martini) []float64 {
+	size := m.GridSize
+	errs := make([]float64, len(terrain))
+	for i := m.NumTriangles - 1; i >= 0; i-- {
+		ax, ay, bx, by := m.triangle(i)
+		mx := (ax + bx) >> 1
+		my := (ay + by) >> 1
+		cx := mx + my - ay
+		cy := my + ax - mx
+
+		interpolatedHeight := (terrain[int(ay)*size+int(ax)] + terrain[int(by)*size+int(bx)]) / 2
+		middleIndex := int(my)*size + int(mx)
+		errs[middleIndex] = math.Max(errs[middleIndex], math.Abs(interpolatedHeight-terrain[middleIndex]))
+
+		if i < m.NumParentTriangles {
+			leftChildIndex := (int(ay+cy)>>1)*size + (int(ax+cx) >> 1)
+			rightChildIndex := (int(by+cy)>>1)*size + (int(bx+cx) >> 1)
+			errs[middleIndex] = math.Max(math.Max(errs[middleIndex], errs[leftChildIndex]), errs[rightChildIndex])
+		}
+	}
+	return errs
+}
+
+func TestUpdateMatchesReference(t *testing.T) {
+	for _, size := range []int{3, 5, 17, 129} {
+		m, _ := NewMartini(size)
+		terrain := testTerrain(size, hills)
+		tile, _ := m.CreateTile(terrain)
+		want := referenceErrors(terrain, m)
+		for i := range want {
+			if tile.Errors[i] != want[i] {
+				t.Fatalf("size %d: error %d is %v, want %v", size, i, tile.Errors[i], want[i])
+			}
+		}
+	}
+}
+
+func TestMidErrorsKernel(t *testing.T) {
+	n := 37
+	a := make([]float64, n)
+	m := make([]float64, n)
+	b := make([]float64, n)
+	for i := 0; i < n; i++ {
+		a[i] = hills(i, 3)
+		m[i] = hills(i, 5) * 1.5
+		b[i] = -hills(7, i)
+	}
+	want := make([]float64, n)
+	got := make([]float64, n)
+	midErrorsGeneric(want, a, m, b)
+	midErrors(got, a, m, b)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("element %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	m, _ := NewMartini(1025)
+	tile, _ := m.CreateTile(testTerrain(1025, hills))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tile.Update()
+	}
+}
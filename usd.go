@@ -0,0 +1,171 @@
+package martini
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+)
+
+// vertexNormals returns unit normals per vertex, averaged from the
+// area-weighted normals of the adjacent triangles and facing up along axis
+// up.
+func vertexNormals(m *Mesh, up int) [][3]float64 {
+	normals := make([][3]float64, m.NumVertices())
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		var p [3][3]float64
+		for k := range p {
+			x, y, z := m.Vertex(int(m.Triangles[i+k]))
+			p[k] = [3]float64{x, y, z}
+		}
+		u := [3]float64{p[1][0] - p[0][0], p[1][1] - p[0][1], p[1][2] - p[0][2]}
+		v := [3]float64{p[2][0] - p[0][0], p[2][1] - p[0][1], p[2][2] - p[0][2]}
+		n := [3]float64{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+		if n[up] < 0 {
+			n = [3]float64{-n[0], -n[1], -n[2]}
+		}
+		for k := 0; k < 3; k++ {
+			s := &normals[m.Triangles[i+k]]
+			s[0], s[1], s[2] = s[0]+n[0], s[1]+n[1], s[2]+n[2]
+		}
+	}
+	for i, n := range normals {
+		normals[i] = normalize3(n)
+	}
+	return normals
+}
+
+// EncodeUSDA writes m as a USD ASCII layer holding one Mesh prim with
+// vertex normals and st texture coordinates spanning the mesh extent, with
+// v growing against grid y so that row 0 of an image maps to the top. The
+// stage is Z up in meters; the prim's orientation follows the winding of
+// the triangles seen from above.
+func EncodeUSDA(w io.Writer, m *Mesh) error {
+	bw := bufio.NewWriter(w)
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 32)
+	}
+	list := func(n int, item func(i int) string) {
+		bw.WriteByte('[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				bw.WriteString(", ")
+			}
+			bw.WriteString(item(i))
+		}
+		bw.WriteByte(']')
+	}
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	area := 0.0
+	for i := 0; i < m.NumVertices(); i++ {
+		x, y, _ := m.Vertex(i)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		ax, ay, _ := m.Vertex(int(m.Triangles[i]))
+		bx, by, _ := m.Vertex(int(m.Triangles[i+1]))
+		cx, cy, _ := m.Vertex(int(m.Triangles[i+2]))
+		area += (bx-ax)*(cy-ay) - (by-ay)*(cx-ax)
+	}
+	orientation := "rightHanded"
+	if area < 0 {
+		orientation = "leftHanded"
+	}
+	spanX, spanY := math.Max(maxX-minX, 1e-300), math.Max(maxY-minY, 1e-300)
+	normals := vertexNormals(m, 2)
+
+	fmt.Fprintf(bw, "#usda 1.0\n(\n    defaultPrim = \"Terrain\"\n    metersPerUnit = 1\n    upAxis = \"Z\"\n)\n\n")
+	fmt.Fprintf(bw, "def Mesh \"Terrain\"\n{\n    int[] faceVertexCounts = ")
+	list(m.NumTriangles(), func(int) string { return "3" })
+	fmt.Fprintf(bw, "\n    int[] faceVertexIndices = ")
+	list(len(m.Triangles), func(i int) string { return strconv.FormatUint(uint64(m.Triangles[i]), 10) })
+	fmt.Fprintf(bw, "\n    uniform token orientation = \"%s\"\n    point3f[] points = ", orientation)
+	list(m.NumVertices(), func(i int) string {
+		x, y, z := m.Vertex(i)
+		return "(" + f(x) + ", " + f(y) + ", " + f(z) + ")"
+	})
+	fmt.Fprintf(bw, "\n    normal3f[] normals = ")
+	list(len(normals), func(i int) string {
+		n := normals[i]
+		return "(" + f(n[0]) + ", " + f(n[1]) + ", " + f(n[2]) + ")"
+	})
+	fmt.Fprintf(bw, " (\n        interpolation = \"vertex\"\n    )\n    texCoord2f[] primvars:st = ")
+	list(m.NumVertices(), func(i int) string {
+		x, y, _ := m.Vertex(i)
+		return "(" + f((x-minX)/spanX) + ", " + f(1-(y-minY)/spanY) + ")"
+	})
+	fmt.Fprintf(bw, " (\n        interpolation = \"vertex\"\n    )\n    uniform token subdivisionScheme = \"none\"\n}\n")
+	return bw.Flush()
+}
+
+// EncodeUSDZ writes m as a USDZ package: an uncompressed zip archive
+// holding the EncodeUSDA layer, its data aligned to 64 bytes as the format
+// requires. The archive is written directly so that the local header
+// carries the CRC and sizes, with no data descriptor.
+func EncodeUSDZ(w io.Writer, m *Mesh) error {
+	var layer bytes.Buffer
+	if err := EncodeUSDA(&layer, m); err != nil {
+		return err
+	}
+	const name = "terrain.usda"
+	data := layer.Bytes()
+	crc := crc32.ChecksumIEEE(data)
+	// Pad with an unregistered extra field so that the data, which follows
+	// the 30-byte local header, the name and the extra field, is aligned.
+	pad := (64 - (30+len(name)+4)%64) % 64
+	extra := make([]byte, 4+pad)
+	binary.LittleEndian.PutUint16(extra, 0x1986)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(pad))
+
+	// Version 2.0, no flags, stored, dated 1980-01-01.
+	entry := func(b *bytes.Buffer) {
+		le := binary.LittleEndian
+		binary.Write(b, le, [5]uint16{20, 0, uint16(zip.Store), 0, 0x21})
+		binary.Write(b, le, [3]uint32{crc, uint32(len(data)), uint32(len(data))})
+	}
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, uint32(0x04034b50))
+	entry(&buf)
+	binary.Write(&buf, le, [2]uint16{uint16(len(name)), uint16(len(extra))})
+	buf.WriteString(name)
+	buf.Write(extra)
+	buf.Write(data)
+
+	dir := buf.Len()
+	binary.Write(&buf, le, uint32(0x02014b50))
+	binary.Write(&buf, le, uint16(20)) // made by
+	entry(&buf)
+	binary.Write(&buf, le, [5]uint16{uint16(len(name)), 0, 0, 0, 0})
+	binary.Write(&buf, le, [2]uint32{0, 0}) // attributes, local header offset
+	buf.WriteString(name)
+
+	end := buf.Len()
+	binary.Write(&buf, le, uint32(0x06054b50))
+	binary.Write(&buf, le, [4]uint16{0, 0, 1, 1})
+	binary.Write(&buf, le, [2]uint32{uint32(end - dir), uint32(dir)})
+	binary.Write(&buf, le, uint16(0))
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var FormatUSDA = &Format{
+	Name:        "usda",
+	Extension:   "usda",
+	ContentType: "model/vnd.usda",
+	Encode:      EncodeUSDA,
+}
+
+var FormatUSDZ = &Format{
+	Name:        "usdz",
+	Extension:   "usdz",
+	ContentType: "model/vnd.usdz+zip",
+	Encode:      EncodeUSDZ,
+}
@@ -0,0 +1,62 @@
+package martini
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncodeUSD(t *testing.T) {
+	m, _ := NewMartini(17)
+	tile, _ := m.CreateTile(testTerrain(17, hills))
+	mesh := tile.ToMesh(5)
+
+	var buf bytes.Buffer
+	if err := EncodeUSDA(&buf, mesh); err != nil {
+		t.Fatal(err)
+	}
+	usda := buf.String()
+	for _, want := range []string{"#usda 1.0", `upAxis = "Z"`, "point3f[] points = [(", "normal3f[] normals", "texCoord2f[] primvars:st", `orientation = "`} {
+		if !strings.Contains(usda, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if n := strings.Count(usda[strings.Index(usda, "faceVertexCounts"):strings.Index(usda, "faceVertexIndices")], "3"); n != mesh.NumTriangles() {
+		t.Errorf("got %d face counts, want %d", n, mesh.NumTriangles())
+	}
+	for i, n := range vertexNormals(mesh, 2) {
+		if l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2]); math.Abs(l-1) > 1e-9 || n[2] <= 0 {
+			t.Fatalf("normal %d = %v", i, n)
+		}
+	}
+
+	var usdz bytes.Buffer
+	if err := EncodeUSDZ(&usdz, mesh); err != nil {
+		t.Fatal(err)
+	}
+	data := usdz.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := zr.File[0]
+	if f.Name != "terrain.usda" || f.Method != zip.Store {
+		t.Errorf("unexpected entry %s method %d", f.Name, f.Method)
+	}
+	offset, _ := f.DataOffset()
+	if offset%64 != 0 {
+		t.Errorf("data offset %d is not 64-byte aligned", offset)
+	}
+	if binary.LittleEndian.Uint32(data) != 0x04034b50 || binary.LittleEndian.Uint32(data[14:]) != f.CRC32 {
+		t.Error("expected sizes and CRC in the local header")
+	}
+	rc, _ := f.Open()
+	got, _ := ioutil.ReadAll(rc)
+	if string(got) != usda {
+		t.Error("packaged layer differs from EncodeUSDA")
+	}
+}
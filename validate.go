@@ -0,0 +1,93 @@
+package martini
+
+import "sort"
+
+// ValidationReport lists the problems found by Validate. Triangle and vertex
+// problems are reported by index.
+type ValidationReport struct {
+	DegenerateTriangles  []int
+	DuplicateTriangles   []int
+	OutOfRangeTriangles  []int
+	UnreferencedVertices []int
+	NonManifoldEdges     [][2]uint32
+}
+
+func (r *ValidationReport) Valid() bool {
+	return len(r.DegenerateTriangles) == 0 &&
+		len(r.DuplicateTriangles) == 0 &&
+		len(r.OutOfRangeTriangles) == 0 &&
+		len(r.UnreferencedVertices) == 0 &&
+		len(r.NonManifoldEdges) == 0
+}
+
+// Validate checks a mesh for degenerate, duplicate and out-of-range
+// triangles, unreferenced vertices and edges shared by more than two
+// triangles.
+func Validate(m *Mesh) *ValidationReport {
+	r := &ValidationReport{}
+	n := uint32(m.NumVertices())
+	used := make([]bool, n)
+	triangles := make(map[[3]uint32]bool)
+	edges := make(map[[2]uint32]int)
+
+	for t := 0; t < m.NumTriangles(); t++ {
+		tri := [3]uint32{m.Triangles[3*t], m.Triangles[3*t+1], m.Triangles[3*t+2]}
+		if tri[0] >= n || tri[1] >= n || tri[2] >= n {
+			r.OutOfRangeTriangles = append(r.OutOfRangeTriangles, t)
+			continue
+		}
+		used[tri[0]], used[tri[1]], used[tri[2]] = true, true, true
+
+		if isDegenerate(m, tri) {
+			r.DegenerateTriangles = append(r.DegenerateTriangles, t)
+			continue
+		}
+
+		key := tri
+		sort.Slice(key[:], func(i, j int) bool { return key[i] < key[j] })
+		if triangles[key] {
+			r.DuplicateTriangles = append(r.DuplicateTriangles, t)
+			continue
+		}
+		triangles[key] = true
+
+		for k := 0; k < 3; k++ {
+			a, b := tri[k], tri[(k+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			edges[[2]uint32{a, b}]++
+		}
+	}
+
+	for i, u := range used {
+		if !u {
+			r.UnreferencedVertices = append(r.UnreferencedVertices, i)
+		}
+	}
+	for e, c := range edges {
+		if c > 2 {
+			r.NonManifoldEdges = append(r.NonManifoldEdges, e)
+		}
+	}
+	sort.Slice(r.NonManifoldEdges, func(i, j int) bool {
+		a, b := r.NonManifoldEdges[i], r.NonManifoldEdges[j]
+		return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+	})
+	return r
+}
+
+func isDegenerate(m *Mesh, tri [3]uint32) bool {
+	if tri[0] == tri[1] || tri[1] == tri[2] || tri[0] == tri[2] {
+		return true
+	}
+	ax, ay, az := m.Vertex(int(tri[0]))
+	bx, by, bz := m.Vertex(int(tri[1]))
+	cx, cy, cz := m.Vertex(int(tri[2]))
+	ux, uy, uz := bx-ax, by-ay, bz-az
+	vx, vy, vz := cx-ax, cy-ay, cz-az
+	nx := uy*vz - uz*vy
+	ny := uz*vx - ux*vz
+	nz := ux*vy - uy*vx
+	return nx == 0 && ny == 0 && nz == 0
+}
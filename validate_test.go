@@ -0,0 +1,49 @@
+package martini
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	martini, _ := NewMartini(17)
+	tile, _ := martini.CreateTile(testTerrain(17, hills))
+	if r := Validate(tile.ToMesh(5)); !r.Valid() {
+		t.Errorf("expected extracted mesh to be valid: %+v", r)
+	}
+
+	m := &Mesh{
+		Vertices: []float64{
+			0, 0, 0,
+			1, 0, 0,
+			0, 1, 0,
+			1, 1, 0,
+			2, 2, 0,
+			5, 5, 5,
+		},
+		Triangles: []uint32{
+			0, 1, 2,
+			2, 1, 0,
+			0, 3, 4,
+			1, 2, 9,
+			1, 2, 3,
+			1, 2, 5,
+		},
+	}
+	r := Validate(m)
+	if len(r.DuplicateTriangles) != 1 || r.DuplicateTriangles[0] != 1 {
+		t.Errorf("unexpected duplicates: %v", r.DuplicateTriangles)
+	}
+	if len(r.DegenerateTriangles) != 1 || r.DegenerateTriangles[0] != 2 {
+		t.Errorf("unexpected degenerates: %v", r.DegenerateTriangles)
+	}
+	if len(r.OutOfRangeTriangles) != 1 || r.OutOfRangeTriangles[0] != 3 {
+		t.Errorf("unexpected out of range: %v", r.OutOfRangeTriangles)
+	}
+	if len(r.NonManifoldEdges) != 1 || r.NonManifoldEdges[0] != [2]uint32{1, 2} {
+		t.Errorf("unexpected non-manifold edges: %v", r.NonManifoldEdges)
+	}
+	if len(r.UnreferencedVertices) != 0 {
+		t.Errorf("unexpected unreferenced vertices: %v", r.UnreferencedVertices)
+	}
+	if r.Valid() {
+		t.Error("expected report to be invalid")
+	}
+}
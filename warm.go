@@ -0,0 +1,46 @@
+package martini
+
+import "context"
+
+// Warm meshes tiles with the server's current settings and stores them in
+// its Cache and DiskCache, so that later requests for them are served
+// without meshing. Results arrive on the returned channel as with
+// ProcessTiles; failed tiles are reported there and do not stop the others.
+// Warm is not subject to MaxConcurrent: workers bounds its cost.
+func (s *Server) Warm(ctx context.Context, tiles []TileID, workers int) <-chan Result {
+	s.mu.RLock()
+	source, maxError, forZoom, cache := s.Source, s.MaxError, s.MaxErrorForZoom, s.Cache
+	s.mu.RUnlock()
+
+	// Tiles are meshed in groups sharing a maxError, in order of first
+	// appearance.
+	var order []float64
+	groups := make(map[float64][]TileID)
+	for _, id := range tiles {
+		e := maxError
+		if forZoom != nil {
+			e = forZoom(id.Z)
+		}
+		if _, ok := groups[e]; !ok {
+			order = append(order, e)
+		}
+		groups[e] = append(groups[e], id)
+	}
+
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		for _, e := range order {
+			opts := s.batchOptions(e, cache)
+			opts.Workers = workers
+			for r := range ProcessTiles(ctx, source, groups[e], opts) {
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,41 @@
+package martini
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerWarm(t *testing.T) {
+	dir := testDir(t)
+	s := newTestServer(t)
+	s.Cache = NewMeshCache(1 << 20)
+	var err error
+	if s.DiskCache, err = NewDiskCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	s.MaxErrorForZoom = func(z int) float64 { return float64(z) }
+
+	tiles := append(TilesInBounds(1, WorldBounds), TileID{0, 0, 0}, TileID{4, 0, 0})
+	failed := 0
+	for r := range s.Warm(context.Background(), tiles, 2) {
+		if r.Err != nil {
+			failed++
+			if r.ID != (TileID{4, 0, 0}) {
+				t.Errorf("%v: %v", r.ID, r.Err)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("got %d failures, want 1", failed)
+	}
+	for _, id := range tiles[:5] {
+		if _, ok := s.Cache.Get(CacheKey{ID: id, GridSize: 17, MaxError: float64(id.Z)}); !ok {
+			t.Errorf("%v not cached", id)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*.mesh"))
+	if len(files) != 5 {
+		t.Errorf("got %d meshes on disk, want 5", len(files))
+	}
+}
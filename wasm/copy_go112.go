@@ -0,0 +1,21 @@
+//go:build js && wasm && !go1.13
+// +build js,wasm,!go1.13
+
+package main
+
+import "syscall/js"
+
+// Go 1.12 has no bulk copies between Go and typed arrays; copy byte by
+// byte.
+
+func copyBytesToGo(dst []byte, src js.Value) {
+	for i := range dst {
+		dst[i] = byte(src.Index(i).Int())
+	}
+}
+
+func copyBytesToJS(dst js.Value, src []byte) {
+	for i, b := range src {
+		dst.SetIndex(i, b)
+	}
+}
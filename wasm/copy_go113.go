@@ -0,0 +1,14 @@
+//go:build js && wasm && go1.13
+// +build js,wasm,go1.13
+
+package main
+
+import "syscall/js"
+
+func copyBytesToGo(dst []byte, src js.Value) {
+	js.CopyBytesToGo(dst, src)
+}
+
+func copyBytesToJS(dst js.Value, src []byte) {
+	js.CopyBytesToJS(dst, src)
+}
@@ -0,0 +1,177 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm exposes the package to JavaScript:
+//
+//	GOOS=js GOARCH=wasm go build -o martini.wasm ./wasm
+//
+// After running it with wasm_exec.js, globalThis.Martini mirrors the JS
+// martini API:
+//
+//	const martini = new Martini(257);
+//	const tile = martini.createTile(terrain); // Float32Array or Float64Array
+//	const {vertices, triangles} = tile.getMesh(10); // Uint16Array, Uint32Array
+//
+// Tiles also offer toMesh(maxError), which returns x, y, z triples as a
+// Float64Array, and errors, a Float32Array of the per-sample errors. Both
+// mesh methods default maxError to 0. Call free() on martini and tile
+// objects that are no longer needed.
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"syscall/js"
+
+	"github.com/flywave/go-martini"
+)
+
+var uint8Array = js.Global().Get("Uint8Array")
+
+// bytesOf copies the contents of any typed array.
+func bytesOf(v js.Value) []byte {
+	view := uint8Array.New(v.Get("buffer"), v.Get("byteOffset"), v.Get("byteLength"))
+	b := make([]byte, view.Length())
+	copyBytesToGo(b, view)
+	return b
+}
+
+// typedArray copies b into a new typed array of the named kind.
+func typedArray(kind string, b []byte) js.Value {
+	view := uint8Array.New(len(b))
+	copyBytesToJS(view, b)
+	return js.Global().Get(kind).New(view.Get("buffer"))
+}
+
+func float64s(v js.Value) []float64 {
+	b := bytesOf(v)
+	if v.Get("BYTES_PER_ELEMENT").Int() == 4 {
+		out := make([]float64, len(b)/4)
+		for i := range out {
+			out[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:])))
+		}
+		return out
+	}
+	out := make([]float64, len(b)/8)
+	for i := range out {
+		out[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+	return out
+}
+
+// checked wraps a Go function so that a returned Error is thrown in
+// JavaScript; panicking would bring down the Go runtime instead.
+var checked = js.Global().Get("Function").New("f", `return function() {
+	const r = f.apply(this, arguments);
+	if (r instanceof Error) throw r;
+	return r;
+}`)
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
+
+// object builds a JS object whose methods are released by its free method.
+func object(methods map[string]func(args []js.Value) interface{}) js.Value {
+	obj := js.Global().Get("Object").New()
+	var funcs []js.Func
+	for name, fn := range methods {
+		fn := fn
+		f := js.FuncOf(func(this js.Value, args []js.Value) interface{} { return fn(args) })
+		funcs = append(funcs, f)
+		obj.Set(name, checked.Invoke(f))
+	}
+	var free js.Func
+	free = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		for _, f := range funcs {
+			f.Release()
+		}
+		free.Release()
+		return nil
+	})
+	obj.Set("free", free)
+	return obj
+}
+
+// maxErrorArg returns the maxError argument, 0 when omitted as in JS
+// martini.
+func maxErrorArg(args []js.Value) float64 {
+	if len(args) > 0 && !args[0].IsUndefined() {
+		return args[0].Float()
+	}
+	return 0
+}
+
+func newTile(tile *martini.Tile) js.Value {
+	obj := object(map[string]func([]js.Value) interface{}{
+		"getMesh": func(args []js.Value) interface{} {
+			mesh := tile.ToMesh(maxErrorArg(args))
+			vertices := make([]byte, 2*len(mesh.Vertices)/3*2)
+			for i := 0; i < mesh.NumVertices(); i++ {
+				binary.LittleEndian.PutUint16(vertices[4*i:], uint16(mesh.Vertices[3*i]))
+				binary.LittleEndian.PutUint16(vertices[4*i+2:], uint16(mesh.Vertices[3*i+1]))
+			}
+			return map[string]interface{}{
+				"vertices":  typedArray("Uint16Array", vertices),
+				"triangles": uint32s(mesh.Triangles),
+			}
+		},
+		"toMesh": func(args []js.Value) interface{} {
+			mesh := tile.ToMesh(maxErrorArg(args))
+			vertices := make([]byte, 8*len(mesh.Vertices))
+			for i, v := range mesh.Vertices {
+				binary.LittleEndian.PutUint64(vertices[8*i:], math.Float64bits(v))
+			}
+			return map[string]interface{}{
+				"vertices":  typedArray("Float64Array", vertices),
+				"triangles": uint32s(mesh.Triangles),
+			}
+		},
+	})
+	errors := make([]byte, 4*len(tile.Errors))
+	for i, e := range tile.Errors {
+		binary.LittleEndian.PutUint32(errors[4*i:], math.Float32bits(float32(e)))
+	}
+	obj.Set("errors", typedArray("Float32Array", errors))
+	return obj
+}
+
+func uint32s(values []uint32) js.Value {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	return typedArray("Uint32Array", b)
+}
+
+// register installs the Martini constructor on globalThis.
+func register() {
+	ctor := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		gridSize := 257
+		if len(args) > 0 && !args[0].IsUndefined() {
+			gridSize = args[0].Int()
+		}
+		m, err := martini.NewMartini(gridSize)
+		if err != nil {
+			return jsError(err)
+		}
+		return object(map[string]func([]js.Value) interface{}{
+			"createTile": func(args []js.Value) interface{} {
+				if len(args) == 0 || args[0].Get("buffer").IsUndefined() {
+					return js.Global().Get("TypeError").New("Expected a terrain typed array")
+				}
+				tile, err := m.CreateTile(float64s(args[0]))
+				if err != nil {
+					return jsError(err)
+				}
+				return newTile(tile)
+			},
+		})
+	})
+	js.Global().Set("Martini", checked.Invoke(ctor))
+}
+
+func main() {
+	register()
+	select {}
+}
@@ -0,0 +1,90 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/flywave/go-martini"
+)
+
+func TestMartiniJS(t *testing.T) {
+	register()
+	const size = 17
+	terrain := make([]float64, size*size)
+	for i := range terrain {
+		x, y := i%size, i/size
+		terrain[i] = float64((x - 8) * (y - 8))
+	}
+	m, err := martini.NewMartini(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, err := m.CreateTile(terrain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tile.ToMesh(1)
+
+	array := js.Global().Get("Float32Array").New(len(terrain))
+	for i, h := range terrain {
+		array.SetIndex(i, h)
+	}
+	jm := js.Global().Get("Martini").New(size)
+	defer jm.Call("free")
+	jt := jm.Call("createTile", array)
+	defer jt.Call("free")
+
+	got := jt.Call("toMesh", 1)
+	vertices, triangles := got.Get("vertices"), got.Get("triangles")
+	if vertices.Length() != len(want.Vertices) || triangles.Length() != len(want.Triangles) {
+		t.Fatalf("Expected %d vertices and %d indices, got %d and %d",
+			len(want.Vertices), len(want.Triangles), vertices.Length(), triangles.Length())
+	}
+	for i, v := range want.Vertices {
+		if g := vertices.Index(i).Float(); g != v {
+			t.Fatalf("Vertex component %d: expected %v, got %v", i, v, g)
+		}
+	}
+	for i, v := range want.Triangles {
+		if g := triangles.Index(i).Int(); g != int(v) {
+			t.Fatalf("Index %d: expected %d, got %d", i, v, g)
+		}
+	}
+
+	mesh := jt.Call("getMesh", 1)
+	if n := mesh.Get("vertices").Length(); n != 2*want.NumVertices() {
+		t.Errorf("Expected %d getMesh coordinates, got %d", 2*want.NumVertices(), n)
+	}
+	full := tile.ToMesh(0)
+	if n := jt.Call("toMesh").Get("vertices").Length(); n != len(full.Vertices) {
+		t.Errorf("Expected maxError 0 by default, got %d vertex components", n)
+	}
+	if n := jt.Get("errors").Length(); n != size*size {
+		t.Errorf("Expected %d errors, got %d", size*size, n)
+	}
+}
+
+func TestMartiniJSInvalidSize(t *testing.T) {
+	register()
+	defer func() {
+		if _, ok := recover().(js.Error); !ok {
+			t.Error("Expected a thrown Error")
+		}
+	}()
+	js.Global().Get("Martini").New(16)
+}
+
+func TestMartiniJSMissingTerrain(t *testing.T) {
+	register()
+	jm := js.Global().Get("Martini").New(17)
+	defer jm.Call("free")
+	defer func() {
+		if _, ok := recover().(js.Error); !ok {
+			t.Error("Expected a thrown Error")
+		}
+	}()
+	jm.Call("createTile")
+}
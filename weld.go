@@ -0,0 +1,78 @@
+package martini
+
+import "math"
+
+type weldCell struct {
+	x, y, z int64
+}
+
+// WeldVertices merges vertices that lie within epsilon of each other and
+// remaps the triangle indices accordingly. The first vertex of a cluster
+// keeps its position, attributes and colors. Triangles that collapse onto
+// fewer than three distinct vertices are dropped. An epsilon of zero welds
+// exact duplicates only.
+func WeldVertices(m *Mesh, epsilon float64) *Mesh {
+	cellSize := epsilon
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+	cellOf := func(x, y, z float64) weldCell {
+		return weldCell{
+			int64(math.Floor(x / cellSize)),
+			int64(math.Floor(y / cellSize)),
+			int64(math.Floor(z / cellSize)),
+		}
+	}
+
+	welded := &Mesh{}
+	for _, a := range m.Attributes {
+		welded.Attributes = append(welded.Attributes, Attribute{Name: a.Name})
+	}
+	grid := make(map[weldCell][]uint32)
+	remap := make([]uint32, m.NumVertices())
+	eps2 := epsilon * epsilon
+
+	for i := range remap {
+		x, y, z := m.Vertex(i)
+		c := cellOf(x, y, z)
+		found := -1
+	search:
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for dz := int64(-1); dz <= 1; dz++ {
+					for _, j := range grid[weldCell{c.x + dx, c.y + dy, c.z + dz}] {
+						wx, wy, wz := welded.Vertex(int(j))
+						ddx, ddy, ddz := wx-x, wy-y, wz-z
+						if ddx*ddx+ddy*ddy+ddz*ddz <= eps2 {
+							found = int(j)
+							break search
+						}
+					}
+				}
+			}
+		}
+		if found < 0 {
+			found = welded.NumVertices()
+			welded.Vertices = append(welded.Vertices, x, y, z)
+			for j, a := range m.Attributes {
+				welded.Attributes[j].Values = append(welded.Attributes[j].Values, a.Values[i])
+			}
+			if len(m.Colors) > 0 {
+				welded.Colors = append(welded.Colors, m.Colors[3*i:3*i+3]...)
+			}
+			grid[c] = append(grid[c], uint32(found))
+		}
+		remap[i] = uint32(found)
+	}
+
+	for i := 0; i+2 < len(m.Triangles); i += 3 {
+		a := remap[m.Triangles[i]]
+		b := remap[m.Triangles[i+1]]
+		c := remap[m.Triangles[i+2]]
+		if a == b || b == c || a == c {
+			continue
+		}
+		welded.Triangles = append(welded.Triangles, a, b, c)
+	}
+	return welded
+}
@@ -0,0 +1,41 @@
+package martini
+
+import "testing"
+
+func TestWeldVertices(t *testing.T) {
+	m := &Mesh{
+		Vertices: []float64{
+			0, 0, 0,
+			1, 0, 0,
+			0, 1, 0,
+			1.0001, 0, 0,
+			1, 1, 0,
+			0, 1.0001, 0.0001,
+		},
+		Triangles:  []uint32{0, 1, 2, 3, 4, 5, 1, 3, 4},
+		Attributes: []Attribute{{Name: "id", Values: []float64{0, 1, 2, 3, 4, 5}}},
+		Colors:     []uint8{0, 0, 0, 1, 1, 1, 2, 2, 2, 3, 3, 3, 4, 4, 4, 5, 5, 5},
+	}
+
+	exact := WeldVertices(m, 0)
+	if exact.NumVertices() != 6 || exact.NumTriangles() != 3 {
+		t.Errorf("exact weld changed mesh: %d vertices, %d triangles", exact.NumVertices(), exact.NumTriangles())
+	}
+
+	w := WeldVertices(m, 0.001)
+	if w.NumVertices() != 4 {
+		t.Errorf("expected 4 vertices, got %d", w.NumVertices())
+	}
+	if w.NumTriangles() != 2 {
+		t.Errorf("expected degenerate triangle to be dropped, got %d triangles", w.NumTriangles())
+	}
+	if w.Triangles[3] != 1 || w.Triangles[5] != 2 {
+		t.Errorf("unexpected remap: %v", w.Triangles)
+	}
+	if ids := w.Attributes[0].Values; len(ids) != 4 || ids[1] != 1 || ids[3] != 4 {
+		t.Errorf("unexpected attributes: %v", ids)
+	}
+	if len(w.Colors) != 12 || w.Colors[3] != 1 || w.Colors[9] != 4 {
+		t.Errorf("unexpected colors: %v", w.Colors)
+	}
+}
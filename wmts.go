@@ -0,0 +1,192 @@
+package martini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// WMTSLayer describes the meshes of a Server in a WMTS GetCapabilities
+// document.
+type WMTSLayer struct {
+	Identifier, Title, Abstract string
+	MinZoom, MaxZoom            int
+	// Bounds is the area with data. The zero value is the whole scheme.
+	Bounds Bounds
+	// URL is the public base URL of the server. When empty, Server derives
+	// it from the request.
+	URL string
+}
+
+// WriteWMTSCapabilities writes a WMTS 1.0 capabilities document with one
+// layer offered in each of formats, tiled by scheme (nil means SchemeXYZ).
+// Mesh tiles have no pixels; tiles are advertised as gridSize-1 pixels
+// wide, which is what the scale denominators are computed from.
+func WriteWMTSCapabilities(w io.Writer, layer WMTSLayer, scheme *TilingScheme, formats map[string]*Format, gridSize int) error {
+	if scheme == nil {
+		scheme = SchemeXYZ
+	}
+	if scheme.BottomUp {
+		return errors.New("WMTS requires rows numbered from the north")
+	}
+	if layer.MaxZoom < layer.MinZoom {
+		return errors.New("Expected MaxZoom to be at least MinZoom")
+	}
+	if gridSize < 2 {
+		return errors.New("Expected gridSize of at least 2")
+	}
+	b := layer.Bounds
+	if b == (Bounds{}) && scheme.ToLngLat != nil {
+		b = Bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+		w, h := scheme.Matrix(0)
+		for x := 0; x < w; x++ {
+			for y := 0; y < h; y++ {
+				t, _ := scheme.Bounds(TileID{0, x, y})
+				b = Bounds{math.Min(b.West, t.West), math.Min(b.South, t.South), math.Max(b.East, t.East), math.Max(b.North, t.North)}
+			}
+		}
+	}
+	exts := make([]string, 0, len(formats))
+	for ext := range formats {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	base := strings.TrimSuffix(layer.URL, "/")
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>
+<Capabilities xmlns="http://www.opengis.net/wmts/1.0" xmlns:ows="http://www.opengis.net/ows/1.1" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.0.0">
+  <ows:ServiceIdentification>
+    <ows:Title>%s</ows:Title>
+    <ows:ServiceType>OGC WMTS</ows:ServiceType>
+    <ows:ServiceTypeVersion>1.0.0</ows:ServiceTypeVersion>
+  </ows:ServiceIdentification>
+  <Contents>
+    <Layer>
+      <ows:Title>%s</ows:Title>
+`, escapeXML(layer.Title), escapeXML(layer.Title))
+	if layer.Abstract != "" {
+		fmt.Fprintf(bw, "      <ows:Abstract>%s</ows:Abstract>\n", escapeXML(layer.Abstract))
+	}
+	if b != (Bounds{}) {
+		fmt.Fprintf(bw, `      <ows:WGS84BoundingBox>
+        <ows:LowerCorner>%v %v</ows:LowerCorner>
+        <ows:UpperCorner>%v %v</ows:UpperCorner>
+      </ows:WGS84BoundingBox>
+`, b.West, b.South, b.East, b.North)
+	}
+	fmt.Fprintf(bw, `      <ows:Identifier>%s</ows:Identifier>
+      <Style isDefault="true"><ows:Identifier>default</ows:Identifier></Style>
+`, escapeXML(layer.Identifier))
+	for _, ext := range exts {
+		fmt.Fprintf(bw, "      <Format>%s</Format>\n", escapeXML(formats[ext].ContentType))
+	}
+	fmt.Fprintf(bw, "      <TileMatrixSetLink>\n        <TileMatrixSet>%s</TileMatrixSet>\n        <TileMatrixSetLimits>\n", escapeXML(scheme.Name))
+	for z := layer.MinZoom; z <= layer.MaxZoom; z++ {
+		tiles := scheme.TilesInExtent(z, scheme.Extent)
+		if b != (Bounds{}) {
+			tiles = scheme.TilesInBounds(z, b)
+		}
+		minCol, minRow, maxCol, maxRow := math.MaxInt32, math.MaxInt32, 0, 0
+		for _, id := range tiles {
+			minCol, maxCol = minInt(minCol, id.X), maxInt(maxCol, id.X)
+			minRow, maxRow = minInt(minRow, id.Y), maxInt(maxRow, id.Y)
+		}
+		fmt.Fprintf(bw, "          <TileMatrixLimits><TileMatrix>%d</TileMatrix><MinTileRow>%d</MinTileRow><MaxTileRow>%d</MaxTileRow><MinTileCol>%d</MinTileCol><MaxTileCol>%d</MaxTileCol></TileMatrixLimits>\n",
+			z, minRow, maxRow, minCol, maxCol)
+	}
+	fmt.Fprintf(bw, "        </TileMatrixSetLimits>\n      </TileMatrixSetLink>\n")
+	for _, ext := range exts {
+		fmt.Fprintf(bw, "      <ResourceURL format=\"%s\" resourceType=\"tile\" template=\"%s/{TileMatrix}/{TileCol}/{TileRow}.%s\"/>\n",
+			escapeXML(formats[ext].ContentType), escapeXML(base), escapeXML(ext))
+	}
+	fmt.Fprintf(bw, "    </Layer>\n")
+
+	// Degrees are converted to meters on the equator, as OGC does.
+	metersPerUnit := 1.0
+	crs := scheme.CRS
+	if crs == "EPSG:4326" {
+		metersPerUnit = 2 * math.Pi * 6378137 / 360
+		crs = "OGC:CRS84"
+	}
+	pixels := gridSize - 1
+	fmt.Fprintf(bw, "    <TileMatrixSet>\n      <ows:Identifier>%s</ows:Identifier>\n      <ows:SupportedCRS>%s</ows:SupportedCRS>\n", escapeXML(scheme.Name), escapeXML(crsURN(crs)))
+	for z := layer.MinZoom; z <= layer.MaxZoom; z++ {
+		tw, _ := scheme.tileSize(z)
+		mw, mh := scheme.Matrix(z)
+		scale := tw / float64(pixels) * metersPerUnit / 0.00028
+		fmt.Fprintf(bw, `      <TileMatrix>
+        <ows:Identifier>%d</ows:Identifier>
+        <ScaleDenominator>%v</ScaleDenominator>
+        <TopLeftCorner>%v %v</TopLeftCorner>
+        <TileWidth>%d</TileWidth>
+        <TileHeight>%d</TileHeight>
+        <MatrixWidth>%d</MatrixWidth>
+        <MatrixHeight>%d</MatrixHeight>
+      </TileMatrix>
+`, z, scale, scheme.Extent[0], scheme.Extent[3], pixels, pixels, mw, mh)
+	}
+	fmt.Fprintf(bw, "    </TileMatrixSet>\n  </Contents>\n</Capabilities>\n")
+	return bw.Flush()
+}
+
+// crsURN turns "EPSG:3857" into "urn:ogc:def:crs:EPSG::3857".
+func crsURN(crs string) string {
+	parts := strings.SplitN(crs, ":", 2)
+	if len(parts) != 2 {
+		return crs
+	}
+	if parts[0] == "OGC" {
+		return "urn:ogc:def:crs:OGC:1.3:" + parts[1]
+	}
+	return "urn:ogc:def:crs:" + parts[0] + "::" + parts[1]
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// isCapabilitiesRequest reports whether r asks for the WMTS capabilities,
+// RESTfully or as a KVP GetCapabilities request.
+func isCapabilitiesRequest(r *http.Request) bool {
+	if r.URL.Path == "/WMTSCapabilities.xml" || r.URL.Path == "/1.0.0/WMTSCapabilities.xml" {
+		return true
+	}
+	q := r.URL.Query()
+	get := func(key string) string {
+		for k, v := range q {
+			if strings.EqualFold(k, key) && len(v) > 0 {
+				return v[0]
+			}
+		}
+		return ""
+	}
+	return strings.EqualFold(get("service"), "WMTS") && strings.EqualFold(get("request"), "GetCapabilities")
+}
+
+func (s *Server) serveCapabilities(w http.ResponseWriter, r *http.Request) {
+	layer := *s.WMTS
+	if layer.URL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		layer.URL = scheme + "://" + r.Host
+	}
+	var buf bytes.Buffer
+	if err := WriteWMTSCapabilities(&buf, layer, s.Scheme, s.Formats, s.GridSize); err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,71 @@
+package martini
+
+import (
+	"encoding/xml"
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWMTSCapabilities(t *testing.T) {
+	s, _ := NewServer(testSource(), 257, 5)
+	s.Formats[FormatGLB.Extension] = FormatGLB
+	s.WMTS = &WMTSLayer{Identifier: "terrain", Title: "Terrain & bathymetry", MinZoom: 0, MaxZoom: 3,
+		Bounds: Bounds{West: 5, South: 45, East: 10, North: 48}}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "http://tiles.example.com/?SERVICE=WMTS&REQUEST=GetCapabilities", nil))
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "application/xml" {
+		t.Fatalf("unexpected response %d %v", rec.Code, rec.Header())
+	}
+	var doc struct {
+		Layer struct {
+			Title   string   `xml:"Title"`
+			Formats []string `xml:"Format"`
+			Limits  []struct {
+				Matrix string `xml:"TileMatrix"`
+				MinRow int    `xml:"MinTileRow"`
+				MaxRow int    `xml:"MaxTileRow"`
+				MinCol int    `xml:"MinTileCol"`
+				MaxCol int    `xml:"MaxTileCol"`
+			} `xml:"TileMatrixSetLink>TileMatrixSetLimits>TileMatrixLimits"`
+			Resources []struct {
+				Template string `xml:"template,attr"`
+			} `xml:"ResourceURL"`
+		} `xml:"Contents>Layer"`
+		Matrices []struct {
+			Identifier string  `xml:"Identifier"`
+			Scale      float64 `xml:"ScaleDenominator"`
+			Width      int     `xml:"MatrixWidth"`
+		} `xml:"Contents>TileMatrixSet>TileMatrix"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Layer.Title != "Terrain & bathymetry" || len(doc.Layer.Formats) != 2 {
+		t.Errorf("unexpected layer %+v", doc.Layer)
+	}
+	if got := doc.Layer.Resources[0].Template; got != "http://tiles.example.com/{TileMatrix}/{TileCol}/{TileRow}.glb" {
+		t.Errorf("unexpected template %s", got)
+	}
+	// Zoom 3 tiles are 45 degrees wide: the bounds fall in column 4, row 2.
+	if l := doc.Layer.Limits[3]; l.MinCol != 4 || l.MaxCol != 4 || l.MinRow != 2 || l.MaxRow != 2 {
+		t.Errorf("unexpected limits %+v", l)
+	}
+	// The well-known GoogleMapsCompatible scale of zoom 0.
+	if len(doc.Matrices) != 4 || math.Abs(doc.Matrices[0].Scale-559082264.0287178) > 1e-3 || doc.Matrices[3].Width != 8 {
+		t.Errorf("unexpected tile matrices %+v", doc.Matrices)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/WMTSCapabilities.xml", nil))
+	if rec.Code != 200 {
+		t.Errorf("expected RESTful capabilities, got %d", rec.Code)
+	}
+	s.Scheme = SchemeTMS
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/WMTSCapabilities.xml", nil))
+	if rec.Code != 500 {
+		t.Errorf("expected TMS rows to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,119 @@
+package martini
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// XYZGrid is a regular raster recovered from an XYZ point file. Row 0 is
+// the northernmost, at the largest y; cells without a point are NaN.
+type XYZGrid struct {
+	Width, Height int
+	// MinX and MaxY locate the first sample, StepX and StepY the spacing.
+	MinX, MaxY   float64
+	StepX, StepY float64
+	Values       []float64
+}
+
+// ReadXYZ reads a file of x, y, z elevation points, one per line,
+// separated by whitespace, commas or semicolons, and infers the regular
+// grid they were sampled on. Points may come in any order and some may be
+// missing. Lines that do not start with three numbers, such as headers or
+// comments, are skipped.
+func ReadXYZ(r io.Reader) (*XYZGrid, error) {
+	var points [][3]float64
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.FieldsFunc(sc.Text(), func(c rune) bool {
+			return c == ' ' || c == '\t' || c == ',' || c == ';'
+		})
+		if len(fields) < 3 {
+			continue
+		}
+		var p [3]float64
+		ok := true
+		for i := range p {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				ok = false
+				break
+			}
+			p[i] = v
+		}
+		if ok {
+			points = append(points, p)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, errors.New("Expected x, y, z points")
+	}
+
+	xs, stepX := gridAxis(points, 0)
+	ys, stepY := gridAxis(points, 1)
+	g := &XYZGrid{MinX: xs[0], MaxY: ys[len(ys)-1], StepX: stepX, StepY: stepY, Width: 1, Height: 1}
+	if stepX > 0 {
+		g.Width = int(math.Round((xs[len(xs)-1]-xs[0])/stepX)) + 1
+	}
+	if stepY > 0 {
+		g.Height = int(math.Round((ys[len(ys)-1]-ys[0])/stepY)) + 1
+	}
+	// Scattered points would produce a vast, mostly empty grid.
+	if g.Width*g.Height > 4*len(points)+1024 {
+		return nil, errors.New("Expected points on a regular grid")
+	}
+	g.Values = make([]float64, g.Width*g.Height)
+	for i := range g.Values {
+		g.Values[i] = math.NaN()
+	}
+	for _, p := range points {
+		x, y := 0, 0
+		if stepX > 0 {
+			x = int(math.Round((p[0] - g.MinX) / stepX))
+		}
+		if stepY > 0 {
+			y = int(math.Round((g.MaxY - p[1]) / stepY))
+		}
+		g.Values[y*g.Width+x] = p[2]
+	}
+	return g, nil
+}
+
+// gridAxis returns the sorted distinct coordinates of axis c and their
+// spacing, the smallest gap between them. Coordinates closer than a
+// millionth of the extent are considered equal.
+func gridAxis(points [][3]float64, c int) ([]float64, float64) {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p[c]
+	}
+	sort.Float64s(values)
+	eps := (values[len(values)-1] - values[0]) * 1e-6
+	distinct := values[:1]
+	for _, v := range values[1:] {
+		if v-distinct[len(distinct)-1] > eps {
+			distinct = append(distinct, v)
+		}
+	}
+	step := 0.0
+	for i := 1; i < len(distinct); i++ {
+		if d := distinct[i] - distinct[i-1]; step == 0 || d < step {
+			step = d
+		}
+	}
+	return distinct, step
+}
+
+// Terrain resamples the grid onto a gridSize*gridSize terrain grid with
+// corners aligned. Missing cells leave NaN in the samples around them,
+// which FillHoles can then repair.
+func (g *XYZGrid) Terrain(gridSize int, k Kernel) ([]float64, error) {
+	return Resample(g.Values, g.Width, g.Height, gridSize, k)
+}
@@ -0,0 +1,54 @@
+package martini
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadXYZ(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# x y z\nX,Y,Z\n")
+	// A 5 by 3 grid with 10 m spacing, listed south to north, one point
+	// missing and separators mixed.
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 5; x++ {
+			if x == 2 && y == 1 {
+				continue
+			}
+			sep := " "
+			if x%2 == 1 {
+				sep = ", "
+			}
+			fmt.Fprintf(&b, "%v%s%v%s%v\n", 500000+10*x, sep, 4000000+10*y, sep, float64(x*y)+0.5)
+		}
+	}
+	g, err := ReadXYZ(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Width != 5 || g.Height != 3 || g.StepX != 10 || g.StepY != 10 || g.MinX != 500000 || g.MaxY != 4000020 {
+		t.Fatalf("unexpected grid %+v", g)
+	}
+	// Row 0 is the northernmost, y = 2.
+	if g.Values[0*5+4] != 8.5 || g.Values[2*5+4] != 0.5 || !math.IsNaN(g.Values[1*5+2]) {
+		t.Errorf("unexpected values %v", g.Values)
+	}
+
+	g.Values[1*5+2] = 2
+	terrain, err := g.Terrain(5, KernelBilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terrain[4] != 8.5 || terrain[24] != 0.5 {
+		t.Errorf("expected corners to be preserved, got %v", terrain)
+	}
+
+	if _, err := ReadXYZ(strings.NewReader("0 0 1\n1000 0.5 2\n0.001 1000 3\n")); err == nil {
+		t.Error("expected error for scattered points")
+	}
+	if _, err := ReadXYZ(strings.NewReader("no points here\n")); err == nil {
+		t.Error("expected error for an empty file")
+	}
+}